@@ -18,8 +18,10 @@ import (
 	"github.com/faciam-dev/gcfm/internal/logger"
 	"github.com/faciam-dev/gcfm/internal/monitordb"
 	"github.com/faciam-dev/gcfm/internal/server"
+	"github.com/faciam-dev/gcfm/pkg/audit"
 	"github.com/faciam-dev/gcfm/pkg/crypto"
 	md "github.com/faciam-dev/gcfm/pkg/metadata"
+	"github.com/faciam-dev/gcfm/pkg/snapshot"
 	"github.com/faciam-dev/gcfm/pkg/util"
 	"github.com/go-co-op/gocron"
 )
@@ -84,7 +86,8 @@ func main() {
 	dbCfg := server.DBConfig{Driver: *driver, DSN: *dsn, TablePrefix: cfg.TablePrefix}
 	log.Printf("table prefix: %q", dbCfg.TablePrefix)
 
-	api := server.New(db, dbCfg)
+	api, cleanup := server.NewWithCleanup(db, dbCfg)
+	defer cleanup()
 
 	if db != nil {
 		repo := &monitordb.Repo{DB: db, Driver: dbCfg.Driver, Dialect: dialect, TablePrefix: dbCfg.TablePrefix}
@@ -102,6 +105,31 @@ func main() {
 		}); err != nil {
 			logger.L.Error("schedule db scan", "err", err)
 		}
+		if _, err := s.Every(util.GetEnv("SNAPSHOT_PRUNE_INTERVAL", "1h")).Do(func() {
+			results, err := snapshot.PruneAll(context.Background(), db, dialect, dbCfg.TablePrefix, time.Now())
+			if err != nil {
+				logger.L.Error("prune snapshots", "err", err)
+			}
+			for _, r := range results {
+				if len(r.Deleted) > 0 {
+					logger.L.Info("pruned snapshots", "tenant", r.Tenant, "deleted", len(r.Deleted), "bytes", r.Bytes)
+				}
+			}
+		}); err != nil {
+			logger.L.Error("schedule snapshot prune", "err", err)
+		}
+		if dest := auditAnchorDest(context.Background()); dest != nil {
+			if _, err := s.Every(util.GetEnv("AUDIT_ANCHOR_INTERVAL", "24h")).Do(func() {
+				name, err := audit.ExportAnchors(context.Background(), db, dialect, dbCfg.TablePrefix, dest, time.Now())
+				if err != nil {
+					logger.L.Error("export audit anchors", "err", err)
+					return
+				}
+				logger.L.Info("exported audit anchors", "name", name)
+			}); err != nil {
+				logger.L.Error("schedule audit anchors", "err", err)
+			}
+		}
 		s.StartAsync()
 	}
 
@@ -132,3 +160,21 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// auditAnchorDest returns the destination for audit chain anchors configured
+// through AUDIT_ANCHOR_DIR or AUDIT_ANCHOR_S3_BUCKET, or nil when anchoring is
+// disabled.
+func auditAnchorDest(ctx context.Context) audit.AnchorDest {
+	if dir := os.Getenv("AUDIT_ANCHOR_DIR"); dir != "" {
+		return snapshot.LocalDir{Path: dir}
+	}
+	if bucket := os.Getenv("AUDIT_ANCHOR_S3_BUCKET"); bucket != "" {
+		dest, err := snapshot.NewS3(ctx, bucket, os.Getenv("AUDIT_ANCHOR_S3_PREFIX"))
+		if err != nil {
+			logger.L.Error("audit anchor destination", "err", err)
+			return nil
+		}
+		return dest
+	}
+	return nil
+}
@@ -19,10 +19,18 @@ func newApplyCmd() *cobra.Command {
 		dbDSN      string
 		schema     string
 		driverFlag string
+		across     acrossFlags
 	)
 	cmd := &cobra.Command{
 		Use:   "apply",
 		Short: "Apply registry YAML to database",
+		Long: `Apply registry YAML to the database given by --db, or with --targets to
+every target matching a label query. Targets come from the Admin API or
+--targets-file. Canaries are applied first, one at a time; the other targets
+follow --concurrency at a time until more than --max-failure-ratio of them
+failed.`,
+		Example: `  fieldctl apply --db "$DSN" --schema public --file registry.yaml
+  fieldctl apply --targets 'region=eu,tier!=free' --canary 1 --canary-label canary --max-failure-ratio 0.1`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if file == "" {
 				return errors.New("--file is required")
@@ -32,6 +40,12 @@ func newApplyCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
+			if across.query != "" {
+				return applyAcross(cmd, across, data, dryRun)
+			}
+			if dbDSN == "" || schema == "" {
+				return errors.New("--db and --schema are required unless --targets is set")
+			}
 			ctx := context.Background()
 			svc := sdk.New(sdk.ServiceConfig{})
 			rep, err := svc.Apply(ctx, sdk.DBConfig{Driver: driverFlag, DSN: dbDSN, Schema: schema}, data, sdk.ApplyOptions{DryRun: dryRun})
@@ -47,7 +61,6 @@ func newApplyCmd() *cobra.Command {
 	cmd.Flags().StringVar(&file, "file", "registry.yaml", "input file")
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "show diff without applying")
 	cmd.Flags().StringVar(&driverFlag, "driver", "", "database driver (mysql|postgres|mongo)")
-	mustFlag(cmd, "db")
-	mustFlag(cmd, "schema")
+	across.addFlags(cmd)
 	return cmd
 }
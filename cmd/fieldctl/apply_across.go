@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/faciam-dev/gcfm/sdk"
+)
+
+// acrossFlags holds the flags of apply --targets.
+type acrossFlags struct {
+	query       string
+	file        string
+	concurrency int
+	canaries    int
+	canaryLabel string
+	maxFailure  float64
+}
+
+func (f *acrossFlags) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.query, "targets", "", "apply to every target matching this label query, e.g. 'region=eu,tier!=free'")
+	cmd.Flags().StringVar(&f.file, "targets-file", "", "read targets from this JSON file instead of the Admin API")
+	cmd.Flags().IntVar(&f.concurrency, "concurrency", 4, "number of targets applied at once")
+	cmd.Flags().IntVar(&f.canaries, "canary", 0, "number of targets applied one at a time before the others")
+	cmd.Flags().StringVar(&f.canaryLabel, "canary-label", "", "label of the targets to pick as canaries first")
+	cmd.Flags().Float64Var(&f.maxFailure, "max-failure-ratio", 0, "abort once more than this fraction of the targets failed")
+}
+
+// applyAcross applies data to the targets selected by f and prints the
+// per-target report.
+func applyAcross(cmd *cobra.Command, f acrossFlags, data []byte, dryRun bool) error {
+	q, err := sdk.ParseQuery(f.query)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	cfgs, err := loadApplyTargets(ctx, f.file)
+	if err != nil {
+		return err
+	}
+	// Connections are opened lazily so that unreachable targets are
+	// reported as failed instead of being left out.
+	for i, c := range cfgs {
+		db, err := sql.Open(c.Driver, c.DSN)
+		if err != nil {
+			return fmt.Errorf("target %s: %w", c.Key, err)
+		}
+		defer db.Close()
+		cfgs[i].DB = db
+	}
+	svc := sdk.New(sdk.ServiceConfig{Targets: cfgs})
+	rep, err := svc.ApplyAcross(ctx, q, data, sdk.AcrossOptions{
+		ApplyOptions:    sdk.ApplyOptions{DryRun: dryRun},
+		Concurrency:     f.concurrency,
+		Canaries:        f.canaries,
+		CanaryLabel:     f.canaryLabel,
+		MaxFailureRatio: f.maxFailure,
+	})
+	if rep.Results != nil {
+		if perr := printAcrossReport(cmd, rep); perr != nil {
+			return perr
+		}
+	}
+	return err
+}
+
+// loadApplyTargets reads the target definitions from path, or from the Admin
+// API when path is empty.
+func loadApplyTargets(ctx context.Context, path string) ([]sdk.TargetConfig, error) {
+	if path != "" {
+		m, _, _, err := sdk.NewFileProvider(path).Fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]sdk.TargetConfig, 0, len(m))
+		for k, c := range m {
+			c.Key = k
+			out = append(out, c)
+		}
+		return out, nil
+	}
+	var out []sdk.TargetConfig
+	cursor := ""
+	for {
+		path := "/admin/targets?limit=200"
+		if cursor != "" {
+			path += "&cursor=" + url.QueryEscape(cursor)
+		}
+		resp, err := apiRequest(http.MethodGet, path, nil, "")
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Items      []Target `json:"items"`
+			NextCursor string   `json:"nextCursor"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("error: %s", resp.Status)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range page.Items {
+			out = append(out, sdk.TargetConfig{Key: t.Key, Driver: t.Driver, DSN: t.Dsn, Schema: t.Schema, Labels: t.Labels})
+		}
+		if page.NextCursor == "" {
+			return out, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+type acrossResult struct {
+	Key        string  `json:"key"`
+	Canary     bool    `json:"canary,omitempty"`
+	Status     string  `json:"status"`
+	Added      int     `json:"added"`
+	Deleted    int     `json:"deleted"`
+	Updated    int     `json:"updated"`
+	DurationMs int64   `json:"durationMs"`
+	Error      *string `json:"error,omitempty"`
+}
+
+func printAcrossReport(cmd *cobra.Command, rep sdk.AcrossReport) error {
+	rows := make([]acrossResult, len(rep.Results))
+	for i, r := range rep.Results {
+		rows[i] = acrossResult{
+			Key:        r.Key,
+			Canary:     r.Canary,
+			Status:     r.Status,
+			Added:      r.Report.Added,
+			Deleted:    r.Report.Deleted,
+			Updated:    r.Report.Updated,
+			DurationMs: r.Duration.Milliseconds(),
+		}
+		if r.Err != nil {
+			msg := r.Err.Error()
+			rows[i].Error = &msg
+		}
+	}
+	format, _ := cmd.Flags().GetString("output")
+	return writeAcrossReport(cmd.OutOrStdout(), format, rep, rows)
+}
+
+func writeAcrossReport(w io.Writer, format string, rep sdk.AcrossReport, rows []acrossResult) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	}
+	tw := tablewriter.NewWriter(w)
+	tw.SetHeader([]string{"Target", "Status", "Changes", "Duration", "Error"})
+	for _, r := range rows {
+		key := r.Key
+		if r.Canary {
+			key += " (canary)"
+		}
+		errMsg := ""
+		if r.Error != nil {
+			errMsg = *r.Error
+		}
+		changes := ""
+		if r.Status == sdk.ApplyStatusApplied {
+			changes = fmt.Sprintf("+%d/-%d/±%d", r.Added, r.Deleted, r.Updated)
+		}
+		tw.Append([]string{key, r.Status, changes, (time.Duration(r.DurationMs) * time.Millisecond).String(), errMsg})
+	}
+	tw.Render()
+	_, err := fmt.Fprintf(w, "%d applied, %d failed, %d skipped\n", rep.Applied, rep.Failed, rep.Skipped)
+	return err
+}
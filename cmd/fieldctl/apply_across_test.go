@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/faciam-dev/gcfm/sdk"
+)
+
+func TestLoadApplyTargetsPagesAdminAPI(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			_, _ = w.Write([]byte(`{"items":[{"key":"a","driver":"mysql","dsn":"mysql://a/db","labels":["region=eu"]}],"nextCursor":"a"}`))
+		case "a":
+			_, _ = w.Write([]byte(`{"items":[{"key":"b","driver":"postgres","dsn":"postgres://b/db","schema":"public"}]}`))
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("FIELDTOOL_API_URL", srv.URL)
+	t.Setenv("FIELDTOOL_TOKEN", "tok")
+
+	got, err := loadApplyTargets(context.Background(), "")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(got) != 2 || got[0].Key != "a" || got[0].Labels[0] != "region=eu" || got[1].Schema != "public" || got[1].DSN != "postgres://b/db" {
+		t.Fatalf("targets = %+v", got)
+	}
+}
+
+func TestWriteAcrossReport(t *testing.T) {
+	rep := sdk.AcrossReport{
+		Results: []sdk.TargetApplyResult{
+			{Key: "a", Canary: true, Status: sdk.ApplyStatusApplied, Report: sdk.DiffReport{Added: 2}},
+			{Key: "b", Status: sdk.ApplyStatusFailed, Err: errors.New("boom")},
+			{Key: "c", Status: sdk.ApplyStatusSkipped},
+		},
+		Applied: 1, Failed: 1, Skipped: 1,
+	}
+	rows := []acrossResult{
+		{Key: "a", Canary: true, Status: sdk.ApplyStatusApplied, Added: 2},
+		{Key: "b", Status: sdk.ApplyStatusFailed, Error: strptr("boom")},
+		{Key: "c", Status: sdk.ApplyStatusSkipped},
+	}
+	var buf bytes.Buffer
+	if err := writeAcrossReport(&buf, "table", rep, rows); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"a (canary)", "+2/-0/±0", "boom", "skipped", "1 applied, 1 failed, 1 skipped"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in\n%s", want, out)
+		}
+	}
+}
+
+func strptr(s string) *string { return &s }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/faciam-dev/gcfm/pkg/audit"
+	"github.com/faciam-dev/gcfm/pkg/util"
+)
+
+func newAuditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Audit log operations",
+	}
+	cmd.AddCommand(newAuditVerifyCmd(), newAuditExportCmd())
+	return cmd
+}
+
+func newAuditVerifyCmd() *cobra.Command {
+	var (
+		dbDSN       string
+		driverFlag  string
+		tenant      string
+		tablePrefix string
+		all         bool
+		anchorPath  string
+	)
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify the audit log hash chain",
+		Long: `Walk the audit log hash chain of a tenant and report the first row whose
+content or link to the previous row does not match. With --anchor the heads
+recorded in an exported anchor file are checked as well.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if driverFlag == "" {
+				if d, err := util.DetectDriver(dbDSN); err == nil {
+					driverFlag = d
+				} else {
+					driverFlag = "unknown"
+				}
+			}
+			db, err := sql.Open(driverFlag, dbDSN)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			ctx := context.Background()
+			dialect := util.DialectFromDriver(driverFlag)
+
+			tenants := []string{tenant}
+			if all {
+				heads, err := audit.ChainHeads(ctx, db, dialect, tablePrefix)
+				if err != nil {
+					return err
+				}
+				tenants = tenants[:0]
+				for _, h := range heads {
+					tenants = append(tenants, h.Tenant)
+				}
+			}
+			out := cmd.OutOrStdout()
+			failed := false
+			for _, t := range tenants {
+				st, err := audit.VerifyChain(ctx, db, dialect, tablePrefix, t)
+				if err != nil {
+					return fmt.Errorf("tenant %s: %w", t, err)
+				}
+				if st.Broken != nil {
+					failed = true
+					fmt.Fprintf(out, "tenant %s: chain broken at audit log %d: %s\n", t, st.Broken.ID, st.Broken.Reason)
+					continue
+				}
+				fmt.Fprintf(out, "tenant %s: ok (%d rows verified, %d unhashed rows skipped)\n", t, st.Checked, st.Unhashed)
+			}
+			if anchorPath != "" {
+				data, err := os.ReadFile(anchorPath)
+				if err != nil {
+					return err
+				}
+				var a audit.Anchor
+				if err := json.Unmarshal(data, &a); err != nil {
+					return fmt.Errorf("parse anchor: %w", err)
+				}
+				for _, h := range a.Heads {
+					if !all && h.Tenant != tenant {
+						continue
+					}
+					if err := audit.CheckAnchor(ctx, db, dialect, tablePrefix, h); err != nil {
+						failed = true
+						fmt.Fprintf(out, "anchor %s: %v\n", a.ExportedAt.Format("2006-01-02T15:04:05Z07:00"), err)
+					}
+				}
+			}
+			if failed {
+				return errors.New("audit chain verification failed")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&dbDSN, "db", "", "database DSN")
+	cmd.Flags().StringVar(&driverFlag, "driver", "", "database driver (mysql|postgres)")
+	cmd.Flags().StringVar(&tenant, "tenant", util.GetEnv("CF_TENANT", "default"), "tenant id")
+	cmd.Flags().StringVar(&tablePrefix, "table-prefix", util.GetEnv("CF_TABLE_PREFIX", "gcfm_"), "table name prefix")
+	cmd.Flags().BoolVar(&all, "all", false, "verify the chains of all tenants")
+	cmd.Flags().StringVar(&anchorPath, "anchor", "", "anchor file exported by the API server")
+	mustFlag(cmd, "db")
+	return cmd
+}
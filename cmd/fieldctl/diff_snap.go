@@ -60,6 +60,8 @@ func newDiffSnapCmd() *cobra.Command {
 			}
 			ya, _ := snapshot.Decode(a.YAML)
 			yb, _ := snapshot.Decode(b.YAML)
+			ya, _ = snapshot.RegistryYAML(ya)
+			yb, _ = snapshot.RegistryYAML(yb)
 			diff := sdk.UnifiedDiff(string(ya), string(yb))
 			fmt.Fprint(cmd.OutOrStdout(), diff)
 			return nil
@@ -1,14 +1,14 @@
 package events
 
 import (
-	"context"
 	"database/sql"
-	"encoding/json"
+	"os"
 
 	"github.com/redis/go-redis/v9"
 
 	dbcmd "github.com/faciam-dev/gcfm/cmd/fieldctl/db"
 	notify "github.com/faciam-dev/gcfm/internal/events"
+	"github.com/faciam-dev/gcfm/pkg/util"
 	"github.com/spf13/cobra"
 )
 
@@ -21,46 +21,49 @@ func NewCmd() *cobra.Command {
 	return cmd
 }
 
-func openDB(f dbcmd.DBFlags) (*sql.DB, error) {
+func openDB(f dbcmd.DBFlags) (*sql.DB, *notify.SQLDLQ, error) {
 	if f.Driver == "" {
 		d, err := dbcmd.DetectDriver(f.DSN)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		f.Driver = d
 	}
-	return sql.Open(f.Driver, f.DSN)
+	db, err := sql.Open(f.Driver, f.DSN)
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, &notify.SQLDLQ{DB: db, Dialect: util.DialectFromDriver(f.Driver), TablePrefix: f.TablePrefix}, nil
 }
 
 func newListFailedCmd() *cobra.Command {
 	var flags dbcmd.DBFlags
+	var tenant, sink, typ string
+	var limit int
 	cmd := &cobra.Command{
 		Use:   "ls-failed",
 		Short: "List failed events",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			db, err := openDB(flags)
+			db, dlq, err := openDB(flags)
 			if err != nil {
 				return err
 			}
 			defer db.Close()
-			rows, err := db.QueryContext(cmd.Context(), "SELECT id,name,payload,attempts,last_error FROM gcfm_events_failed ORDER BY id")
+			rows, err := dlq.List(cmd.Context(), notify.FailedFilter{Tenant: tenant, AllTenants: tenant == "", Sink: sink, Name: typ, Limit: limit})
 			if err != nil {
 				return err
 			}
-			defer rows.Close()
-			for rows.Next() {
-				var id int64
-				var name, payload, last string
-				var attempts int
-				if err := rows.Scan(&id, &name, &payload, &attempts, &last); err != nil {
-					return err
-				}
-				cmd.Printf("%d\t%s\t%d\t%s\n", id, name, attempts, last)
+			for _, f := range rows {
+				cmd.Printf("%d\t%s\t%s\t%d\t%s\n", f.ID, f.Name, f.Sink, f.Attempts, f.LastError)
 			}
-			return rows.Err()
+			return nil
 		},
 	}
 	flags.AddFlags(cmd)
+	cmd.Flags().StringVar(&tenant, "tenant", "", "only events of this tenant (default all tenants)")
+	cmd.Flags().StringVar(&sink, "sink", "", "only events failed by this sink")
+	cmd.Flags().StringVar(&typ, "type", "", "only events of this type")
+	cmd.Flags().IntVar(&limit, "limit", 100, "maximum number of events")
 	cobra.CheckErr(cmd.MarkFlagRequired("db"))
 	return cmd
 }
@@ -68,36 +71,52 @@ func newListFailedCmd() *cobra.Command {
 func newRetryCmd() *cobra.Command {
 	var flags dbcmd.DBFlags
 	var id int64
-	var redisDSN, channel string
+	var tenant, configPath, redisDSN, channel string
 	cmd := &cobra.Command{
 		Use:   "retry",
 		Short: "Retry failed event by id",
+		Long: `Replay a failed event to the sink that failed it, using the sinks of the
+events configuration. With --redis the event is published to that Redis
+channel instead. The event is removed from the DLQ once it is accepted.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			db, err := openDB(flags)
+			db, dlq, err := openDB(flags)
 			if err != nil {
 				return err
 			}
 			defer db.Close()
-			var payload string
-			err = db.QueryRowContext(cmd.Context(), "SELECT payload FROM gcfm_events_failed WHERE id=?", id).Scan(&payload)
-			if err != nil {
-				return err
-			}
-			var evt notify.Event
-			if err := json.Unmarshal([]byte(payload), &evt); err != nil {
-				return err
-			}
-			opt, err := redis.ParseURL(redisDSN)
-			if err != nil {
-				return err
-			}
-			cli := redis.NewClient(opt)
-			sink := &notify.RedisSink{Client: cli, Channel: channel}
-			if err := sink.Emit(cmd.Context(), evt); err != nil {
-				return err
-			}
-			if _, err := db.ExecContext(cmd.Context(), "DELETE FROM gcfm_events_failed WHERE id=?", id); err != nil {
-				return err
+			ctx := cmd.Context()
+			if redisDSN != "" {
+				f, err := dlq.Get(ctx, tenant, id)
+				if err != nil {
+					return err
+				}
+				opt, err := redis.ParseURL(redisDSN)
+				if err != nil {
+					return err
+				}
+				cli := redis.NewClient(opt)
+				defer cli.Close()
+				sink := &notify.RedisSink{Client: cli, Channel: channel}
+				if err := sink.Emit(ctx, f.Event); err != nil {
+					return err
+				}
+				if err := dlq.Delete(ctx, tenant, id); err != nil {
+					return err
+				}
+			} else {
+				cfg, err := notify.LoadConfig(configPath)
+				if err != nil {
+					return err
+				}
+				sinks, err := notify.NewSinks(cfg)
+				if err != nil {
+					return err
+				}
+				sinks = append(sinks, notify.NewTenantWebhookSink(&notify.WebhookStore{DB: db, Dialect: dlq.Dialect, TablePrefix: dlq.TablePrefix}, cfg.TenantWebhooks))
+				r := notify.NewReplayer(dlq, notify.NewDispatcher(cfg, nil, sinks...), cfg.Replay)
+				if err := r.ReplayNow(ctx, tenant, id); err != nil {
+					return err
+				}
 			}
 			cmd.Println("re-dispatched", id)
 			return nil
@@ -105,37 +124,11 @@ func newRetryCmd() *cobra.Command {
 	}
 	flags.AddFlags(cmd)
 	cmd.Flags().Int64Var(&id, "id", 0, "event id")
-	cmd.Flags().StringVar(&redisDSN, "redis", "", "redis DSN")
+	cmd.Flags().StringVar(&tenant, "tenant", util.GetEnv("CF_TENANT", "default"), "tenant id")
+	cmd.Flags().StringVar(&configPath, "events-config", os.Getenv("CF_EVENTS_CONFIG"), "events configuration file")
+	cmd.Flags().StringVar(&redisDSN, "redis", "", "publish to this redis DSN instead of the configured sink")
 	cmd.Flags().StringVar(&channel, "channel", "cf-events", "channel name")
 	cobra.CheckErr(cmd.MarkFlagRequired("db"))
 	cobra.CheckErr(cmd.MarkFlagRequired("id"))
-	cobra.CheckErr(cmd.MarkFlagRequired("redis"))
-	return cmd
-}
-
-func newTailCmd() *cobra.Command {
-	var dsn, channel string
-	cmd := &cobra.Command{
-		Use:   "tail",
-		Short: "Tail events from redis",
-		RunE: func(cmd *cobra.Command, args []string) error {
-			opt, err := redis.ParseURL(dsn)
-			if err != nil {
-				return err
-			}
-			client := redis.NewClient(opt)
-			sub := client.Subscribe(context.Background(), channel)
-			if _, err := sub.Receive(cmd.Context()); err != nil {
-				return err
-			}
-			for msg := range sub.Channel() {
-				cmd.Println(msg.Payload)
-			}
-			return nil
-		},
-	}
-	cmd.Flags().StringVar(&dsn, "dsn", "", "redis DSN")
-	cmd.Flags().StringVar(&channel, "channel", "cf-events", "channel name")
-	cobra.CheckErr(cmd.MarkFlagRequired("dsn"))
 	return cmd
 }
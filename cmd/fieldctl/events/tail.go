@@ -0,0 +1,226 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/cobra"
+
+	"github.com/faciam-dev/gcfm/pkg/config"
+	"github.com/faciam-dev/gcfm/pkg/util"
+)
+
+func newTailCmd() *cobra.Command {
+	var dsn, channel string
+	var o FollowOptions
+	cmd := &cobra.Command{
+		Use:   "tail",
+		Short: "Tail events",
+		Long: `Print events as they happen. By default the command follows
+GET /v1/events/stream of the API configured with --api-url/--token or
+fieldctl login, and resumes where it stopped when the connection drops.
+With --dsn it subscribes to the Redis sink channel instead.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			print := func(data string) error {
+				cmd.Println(data)
+				return nil
+			}
+			if dsn != "" {
+				return FollowRedis(cmd.Context(), dsn, channel, print)
+			}
+			return Follow(cmd, o, print)
+		},
+	}
+	cmd.Flags().StringVar(&dsn, "dsn", "", "redis DSN; tail the redis sink instead of the API")
+	cmd.Flags().StringVar(&channel, "channel", "cf-events", "redis channel name")
+	o.AddFlags(cmd)
+	return cmd
+}
+
+// FollowOptions selects the events read from the API stream.
+type FollowOptions struct {
+	Tenant string
+	Types  string
+	After  string
+}
+
+// AddFlags registers --tenant, --types and --after on cmd.
+func (o *FollowOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Tenant, "tenant", util.GetEnv("CF_TENANT", ""), "tenant id; defaults to the tenant of the token")
+	cmd.Flags().StringVar(&o.Types, "types", "", "comma separated event type globs, e.g. cf.field.*")
+	cmd.Flags().StringVar(&o.After, "after", "", "start after this event id; 0 replays every retained event")
+}
+
+// Follow calls fn with the CloudEvent JSON of every event streamed by the
+// API that cmd is configured for, until the command is cancelled. When fn
+// fails, the stream reconnects and the event is read again.
+func Follow(cmd *cobra.Command, o FollowOptions, fn func(data string) error) error {
+	resolved, err := config.Resolve(cmd)
+	if err != nil {
+		return err
+	}
+	t := &streamTail{
+		URL:    strings.TrimSuffix(resolved.APIURL, "/") + "/v1/events/stream",
+		Token:  resolved.Token,
+		Tenant: o.Tenant,
+		Types:  o.Types,
+		LastID: o.After,
+		Handle: fn,
+	}
+	return t.Run(cmd.Context())
+}
+
+// FollowRedis calls fn with every message published on channel until ctx
+// is cancelled.
+func FollowRedis(ctx context.Context, dsn, channel string, fn func(data string) error) error {
+	opt, err := redis.ParseURL(dsn)
+	if err != nil {
+		return err
+	}
+	client := redis.NewClient(opt)
+	defer client.Close()
+	sub := client.Subscribe(ctx, channel)
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		return err
+	}
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err := fn(msg.Payload); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// streamTail follows the API event stream and hands the data of every event
+// to Handle.
+type streamTail struct {
+	URL    string
+	Token  string
+	Tenant string
+	Types  string
+	// LastID is the id of the last event seen; the stream resumes after it.
+	LastID string
+	Handle func(data string) error
+
+	retry time.Duration
+}
+
+// Run reads the stream until ctx is cancelled, reconnecting after
+// connection errors. Responses other than 200 end the command.
+func (t *streamTail) Run(ctx context.Context) error {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	client := &http.Client{Transport: tr}
+	u, err := url.Parse(t.URL)
+	if err != nil {
+		return err
+	}
+	if t.Types != "" {
+		q := u.Query()
+		q.Set("types", t.Types)
+		u.RawQuery = q.Encode()
+	}
+	t.retry = time.Second
+	for {
+		err := t.read(ctx, client, u.String())
+		if ctx.Err() != nil {
+			return nil
+		}
+		if _, ok := err.(statusError); ok {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(t.retry):
+		}
+	}
+}
+
+type statusError struct {
+	code int
+	body string
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("event stream: %d %s", e.code, strings.TrimSpace(e.body))
+}
+
+func (t *streamTail) read(ctx context.Context, client *http.Client, u string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Authorization", "Bearer "+t.Token)
+	if t.Tenant != "" {
+		req.Header.Set("X-Tenant-ID", t.Tenant)
+	}
+	if t.LastID != "" {
+		req.Header.Set("Last-Event-ID", t.LastID)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return statusError{code: resp.StatusCode, body: string(b)}
+	}
+	return t.parse(resp.Body)
+}
+
+// parse handles the events of an SSE body and remembers their ids.
+func (t *streamTail) parse(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	var id string
+	var data []string
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			if len(data) > 0 {
+				if err := t.Handle(strings.Join(data, "\n")); err != nil {
+					return err
+				}
+				if id != "" {
+					t.LastID = id
+				}
+			}
+			id, data = "", nil
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			id = value
+		case "data":
+			data = append(data, value)
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms > 0 {
+				t.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	return sc.Err()
+}
@@ -0,0 +1,26 @@
+package events
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamTailParse(t *testing.T) {
+	var out bytes.Buffer
+	tl := &streamTail{LastID: "3", Handle: func(data string) error {
+		out.WriteString(data + "\n")
+		return nil
+	}}
+	body := "retry: 2500\n\n: keepalive\n\nid: 4\nevent: cf.scan\ndata: {\"a\":1}\n\nid: 5\ndata: x\ndata: y\n\n"
+	if err := tl.parse(strings.NewReader(body)); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if out.String() != "{\"a\":1}\nx\ny\n" {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+	if tl.LastID != "5" || tl.retry != 2500*time.Millisecond {
+		t.Fatalf("last id %q, retry %v", tl.LastID, tl.retry)
+	}
+}
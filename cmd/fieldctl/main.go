@@ -27,6 +27,7 @@ func init() {
 	rootCmd.AddCommand(newGenerateCmd())
 	rootCmd.AddCommand(newGenDocsCmd())
 	rootCmd.AddCommand(newSnapshotCmd())
+	rootCmd.AddCommand(newAuditCmd())
 	rootCmd.AddCommand(newRevertCmd())
 	rootCmd.AddCommand(newDiffSnapCmd())
 	rootCmd.AddCommand(newNotifierCmd())
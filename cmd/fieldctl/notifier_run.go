@@ -1,39 +1,98 @@
 package main
 
 import (
-	"context"
-	"errors"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
 
-	"github.com/redis/go-redis/v9"
 	"github.com/spf13/cobra"
+
+	eventscmd "github.com/faciam-dev/gcfm/cmd/fieldctl/events"
+	"github.com/faciam-dev/gcfm/pkg/notifier"
 )
 
 func NewRunCmd() *cobra.Command {
 	var mode string
 	var dsn string
 	var channel string
+	var summary bool
+	var o eventscmd.FollowOptions
 	cmd := &cobra.Command{
 		Use:   "run",
 		Short: "Run notifier daemon",
+		Long: `Print the cf.* events published by the API server and by SDK applies,
+one "event: <payload>" line per event. In api mode the events are read from
+GET /v1/events/stream; in redis mode from the channel of the Redis sink.
+Use --summary to print the time, type, subject and tenant of each CloudEvent
+instead of its payload.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if mode != "redis" {
-				return errors.New("only redis mode supported")
-			}
-			opt, err := redis.ParseURL(dsn)
-			if err != nil {
-				return err
+			handle := func(data string) error {
+				cmd.Println(formatNotification(data, summary))
+				return nil
 			}
-			client := redis.NewClient(opt)
-			sub := client.Subscribe(context.Background(), channel)
-			for msg := range sub.Channel() {
-				cmd.Printf("event: %s\n", msg.Payload)
+			switch mode {
+			case "api":
+				return eventscmd.Follow(cmd, o, handle)
+			case "redis":
+				if dsn == "" {
+					return fmt.Errorf("--dsn is required in redis mode")
+				}
+				return eventscmd.FollowRedis(cmd.Context(), dsn, channel, func(data string) error {
+					if !selected(data, o) {
+						return nil
+					}
+					return handle(data)
+				})
+			default:
+				return fmt.Errorf("unknown mode %q: use api or redis", mode)
 			}
-			return nil
 		},
 	}
-	cmd.Flags().StringVar(&mode, "mode", "redis", "broker mode")
+	cmd.Flags().StringVar(&mode, "mode", "redis", "event source: api or redis")
 	cmd.Flags().StringVar(&dsn, "dsn", "", "redis DSN")
 	cmd.Flags().StringVar(&channel, "channel", "cf-events", "channel name")
-	mustFlag(cmd, "dsn")
+	cmd.Flags().BoolVar(&summary, "summary", false, "print one summary line per CloudEvent instead of its payload")
+	o.AddFlags(cmd)
 	return cmd
 }
+
+// formatNotification renders a message for the notifier output. Messages
+// are printed as received unless summary is set; payloads that are not
+// CloudEvents, such as the legacy diff reports, are always printed as
+// received.
+func formatNotification(data string, summary bool) string {
+	var ce notifier.CloudEvent
+	if !summary || json.Unmarshal([]byte(data), &ce) != nil || ce.Type == "" {
+		return "event: " + data
+	}
+	line := fmt.Sprintf("event: %s %s", ce.Time.Format("2006-01-02T15:04:05Z07:00"), ce.Type)
+	if ce.Subject != "" {
+		line += " " + ce.Subject
+	}
+	if ce.Tenant != "" {
+		line += " tenant=" + ce.Tenant
+	}
+	return line + " id=" + ce.ID
+}
+
+// selected applies the --tenant and --types filters of o to a message read
+// from Redis. Messages that are not CloudEvents are kept.
+func selected(data string, o eventscmd.FollowOptions) bool {
+	var ce notifier.CloudEvent
+	if json.Unmarshal([]byte(data), &ce) != nil || ce.Type == "" {
+		return true
+	}
+	if o.Tenant != "" && ce.Tenant != o.Tenant {
+		return false
+	}
+	if o.Types == "" {
+		return true
+	}
+	for _, g := range strings.Split(o.Types, ",") {
+		if ok, _ := path.Match(strings.TrimSpace(g), ce.Type); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	eventscmd "github.com/faciam-dev/gcfm/cmd/fieldctl/events"
+)
+
+func TestFormatNotification(t *testing.T) {
+	ce := `{"specversion":"1.0","id":"e1","source":"/gcfm","type":"cf.field.created","subject":"posts.title","time":"2026-05-01T12:00:00Z","tenant":"t1","data":{}}`
+	if got := formatNotification(ce, true); got != "event: 2026-05-01T12:00:00Z cf.field.created posts.title tenant=t1 id=e1" {
+		t.Fatalf("unexpected line %q", got)
+	}
+	if got := formatNotification(ce, false); got != "event: "+ce {
+		t.Fatalf("unexpected default line %q", got)
+	}
+	legacy := `{"Added":1,"Deleted":0,"Updated":0}`
+	if got := formatNotification(legacy, true); got != "event: "+legacy {
+		t.Fatalf("unexpected legacy line %q", got)
+	}
+	if !selected(legacy, eventscmd.FollowOptions{Tenant: "t2"}) {
+		t.Fatal("legacy payload filtered")
+	}
+	if selected(ce, eventscmd.FollowOptions{Tenant: "t2"}) || selected(ce, eventscmd.FollowOptions{Types: "cf.scan"}) {
+		t.Fatal("filters not applied")
+	}
+	if !selected(ce, eventscmd.FollowOptions{Tenant: "t1", Types: "cf.scan, cf.field.*"}) {
+		t.Fatal("matching event filtered")
+	}
+}
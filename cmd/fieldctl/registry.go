@@ -12,5 +12,6 @@ func newRegistryCmd() *cobra.Command {
 	}
 	cmd.AddCommand(registrycmd.NewMigrateCmd())
 	cmd.AddCommand(registrycmd.NewVersionCmd())
+	cmd.AddCommand(registrycmd.NewAtCmd())
 	return cmd
 }
@@ -0,0 +1,70 @@
+package registrycmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/faciam-dev/gcfm/pkg/snapshot"
+	"github.com/faciam-dev/gcfm/pkg/util"
+)
+
+// NewAtCmd creates the at subcommand.
+func NewAtCmd() *cobra.Command {
+	var (
+		dbDSN       string
+		driver      string
+		tenant      string
+		tablePrefix string
+		at          string
+		out         string
+	)
+	cmd := &cobra.Command{
+		Use:   "at",
+		Short: "Reconstruct the registry at a point in time",
+		Long:  "Rebuild the registry YAML as of --time by replaying audit log entries on top of the nearest earlier snapshot.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t, err := time.Parse(time.RFC3339, at)
+			if err != nil {
+				return fmt.Errorf("--time must be an RFC3339 timestamp: %w", err)
+			}
+			if driver == "" {
+				if driver, err = util.DetectDriver(dbDSN); err != nil {
+					return err
+				}
+			}
+			db, err := sql.Open(driver, dbDSN)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			res, err := snapshot.RegistryAt(context.Background(), db, util.DialectFromDriver(driver), tablePrefix, tenant, t)
+			if err != nil {
+				return err
+			}
+			base := "empty registry"
+			if res.Base != nil {
+				base = "snapshot " + res.Base.Semver
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "replayed %d change(s) on top of %s\n", res.Replayed, base)
+			if out != "" {
+				return os.WriteFile(out, res.YAML, 0o644)
+			}
+			_, err = cmd.OutOrStdout().Write(res.YAML)
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&dbDSN, "db", "", "database DSN")
+	cmd.Flags().StringVar(&driver, "driver", "", "database driver")
+	cmd.Flags().StringVar(&tenant, "tenant", util.GetEnv("CF_TENANT", "default"), "tenant id")
+	cmd.Flags().StringVar(&tablePrefix, "table-prefix", util.GetEnv("CF_TABLE_PREFIX", "gcfm_"), "registry table prefix")
+	cmd.Flags().StringVar(&at, "time", "", "RFC3339 timestamp")
+	cmd.Flags().StringVarP(&out, "out", "o", "", "write YAML to file instead of stdout")
+	cobra.CheckErr(cmd.MarkFlagRequired("db"))
+	cobra.CheckErr(cmd.MarkFlagRequired("time"))
+	return cmd
+}
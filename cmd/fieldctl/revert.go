@@ -56,6 +56,9 @@ func newRevertCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
+			if data, err = snapshot.RegistryYAML(data); err != nil {
+				return err
+			}
 			svc := sdk.New(sdk.ServiceConfig{})
 			_, err = svc.Apply(ctx, sdk.DBConfig{Driver: driverFlag, DSN: dbDSN, Schema: schema, TablePrefix: tablePrefix}, data, sdk.ApplyOptions{})
 			return err
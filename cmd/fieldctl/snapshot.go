@@ -5,11 +5,17 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
+	"os"
 
 	"github.com/spf13/cobra"
 
+	widgetsrepo "github.com/faciam-dev/gcfm/internal/repository/widgets"
+	"github.com/faciam-dev/gcfm/internal/snapshotbundle"
+	"github.com/faciam-dev/gcfm/meta/sqlmetastore"
 	"github.com/faciam-dev/gcfm/pkg/snapshot"
 	"github.com/faciam-dev/gcfm/pkg/util"
+	"github.com/faciam-dev/gcfm/pkg/widgetpolicy"
 	"github.com/faciam-dev/gcfm/sdk"
 )
 
@@ -22,6 +28,9 @@ func newSnapshotCmd() *cobra.Command {
 		driverFlag  string
 		message     string
 		tablePrefix string
+		tag         string
+		bundle      bool
+		policyPath  string
 	)
 	cmd := &cobra.Command{
 		Use:   "snapshot",
@@ -40,6 +49,11 @@ func newSnapshotCmd() *cobra.Command {
 					driverFlag = "unknown"
 				}
 			}
+			if tag != "" {
+				if err := snapshot.ValidateTag(tag); err != nil {
+					return err
+				}
+			}
 			db, err := sql.Open(driverFlag, dbDSN)
 			if err != nil {
 				return err
@@ -51,6 +65,15 @@ func newSnapshotCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
+			if bundle {
+				b, err := collectBundle(ctx, db, driverFlag, dbDSN, schema, tablePrefix, tenant, policyPath, data)
+				if err != nil {
+					return err
+				}
+				if data, err = snapshot.EncodeBundle(b); err != nil {
+					return err
+				}
+			}
 			comp, err := snapshot.Encode(data)
 			if err != nil {
 				return err
@@ -73,6 +96,11 @@ func newSnapshotCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
+			if tag != "" {
+				if err := snapshot.SetTag(ctx, db, dialect, tablePrefix, tenant, tag, rec.ID); err != nil {
+					return err
+				}
+			}
 			fmt.Fprintln(cmd.OutOrStdout(), rec.Semver)
 			return nil
 		},
@@ -84,7 +112,45 @@ func newSnapshotCmd() *cobra.Command {
 	cmd.Flags().StringVar(&bump, "bump", "patch", "semver bump type")
 	cmd.Flags().StringVar(&message, "message", "", "snapshot message")
 	cmd.Flags().StringVar(&tablePrefix, "table-prefix", util.GetEnv("CF_TABLE_PREFIX", "gcfm_"), "table name prefix")
+	cmd.Flags().StringVar(&tag, "tag", "", "tag the new snapshot")
+	cmd.Flags().BoolVar(&bundle, "bundle", false, "capture the full tenant configuration bundle")
+	cmd.Flags().StringVar(&policyPath, "widget-policy", os.Getenv("WIDGET_POLICY_PATH"), "widget policy file included in bundles")
 	mustFlag(cmd, "db")
 	mustFlag(cmd, "schema")
+	cmd.AddCommand(newSnapshotPruneCmd(), newSnapshotTagCmd(), newSnapshotPromoteCmd())
 	return cmd
 }
+
+// collectBundle gathers the tenant configuration stored in db. registry is
+// the already exported registry YAML; the widget policy is only included when
+// policyPath is set.
+func collectBundle(ctx context.Context, db *sql.DB, driver, dsn, schema, tablePrefix, tenant, policyPath string, registry []byte) (*snapshot.Bundle, error) {
+	svc := &snapshotbundle.Service{
+		DB:          db,
+		Driver:      driver,
+		Dialect:     util.DialectFromDriver(driver),
+		DSN:         dsn,
+		TablePrefix: tablePrefix,
+		Targets:     sqlmetastore.NewSQLMetaStore(db, driver, schema),
+	}
+	switch driver {
+	case "postgres":
+		svc.Widgets = widgetsrepo.NewPGRepo(db, tablePrefix)
+	case "mysql":
+		svc.Widgets = widgetsrepo.NewMySQLRepo(db, tablePrefix)
+	default:
+		return nil, fmt.Errorf("bundles are not supported for driver %s", driver)
+	}
+	if policyPath != "" {
+		svc.Policy = widgetpolicy.NewStore(policyPath, slog.Default())
+		if err := svc.Policy.Load(); err != nil {
+			return nil, fmt.Errorf("load widget policy: %w", err)
+		}
+	}
+	b, err := svc.Collect(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	b.Registry = registry
+	return b, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/faciam-dev/gcfm/pkg/audit"
+	"github.com/faciam-dev/gcfm/pkg/registry"
+	"github.com/faciam-dev/gcfm/pkg/snapshot"
+	"github.com/faciam-dev/gcfm/pkg/util"
+)
+
+func newSnapshotPromoteCmd() *cobra.Command {
+	var (
+		dbDSN       string
+		driverFlag  string
+		tablePrefix string
+		fromTenant  string
+		toTenant    string
+		tag         string
+		bump        string
+		actor       string
+		dryRun      bool
+	)
+	cmd := &cobra.Command{
+		Use:   "promote",
+		Short: "Promote a tagged snapshot to another tenant",
+		Long: `Apply the snapshot tagged --tag in --from-tenant to the registry of
+--to-tenant. The destination registry is stored as a new snapshot carrying the
+same tag, and the promotion is recorded on both snapshots.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if dbDSN == "" {
+				return errors.New("--db is required")
+			}
+			if driverFlag == "" {
+				if d, err := util.DetectDriver(dbDSN); err == nil {
+					driverFlag = d
+				} else {
+					driverFlag = "unknown"
+				}
+			}
+			db, err := sql.Open(driverFlag, dbDSN)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			ctx := context.Background()
+			dialect := util.DialectFromDriver(driverFlag)
+			rec := &audit.Recorder{DB: db, Dialect: dialect, TablePrefix: tablePrefix, HashChain: true}
+			res, err := snapshot.Promote(ctx, db, dialect, driverFlag, tablePrefix, snapshot.PromoteOptions{
+				FromTenant: fromTenant,
+				ToTenant:   toTenant,
+				Tag:        tag,
+				Actor:      actor,
+				Bump:       bump,
+				DryRun:     dryRun,
+			}, rec)
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			for _, c := range res.Changes {
+				switch c.Type {
+				case registry.ChangeAdded:
+					fmt.Fprintf(out, "+ %s.%s\n", c.New.TableName, c.New.ColumnName)
+				case registry.ChangeDeleted:
+					fmt.Fprintf(out, "- %s.%s\n", c.Old.TableName, c.Old.ColumnName)
+				case registry.ChangeUpdated:
+					fmt.Fprintf(out, "~ %s.%s\n", c.New.TableName, c.New.ColumnName)
+				}
+			}
+			summary := fmt.Sprintf("+%d -%d ~%d", res.Report.Added, res.Report.Deleted, res.Report.Updated)
+			if dryRun {
+				fmt.Fprintf(out, "would promote %s@%s to %s (%s)\n", fromTenant, res.Source.Semver, toTenant, summary)
+				return nil
+			}
+			fmt.Fprintf(out, "promoted %s@%s to %s@%s (%s)\n", fromTenant, res.Source.Semver, toTenant, res.Dest.Semver, summary)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&dbDSN, "db", "", "database DSN")
+	cmd.Flags().StringVar(&driverFlag, "driver", "", "database driver (mysql|postgres)")
+	cmd.Flags().StringVar(&tablePrefix, "table-prefix", util.GetEnv("CF_TABLE_PREFIX", "gcfm_"), "table name prefix")
+	cmd.Flags().StringVar(&fromTenant, "from-tenant", "", "source tenant id")
+	cmd.Flags().StringVar(&toTenant, "to-tenant", "", "destination tenant id")
+	cmd.Flags().StringVar(&tag, "tag", "", "tag of the source snapshot")
+	cmd.Flags().StringVar(&bump, "bump", "patch", "semver bump of the destination snapshot")
+	cmd.Flags().StringVar(&actor, "actor", util.GetEnv("USER", ""), "actor recorded in audit log and lineage")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "show the diff without applying it")
+	mustFlag(cmd, "db")
+	mustFlag(cmd, "from-tenant")
+	mustFlag(cmd, "to-tenant")
+	mustFlag(cmd, "tag")
+	return cmd
+}
+
+func newSnapshotTagCmd() *cobra.Command {
+	var (
+		dbDSN       string
+		driverFlag  string
+		tenant      string
+		tablePrefix string
+		remove      bool
+	)
+	cmd := &cobra.Command{
+		Use:   "tag <version> <tag>",
+		Short: "Tag a snapshot",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ver, tag := args[0], args[1]
+			if driverFlag == "" {
+				if d, err := util.DetectDriver(dbDSN); err == nil {
+					driverFlag = d
+				} else {
+					driverFlag = "unknown"
+				}
+			}
+			db, err := sql.Open(driverFlag, dbDSN)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			ctx := context.Background()
+			dialect := util.DialectFromDriver(driverFlag)
+			if remove {
+				return snapshot.DeleteTag(ctx, db, dialect, tablePrefix, tenant, tag)
+			}
+			rec, err := snapshot.Get(ctx, db, dialect, tablePrefix, tenant, ver)
+			if err != nil {
+				return fmt.Errorf("snapshot %s: %w", ver, err)
+			}
+			return snapshot.SetTag(ctx, db, dialect, tablePrefix, tenant, tag, rec.ID)
+		},
+	}
+	cmd.Flags().StringVar(&dbDSN, "db", "", "database DSN")
+	cmd.Flags().StringVar(&driverFlag, "driver", "", "database driver (mysql|postgres)")
+	cmd.Flags().StringVar(&tenant, "tenant", util.GetEnv("CF_TENANT", "default"), "tenant id")
+	cmd.Flags().StringVar(&tablePrefix, "table-prefix", util.GetEnv("CF_TABLE_PREFIX", "gcfm_"), "table name prefix")
+	cmd.Flags().BoolVar(&remove, "delete", false, "remove the tag instead of setting it")
+	mustFlag(cmd, "db")
+	return cmd
+}
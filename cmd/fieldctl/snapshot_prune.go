@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/faciam-dev/gcfm/pkg/snapshot"
+	"github.com/faciam-dev/gcfm/pkg/util"
+)
+
+func newSnapshotPruneCmd() *cobra.Command {
+	var (
+		dbDSN       string
+		driverFlag  string
+		tenant      string
+		tablePrefix string
+		dryRun      bool
+		keepLast    int
+		keepWithin  time.Duration
+		save        bool
+	)
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete snapshots outside the tenant retention policy",
+		Long: `Delete snapshots that are not retained by the tenant retention policy.
+The newest snapshot and tagged snapshots are always kept. --keep-last and
+--keep-within override the stored policy for this run; add --save to store
+them as the new policy.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if dbDSN == "" {
+				return errors.New("--db is required")
+			}
+			if driverFlag == "" {
+				if d, err := util.DetectDriver(dbDSN); err == nil {
+					driverFlag = d
+				} else {
+					driverFlag = "unknown"
+				}
+			}
+			db, err := sql.Open(driverFlag, dbDSN)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			ctx := context.Background()
+			dialect := util.DialectFromDriver(driverFlag)
+			policy, err := snapshot.GetRetention(ctx, db, dialect, tablePrefix, tenant)
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("keep-last") {
+				policy.KeepLast = keepLast
+			}
+			if cmd.Flags().Changed("keep-within") {
+				policy.KeepWithin = keepWithin
+			}
+			if !policy.Enabled() {
+				return fmt.Errorf("no retention policy for tenant %q; use --keep-last or --keep-within", tenant)
+			}
+			if save && !dryRun {
+				if err := snapshot.SetRetention(ctx, db, dialect, tablePrefix, policy); err != nil {
+					return err
+				}
+			}
+			res, err := snapshot.Prune(ctx, db, dialect, tablePrefix, policy, time.Now(), dryRun)
+			if err != nil {
+				return err
+			}
+			verb := "deleted"
+			if dryRun {
+				verb = "would delete"
+			}
+			for _, r := range res.Deleted {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s %s (%s)\n", verb, r.Semver, r.TakenAt.Format(time.RFC3339))
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s %d snapshot(s), %d bytes; kept %d\n", verb, len(res.Deleted), res.Bytes, res.Kept)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&dbDSN, "db", "", "database DSN")
+	cmd.Flags().StringVar(&driverFlag, "driver", "", "database driver (mysql|postgres)")
+	cmd.Flags().StringVar(&tenant, "tenant", util.GetEnv("CF_TENANT", "default"), "tenant id")
+	cmd.Flags().StringVar(&tablePrefix, "table-prefix", util.GetEnv("CF_TABLE_PREFIX", "gcfm_"), "table name prefix")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "list snapshots that would be deleted")
+	cmd.Flags().IntVar(&keepLast, "keep-last", 0, "keep the newest N snapshots")
+	cmd.Flags().DurationVar(&keepWithin, "keep-within", 0, "keep snapshots newer than this duration")
+	cmd.Flags().BoolVar(&save, "save", false, "store --keep-last/--keep-within as the tenant policy")
+	mustFlag(cmd, "db")
+	return cmd
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/faciam-dev/gcfm/pkg/config"
+	"github.com/faciam-dev/gcfm/sdk"
 )
 
 // Target represents a target definition returned by the Admin API.
@@ -29,6 +30,7 @@ type Target struct {
 	ConnMaxIdleMs int      `json:"connMaxIdleMs"`
 	ConnMaxLifeMs int      `json:"connMaxLifeMs"`
 	IsDefault     bool     `json:"isDefault"`
+	Draining      bool     `json:"draining"`
 	UpdatedAt     string   `json:"updatedAt"`
 }
 
@@ -58,6 +60,7 @@ var (
 	targetConnMaxLifeMs int
 	targetIsDefault     bool
 	targetIfMatch       string
+	targetSelector      string
 )
 
 // apiRequest performs an HTTP request against the Admin API using resolved configuration.
@@ -110,13 +113,13 @@ func printOutput(v any) error {
 		switch x := v.(type) {
 		case []Target:
 			tw := tablewriter.NewWriter(os.Stdout)
-			tw.SetHeader([]string{"Key", "Driver", "DSN", "Labels", "Default", "Updated"})
+			tw.SetHeader([]string{"Key", "Driver", "DSN", "Labels", "Default", "Draining", "Updated"})
 			for _, t := range x {
-				tw.Append([]string{t.Key, t.Driver, t.Dsn, strings.Join(t.Labels, ","), fmt.Sprint(t.IsDefault), t.UpdatedAt})
+				tw.Append([]string{t.Key, t.Driver, t.Dsn, strings.Join(t.Labels, ","), fmt.Sprint(t.IsDefault), fmt.Sprint(t.Draining), t.UpdatedAt})
 			}
 			tw.Render()
 		case Target:
-			fmt.Printf("%s (%s) default=%v\n", x.Key, x.Driver, x.IsDefault)
+			fmt.Printf("%s (%s) default=%v draining=%v\n", x.Key, x.Driver, x.IsDefault, x.Draining)
 			fmt.Println("Labels:", strings.Join(x.Labels, ","))
 			fmt.Println("DSN:", x.Dsn)
 		default:
@@ -138,8 +141,18 @@ var targetsCmd = &cobra.Command{
 var listTargetsCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all targets",
+	Example: `  fieldctl targets list --selector 'region=~"^eu-",tier!=free'
+  fieldctl targets list --selector '(env=prod | env=stg) && !deprecated'`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		resp, err := apiRequest("GET", "/admin/targets", nil, "")
+		path := "/admin/targets"
+		if targetSelector != "" {
+			// Report syntax errors with their position before calling the API.
+			if _, err := sdk.ParseQuery(targetSelector); err != nil {
+				return err
+			}
+			path += "?selector=" + url.QueryEscape(targetSelector)
+		}
+		resp, err := apiRequest("GET", path, nil, "")
 		if err != nil {
 			return err
 		}
@@ -317,6 +330,44 @@ var setDefaultTargetCmd = &cobra.Command{
 	},
 }
 
+var drainTargetCmd = &cobra.Command{
+	Use:   "drain [key]",
+	Short: "Stop routing new work to a target",
+	Long: `Put a target in maintenance. Clients stop selecting it, close its pool once
+in-flight operations finish, and reject calls pinned to it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setTargetDraining(args[0], "drain")
+	},
+}
+
+var undrainTargetCmd = &cobra.Command{
+	Use:   "undrain [key]",
+	Short: "Route work to a drained target again",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setTargetDraining(args[0], "undrain")
+	},
+}
+
+func setTargetDraining(key, action string) error {
+	path := fmt.Sprintf("/admin/targets/%s/%s", url.PathEscape(key), action)
+	resp, err := apiRequest("POST", path, nil, targetIfMatch)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("error: %s", resp.Status)
+	}
+	if action == "drain" {
+		fmt.Println("Draining:", key)
+	} else {
+		fmt.Println("Undrained:", key)
+	}
+	return nil
+}
+
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Show current version & default target",
@@ -361,10 +412,14 @@ func init() {
 		patchTargetCmd,
 		deleteTargetCmd,
 		setDefaultTargetCmd,
+		drainTargetCmd,
+		undrainTargetCmd,
 		versionCmd,
 		bumpVersionCmd,
 	)
 
+	listTargetsCmd.Flags().StringVar(&targetSelector, "selector", "", "only list targets matching this label query")
+
 	cmdWithTargetFlags := []*cobra.Command{createTargetCmd, updateTargetCmd, patchTargetCmd}
 	for _, c := range cmdWithTargetFlags {
 		c.Flags().StringVar(&targetKey, "key", "", "Target key")
@@ -379,7 +434,7 @@ func init() {
 		c.Flags().BoolVar(&targetIsDefault, "default", false, "Set as default")
 	}
 
-	for _, c := range []*cobra.Command{updateTargetCmd, patchTargetCmd, deleteTargetCmd, setDefaultTargetCmd} {
+	for _, c := range []*cobra.Command{updateTargetCmd, patchTargetCmd, deleteTargetCmd, setDefaultTargetCmd, drainTargetCmd, undrainTargetCmd} {
 		c.Flags().StringVar(&targetIfMatch, "if-match", "", "ETag value")
 	}
 }
@@ -21,6 +21,7 @@ import (
 	metapkg "github.com/faciam-dev/gcfm/meta"
 	"github.com/faciam-dev/gcfm/pkg/audit"
 	"github.com/faciam-dev/gcfm/pkg/schema"
+	"github.com/faciam-dev/gcfm/sdk"
 )
 
 // Deps defines external dependencies for handlers.
@@ -28,44 +29,60 @@ type Deps struct {
 	Meta metapkg.MetaStore
 	Rec  *audit.Recorder
 	Auth func(scopes ...string) func(huma.Context, func(huma.Context))
+	// Health reports the state of the targets probed by the API server. It
+	// is nil when probing is disabled.
+	Health func() []sdk.TargetHealth
+	// Notify, when set, is called with the new targets version after each
+	// committed change.
+	Notify func(ctx context.Context, version string)
 }
 
-// RegisterRoutes registers the admin target management routes.
+// RegisterRoutes registers the admin target management routes. Reads
+// require the admin:targets scope and changes admin:targets:write.
 func RegisterRoutes(api huma.API, deps Deps) {
-	r := huma.NewGroup(api, "/admin/targets")
-	if deps.Auth != nil {
-		r.UseMiddleware(deps.Auth("admin:targets"))
-	}
+	read := func(o *huma.Operation) { deps.requireScope(o, "admin:targets") }
+	write := func(o *huma.Operation) { deps.requireScope(o, "admin:targets:write") }
 
-	huma.Get(r, "/", listHandler(deps))
+	r := huma.NewGroup(api, "/admin/targets")
+	huma.Get(r, "/", listHandler(deps), read)
 	huma.Post(r, "/", createHandler(deps), func(o *huma.Operation) {
 		o.DefaultStatus = http.StatusCreated
-		o.Middlewares = append(o.Middlewares, deps.Auth("admin:targets:write"))
-	})
-	huma.Get(r, "/{key}", getHandler(deps))
-	huma.Put(r, "/{key}", putHandler(deps), func(o *huma.Operation) {
-		o.Middlewares = append(o.Middlewares, deps.Auth("admin:targets:write"))
-	})
-	huma.Patch(r, "/{key}", patchHandler(deps), func(o *huma.Operation) {
-		o.Middlewares = append(o.Middlewares, deps.Auth("admin:targets:write"))
-	})
+	}, write)
+	huma.Get(r, "/{key}", getHandler(deps), read)
+	huma.Get(r, "/{key}/health", healthHandler(deps), read)
+	huma.Put(r, "/{key}", putHandler(deps), write)
+	huma.Patch(r, "/{key}", patchHandler(deps), write)
 	huma.Delete(r, "/{key}", deleteHandler(deps), func(o *huma.Operation) {
 		o.DefaultStatus = http.StatusNoContent
-		o.Middlewares = append(o.Middlewares, deps.Auth("admin:targets:write"))
-	})
+	}, write)
 	huma.Post(r, "/{key}/default", setDefaultHandler(deps), func(o *huma.Operation) {
 		o.DefaultStatus = http.StatusNoContent
-		o.Middlewares = append(o.Middlewares, deps.Auth("admin:targets:write"))
-	})
+	}, write)
+	huma.Post(r, "/{key}/drain", drainHandler(deps, true), func(o *huma.Operation) {
+		o.DefaultStatus = http.StatusNoContent
+	}, write)
+	huma.Post(r, "/{key}/undrain", drainHandler(deps, false), func(o *huma.Operation) {
+		o.DefaultStatus = http.StatusNoContent
+	}, write)
 
 	v := huma.NewGroup(api, "/admin/targets/version")
-	if deps.Auth != nil {
-		v.UseMiddleware(deps.Auth("admin:targets"))
+	huma.Get(v, "", getVersionHandler(deps), read)
+	huma.Post(v, "/bump", bumpVersionHandler(deps), write)
+}
+
+// requireScope makes o require scope. The scope is recorded in the
+// operation metadata, which makes RBAC defer to the scope check, so both are
+// only set together.
+func (d Deps) requireScope(o *huma.Operation, scope string) {
+	if d.Auth == nil {
+		return
 	}
-	huma.Get(v, "", getVersionHandler(deps))
-	huma.Post(v, "/bump", bumpVersionHandler(deps), func(o *huma.Operation) {
-		o.Middlewares = append(o.Middlewares, deps.Auth("admin:targets:write"))
-	})
+	o.Middlewares = append(o.Middlewares, d.Auth(scope))
+	if o.Metadata == nil {
+		o.Metadata = map[string]any{}
+	}
+	scopes, _ := o.Metadata[middleware.ScopesMetadataKey].([]string)
+	o.Metadata[middleware.ScopesMetadataKey] = append(scopes, scope)
 }
 
 // ---- handler wrapper ----
@@ -88,6 +105,11 @@ func getHandler(d Deps) func(context.Context, *targetKeyParams) (*targetOutput,
 	return h.get
 }
 
+func healthHandler(d Deps) func(context.Context, *targetKeyParams) (*targetHealthOutput, error) {
+	h := handler{d}
+	return h.health
+}
+
 func putHandler(d Deps) func(context.Context, *targetPutInput) (*targetOutput, error) {
 	h := handler{d}
 	return h.put
@@ -108,6 +130,13 @@ func setDefaultHandler(d Deps) func(context.Context, *targetDeleteParams) (*etag
 	return h.setDefault
 }
 
+func drainHandler(d Deps, draining bool) func(context.Context, *targetDeleteParams) (*etagOnly, error) {
+	h := handler{d}
+	return func(ctx context.Context, p *targetDeleteParams) (*etagOnly, error) {
+		return h.setDraining(ctx, p, draining)
+	}
+}
+
 func getVersionHandler(d Deps) func(context.Context, *struct{}) (*versionOutput, error) {
 	h := handler{d}
 	return func(ctx context.Context, _ *struct{}) (*versionOutput, error) { return h.getVersion(ctx) }
@@ -120,10 +149,11 @@ func bumpVersionHandler(d Deps) func(context.Context, *ifMatchHeader) (*versionB
 
 // ---- parameter & output types ----
 type targetListParams struct {
-	Label  []string `query:"label" explode:"true"`
-	Q      string   `query:"q"`
-	Limit  int      `query:"limit" default:"50" minimum:"1" maximum:"200"`
-	Cursor string   `query:"cursor"`
+	Label    []string `query:"label" explode:"true" deprecated:"true" doc:"Labels every target must have. Deprecated: use selector; the two cannot be combined."`
+	Selector string   `query:"selector" doc:"Label query, e.g. region=~\"^eu-\",tier!=free"`
+	Q        string   `query:"q"`
+	Limit    int      `query:"limit" default:"50" minimum:"1" maximum:"200"`
+	Cursor   string   `query:"cursor"`
 }
 
 type targetListOutput struct {
@@ -145,6 +175,10 @@ type targetKeyParams struct {
 	Key string `path:"key"`
 }
 
+type targetHealthOutput struct {
+	Body schema.TargetHealth
+}
+
 type targetPutInput struct {
 	Key     string `path:"key"`
 	IfMatch string `header:"If-Match"`
@@ -231,6 +265,9 @@ func mapStoreError(err error) error {
 	if err == nil {
 		return nil
 	}
+	if errors.Is(err, metapkg.ErrTargetNotFound) {
+		return huma.Error404NotFound("not found")
+	}
 	if isConflictError(err) {
 		return huma.Error409Conflict("conflict")
 	}
@@ -268,6 +305,27 @@ func toSchema(r metapkg.TargetRowWithLabels) schema.Target {
 		ConnMaxIdleMs: int(r.ConnMaxIdle / time.Millisecond),
 		ConnMaxLifeMs: int(r.ConnMaxLife / time.Millisecond),
 		IsDefault:     r.IsDefault,
+		Draining:      r.Draining,
+	}
+}
+
+func toHealthSchema(th sdk.TargetHealth) schema.TargetHealth {
+	at := func(t time.Time) *time.Time {
+		if t.IsZero() {
+			return nil
+		}
+		return &t
+	}
+	return schema.TargetHealth{
+		Key:            th.Key,
+		State:          th.State,
+		Failures:       th.Failures,
+		OpenUntil:      at(th.OpenUntil),
+		LastError:      th.LastError,
+		LastErrorAt:    at(th.LastErrorAt),
+		LastSuccessAt:  at(th.LastSuccess),
+		LastProbeAt:    at(th.LastProbe),
+		ProbeLatencyMs: th.ProbeLatency.Milliseconds(),
 	}
 }
 
@@ -321,13 +379,20 @@ func (h handler) find(ctx context.Context, key string) (*metapkg.TargetRowWithLa
 
 // ---- handler implementations ----
 func (h handler) list(ctx context.Context, p *targetListParams) (*targetListOutput, error) {
+	if len(p.Label) > 0 && p.Selector != "" {
+		return nil, huma.Error422UnprocessableEntity("label and selector cannot be combined; use selector")
+	}
+	sel, err := sdk.ParseQuery(p.Selector)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
 	rows, ver, _, err := h.Meta.ListTargets(ctx)
 	if err != nil {
 		return nil, err
 	}
 	filtered := make([]metapkg.TargetRowWithLabels, 0, len(rows))
 	for _, r := range rows {
-		if !matchLabels(r.Labels, p.Label) {
+		if !matchLabels(r.Labels, p.Label) || !sel.Matches(r.Labels) {
 			continue
 		}
 		if p.Q != "" && !matchQuery(r, p.Q) {
@@ -372,6 +437,18 @@ func (h handler) get(ctx context.Context, p *targetKeyParams) (*targetOutput, er
 	return nil, huma.Error404NotFound("not found")
 }
 
+func (h handler) health(_ context.Context, p *targetKeyParams) (*targetHealthOutput, error) {
+	if h.Health == nil {
+		return nil, huma.NewError(http.StatusNotImplemented, "target health probing is disabled")
+	}
+	for _, th := range h.Health() {
+		if th.Key == p.Key {
+			return &targetHealthOutput{Body: toHealthSchema(th)}, nil
+		}
+	}
+	return nil, huma.Error404NotFound("not found")
+}
+
 func (h handler) create(ctx context.Context, in *targetCreateInput) (*targetOutput, error) {
 	if err := validateDSN(in.Body.Driver, in.Body.DSN); err != nil {
 		return nil, huma.Error400BadRequest(err.Error())
@@ -390,16 +467,10 @@ func (h handler) create(ctx context.Context, in *targetCreateInput) (*targetOutp
 	if err != nil {
 		return nil, err
 	}
+	h.changed(ctx, newVer)
 	actor := middleware.UserFromContext(ctx)
 	if h.Rec != nil {
-		_ = h.Rec.WriteJSON(ctx, actor, "admin.targets.upsert", map[string]any{
-			"actor":   actor,
-			"key":     in.Body.Key,
-			"before":  nil,
-			"after":   in.Body,
-			"labels":  in.Body.Labels,
-			"version": newVer,
-		})
+		h.record(ctx, actor, "admin.targets.upsert", in.Body.Key, nil, row, newVer)
 	}
 	return &targetOutput{ETag: newVer, Body: toSchema(*row)}, nil
 }
@@ -423,21 +494,16 @@ func (h handler) put(ctx context.Context, in *targetPutInput) (*targetOutput, er
 	}
 	if existing != nil {
 		in.Body.IsDefault = existing.IsDefault
+		in.Body.Draining = existing.Draining
 	}
 	row, newVer, err := createOrUpsert(ctx, h.Meta, in.Body)
 	if err != nil {
 		return nil, err
 	}
+	h.changed(ctx, newVer)
 	actor := middleware.UserFromContext(ctx)
 	if h.Rec != nil {
-		_ = h.Rec.WriteJSON(ctx, actor, "admin.targets.upsert", map[string]any{
-			"actor":   actor,
-			"key":     in.Body.Key,
-			"before":  existing,
-			"after":   in.Body,
-			"labels":  in.Body.Labels,
-			"version": newVer,
-		})
+		h.record(ctx, actor, "admin.targets.upsert", in.Body.Key, existing, row, newVer)
 	}
 	return &targetOutput{ETag: newVer, Body: toSchema(*row)}, nil
 }
@@ -513,16 +579,10 @@ func (h handler) patch(ctx context.Context, in *targetPatchInput) (*targetOutput
 	if err := tx.Commit(); err != nil {
 		return nil, mapStoreError(err)
 	}
+	h.changed(ctx, newVer)
 	actor := middleware.UserFromContext(ctx)
 	if h.Rec != nil {
-		_ = h.Rec.WriteJSON(ctx, actor, "admin.targets.patch", map[string]any{
-			"actor":   actor,
-			"key":     row.Key,
-			"before":  existing,
-			"after":   toSchema(row),
-			"labels":  row.Labels,
-			"version": newVer,
-		})
+		h.record(ctx, actor, "admin.targets.patch", row.Key, existing, &row, newVer)
 	}
 	return &targetOutput{ETag: newVer, Body: toSchema(row)}, nil
 }
@@ -554,15 +614,10 @@ func (h handler) del(ctx context.Context, p *targetDeleteParams) (*etagOnly, err
 	if err := tx.Commit(); err != nil {
 		return nil, mapStoreError(err)
 	}
+	h.changed(ctx, newVer)
 	actor := middleware.UserFromContext(ctx)
 	if h.Rec != nil {
-		_ = h.Rec.WriteJSON(ctx, actor, "admin.targets.delete", map[string]any{
-			"actor":   actor,
-			"key":     p.Key,
-			"before":  existing,
-			"after":   nil,
-			"version": newVer,
-		})
+		h.record(ctx, actor, "admin.targets.delete", p.Key, existing, nil, newVer)
 	}
 	return &etagOnly{ETag: newVer}, nil
 }
@@ -594,13 +649,55 @@ func (h handler) setDefault(ctx context.Context, p *targetDeleteParams) (*etagOn
 	if err := tx.Commit(); err != nil {
 		return nil, mapStoreError(err)
 	}
+	h.changed(ctx, newVer)
 	actor := middleware.UserFromContext(ctx)
 	if h.Rec != nil {
-		_ = h.Rec.WriteJSON(ctx, actor, "admin.targets.set-default", map[string]any{
-			"actor":   actor,
-			"key":     p.Key,
-			"version": newVer,
-		})
+		after := *existing
+		after.IsDefault = true
+		h.record(ctx, actor, "admin.targets.set-default", p.Key, existing, &after, newVer)
+	}
+	return &etagOnly{ETag: newVer}, nil
+}
+
+// setDraining moves a target in or out of maintenance. Clients stop routing
+// new work to a draining target and close its pool once idle.
+func (h handler) setDraining(ctx context.Context, p *targetDeleteParams, draining bool) (*etagOnly, error) {
+	existing, ver, err := h.find(ctx, p.Key)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, huma.Error404NotFound("not found")
+	}
+	if err := checkIfMatch(p.IfMatch, ver); err != nil {
+		return nil, err
+	}
+	tx, err := h.Meta.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Meta.SetTargetDraining(ctx, tx, p.Key, draining); err != nil {
+		rollbackIfNeeded(tx)
+		return nil, mapStoreError(err)
+	}
+	newVer, err := h.Meta.BumpTargetsVersion(ctx, tx)
+	if err != nil {
+		rollbackIfNeeded(tx)
+		return nil, mapStoreError(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, mapStoreError(err)
+	}
+	h.changed(ctx, newVer)
+	actor := middleware.UserFromContext(ctx)
+	if h.Rec != nil {
+		action := "admin.targets.undrain"
+		if draining {
+			action = "admin.targets.drain"
+		}
+		after := *existing
+		after.Draining = draining
+		h.record(ctx, actor, action, p.Key, existing, &after, newVer)
 	}
 	return &etagOnly{ETag: newVer}, nil
 }
@@ -636,11 +733,15 @@ func (h handler) bumpVersion(ctx context.Context, in *ifMatchHeader) (*versionBo
 	if err := tx.Commit(); err != nil {
 		return nil, mapStoreError(err)
 	}
+	h.changed(ctx, newVer)
 	actor := middleware.UserFromContext(ctx)
 	if h.Rec != nil {
-		_ = h.Rec.WriteJSON(ctx, actor, "admin.targets.bump-version", map[string]any{
-			"actor":   actor,
-			"version": newVer,
+		_ = h.Rec.Record(ctx, audit.Event{
+			ResourceType: audit.ResourceTarget,
+			Action:       "admin.targets.bump-version",
+			Actor:        actor,
+			Before:       map[string]any{"version": ver},
+			After:        map[string]any{"version": newVer},
 		})
 	}
 	out := &versionBodyOutput{ETag: newVer}
@@ -648,6 +749,39 @@ func (h handler) bumpVersion(ctx context.Context, in *ifMatchHeader) (*versionBo
 	return out, nil
 }
 
+// changed passes a committed targets version to Notify.
+func (h handler) changed(ctx context.Context, version string) {
+	if h.Notify != nil {
+		h.Notify(ctx, version)
+	}
+}
+
+// auditTarget is the audit representation of a target. DSNs carry
+// credentials and are never written to the audit log.
+type auditTarget struct {
+	schema.Target
+	DSN     string `json:"dsn,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// record writes a target change to the audit log.
+func (h handler) record(ctx context.Context, actor, action, key string, before, after *metapkg.TargetRowWithLabels, version string) {
+	view := func(r *metapkg.TargetRowWithLabels, version string) any {
+		if r == nil {
+			return nil
+		}
+		return auditTarget{Target: toSchema(*r), Version: version}
+	}
+	_ = h.Rec.Record(ctx, audit.Event{
+		ResourceType: audit.ResourceTarget,
+		ResourceID:   key,
+		Action:       action,
+		Actor:        actor,
+		Before:       view(before, ""),
+		After:        view(after, version),
+	})
+}
+
 // createOrUpsert performs target upsert and optional default setting.
 func createOrUpsert(ctx context.Context, m metapkg.MetaStore, in schema.TargetInput) (*metapkg.TargetRowWithLabels, string, error) {
 	tx, err := m.BeginTx(ctx, nil)
@@ -662,6 +796,7 @@ func createOrUpsert(ctx context.Context, m metapkg.MetaStore, in schema.TargetIn
 		ConnMaxIdle: time.Millisecond * time.Duration(in.ConnMaxIdleMs),
 		ConnMaxLife: time.Millisecond * time.Duration(in.ConnMaxLifeMs),
 		IsDefault:   in.IsDefault,
+		Draining:    in.Draining,
 	}
 	if err := m.UpsertTarget(ctx, tx, row, in.Labels); err != nil {
 		return nil, "", mapStoreError(err)
@@ -671,6 +806,11 @@ func createOrUpsert(ctx context.Context, m metapkg.MetaStore, in schema.TargetIn
 			return nil, "", mapStoreError(err)
 		}
 	}
+	if in.Draining {
+		if err := m.SetTargetDraining(ctx, tx, in.Key, true); err != nil {
+			return nil, "", mapStoreError(err)
+		}
+	}
 	ver, err := m.BumpTargetsVersion(ctx, tx)
 	if err != nil {
 		return nil, "", mapStoreError(err)
@@ -13,7 +13,6 @@ import (
 	"time"
 
 	"github.com/faciam-dev/goquent/orm/driver"
-	"github.com/faciam-dev/goquent/orm/query"
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/faciam-dev/gcfm/internal/auditlog"
@@ -27,6 +26,8 @@ type AuditHandler struct {
 	DB          *sql.DB
 	Dialect     driver.Dialect
 	TablePrefix string
+	// Fields applies reverts through the custom field endpoints.
+	Fields fieldApplier
 }
 
 // auditLogOverfetchMultiplier controls how many extra rows are fetched when
@@ -70,16 +71,19 @@ func (h *AuditHandler) getDiff(ctx context.Context,
 }
 
 type auditListParams struct {
-	Limit      int         `query:"limit"`
-	Cursor     string      `query:"cursor"` // base64("RFC3339Nano:id")
-	Action     string      `query:"action"` // "add,update" など
-	Actor      string      `query:"actor"`
-	Table      string      `query:"table"`
-	Column     string      `query:"column"`
-	From       string      `query:"from"` // ISO
-	To         string      `query:"to"`   // ISO (閉区間上端は < To+1day にします)
-	MinChanges optionalInt `query:"min_changes"`
-	MaxChanges optionalInt `query:"max_changes"`
+	Limit  int    `query:"limit"`
+	Cursor string `query:"cursor"` // base64("RFC3339Nano:id")
+	Action string `query:"action"` // "add,update" など
+	Actor  string `query:"actor"`
+	Table  string `query:"table"`
+	Column string `query:"column"`
+	// ResourceType filters by audited resource, e.g. "role,target".
+	ResourceType string      `query:"resource_type"`
+	ResourceID   string      `query:"resource_id"`
+	From         string      `query:"from"` // ISO
+	To           string      `query:"to"`   // ISO (閉区間上端は < To+1day にします)
+	MinChanges   optionalInt `query:"min_changes"`
+	MaxChanges   optionalInt `query:"max_changes"`
 	// Compatibility aliases for camelCase parameters
 	MinChangesAlias optionalInt `query:"minChanges" json:"-" huma:"deprecated"`
 	MaxChangesAlias optionalInt `query:"maxChanges" json:"-" huma:"deprecated"`
@@ -142,16 +146,20 @@ func (p *auditListParams) EffMax() *int {
 }
 
 type AuditDTO struct {
-	ID          int64           `json:"id"`
-	Actor       string          `json:"actor"`
-	Action      string          `json:"action"`
-	TableName   string          `json:"tableName"`
-	ColumnName  string          `json:"columnName"`
-	AppliedAt   time.Time       `json:"appliedAt"`
-	BeforeJson  json.RawMessage `json:"beforeJson"`
-	AfterJson   json.RawMessage `json:"afterJson"`
-	Summary     string          `json:"summary,omitempty"`
-	ChangeCount int             `json:"changeCount"`
+	ID           int64           `json:"id"`
+	Actor        string          `json:"actor"`
+	Action       string          `json:"action"`
+	TableName    string          `json:"tableName"`
+	ColumnName   string          `json:"columnName"`
+	ResourceType string          `json:"resourceType,omitempty"`
+	ResourceID   string          `json:"resourceId,omitempty"`
+	RequestID    string          `json:"requestId,omitempty"`
+	RevertOf     int64           `json:"revertOf,omitempty"`
+	AppliedAt    time.Time       `json:"appliedAt"`
+	BeforeJson   json.RawMessage `json:"beforeJson"`
+	AfterJson    json.RawMessage `json:"afterJson"`
+	Summary      string          `json:"summary,omitempty"`
+	ChangeCount  int             `json:"changeCount"`
 }
 
 type auditListOutput struct {
@@ -186,6 +194,26 @@ func RegisterAudit(api huma.API, h *AuditHandler) {
 		Tags:        []string{"Audit"},
 	}, h.get)
 
+	huma.Register(api, huma.Operation{
+		OperationID: "exportAuditLogs",
+		Method:      http.MethodGet,
+		Path:        "/v1/audit-logs/export",
+		Summary:     "Export audit logs",
+		Description: "Streams every audit log matching the list filters as JSON lines or CSV, newest first.",
+		Tags:        []string{"Audit"},
+	}, h.export)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "getAuditLogStats",
+		Method:      http.MethodGet,
+		Path:        "/v1/audit-logs/stats",
+		Summary:     "Aggregate audit logs",
+		Description: "Counts the audit logs matching the list filters per group and time bucket. " +
+			"Grouping by table and column lists the most churned fields first.",
+		Tags:   []string{"Audit"},
+		Errors: []int{http.StatusUnprocessableEntity},
+	}, h.stats)
+
 	// Register diff endpoint
 	huma.Register(api, huma.Operation{
 		OperationID: "getAuditDiff",
@@ -194,6 +222,8 @@ func RegisterAudit(api huma.API, h *AuditHandler) {
 		Summary:     "Get unified diff for an audit log",
 		Tags:        []string{"Audit"},
 	}, h.getDiff)
+
+	registerAuditRevert(api, h)
 }
 
 func (h *AuditHandler) list(ctx context.Context, p *auditListParams) (_ *auditListOutput, err error) {
@@ -203,8 +233,6 @@ func (h *AuditHandler) list(ctx context.Context, p *auditListParams) (_ *auditLi
 		}
 	}()
 
-	tid := tenant.FromContext(ctx)
-
 	limit := p.Limit
 	if limit <= 0 {
 		limit = 50
@@ -213,111 +241,10 @@ func (h *AuditHandler) list(ctx context.Context, p *auditListParams) (_ *auditLi
 		limit = 200
 	}
 
-	min := p.EffMin()
-	max := p.EffMax()
-	pass := func(v int) bool {
-		if min != nil && v < *min {
-			return false
-		}
-		if max != nil && v > *max {
-			return false
-		}
-		return true
-	}
-
-	tbl := h.TablePrefix + "audit_logs"
-	users := h.TablePrefix + "users"
-
-	coalesceBefore := "CAST(COALESCE(l.before_json, JSON_OBJECT()) AS CHAR)"
-	coalesceAfter := "CAST(COALESCE(l.after_json , JSON_OBJECT()) AS CHAR)"
-	isPg := false
-	if _, ok := h.Dialect.(driver.PostgresDialect); ok {
-		coalesceBefore = "COALESCE(l.before_json, '{}'::jsonb)::text"
-		coalesceAfter = "COALESCE(l.after_json , '{}'::jsonb)::text"
-		isPg = true
-	}
-
-	actorSub := "(SELECT username FROM " + users + " u WHERE "
-	if isPg {
-		actorSub += "u.id::text = l.actor"
-	} else {
-		actorSub += "u.id = CAST(l.actor AS UNSIGNED)"
-	}
-	actorSub += ")"
-
-	q := query.New(h.DB, tbl+" as l", h.Dialect).
-		Select("l.id").
-		SelectRaw("COALESCE("+actorSub+", l.actor) as actor").
-		Select("l.action").
-		SelectRaw("COALESCE(l.table_name, '') as table_name").
-		SelectRaw("COALESCE(l.column_name, '') as column_name").
-		SelectRaw(coalesceBefore+" as before_json").
-		SelectRaw(coalesceAfter+" as after_json").
-		Select("l.added_count", "l.removed_count", "l.change_count", "l.applied_at").
-		Where("l.tenant_id", tid)
-
-	if p.Actor != "" {
-		q.Where("l.actor", p.Actor)
-	}
-	if p.Table != "" {
-		q.Where("l.table_name", p.Table)
-	}
-	if p.Column != "" {
-		q.Where("l.column_name", p.Column)
-	}
-	if p.Action != "" {
-		acts := []string{}
-		for _, a := range strings.Split(p.Action, ",") {
-			a = strings.TrimSpace(a)
-			if a == "" {
-				continue
-			}
-			acts = append(acts, a)
-		}
-		if len(acts) > 0 {
-			q.WhereIn("l.action", acts)
-		}
-	}
-	if p.From != "" {
-		if t, err := time.Parse(time.RFC3339, p.From); err == nil {
-			q.Where("l.applied_at", ">=", t)
-		}
-	}
-	if p.To != "" {
-		if t, err := time.Parse(time.RFC3339, p.To); err == nil {
-			q.Where("l.applied_at", "<", t.Add(24*time.Hour))
-		}
-	}
-	if p.Cursor != "" {
-		if ts, id, err := decodeCursor(p.Cursor); err == nil {
-			q.WhereGroup(func(g *query.Query) {
-				g.Where("l.applied_at", "<", ts)
-				g.OrWhereGroup(func(g2 *query.Query) {
-					g2.Where("l.applied_at", "=", ts)
-					g2.Where("l.id", "<", id)
-				})
-			})
-		}
-	}
-
-	q.OrderBy("l.applied_at", "desc").OrderBy("l.id", "desc").
-		Limit(limit*auditLogOverfetchMultiplier + 1)
-
-	type dbRow struct {
-		ID           int64  `db:"id"`
-		Actor        string `db:"actor"`
-		Action       string `db:"action"`
-		TableName    string `db:"table_name"`
-		ColumnName   string `db:"column_name"`
-		BeforeJSON   []byte `db:"before_json"`
-		AfterJSON    []byte `db:"after_json"`
-		AddedCount   int    `db:"added_count"`
-		RemovedCount int    `db:"removed_count"`
-		ChangeCount  int    `db:"change_count"`
-		AppliedAt    any    `db:"applied_at"`
-	}
-	var rs []dbRow
-	if err := q.WithContext(ctx).Get(&rs); err != nil {
+	f := p.filter(tenant.FromContext(ctx))
+	repo := auditlog.Repo{DB: h.DB, Dialect: h.Dialect, TablePrefix: h.TablePrefix}
+	rs, err := repo.List(ctx, f, limit*auditLogOverfetchMultiplier+1)
+	if err != nil {
 		logger.L.Error("query audit logs", "err", err)
 		return nil, err
 	}
@@ -329,19 +256,18 @@ func (h *AuditHandler) list(ctx context.Context, p *auditListParams) (_ *auditLi
 
 	for _, r := range rs {
 		var it AuditDTO
-		t, err := ParseAuditTime(r.AppliedAt)
-		if err != nil {
-			logger.L.Error("parse audit time", "err", err, "value", r.AppliedAt)
-			return nil, err
-		}
 		it.ID = r.ID
 		it.Actor = r.Actor
 		it.Action = r.Action
 		it.TableName = r.TableName
 		it.ColumnName = r.ColumnName
-		it.AppliedAt = t
-		it.BeforeJson = append([]byte(nil), r.BeforeJSON...)
-		it.AfterJson = append([]byte(nil), r.AfterJSON...)
+		it.ResourceType = r.ResourceType
+		it.ResourceID = r.ResourceID
+		it.RequestID = r.RequestID
+		it.RevertOf = r.RevertOf
+		it.AppliedAt = r.AppliedAt
+		it.BeforeJson = r.BeforeJSON
+		it.AfterJson = r.AfterJSON
 		if r.ChangeCount == 0 && it.Action != "snapshot" && it.Action != "rollback" {
 			it.Summary = "diff unavailable"
 		} else if it.Action == "snapshot" || it.Action == "rollback" {
@@ -351,7 +277,7 @@ func (h *AuditHandler) list(ctx context.Context, p *auditListParams) (_ *auditLi
 		}
 		it.ChangeCount = r.ChangeCount
 
-		if !pass(r.ChangeCount) {
+		if !f.Pass(r.ChangeCount) {
 			continue
 		}
 		if len(items) < limit {
@@ -373,6 +299,101 @@ func (h *AuditHandler) list(ctx context.Context, p *auditListParams) (_ *auditLi
 	return out, nil
 }
 
+type auditExportParams struct {
+	auditListParams
+	Format string `query:"format" enum:"jsonl,csv" default:"jsonl"`
+}
+
+func (h *AuditHandler) export(ctx context.Context, p *auditExportParams) (*huma.StreamResponse, error) {
+	f := p.filter(tenant.FromContext(ctx))
+	format := p.Format
+	repo := auditlog.Repo{DB: h.DB, Dialect: h.Dialect, TablePrefix: h.TablePrefix}
+	return &huma.StreamResponse{Body: func(hctx huma.Context) {
+		hctx.SetHeader("Content-Type", auditlog.ContentType(format))
+		hctx.SetHeader("Content-Disposition", fmt.Sprintf("attachment; filename=audit-logs.%s", format))
+		if _, err := repo.Export(hctx.Context(), hctx.BodyWriter(), format, f); err != nil {
+			logger.L.Error("export audit logs", "err", err)
+		}
+	}}, nil
+}
+
+type auditStatsParams struct {
+	auditListParams
+	// GroupBy is a comma separated list of actor, table, column, action and
+	// resource_type.
+	GroupBy string `query:"group_by" doc:"Comma separated dimensions: actor, table, column, action, resource_type"`
+	Bucket  string `query:"bucket" enum:"hour,day,week,month" doc:"Group by truncated applied_at"`
+}
+
+type auditStatsOutput struct {
+	Body struct {
+		Items []auditlog.Stat `json:"items"`
+		// Truncated reports that the limit left out further groups.
+		Truncated bool `json:"truncated"`
+	}
+}
+
+func (h *AuditHandler) stats(ctx context.Context, p *auditStatsParams) (*auditStatsOutput, error) {
+	limit := p.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	sq := auditlog.StatsQuery{
+		Filter:  p.filter(tenant.FromContext(ctx)),
+		GroupBy: splitList(p.GroupBy),
+		Bucket:  p.Bucket,
+		Limit:   limit,
+	}
+	if err := sq.Validate(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	repo := auditlog.Repo{DB: h.DB, Dialect: h.Dialect, TablePrefix: h.TablePrefix}
+	items, truncated, err := repo.Stats(ctx, sq)
+	if err != nil {
+		logger.L.Error("audit log stats", "err", err)
+		return nil, err
+	}
+	out := &auditStatsOutput{}
+	out.Body.Items = items
+	out.Body.Truncated = truncated
+	return out, nil
+}
+
+// filter converts the query parameters into a repository filter for tenant
+// tid. Malformed dates and cursors are ignored.
+func (p *auditListParams) filter(tid string) auditlog.Filter {
+	f := auditlog.Filter{
+		Tenant:        tid,
+		Actions:       splitList(p.Action),
+		Actor:         p.Actor,
+		Table:         p.Table,
+		Column:        p.Column,
+		ResourceTypes: splitList(p.ResourceType),
+		ResourceID:    p.ResourceID,
+		MinChanges:    p.EffMin(),
+		MaxChanges:    p.EffMax(),
+	}
+	if p.From != "" {
+		if t, err := time.Parse(time.RFC3339, p.From); err == nil {
+			f.From = t
+		}
+	}
+	if p.To != "" {
+		if t, err := time.Parse(time.RFC3339, p.To); err == nil {
+			f.To = t.Add(24 * time.Hour)
+		}
+	}
+	if p.Cursor != "" {
+		if ts, id, err := decodeCursor(p.Cursor); err == nil {
+			f.After = &auditlog.Cursor{AppliedAt: ts, ID: id}
+		}
+	}
+	return f
+}
+
 func (h *AuditHandler) get(ctx context.Context, p *auditGetParams) (*auditGetOutput, error) {
 	repo := auditlog.Repo{DB: h.DB, Dialect: h.Dialect, TablePrefix: h.TablePrefix}
 	rec, err := repo.FindByID(ctx, p.ID)
@@ -393,6 +414,11 @@ func (h *AuditHandler) get(ctx context.Context, p *auditGetParams) (*auditGetOut
 		TableName:  rec.TableName,
 		ColumnName: rec.ColumnName,
 		AppliedAt:  t,
+
+		ResourceType: rec.ResourceType,
+		ResourceID:   rec.ResourceID,
+		RequestID:    rec.RequestID,
+		RevertOf:     rec.RevertOf.Int64,
 	}
 	enrichAuditLog(&log, rec.BeforeJSON, rec.AfterJSON, rec.AddedCount, rec.RemovedCount)
 	return &auditGetOutput{Body: log}, nil
@@ -402,29 +428,7 @@ func (h *AuditHandler) get(ctx context.Context, p *auditGetParams) (*auditGetOut
 // Drivers like the MySQL driver may return []byte or string for TIMESTAMP
 // columns when parseTime is disabled.
 func ParseAuditTime(v any) (time.Time, error) {
-	if v == nil {
-		return time.Time{}, nil
-	}
-	switch t := v.(type) {
-	case time.Time:
-		return t, nil
-	case []byte:
-		return parseAuditTimeString(string(t))
-	case string:
-		return parseAuditTimeString(t)
-	default:
-		return time.Time{}, fmt.Errorf("unsupported time type %T", v)
-	}
-}
-
-func parseAuditTimeString(s string) (time.Time, error) {
-	layouts := []string{time.RFC3339Nano, "2006-01-02 15:04:05.000000000", "2006-01-02 15:04:05", time.RFC3339}
-	for _, l := range layouts {
-		if ts, err := time.Parse(l, s); err == nil {
-			return ts, nil
-		}
-	}
-	return time.Time{}, fmt.Errorf("cannot parse time: %s", s)
+	return auditlog.ParseTime(v)
 }
 
 func enrichAuditLog(l *schema.AuditLog, beforeJSON, afterJSON sql.NullString, addCnt, delCnt int) {
@@ -444,6 +448,17 @@ func enrichAuditLog(l *schema.AuditLog, beforeJSON, afterJSON sql.NullString, ad
 	l.DiffURL = fmt.Sprintf("/v1/audit-logs/%d/diff", l.ID)
 }
 
+// splitList splits a comma separated query value, dropping empty items.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
 func encodeCursor(ts time.Time, id int64) string {
 	s := fmt.Sprintf("%s:%d", ts.UTC().Format(time.RFC3339Nano), id)
 	return base64.StdEncoding.EncodeToString([]byte(s))
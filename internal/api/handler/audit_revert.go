@@ -0,0 +1,276 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/faciam-dev/gcfm/internal/auditlog"
+	"github.com/faciam-dev/gcfm/pkg/audit"
+	pkgmonitordb "github.com/faciam-dev/gcfm/pkg/monitordb"
+	"github.com/faciam-dev/gcfm/pkg/registry"
+	"github.com/faciam-dev/gcfm/pkg/schema"
+	"github.com/faciam-dev/gcfm/pkg/tenant"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+	"github.com/faciam-dev/goquent/orm/query"
+)
+
+// fieldApplier applies the inverse of a custom field change. It is
+// implemented by *CustomFieldHandler.
+type fieldApplier interface {
+	create(ctx context.Context, in *createInput) (*createOutput, error)
+	update(ctx context.Context, in *updateInput) (*createOutput, error)
+	delete(ctx context.Context, in *deleteInput) (*struct{}, error)
+}
+
+type auditIDRow struct {
+	ID int64 `db:"id"`
+}
+
+type auditRevertParams struct {
+	ID int64 `path:"id"`
+}
+
+type auditRevertOutput struct {
+	Body struct {
+		// RevertedID is the audit entry that was undone.
+		RevertedID int64 `json:"revertedId"`
+		// AuditID is the audit entry recording the revert.
+		AuditID int64 `json:"auditId,omitempty"`
+		// Action is the inverse change that was applied.
+		Action string              `json:"action" enum:"add,update,delete"`
+		Field  *registry.FieldMeta `json:"field,omitempty"`
+	}
+}
+
+func registerAuditRevert(api huma.API, h *AuditHandler) {
+	huma.Register(api, huma.Operation{
+		OperationID: "revertAuditLog",
+		Method:      http.MethodPost,
+		Path:        "/v1/audit-logs/{id}/revert",
+		Summary:     "Revert the change recorded by an audit log",
+		Description: "Applies the inverse of a custom field change through the custom field endpoints. " +
+			"Fails with 409 when the field was changed again after the entry.",
+		Tags:   []string{"Audit"},
+		Errors: []int{http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+	}, h.revert)
+}
+
+func (h *AuditHandler) revert(ctx context.Context, p *auditRevertParams) (*auditRevertOutput, error) {
+	if h.Fields == nil {
+		return nil, huma.NewError(http.StatusNotImplemented, "custom fields not configured")
+	}
+	tid := tenant.FromContext(ctx)
+	repo := auditlog.Repo{DB: h.DB, Dialect: h.Dialect, TablePrefix: h.TablePrefix}
+	rec, err := repo.FindInTenant(ctx, tid, p.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, huma.Error404NotFound("not found")
+		}
+		return nil, err
+	}
+	if rec.Tenant != tid {
+		return nil, huma.Error404NotFound("not found")
+	}
+	if rec.ResourceType != "" && rec.ResourceType != audit.ResourceCustomField || rec.TableName == "" {
+		return nil, huma.Error422UnprocessableEntity("only custom field changes can be reverted")
+	}
+	before, err := decodeAuditField(rec.BeforeJSON)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("before_json: %v", err))
+	}
+	after, err := decodeAuditField(rec.AfterJSON)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("after_json: %v", err))
+	}
+
+	field := after
+	if field == nil {
+		field = before
+	}
+	var dbID int64
+	if field != nil {
+		dbID = field.DBID
+	}
+	// The check is repeated in the transaction of the inverse change, with
+	// the field row locked, so that a change committed in between is not
+	// overwritten. Checking first avoids touching the target database when
+	// the conflict is already known.
+	if err := h.checkNoLaterChange(ctx, h.DB, tid, rec, dbID); err != nil {
+		return nil, err
+	}
+	rctx := withFieldCheck(audit.WithRevertOf(ctx, rec.ID), func(ctx context.Context, tx *sql.Tx) error {
+		var locked []auditIDRow
+		if err := query.New(tx, h.TablePrefix+"custom_fields", h.Dialect).
+			SelectRaw("1 AS id").
+			Where("db_id", pkgmonitordb.NormalizeDBID(dbID)).
+			Where("tenant_id", tid).
+			Where("table_name", rec.TableName).
+			Where("column_name", rec.ColumnName).
+			LockForUpdate().
+			WithContext(ctx).
+			Get(&locked); err != nil {
+			return err
+		}
+		return h.checkNoLaterChange(ctx, tx, tid, rec, dbID)
+	})
+
+	out := &auditRevertOutput{}
+	out.Body.RevertedID = rec.ID
+	id := rec.TableName + "." + rec.ColumnName
+	switch rec.Action {
+	case "add":
+		if after == nil {
+			return nil, huma.Error422UnprocessableEntity("audit log has no field to remove")
+		}
+		if _, err := h.Fields.delete(rctx, &deleteInput{ID: id, DBID: pkgmonitordb.NormalizeDBID(dbID)}); err != nil {
+			return nil, err
+		}
+		out.Body.Action = "delete"
+	case "update":
+		if before == nil {
+			return nil, huma.Error422UnprocessableEntity("audit log has no previous field state")
+		}
+		res, err := h.Fields.update(rctx, &updateInput{ID: id, Body: customFieldFromMeta(*before)})
+		if err != nil {
+			return nil, err
+		}
+		out.Body.Action = "update"
+		out.Body.Field = &res.Body
+	case "delete":
+		if before == nil {
+			return nil, huma.Error422UnprocessableEntity("audit log has no previous field state")
+		}
+		res, err := h.Fields.create(rctx, &createInput{Body: customFieldFromMeta(*before)})
+		if err != nil {
+			return nil, err
+		}
+		out.Body.Action = "add"
+		out.Body.Field = &res.Body
+	default:
+		return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("action %q cannot be reverted", rec.Action))
+	}
+
+	var ids []auditIDRow
+	if err := query.New(h.DB, h.TablePrefix+"audit_logs", h.Dialect).
+		Select("id").
+		Where("tenant_id", tid).
+		Where("revert_of", rec.ID).
+		OrderBy("id", "desc").
+		Limit(1).
+		WithContext(ctx).
+		Get(&ids); err == nil && len(ids) > 0 {
+		out.Body.AuditID = ids[0].ID
+	}
+	return out, nil
+}
+
+// execer is satisfied by *sql.DB and *sql.Tx.
+type execer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	Exec(query string, args ...any) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// checkNoLaterChange returns a 409 error when the field of rec in database
+// dbID was changed by an audit entry newer than rec.
+func (h *AuditHandler) checkNoLaterChange(ctx context.Context, db execer, tid string, rec auditlog.Record, dbID int64) error {
+	textCast := "CAST(%s AS CHAR)"
+	if _, ok := h.Dialect.(ormdriver.PostgresDialect); ok {
+		textCast = "%s::text"
+	}
+	var later []struct {
+		ID     int64          `db:"id"`
+		Before sql.NullString `db:"before_json"`
+		After  sql.NullString `db:"after_json"`
+	}
+	if err := query.New(db, h.TablePrefix+"audit_logs", h.Dialect).
+		Select("id").
+		SelectRaw(fmt.Sprintf(textCast, "before_json")+" AS before_json").
+		SelectRaw(fmt.Sprintf(textCast, "after_json")+" AS after_json").
+		Where("tenant_id", tid).
+		Where("table_name", rec.TableName).
+		Where("column_name", rec.ColumnName).
+		WhereIn("action", []string{"add", "update", "delete"}).
+		Where("id", ">", rec.ID).
+		OrderBy("id", "asc").
+		WithContext(ctx).
+		Get(&later); err != nil {
+		return err
+	}
+	want := pkgmonitordb.NormalizeDBID(dbID)
+	for _, l := range later {
+		m, _ := decodeAuditField(l.After)
+		if m == nil {
+			m, _ = decodeAuditField(l.Before)
+		}
+		// Entries whose payload names no field cannot be told apart and
+		// count as a conflict.
+		if m == nil || pkgmonitordb.NormalizeDBID(m.DBID) == want {
+			return huma.Error409Conflict(fmt.Sprintf("%s.%s was changed again after audit log %d (audit log %d)", rec.TableName, rec.ColumnName, rec.ID, l.ID))
+		}
+	}
+	return nil
+}
+
+// decodeAuditField decodes a field payload of an audit log. Empty objects,
+// as returned by FindByID for NULL columns, yield nil.
+func decodeAuditField(s sql.NullString) (*registry.FieldMeta, error) {
+	if !s.Valid || s.String == "" || s.String == "{}" || s.String == "null" {
+		return nil, nil
+	}
+	var m registry.FieldMeta
+	if err := json.Unmarshal([]byte(s.String), &m); err != nil {
+		return nil, err
+	}
+	if m.TableName == "" || m.ColumnName == "" {
+		return nil, nil
+	}
+	return &m, nil
+}
+
+// customFieldFromMeta converts stored field metadata back into the request
+// body accepted by the custom field endpoints.
+func customFieldFromMeta(m registry.FieldMeta) schema.CustomField {
+	dbID := m.DBID
+	nullable, unique := m.Nullable, m.Unique
+	cf := schema.CustomField{
+		DBID:            &dbID,
+		Table:           m.TableName,
+		Column:          m.ColumnName,
+		Type:            m.DataType,
+		DriverExtras:    m.DriverExtras,
+		Nullable:        &nullable,
+		Unique:          &unique,
+		HasDefault:      m.HasDefault,
+		DefaultValue:    m.Default,
+		Validator:       m.Validator,
+		ValidatorParams: m.ValidatorParams,
+	}
+	if m.StoreKind != "" {
+		cf.StoreKind = &m.StoreKind
+	}
+	if m.Kind != "" {
+		cf.Kind = &m.Kind
+	}
+	if m.PhysicalType != "" {
+		cf.PhysicalType = &m.PhysicalType
+	}
+	if d := m.Display; d != nil {
+		cf.Display = schema.DisplaySettings{Widget: d.Widget, WidgetConfig: d.WidgetConfig}
+		if d.LabelKey != "" {
+			cf.Display.LabelKey = &d.LabelKey
+		}
+		if d.PlaceholderKey != "" {
+			cf.Display.PlaceholderKey = &d.PlaceholderKey
+		}
+	}
+	return cf
+}
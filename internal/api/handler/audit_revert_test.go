@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/faciam-dev/gcfm/pkg/audit"
+	"github.com/faciam-dev/gcfm/pkg/registry"
+	"github.com/faciam-dev/gcfm/pkg/tenant"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+)
+
+var auditRecordColumns = []string{
+	"id", "tenant_id", "actor", "action", "table_name", "column_name", "resource_type",
+	"resource_id", "request_id", "revert_of", "before_json", "after_json", "added_count",
+	"removed_count", "change_count", "applied_at",
+}
+
+var laterColumns = []string{"id", "before_json", "after_json"}
+
+func TestAuditRevertConflict(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT .* FROM `gcfm_audit_logs` as `l` WHERE `l`.`id` = \\? AND `l`.`tenant_id` = \\?").
+		WithArgs(int64(3), "t1").
+		WillReturnRows(sqlmock.NewRows(auditRecordColumns).
+			AddRow(3, "t1", "alice", "update", "posts", "title", "custom_field", "posts.title", "", nil,
+				`{"TableName":"posts","ColumnName":"title","DataType":"varchar"}`,
+				`{"TableName":"posts","ColumnName":"title","DataType":"text"}`, 0, 0, 1, time.Now()))
+	mock.ExpectQuery("SELECT `id`, .* FROM `gcfm_audit_logs` WHERE `tenant_id` = \\? AND `table_name` = \\? AND `column_name` = \\?").
+		WithArgs("t1", "posts", "title", "add", "update", "delete", int64(3)).
+		WillReturnRows(sqlmock.NewRows(laterColumns).
+			AddRow(9, `{"TableName":"posts","ColumnName":"title","DataType":"text"}`, `{"TableName":"posts","ColumnName":"title","DataType":"longtext"}`))
+
+	h := &AuditHandler{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_", Fields: &CustomFieldHandler{}}
+	ctx := tenant.WithTenant(context.Background(), "t1")
+	_, err = h.revert(ctx, &auditRevertParams{ID: 3})
+	var se huma.StatusError
+	if !errors.As(err, &se) || se.GetStatus() != http.StatusConflict {
+		t.Fatalf("expected 409, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
+
+func TestAuditRevertOtherTenant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT .* FROM `gcfm_audit_logs` as `l`").
+		WillReturnRows(sqlmock.NewRows(auditRecordColumns).
+			AddRow(3, "t2", "alice", "delete", "posts", "title", "custom_field", "posts.title", "", nil, `{}`, `{}`, 0, 0, 0, time.Now()))
+
+	h := &AuditHandler{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_", Fields: &CustomFieldHandler{}}
+	ctx := tenant.WithTenant(context.Background(), "t1")
+	_, err = h.revert(ctx, &auditRevertParams{ID: 3})
+	var se huma.StatusError
+	if !errors.As(err, &se) || se.GetStatus() != http.StatusNotFound {
+		t.Fatalf("expected 404, got %v", err)
+	}
+}
+
+type revertFields struct {
+	t       *testing.T
+	db      *sql.DB
+	deleted string
+	dbID    int64
+}
+
+func (f *revertFields) create(context.Context, *createInput) (*createOutput, error) {
+	f.t.Fatal("unexpected create")
+	return nil, nil
+}
+
+func (f *revertFields) update(context.Context, *updateInput) (*createOutput, error) {
+	f.t.Fatal("unexpected update")
+	return nil, nil
+}
+
+func (f *revertFields) delete(ctx context.Context, in *deleteInput) (*struct{}, error) {
+	if got := audit.RevertOfFromContext(ctx); got != 3 {
+		f.t.Fatalf("revert_of = %d, want 3", got)
+	}
+	check, ok := ctx.Value(fieldCheckKey{}).(func(context.Context, *sql.Tx) error)
+	if !ok {
+		f.t.Fatal("no field check in context")
+	}
+	tx, err := f.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := check(ctx, tx); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	f.deleted = in.ID
+	f.dbID = in.DBID
+	return &struct{}{}, tx.Commit()
+}
+
+func TestAuditRevertSucceeds(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT .* FROM `gcfm_audit_logs` as `l` WHERE `l`.`id` = \\? AND `l`.`tenant_id` = \\?").
+		WithArgs(int64(3), "t1").
+		WillReturnRows(sqlmock.NewRows(auditRecordColumns).
+			AddRow(3, "t1", "alice", "add", "posts", "title", "custom_field", "posts.title", "", nil,
+				nil, `{"DBID":2,"TableName":"posts","ColumnName":"title","DataType":"varchar"}`, 0, 0, 1, time.Now()))
+	// A later change of the same column in another database is no conflict.
+	other := `{"DBID":1,"TableName":"posts","ColumnName":"title","DataType":"text"}`
+	mock.ExpectQuery("SELECT `id`, .* FROM `gcfm_audit_logs`").
+		WithArgs("t1", "posts", "title", "add", "update", "delete", int64(3)).
+		WillReturnRows(sqlmock.NewRows(laterColumns).AddRow(5, nil, other))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT 1 AS id FROM `gcfm_custom_fields` WHERE `db_id` = \\? AND `tenant_id` = \\? AND `table_name` = \\? AND `column_name` = \\? FOR UPDATE").
+		WithArgs(int64(2), "t1", "posts", "title").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT `id`, .* FROM `gcfm_audit_logs`").
+		WithArgs("t1", "posts", "title", "add", "update", "delete", int64(3)).
+		WillReturnRows(sqlmock.NewRows(laterColumns).AddRow(5, nil, other))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT `id` FROM `gcfm_audit_logs` WHERE `tenant_id` = \\? AND `revert_of` = \\?").
+		WithArgs("t1", int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12))
+
+	fields := &revertFields{t: t, db: db}
+	h := &AuditHandler{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_", Fields: fields}
+	ctx := tenant.WithTenant(context.Background(), "t1")
+	out, err := h.revert(ctx, &auditRevertParams{ID: 3})
+	if err != nil {
+		t.Fatalf("revert: %v", err)
+	}
+	if fields.deleted != "posts.title" || fields.dbID != 2 {
+		t.Fatalf("deleted = %q in database %d", fields.deleted, fields.dbID)
+	}
+	if out.Body.RevertedID != 3 || out.Body.AuditID != 12 || out.Body.Action != "delete" {
+		t.Fatalf("unexpected body: %+v", out.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
+
+func TestAuditRevertConflictUnderLock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	after := `{"DBID":2,"TableName":"posts","ColumnName":"title","DataType":"varchar"}`
+	mock.ExpectQuery("SELECT .* FROM `gcfm_audit_logs` as `l`").
+		WillReturnRows(sqlmock.NewRows(auditRecordColumns).
+			AddRow(3, "t1", "alice", "add", "posts", "title", "custom_field", "posts.title", "", nil, nil, after, 0, 0, 1, time.Now()))
+	mock.ExpectQuery("SELECT `id`, .* FROM `gcfm_audit_logs`").
+		WillReturnRows(sqlmock.NewRows(laterColumns))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT 1 AS id FROM `gcfm_custom_fields` .* FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	// Committed between the first check and the lock.
+	mock.ExpectQuery("SELECT `id`, .* FROM `gcfm_audit_logs`").
+		WillReturnRows(sqlmock.NewRows(laterColumns).AddRow(4, after, `{"DBID":2,"TableName":"posts","ColumnName":"title","DataType":"text"}`))
+	mock.ExpectRollback()
+
+	fields := &revertFields{t: t, db: db}
+	h := &AuditHandler{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_", Fields: fields}
+	ctx := tenant.WithTenant(context.Background(), "t1")
+	_, err = h.revert(ctx, &auditRevertParams{ID: 3})
+	var se huma.StatusError
+	if !errors.As(err, &se) || se.GetStatus() != http.StatusConflict {
+		t.Fatalf("expected 409, got %v", err)
+	}
+	if fields.deleted != "" {
+		t.Fatal("field was deleted despite the conflict")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
+
+// TestCustomFieldDeleteScopedToDatabase deletes posts.title, which exists in
+// databases 1 and 2, from database 2 only.
+func TestCustomFieldDeleteScopedToDatabase(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT .* FROM `gcfm_custom_fields` WHERE `table_name` = \\? AND `column_name` = \\? AND `db_id` = \\?").
+		WithArgs("posts", "title", int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"db_id", "data_type", "store_kind", "kind", "physical_type", "driver_extras"}).
+			AddRow(2, "varchar", "sql", nil, nil, nil))
+	// Stop at the monitored database lookup, which must be for database 2.
+	mock.ExpectQuery("SELECT .* FROM `gcfm_monitored_databases`").
+		WithArgs(int64(2), "t1").
+		WillReturnError(sql.ErrNoRows)
+
+	h := &CustomFieldHandler{DB: db, Dialect: ormdriver.MySQLDialect{}, Driver: "mysql", TablePrefix: "gcfm_"}
+	ctx := tenant.WithTenant(context.Background(), "t1")
+	_, err = h.delete(ctx, &deleteInput{ID: "posts.title", DBID: 2})
+	var se huma.StatusError
+	if !errors.As(err, &se) || se.GetStatus() != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
+
+func TestCustomFieldFromMeta(t *testing.T) {
+	m := registry.FieldMeta{
+		DBID:       2,
+		TableName:  "posts",
+		ColumnName: "title",
+		DataType:   "varchar",
+		Nullable:   true,
+		Display:    &registry.DisplayMeta{Widget: "text", LabelKey: "posts.title"},
+	}
+	cf := customFieldFromMeta(m)
+	if cf.Table != "posts" || cf.Column != "title" || cf.Type != "varchar" || *cf.DBID != 2 {
+		t.Fatalf("unexpected field: %+v", cf)
+	}
+	if cf.Nullable == nil || !*cf.Nullable || cf.Unique == nil || *cf.Unique {
+		t.Fatalf("unexpected flags: %+v", cf)
+	}
+	if cf.StoreKind != nil || cf.Display.Widget != "text" || cf.Display.LabelKey == nil || *cf.Display.LabelKey != "posts.title" {
+		t.Fatalf("unexpected display: %+v", cf.Display)
+	}
+}
@@ -78,6 +78,17 @@ var CapMatrix = map[string]Capability{
 	"databases:scan":         {"/v1/databases/{id}/scan", "POST"},
 	"databases:capabilities": {"/v1/databases/{id}/capabilities", "GET"},
 
+	// Events
+	"events:stream": {"/v1/events/stream", "GET"},
+
+	// Event webhooks
+	"event_webhooks:list":          {"/v1/events/webhooks", "GET"},
+	"event_webhooks:create":        {"/v1/events/webhooks", "POST"},
+	"event_webhooks:update":        {"/v1/events/webhooks/{id}", "PUT"},
+	"event_webhooks:delete":        {"/v1/events/webhooks/{id}", "DELETE"},
+	"event_webhooks:rotate-secret": {"/v1/events/webhooks/{id}/rotate-secret", "POST"},
+	"event_webhooks:deliveries":    {"/v1/events/webhooks/{id}/deliveries", "GET"},
+
 	// Targets
 	"targets:list":         {"/admin/targets", "GET"},
 	"targets:create":       {"/admin/targets", "POST"},
@@ -28,6 +28,7 @@ import (
 	"github.com/faciam-dev/gcfm/pkg/schema"
 	"github.com/faciam-dev/gcfm/pkg/tenant"
 	pkgutil "github.com/faciam-dev/gcfm/pkg/util"
+	"github.com/faciam-dev/gcfm/pkg/widgetconfig"
 	"github.com/faciam-dev/gcfm/pkg/widgetpolicy"
 	ormdriver "github.com/faciam-dev/goquent/orm/driver"
 	"github.com/faciam-dev/goquent/orm/query"
@@ -72,6 +73,9 @@ type updateInput struct {
 
 type deleteInput struct {
 	ID string `path:"id"`
+	// DBID selects the database of the field; without it the first field
+	// with the id in any database is deleted.
+	DBID int64 `query:"db_id"`
 }
 
 func canonicalizeWidgetID(raw, colType string) (string, map[string]any, bool) {
@@ -88,14 +92,14 @@ func canonicalizeWidgetID(raw, colType string) (string, map[string]any, bool) {
 	}
 }
 
-func (h *CustomFieldHandler) resolveAuto(ctx widgetpolicy.Ctx) string {
+func (h *CustomFieldHandler) resolveAuto(tenantID string, ctx widgetpolicy.Ctx) string {
 	if h.PolicyStore == nil {
 		return "plugin://text-input"
 	}
 	id, _ := h.PolicyStore.Get().Resolve(ctx, func(id string) bool {
 		if strings.HasPrefix(id, "plugin://") {
 			pid := strings.TrimPrefix(id, "plugin://")
-			return h.WidgetRegistry == nil || h.WidgetRegistry.Has(pid)
+			return h.WidgetRegistry == nil || h.WidgetRegistry.Check(tenantID, pid) == nil
 		}
 		return true
 	})
@@ -158,14 +162,50 @@ func (h *CustomFieldHandler) validateWidget(ctx context.Context, widget string)
 		return nil
 	}
 	if id, ok := isPluginWidget(widget); ok {
-		if h.WidgetRegistry == nil || !h.WidgetRegistry.Has(id) {
+		if h.WidgetRegistry == nil {
 			return huma.NewError(http.StatusUnprocessableEntity, "unknown plugin widget: "+id)
 		}
-		return nil
+		err := h.WidgetRegistry.Check(tenant.FromContext(ctx), id)
+		switch {
+		case err == nil:
+			return nil
+		case errors.Is(err, widgetreg.ErrWidgetNotInstalled):
+			return huma.NewError(http.StatusUnprocessableEntity, "unknown plugin widget: "+id)
+		default:
+			return huma.NewError(http.StatusUnprocessableEntity, fmt.Sprintf("plugin widget %s: %v", id, err))
+		}
 	}
 	return huma.NewError(http.StatusUnprocessableEntity, "unknown widget: "+widget)
 }
 
+// validateWidgetConfig checks cfg against the config schema declared by the
+// plugin widget. Violations are reported as 422 details whose locations are
+// JSON pointers below display.widget_config.
+func (h *CustomFieldHandler) validateWidgetConfig(widget string, cfg json.RawMessage) error {
+	id, ok := isPluginWidget(widget)
+	if !ok || h.WidgetRegistry == nil {
+		return nil
+	}
+	if err := widgetconfig.Validate(h.WidgetRegistry.ConfigSchema(id), cfg); err != nil {
+		return widgetConfigError("display.widget_config", err)
+	}
+	return nil
+}
+
+// widgetConfigError converts a widgetconfig validation error into a 422
+// response with one detail per violation located at loc plus the pointer.
+func widgetConfigError(loc string, err error) error {
+	var verr *widgetconfig.Error
+	if !errors.As(err, &verr) {
+		return huma.Error422(loc, err.Error())
+	}
+	details := make([]error, 0, len(verr.Violations))
+	for _, v := range verr.Violations {
+		details = append(details, &huma.ErrorDetail{Location: loc + v.Pointer, Message: v.Message})
+	}
+	return huma.NewError(http.StatusUnprocessableEntity, err.Error(), details...)
+}
+
 func Register(api huma.API, h *CustomFieldHandler) {
 	huma.Register(api, huma.Operation{
 		OperationID: "listCustomFields",
@@ -174,6 +214,20 @@ func Register(api huma.API, h *CustomFieldHandler) {
 		Summary:     "List custom fields",
 		Tags:        []string{"CustomField"},
 	}, h.list)
+	huma.Register(api, huma.Operation{
+		OperationID: "listWidgetConfigViolations",
+		Method:      http.MethodGet,
+		Path:        "/v1/custom-fields/widget-config-violations",
+		Summary:     "List fields whose widget_config no longer matches the widget schema",
+		Tags:        []string{"CustomField"},
+	}, h.widgetConfigViolations)
+	huma.Register(api, huma.Operation{
+		OperationID: "listBrokenWidgetReferences",
+		Method:      http.MethodGet,
+		Path:        "/v1/custom-fields/widget-references",
+		Summary:     "List fields referencing removed or disabled widgets",
+		Tags:        []string{"CustomField"},
+	}, h.widgetReferences)
 	huma.Register(api, huma.Operation{
 		OperationID:   "createCustomField",
 		Method:        http.MethodPost,
@@ -230,6 +284,9 @@ func (h *CustomFieldHandler) create(ctx context.Context, in *createInput) (*crea
 	if origIsCore || isAuto {
 		in.Body.Display.WidgetConfig = nil
 	}
+	if err := h.validateWidgetConfig(in.Body.Display.Widget, in.Body.Display.WidgetConfig); err != nil {
+		return nil, err
+	}
 	tid := tenant.FromContext(ctx)
 	if err := h.validateDB(ctx, tid, *in.Body.DBID); err != nil {
 		return nil, huma.Error422("db_id", err.Error())
@@ -293,7 +350,7 @@ func (h *CustomFieldHandler) create(ctx context.Context, in *createInput) (*crea
 			typ, _ := widgetpolicy.NormalizeType(mdb.Driver, base, length)
 			val := widgetpolicy.NormalizeValidator(in.Body.Validator)
 			ctx := widgetpolicy.Ctx{Driver: mdb.Driver, Type: typ, Validator: val, Length: length, Name: in.Body.Column, EnumValues: enums}
-			display.WidgetResolved = h.resolveAuto(ctx)
+			display.WidgetResolved = h.resolveAuto(tid, ctx)
 		} else {
 			display.WidgetResolved = display.Widget
 		}
@@ -356,6 +413,11 @@ func (h *CustomFieldHandler) create(ctx context.Context, in *createInput) (*crea
 			meta.Default = norm
 		}
 	}
+	// Fields stored in SQL are committed together with their event; the
+	// others are emitted once every step has succeeded.
+	evt := events.FieldEvent(events.TypeFieldCreated, nil, &meta)
+	actor := middleware.UserFromContext(ctx)
+	queued, audited := false, false
 	switch h.Driver {
 	case "mongo":
 		if err := registry.UpsertMongo(ctx, h.Mongo, registry.DBConfig{Schema: h.Schema, TablePrefix: h.TablePrefix}, []registry.FieldMeta{meta}); err != nil {
@@ -363,21 +425,29 @@ func (h *CustomFieldHandler) create(ctx context.Context, in *createInput) (*crea
 		}
 	default:
 		if storeKind != "mongo" {
-			exists, err := registry.ColumnExists(ctx, target, dialect, mdb.Schema, meta.TableName, meta.ColumnName)
-			if err != nil {
-				return nil, err
-			}
-			if !exists {
-				if err := registry.AddColumnSQL(ctx, target, mdb.Driver, meta.TableName, meta.ColumnName, meta.DataType, in.Body.Nullable, in.Body.Unique, d); err != nil {
-					if errors.Is(err, registry.ErrDefaultNotSupported) {
-						return nil, huma.Error400BadRequest("invalid default for column type")
+			queued, err = h.writeFieldTx(ctx, evt, actor, nil, &meta, func() error {
+				exists, err := registry.ColumnExists(ctx, target, dialect, mdb.Schema, meta.TableName, meta.ColumnName)
+				if err != nil {
+					return err
+				}
+				if !exists {
+					if err := registry.AddColumnSQL(ctx, target, mdb.Driver, meta.TableName, meta.ColumnName, meta.DataType, in.Body.Nullable, in.Body.Unique, d); err != nil {
+						if errors.Is(err, registry.ErrDefaultNotSupported) {
+							return huma.Error400BadRequest("invalid default for column type")
+						}
+						msg := fmt.Sprintf("add column failed: %v", err)
+						return huma.Error422("db", msg)
 					}
-					msg := fmt.Sprintf("add column failed: %v", err)
-					return nil, huma.Error422("db", msg)
 				}
+				return nil
+			}, func(tx *sql.Tx) error {
+				return registry.UpsertSQLTx(ctx, tx, h.Driver, h.TablePrefix, []registry.FieldMeta{meta})
+			})
+			if err != nil {
+				return nil, err
 			}
-		}
-		if err := registry.UpsertSQL(ctx, h.DB, h.Driver, h.TablePrefix, []registry.FieldMeta{meta}); err != nil {
+			audited = true
+		} else if err := registry.UpsertSQL(ctx, h.DB, h.Driver, h.TablePrefix, []registry.FieldMeta{meta}); err != nil {
 			return nil, err
 		}
 	}
@@ -387,12 +457,14 @@ func (h *CustomFieldHandler) create(ctx context.Context, in *createInput) (*crea
 			return nil, huma.NewError(http.StatusInternalServerError, fmt.Sprintf("failed to apply mongo schema: %v", err))
 		}
 	}
-	actor := middleware.UserFromContext(ctx)
-	err = h.Recorder.Write(ctx, actor, nil, &meta)
-	if err != nil {
-		return nil, err
+	if !audited {
+		if err := h.Recorder.Write(ctx, actor, nil, &meta); err != nil {
+			return nil, err
+		}
+	}
+	if !queued {
+		events.Emit(ctx, evt)
 	}
-	events.Emit(ctx, events.Event{Name: "cf.field.created", Time: time.Now(), Data: meta, ID: meta.TableName + "." + meta.ColumnName})
 	return &createOutput{Body: meta}, nil
 }
 
@@ -425,7 +497,7 @@ func (h *CustomFieldHandler) list(ctx context.Context, in *listParams) (*listOut
 				typ, _ := widgetpolicy.NormalizeType(h.Driver, base, length)
 				val := widgetpolicy.NormalizeValidator(metas[i].Validator)
 				ctx := widgetpolicy.Ctx{Driver: h.Driver, Type: typ, Validator: val, Length: length, Name: metas[i].ColumnName, EnumValues: enums}
-				metas[i].Display.WidgetResolved = h.resolveAuto(ctx)
+				metas[i].Display.WidgetResolved = h.resolveAuto(tenantID, ctx)
 			} else {
 				metas[i].Display.WidgetResolved = metas[i].Display.Widget
 			}
@@ -434,6 +506,128 @@ func (h *CustomFieldHandler) list(ctx context.Context, in *listParams) (*listOut
 	return &listOutput{Body: metas}, nil
 }
 
+type widgetConfigViolationsParams struct {
+	DBID   int64  `query:"db_id"`
+	Widget string `query:"widget"`
+}
+
+// WidgetConfigViolation reports a stored field whose widget_config fails the
+// current schema of its widget, typically after a widget upgrade.
+type WidgetConfigViolation struct {
+	ID         string                   `json:"id"`
+	DBID       int64                    `json:"db_id"`
+	Widget     string                   `json:"widget"`
+	Violations []widgetconfig.Violation `json:"violations"`
+}
+
+type widgetConfigViolationsOutput struct {
+	Body []WidgetConfigViolation
+}
+
+func (h *CustomFieldHandler) widgetConfigViolations(ctx context.Context, in *widgetConfigViolationsParams) (*widgetConfigViolationsOutput, error) {
+	metas, err := h.loadTenantFields(ctx, in.DBID)
+	if err != nil {
+		return nil, err
+	}
+	filter := ""
+	if in.Widget != "" {
+		filter = display.CanonicalizeWidgetID(in.Widget)
+	}
+	out := []WidgetConfigViolation{}
+	for _, m := range metas {
+		if m.Display == nil || h.WidgetRegistry == nil {
+			continue
+		}
+		if filter != "" && m.Display.Widget != filter {
+			continue
+		}
+		id, ok := isPluginWidget(m.Display.Widget)
+		if !ok {
+			continue
+		}
+		err := widgetconfig.Validate(h.WidgetRegistry.ConfigSchema(id), m.Display.WidgetConfig)
+		var verr *widgetconfig.Error
+		if !errors.As(err, &verr) {
+			continue
+		}
+		out = append(out, WidgetConfigViolation{
+			ID:         m.TableName + "." + m.ColumnName,
+			DBID:       m.DBID,
+			Widget:     m.Display.Widget,
+			Violations: verr.Violations,
+		})
+	}
+	return &widgetConfigViolationsOutput{Body: out}, nil
+}
+
+// loadTenantFields returns the fields of the request tenant, restricted to
+// dbID when it is non-zero.
+func (h *CustomFieldHandler) loadTenantFields(ctx context.Context, dbID int64) ([]registry.FieldMeta, error) {
+	tenantID := tenant.FromContext(ctx)
+	conf := registry.DBConfig{Driver: h.Driver, Schema: h.Schema, TablePrefix: h.TablePrefix}
+	switch {
+	case h.Driver == "mongo":
+		return registry.LoadMongo(ctx, h.Mongo, conf)
+	case dbID != 0:
+		return registry.LoadSQLByDB(ctx, h.DB, conf, tenantID, dbID)
+	default:
+		return registry.LoadSQLByTenant(ctx, h.DB, conf, tenantID)
+	}
+}
+
+type widgetReferencesParams struct {
+	DBID int64 `query:"db_id"`
+}
+
+// WidgetReference reports a field whose widget cannot be used by the tenant.
+// Reason is one of "removed", "not_installed_for_tenant" or "disabled".
+type WidgetReference struct {
+	ID     string `json:"id"`
+	DBID   int64  `json:"db_id"`
+	Widget string `json:"widget"`
+	Reason string `json:"reason"`
+}
+
+type widgetReferencesOutput struct {
+	Body []WidgetReference
+}
+
+func (h *CustomFieldHandler) widgetReferences(ctx context.Context, in *widgetReferencesParams) (*widgetReferencesOutput, error) {
+	metas, err := h.loadTenantFields(ctx, in.DBID)
+	if err != nil {
+		return nil, err
+	}
+	tenantID := tenant.FromContext(ctx)
+	out := []WidgetReference{}
+	for _, m := range metas {
+		if m.Display == nil || h.WidgetRegistry == nil {
+			continue
+		}
+		id, ok := isPluginWidget(m.Display.Widget)
+		if !ok {
+			continue
+		}
+		var reason string
+		switch err := h.WidgetRegistry.Check(tenantID, id); {
+		case err == nil:
+			continue
+		case errors.Is(err, widgetreg.ErrWidgetNotInstalled):
+			reason = "removed"
+		case errors.Is(err, widgetreg.ErrWidgetNotInTenant):
+			reason = "not_installed_for_tenant"
+		default:
+			reason = "disabled"
+		}
+		out = append(out, WidgetReference{
+			ID:     m.TableName + "." + m.ColumnName,
+			DBID:   m.DBID,
+			Widget: m.Display.Widget,
+			Reason: reason,
+		})
+	}
+	return &widgetReferencesOutput{Body: out}, nil
+}
+
 func splitID(id string) (string, string, bool) {
 	parts := strings.SplitN(id, ".", 2)
 	if len(parts) != 2 {
@@ -547,6 +741,9 @@ func (h *CustomFieldHandler) update(ctx context.Context, in *updateInput) (*crea
 	if origIsCore || isAuto {
 		in.Body.Display.WidgetConfig = nil
 	}
+	if err := h.validateWidgetConfig(in.Body.Display.Widget, in.Body.Display.WidgetConfig); err != nil {
+		return nil, err
+	}
 	tid := tenant.FromContext(ctx)
 	dbID := pkgmonitordb.DefaultDBID
 	if in.Body.DBID != nil {
@@ -613,7 +810,7 @@ func (h *CustomFieldHandler) update(ctx context.Context, in *updateInput) (*crea
 			typ, _ := widgetpolicy.NormalizeType(mdb.Driver, base, length)
 			val := widgetpolicy.NormalizeValidator(in.Body.Validator)
 			ctx := widgetpolicy.Ctx{Driver: mdb.Driver, Type: typ, Validator: val, Length: length, Name: column, EnumValues: enums}
-			display.WidgetResolved = h.resolveAuto(ctx)
+			display.WidgetResolved = h.resolveAuto(tid, ctx)
 		} else {
 			display.WidgetResolved = display.Widget
 		}
@@ -686,6 +883,9 @@ func (h *CustomFieldHandler) update(ctx context.Context, in *updateInput) (*crea
 			meta.Default = norm
 		}
 	}
+	evt := events.FieldEvent(events.TypeFieldUpdated, oldMeta, &meta)
+	actor := middleware.UserFromContext(ctx)
+	queued, audited := false, false
 	switch h.Driver {
 	case "mongo":
 		if err := registry.UpsertMongo(ctx, h.Mongo, registry.DBConfig{Schema: h.Schema, TablePrefix: h.TablePrefix}, []registry.FieldMeta{meta}); err != nil {
@@ -693,29 +893,37 @@ func (h *CustomFieldHandler) update(ctx context.Context, in *updateInput) (*crea
 		}
 	default:
 		if storeKind != "mongo" {
-			exists, err := registry.ColumnExists(ctx, target, dialect, mdb.Schema, table, column)
-			if err != nil {
-				return nil, err
-			}
-			if exists {
-				if err := registry.ModifyColumnSQL(ctx, target, mdb.Driver, table, column, meta.DataType, in.Body.Nullable, in.Body.Unique, d); err != nil {
-					if errors.Is(err, registry.ErrDefaultNotSupported) {
-						return nil, huma.Error400BadRequest("invalid default for column type")
-					}
-					msg := fmt.Sprintf("modify column failed: %v", err)
-					return nil, huma.Error422("db", msg)
+			queued, err = h.writeFieldTx(ctx, evt, actor, oldMeta, &meta, func() error {
+				exists, err := registry.ColumnExists(ctx, target, dialect, mdb.Schema, table, column)
+				if err != nil {
+					return err
 				}
-			} else {
-				if err := registry.AddColumnSQL(ctx, target, mdb.Driver, table, column, meta.DataType, in.Body.Nullable, in.Body.Unique, d); err != nil {
-					if errors.Is(err, registry.ErrDefaultNotSupported) {
-						return nil, huma.Error400BadRequest("invalid default for column type")
+				if exists {
+					if err := registry.ModifyColumnSQL(ctx, target, mdb.Driver, table, column, meta.DataType, in.Body.Nullable, in.Body.Unique, d); err != nil {
+						if errors.Is(err, registry.ErrDefaultNotSupported) {
+							return huma.Error400BadRequest("invalid default for column type")
+						}
+						msg := fmt.Sprintf("modify column failed: %v", err)
+						return huma.Error422("db", msg)
+					}
+				} else {
+					if err := registry.AddColumnSQL(ctx, target, mdb.Driver, table, column, meta.DataType, in.Body.Nullable, in.Body.Unique, d); err != nil {
+						if errors.Is(err, registry.ErrDefaultNotSupported) {
+							return huma.Error400BadRequest("invalid default for column type")
+						}
+						msg := fmt.Sprintf("add column failed: %v", err)
+						return huma.Error422("db", msg)
 					}
-					msg := fmt.Sprintf("add column failed: %v", err)
-					return nil, huma.Error422("db", msg)
 				}
+				return nil
+			}, func(tx *sql.Tx) error {
+				return registry.UpsertSQLTx(ctx, tx, h.Driver, h.TablePrefix, []registry.FieldMeta{meta})
+			})
+			if err != nil {
+				return nil, err
 			}
-		}
-		if err := registry.UpsertSQL(ctx, h.DB, h.Driver, h.TablePrefix, []registry.FieldMeta{meta}); err != nil {
+			audited = true
+		} else if err := registry.UpsertSQL(ctx, h.DB, h.Driver, h.TablePrefix, []registry.FieldMeta{meta}); err != nil {
 			return nil, err
 		}
 	}
@@ -727,11 +935,14 @@ func (h *CustomFieldHandler) update(ctx context.Context, in *updateInput) (*crea
 			return nil, huma.NewError(http.StatusInternalServerError, fmt.Sprintf("failed to apply mongo schema: %v", err))
 		}
 	}
-	actor := middleware.UserFromContext(ctx)
-	if err := h.Recorder.Write(ctx, actor, oldMeta, &meta); err != nil {
-		return nil, fmt.Errorf("failed to write audit log: %w", err)
+	if !audited {
+		if err := h.Recorder.Write(ctx, actor, oldMeta, &meta); err != nil {
+			return nil, fmt.Errorf("failed to write audit log: %w", err)
+		}
+	}
+	if !queued {
+		events.Emit(ctx, evt)
 	}
-	events.Emit(ctx, events.Event{Name: "cf.field.updated", Time: time.Now(), Data: map[string]any{"before": oldMeta, "after": meta}, ID: table + "." + column})
 	return &createOutput{Body: meta}, nil
 }
 
@@ -745,7 +956,12 @@ func (h *CustomFieldHandler) delete(ctx context.Context, in *deleteInput) (*stru
 	}
 	tid := tenant.FromContext(ctx)
 	dbID := pkgmonitordb.DefaultDBID
-	oldMeta, err := h.getField(ctx, nil, table, column)
+	var lookup *int64
+	if in.DBID != 0 {
+		dbID = in.DBID
+		lookup = &in.DBID
+	}
+	oldMeta, err := h.getField(ctx, lookup, table, column)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve field metadata: %w", err)
 	}
@@ -792,6 +1008,13 @@ func (h *CustomFieldHandler) delete(ctx context.Context, in *deleteInput) (*stru
 		return nil, huma.NewError(http.StatusInternalServerError, "mongo client not configured")
 	}
 	meta := registry.FieldMeta{DBID: dbID, TableName: table, ColumnName: column}
+	before := oldMeta
+	if before == nil {
+		before = &meta
+	}
+	evt := events.FieldEvent(events.TypeFieldDeleted, before, nil)
+	actor := middleware.UserFromContext(ctx)
+	queued, audited := false, false
 	switch h.Driver {
 	case "mongo":
 		if err := registry.DeleteMongo(ctx, h.Mongo, registry.DBConfig{Schema: h.Schema, TablePrefix: h.TablePrefix}, []registry.FieldMeta{meta}); err != nil {
@@ -799,12 +1022,20 @@ func (h *CustomFieldHandler) delete(ctx context.Context, in *deleteInput) (*stru
 		}
 	default:
 		if storeKind != "mongo" {
-			if err := registry.DropColumnSQL(ctx, target, mdb.Driver, table, column); err != nil {
-				msg := fmt.Sprintf("drop column failed: %v", err)
-				return nil, huma.Error422("db", msg)
+			queued, err = h.writeFieldTx(ctx, evt, actor, oldMeta, nil, func() error {
+				if err := registry.DropColumnSQL(ctx, target, mdb.Driver, table, column); err != nil {
+					msg := fmt.Sprintf("drop column failed: %v", err)
+					return huma.Error422("db", msg)
+				}
+				return nil
+			}, func(tx *sql.Tx) error {
+				return registry.DeleteSQLTx(ctx, tx, h.Driver, h.TablePrefix, []registry.FieldMeta{meta})
+			})
+			if err != nil {
+				return nil, err
 			}
-		}
-		if err := registry.DeleteSQL(ctx, h.DB, h.Driver, h.TablePrefix, []registry.FieldMeta{meta}); err != nil {
+			audited = true
+		} else if err := registry.DeleteSQL(ctx, h.DB, h.Driver, h.TablePrefix, []registry.FieldMeta{meta}); err != nil {
 			return nil, err
 		}
 	}
@@ -816,14 +1047,71 @@ func (h *CustomFieldHandler) delete(ctx context.Context, in *deleteInput) (*stru
 			return nil, huma.NewError(http.StatusInternalServerError, fmt.Sprintf("failed to apply mongo schema: %v", err))
 		}
 	}
-	actor := middleware.UserFromContext(ctx)
-	if err := h.Recorder.Write(ctx, actor, oldMeta, nil); err != nil {
-		return nil, fmt.Errorf("failed to write audit log: %w", err)
+	if !audited {
+		if err := h.Recorder.Write(ctx, actor, oldMeta, nil); err != nil {
+			return nil, fmt.Errorf("failed to write audit log: %w", err)
+		}
+	}
+	if !queued {
+		events.Emit(ctx, evt)
 	}
-	events.Emit(ctx, events.Event{Name: "cf.field.deleted", Time: time.Now(), Data: map[string]string{"table": table, "column": column}, ID: table + "." + column})
 	return &struct{}{}, nil
 }
 
+// fieldCheckKey carries the check run by writeFieldTx.
+type fieldCheckKey struct{}
+
+// withFieldCheck returns a context under which writeFieldTx runs check in
+// its transaction before anything is changed, e.g. to verify under lock that
+// the field was not changed concurrently.
+func withFieldCheck(ctx context.Context, check func(ctx context.Context, tx *sql.Tx) error) context.Context {
+	return context.WithValue(ctx, fieldCheckKey{}, check)
+}
+
+// writeFieldTx runs ddl against the target database and write in a metadata
+// transaction, and records the change from old to new in the audit log and
+// stores e in the event outbox within the same transaction, so neither is
+// kept unless the change commits. A check set with withFieldCheck runs in
+// the transaction before ddl. It reports false when no outbox is configured
+// and the caller still has to emit e.
+func (h *CustomFieldHandler) writeFieldTx(ctx context.Context, e events.Event, actor string, old, new *registry.FieldMeta, ddl func() error, write func(tx *sql.Tx) error) (bool, error) {
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin tx: %w", err)
+	}
+	if check, ok := ctx.Value(fieldCheckKey{}).(func(context.Context, *sql.Tx) error); ok {
+		if err := check(ctx, tx); err != nil {
+			_ = tx.Rollback()
+			return false, err
+		}
+	}
+	if err := ddl(); err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+	if err := write(tx); err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+	forward, err := h.Recorder.WriteTx(ctx, tx, actor, old, new)
+	if err != nil {
+		_ = tx.Rollback()
+		return false, fmt.Errorf("failed to write audit log: %w", err)
+	}
+	queued := true
+	if err := events.EmitTx(ctx, tx, e); errors.Is(err, events.ErrNoOutbox) {
+		queued = false
+	} else if err != nil {
+		_ = tx.Rollback()
+		return false, fmt.Errorf("enqueue event: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit: %w", err)
+	}
+	forward()
+	return queued, nil
+}
+
 func (h *CustomFieldHandler) syncMongoCollection(ctx context.Context, tenant string, mdb monitordbrepo.Record, table string, dbID int64) error {
 	normalizedID := pkgmonitordb.NormalizeDBID(dbID)
 	metas, err := registry.LoadSQLByDB(ctx, h.DB, registry.DBConfig{Driver: h.Driver, Schema: h.Schema, TablePrefix: h.TablePrefix}, tenant, normalizedID)
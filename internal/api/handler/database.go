@@ -7,7 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"time"
+	"strconv"
 
 	"github.com/casbin/casbin/v2"
 	"github.com/faciam-dev/gcfm/internal/domain/capability"
@@ -208,7 +208,9 @@ func (h *DatabaseHandler) create(ctx context.Context, in *createDBInput) (*creat
 	if err != nil {
 		return nil, err
 	}
-	return &createDBOutput{Body: schema.Database{ID: id, Name: in.Body.Name, Driver: in.Body.Driver}}, nil
+	res := schema.Database{ID: id, Name: in.Body.Name, Driver: in.Body.Driver}
+	h.record(ctx, "create", id, nil, &res)
+	return &createDBOutput{Body: res}, nil
 }
 
 func (h *DatabaseHandler) list(ctx context.Context, _ *struct{}) (*listDBOutput, error) {
@@ -263,9 +265,11 @@ func (h *DatabaseHandler) list(ctx context.Context, _ *struct{}) (*listDBOutput,
 
 func (h *DatabaseHandler) delete(ctx context.Context, in *idParam) (*struct{}, error) {
 	tid := tenant.FromContext(ctx)
+	before := h.auditView(ctx, tid, in.ID)
 	if err := h.Repo.Delete(ctx, tid, in.ID); err != nil {
 		return nil, err
 	}
+	h.record(ctx, "delete", in.ID, before, nil)
 	return &struct{}{}, nil
 }
 
@@ -278,6 +282,7 @@ func (h *DatabaseHandler) update(ctx context.Context, in *updateDBInput) (*dbOut
 		}
 		return nil, err
 	}
+	before := h.auditView(ctx, tid, in.ID)
 	if err := h.Repo.Update(ctx, tid, in.ID, in.Body.Name, in.Body.Driver, enc); err != nil {
 		return nil, err
 	}
@@ -286,6 +291,7 @@ func (h *DatabaseHandler) update(ctx context.Context, in *updateDBInput) (*dbOut
 		return nil, err
 	}
 	res := schema.Database{ID: d.ID, Name: d.Name, Driver: d.Driver, CreatedAt: d.CreatedAt}
+	h.record(ctx, "update", in.ID, before, &res)
 	return &dbOutput{Body: res}, nil
 }
 
@@ -303,10 +309,46 @@ func (h *DatabaseHandler) scan(ctx context.Context, in *scanParams) (*scanOutput
 		}
 	}
 	payload := map[string]any{"total": res.Total, "inserted": res.Inserted, "updated": res.Updated, "skipped": res.Skipped, "db_id": in.ID, "tables": tables}
-	actor := middleware.UserFromContext(ctx)
 	if h.Recorder != nil {
-		_ = h.Recorder.WriteJSON(ctx, actor, "scan", payload)
+		_ = h.Recorder.Record(ctx, audit.Event{
+			ResourceType: audit.ResourceDatabase,
+			ResourceID:   strconv.FormatInt(in.ID, 10),
+			Action:       "scan",
+			Actor:        middleware.UserFromContext(ctx),
+			After:        payload,
+		})
 	}
-	events.Emit(ctx, events.Event{Name: "cf.scan", Time: time.Now(), Data: payload, ID: fmt.Sprintf("%d", in.ID)})
+	events.Emit(ctx, events.Event{
+		Name:    events.TypeScan,
+		Subject: strconv.FormatInt(in.ID, 10),
+		Data:    events.ScanCompleted{DBID: in.ID, Tables: tables, Total: res.Total, Inserted: res.Inserted, Updated: res.Updated, Skipped: res.Skipped},
+	})
 	return &scanOutput{Body: res}, nil
 }
+
+// auditView loads the audited state of a database. DSNs are never included.
+// It returns nil when no recorder is configured or the database is missing.
+func (h *DatabaseHandler) auditView(ctx context.Context, tid string, id int64) *schema.Database {
+	if h.Recorder == nil {
+		return nil
+	}
+	d, err := h.Repo.Get(ctx, tid, id)
+	if err != nil {
+		return nil
+	}
+	return &schema.Database{ID: d.ID, Name: d.Name, Driver: d.Driver, CreatedAt: d.CreatedAt}
+}
+
+func (h *DatabaseHandler) record(ctx context.Context, action string, id int64, before, after *schema.Database) {
+	if h.Recorder == nil {
+		return
+	}
+	_ = h.Recorder.Record(ctx, audit.Event{
+		ResourceType: audit.ResourceDatabase,
+		ResourceID:   strconv.FormatInt(id, 10),
+		Action:       action,
+		Actor:        middleware.UserFromContext(ctx),
+		Before:       before,
+		After:        after,
+	})
+}
@@ -0,0 +1,312 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/faciam-dev/gcfm/internal/events"
+	"github.com/faciam-dev/gcfm/internal/server/middleware"
+	"github.com/faciam-dev/gcfm/pkg/audit"
+	"github.com/faciam-dev/gcfm/pkg/crypto"
+	"github.com/faciam-dev/gcfm/pkg/tenant"
+)
+
+// eventWebhook is the API view of a tenant webhook. The secret is only
+// returned when it is created or rotated.
+type eventWebhook struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events,omitempty"`
+	Filter    string    `json:"filter,omitempty"`
+	Mode      string    `json:"mode"`
+	Enabled   bool      `json:"enabled"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type eventWebhookBody struct {
+	URL string `json:"url" doc:"HTTP(S) endpoint receiving the events"`
+	// Events and Filter form the subscription; see events.Subscription.
+	Events  []string `json:"events,omitempty" doc:"Event type globs, e.g. cf.field.*. Empty subscribes to all events."`
+	Filter  string   `json:"filter,omitempty" doc:"CEL expression over the event attributes (event.type, event.subject, ...) and data"`
+	Mode    string   `json:"mode,omitempty" enum:"structured,binary" doc:"CloudEvents HTTP binding"`
+	Enabled *bool    `json:"enabled,omitempty" doc:"Defaults to true"`
+}
+
+type eventWebhookInput struct {
+	Body eventWebhookBody
+}
+
+type eventWebhookIDParams struct {
+	ID int64 `path:"id"`
+}
+
+type eventWebhookUpdateInput struct {
+	ID   int64 `path:"id"`
+	Body eventWebhookBody
+}
+
+type eventWebhookOutput struct{ Body eventWebhook }
+
+type eventWebhooksOutput struct {
+	Body struct {
+		Items []eventWebhook `json:"items"`
+	}
+}
+
+type eventWebhookDelivery struct {
+	ID           int64     `json:"id"`
+	EventID      string    `json:"eventId"`
+	EventType    string    `json:"eventType"`
+	Status       string    `json:"status"`
+	ResponseCode int       `json:"responseCode,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	DurationMS   int64     `json:"durationMs"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type eventWebhookDeliveriesParams struct {
+	ID    int64 `path:"id"`
+	Limit int   `query:"limit" minimum:"0" maximum:"500" doc:"Defaults to 50"`
+}
+
+type eventWebhookDeliveriesOutput struct {
+	Body struct {
+		Items []eventWebhookDelivery `json:"items"`
+	}
+}
+
+func registerEventWebhooks(api huma.API, h *EventsHandler) {
+	huma.Register(api, huma.Operation{
+		OperationID: "listEventWebhooks",
+		Method:      http.MethodGet,
+		Path:        "/v1/events/webhooks",
+		Summary:     "List the tenant's event webhooks",
+		Tags:        []string{"Events"},
+	}, h.listWebhooks)
+	huma.Register(api, huma.Operation{
+		OperationID:   "createEventWebhook",
+		Method:        http.MethodPost,
+		Path:          "/v1/events/webhooks",
+		Summary:       "Register an event webhook",
+		Description:   "The response contains the signing secret; it is not returned again.",
+		Tags:          []string{"Events"},
+		DefaultStatus: http.StatusCreated,
+		Errors:        []int{http.StatusUnprocessableEntity},
+	}, h.createWebhook)
+	huma.Register(api, huma.Operation{
+		OperationID: "getEventWebhook",
+		Method:      http.MethodGet,
+		Path:        "/v1/events/webhooks/{id}",
+		Summary:     "Get an event webhook",
+		Tags:        []string{"Events"},
+		Errors:      []int{http.StatusNotFound},
+	}, h.getWebhook)
+	huma.Register(api, huma.Operation{
+		OperationID: "updateEventWebhook",
+		Method:      http.MethodPut,
+		Path:        "/v1/events/webhooks/{id}",
+		Summary:     "Update an event webhook",
+		Tags:        []string{"Events"},
+		Errors:      []int{http.StatusNotFound, http.StatusUnprocessableEntity},
+	}, h.updateWebhook)
+	huma.Register(api, huma.Operation{
+		OperationID:   "deleteEventWebhook",
+		Method:        http.MethodDelete,
+		Path:          "/v1/events/webhooks/{id}",
+		Summary:       "Delete an event webhook and its delivery log",
+		Tags:          []string{"Events"},
+		DefaultStatus: http.StatusNoContent,
+		Errors:        []int{http.StatusNotFound},
+	}, h.deleteWebhook)
+	huma.Register(api, huma.Operation{
+		OperationID: "rotateEventWebhookSecret",
+		Method:      http.MethodPost,
+		Path:        "/v1/events/webhooks/{id}/rotate-secret",
+		Summary:     "Replace the signing secret of an event webhook",
+		Tags:        []string{"Events"},
+		Errors:      []int{http.StatusNotFound},
+	}, h.rotateWebhookSecret)
+	huma.Register(api, huma.Operation{
+		OperationID: "listEventWebhookDeliveries",
+		Method:      http.MethodGet,
+		Path:        "/v1/events/webhooks/{id}/deliveries",
+		Summary:     "List recent deliveries of an event webhook",
+		Tags:        []string{"Events"},
+		Errors:      []int{http.StatusNotFound},
+	}, h.listWebhookDeliveries)
+}
+
+func toEventWebhook(w events.TenantWebhook) eventWebhook {
+	return eventWebhook{
+		ID:        w.ID,
+		URL:       w.URL,
+		Events:    w.Events,
+		Filter:    w.Filter,
+		Mode:      w.Mode,
+		Enabled:   w.Enabled,
+		CreatedAt: w.CreatedAt,
+		UpdatedAt: w.UpdatedAt,
+	}
+}
+
+// webhookFromBody validates b and converts it for the store. Unless private
+// networks are allowed, the URL must resolve to public addresses only.
+func (h *EventsHandler) webhookFromBody(ctx context.Context, tid string, id int64, b eventWebhookBody) (events.TenantWebhook, error) {
+	u, err := url.Parse(b.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return events.TenantWebhook{}, huma.Error422UnprocessableEntity("url must be an absolute http or https URL")
+	}
+	if !h.WebhookConfig.AllowPrivateNetworks {
+		if err := events.CheckWebhookURL(ctx, b.URL, h.Resolver); err != nil {
+			return events.TenantWebhook{}, huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+	w := events.TenantWebhook{ID: id, Tenant: tid, URL: b.URL, Events: b.Events, Filter: b.Filter, Mode: b.Mode, Enabled: true}
+	if b.Enabled != nil {
+		w.Enabled = *b.Enabled
+	}
+	if _, err := w.Subscription().Compile(); err != nil {
+		return events.TenantWebhook{}, huma.Error422UnprocessableEntity(err.Error())
+	}
+	return w, nil
+}
+
+func webhookError(err error) error {
+	if errors.Is(err, events.ErrWebhookNotFound) {
+		return huma.Error404NotFound("webhook not found")
+	}
+	if errors.Is(err, crypto.ErrKeyNotSet) {
+		return huma.NewError(http.StatusInternalServerError, err.Error())
+	}
+	return err
+}
+
+func (h *EventsHandler) listWebhooks(ctx context.Context, _ *struct{}) (*eventWebhooksOutput, error) {
+	hooks, err := h.Webhooks.List(ctx, tenant.FromContext(ctx), false)
+	if err != nil {
+		return nil, err
+	}
+	out := &eventWebhooksOutput{}
+	out.Body.Items = make([]eventWebhook, len(hooks))
+	for i, w := range hooks {
+		out.Body.Items[i] = toEventWebhook(w)
+	}
+	return out, nil
+}
+
+func (h *EventsHandler) createWebhook(ctx context.Context, in *eventWebhookInput) (*eventWebhookOutput, error) {
+	w, err := h.webhookFromBody(ctx, tenant.FromContext(ctx), 0, in.Body)
+	if err != nil {
+		return nil, err
+	}
+	if w, err = h.Webhooks.Create(ctx, w); err != nil {
+		return nil, webhookError(err)
+	}
+	res := toEventWebhook(w)
+	h.recordWebhook(ctx, "create", w.ID, nil, &res)
+	res.Secret = w.Secret
+	return &eventWebhookOutput{Body: res}, nil
+}
+
+func (h *EventsHandler) getWebhook(ctx context.Context, p *eventWebhookIDParams) (*eventWebhookOutput, error) {
+	w, err := h.Webhooks.Get(ctx, tenant.FromContext(ctx), p.ID)
+	if err != nil {
+		return nil, webhookError(err)
+	}
+	return &eventWebhookOutput{Body: toEventWebhook(w)}, nil
+}
+
+func (h *EventsHandler) updateWebhook(ctx context.Context, in *eventWebhookUpdateInput) (*eventWebhookOutput, error) {
+	tid := tenant.FromContext(ctx)
+	before, err := h.Webhooks.Get(ctx, tid, in.ID)
+	if err != nil {
+		return nil, webhookError(err)
+	}
+	w, err := h.webhookFromBody(ctx, tid, in.ID, in.Body)
+	if err != nil {
+		return nil, err
+	}
+	if w, err = h.Webhooks.Update(ctx, w); err != nil {
+		return nil, webhookError(err)
+	}
+	old, res := toEventWebhook(before), toEventWebhook(w)
+	h.recordWebhook(ctx, "update", w.ID, &old, &res)
+	return &eventWebhookOutput{Body: res}, nil
+}
+
+func (h *EventsHandler) deleteWebhook(ctx context.Context, p *eventWebhookIDParams) (*struct{}, error) {
+	tid := tenant.FromContext(ctx)
+	before, err := h.Webhooks.Get(ctx, tid, p.ID)
+	if err != nil {
+		return nil, webhookError(err)
+	}
+	if err := h.Webhooks.Delete(ctx, tid, p.ID); err != nil {
+		return nil, webhookError(err)
+	}
+	old := toEventWebhook(before)
+	h.recordWebhook(ctx, "delete", p.ID, &old, nil)
+	return &struct{}{}, nil
+}
+
+func (h *EventsHandler) rotateWebhookSecret(ctx context.Context, p *eventWebhookIDParams) (*eventWebhookOutput, error) {
+	w, err := h.Webhooks.RotateSecret(ctx, tenant.FromContext(ctx), p.ID)
+	if err != nil {
+		return nil, webhookError(err)
+	}
+	res := toEventWebhook(w)
+	h.recordWebhook(ctx, "rotate_secret", w.ID, nil, nil)
+	res.Secret = w.Secret
+	return &eventWebhookOutput{Body: res}, nil
+}
+
+func (h *EventsHandler) listWebhookDeliveries(ctx context.Context, p *eventWebhookDeliveriesParams) (*eventWebhookDeliveriesOutput, error) {
+	tid := tenant.FromContext(ctx)
+	if _, err := h.Webhooks.Get(ctx, tid, p.ID); err != nil {
+		return nil, webhookError(err)
+	}
+	limit := p.Limit
+	if limit == 0 {
+		limit = 50
+	}
+	ds, err := h.Webhooks.Deliveries(ctx, tid, p.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := &eventWebhookDeliveriesOutput{}
+	out.Body.Items = make([]eventWebhookDelivery, len(ds))
+	for i, d := range ds {
+		out.Body.Items[i] = eventWebhookDelivery{
+			ID:           d.ID,
+			EventID:      d.EventID,
+			EventType:    d.EventType,
+			Status:       d.Status,
+			ResponseCode: d.ResponseCode,
+			Error:        d.Error,
+			DurationMS:   d.Duration.Milliseconds(),
+			CreatedAt:    d.CreatedAt,
+		}
+	}
+	return out, nil
+}
+
+// recordWebhook audits a webhook change. Secrets are never recorded.
+func (h *EventsHandler) recordWebhook(ctx context.Context, action string, id int64, before, after *eventWebhook) {
+	if h.Recorder == nil {
+		return
+	}
+	_ = h.Recorder.Record(ctx, audit.Event{
+		ResourceType: audit.ResourceEventWebhook,
+		ResourceID:   strconv.FormatInt(id, 10),
+		Action:       action,
+		Actor:        middleware.UserFromContext(ctx),
+		Before:       before,
+		After:        after,
+	})
+}
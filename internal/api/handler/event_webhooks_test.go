@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/faciam-dev/gcfm/internal/events"
+	"github.com/faciam-dev/gcfm/pkg/tenant"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+)
+
+// staticResolver resolves every host to its addresses.
+type staticResolver map[string][]string
+
+func (r staticResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	addrs, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	out := make([]net.IPAddr, len(addrs))
+	for i, a := range addrs {
+		out[i] = net.IPAddr{IP: net.ParseIP(a)}
+	}
+	return out, nil
+}
+
+var testResolver = staticResolver{
+	"example.com":          {"93.184.215.14"},
+	"internal.example.com": {"93.184.215.14", "192.168.1.10"},
+}
+
+func TestCreateEventWebhookValidation(t *testing.T) {
+	h := &EventsHandler{Resolver: testResolver}
+	ctx := tenant.WithTenant(context.Background(), "t1")
+	for _, body := range []eventWebhookBody{
+		{URL: "ftp://example.com/hook"},
+		{URL: "/relative"},
+		{URL: "https://example.com/hook", Events: []string{"cf.[field"}},
+		{URL: "https://example.com/hook", Filter: "data.table =="},
+		{URL: "http://127.0.0.1:8080/hook"},
+		{URL: "http://169.254.169.254/latest/meta-data"},
+		{URL: "http://10.0.0.5/hook"},
+		{URL: "http://[::1]/hook"},
+		{URL: "https://internal.example.com/hook"},
+		{URL: "https://unknown.example.com/hook"},
+	} {
+		_, err := h.createWebhook(ctx, &eventWebhookInput{Body: body})
+		var se huma.StatusError
+		if !errors.As(err, &se) || se.GetStatus() != http.StatusUnprocessableEntity {
+			t.Errorf("%+v: expected 422, got %v", body, err)
+		}
+	}
+}
+
+func TestCreateEventWebhookAllowsPrivateNetworksWhenConfigured(t *testing.T) {
+	h := &EventsHandler{WebhookConfig: events.TenantWebhookConfig{AllowPrivateNetworks: true}}
+	w, err := h.webhookFromBody(context.Background(), "t1", 0, eventWebhookBody{URL: "http://127.0.0.1:8080/hook"})
+	if err != nil || w.URL != "http://127.0.0.1:8080/hook" {
+		t.Fatalf("webhook = %+v, err = %v", w, err)
+	}
+}
+
+func TestCreateEventWebhookReturnsSecretOnce(t *testing.T) {
+	t.Setenv("CF_ENC_KEY", "0123456789abcdef")
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	cols := []string{"id", "tenant_id", "url", "secret", "event_types", "filter_expr", "mode", "enabled", "created_at", "updated_at"}
+	mock.ExpectExec("INSERT INTO `gcfm_event_webhooks`").
+		WithArgs(true, "cf.field.*", "", "structured", sqlmock.AnyArg(), "t1", "https://example.com/hook").
+		WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectQuery("SELECT .* FROM `gcfm_event_webhooks` WHERE `tenant_id` = \\? AND `id` = \\?").
+		WithArgs("t1", int64(4)).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(4, "t1", "https://example.com/hook", "whsec_x", "cf.field.*", "", "structured", true, nil, nil))
+	mock.ExpectQuery("SELECT .* FROM `gcfm_event_webhooks` WHERE `tenant_id` = \\? AND `id` = \\?").
+		WithArgs("t1", int64(4)).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(4, "t1", "https://example.com/hook", "whsec_x", "cf.field.*", "", "structured", true, nil, nil))
+
+	h := &EventsHandler{Webhooks: &events.WebhookStore{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_"}, Resolver: testResolver}
+	ctx := tenant.WithTenant(context.Background(), "t1")
+	out, err := h.createWebhook(ctx, &eventWebhookInput{Body: eventWebhookBody{URL: "https://example.com/hook", Events: []string{"cf.field.*"}}})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if out.Body.ID != 4 || out.Body.Secret != "whsec_x" || !out.Body.Enabled {
+		t.Fatalf("unexpected webhook: %+v", out.Body)
+	}
+	got, err := h.getWebhook(ctx, &eventWebhookIDParams{ID: 4})
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Body.Secret != "" {
+		t.Fatal("secret returned by get")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
+
+func TestEventWebhookOtherTenantNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT .* FROM `gcfm_event_webhooks` WHERE `tenant_id` = \\? AND `id` = \\?").
+		WithArgs("t2", int64(4)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	h := &EventsHandler{Webhooks: &events.WebhookStore{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_"}}
+	ctx := tenant.WithTenant(context.Background(), "t2")
+	_, err = h.listWebhookDeliveries(ctx, &eventWebhookDeliveriesParams{ID: 4})
+	var se huma.StatusError
+	if !errors.As(err, &se) || se.GetStatus() != http.StatusNotFound {
+		t.Fatalf("expected 404, got %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/faciam-dev/gcfm/internal/events"
+	"github.com/faciam-dev/gcfm/pkg/audit"
+)
+
+// EventsHandler serves the event catalogue, the live event stream, the
+// tenant webhook registrations and the DLQ.
+type EventsHandler struct {
+	// SchemaBaseURL is the configured dataschema base; see
+	// events.Config.SchemaBaseURL.
+	SchemaBaseURL string
+	Webhooks      *events.WebhookStore
+	// WebhookConfig controls which addresses tenant webhooks may target.
+	WebhookConfig events.TenantWebhookConfig
+	// Resolver resolves webhook hosts on registration; nil uses the
+	// system resolver.
+	Resolver events.Resolver
+	// Replayer replays DLQ entries; its DLQ backs /v1/events/failed.
+	Replayer *events.Replayer
+	// Outbox backs /v1/events/stream; the stream is unavailable without it.
+	Outbox *events.Outbox
+	Stream events.StreamConfig
+	// AllowedOrigins lists the origins browsers may open WebSocket streams
+	// from; "*" allows any. Requests without an Origin header, as sent by
+	// non-browser clients, are always accepted.
+	AllowedOrigins []string
+	// Can reports whether the caller holds a capability of CapMatrix. When
+	// nil, streams are only filtered by tenant.
+	Can      func(ctx context.Context, capKey string) bool
+	Recorder *audit.Recorder
+}
+
+type eventSchemaItem struct {
+	Name string `json:"name"`
+	// DataSchema is the URI sent in the dataschema attribute.
+	DataSchema string `json:"dataschema"`
+}
+
+type eventSchemasOutput struct {
+	Body struct {
+		Items []eventSchemaItem `json:"items"`
+	}
+}
+
+type eventSchemaParams struct {
+	Name string `path:"name" doc:"Schema name, e.g. cf.field.updated or cf.audit"`
+}
+
+type eventSchemaOutput struct {
+	ContentType string `header:"Content-Type"`
+	Body        []byte
+}
+
+func RegisterEvents(api huma.API, h *EventsHandler) {
+	huma.Register(api, huma.Operation{
+		OperationID: "listEventSchemas",
+		Method:      http.MethodGet,
+		Path:        "/v1/events/schemas",
+		Summary:     "List event data schemas",
+		Tags:        []string{"Events"},
+	}, h.listSchemas)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "getEventSchema",
+		Method:      http.MethodGet,
+		Path:        "/v1/events/schemas/{name}",
+		Summary:     "Get the JSON Schema of an event type",
+		Description: "cf.audit.<action> events share the cf.audit schema.",
+		Tags:        []string{"Events"},
+		Errors:      []int{http.StatusNotFound},
+	}, h.getSchema)
+
+	registerEventStream(api, h)
+	registerEventWebhooks(api, h)
+	registerFailedEvents(api, h)
+}
+
+func (h *EventsHandler) listSchemas(ctx context.Context, _ *struct{}) (*eventSchemasOutput, error) {
+	out := &eventSchemasOutput{}
+	for _, name := range events.SchemaNames() {
+		out.Body.Items = append(out.Body.Items, eventSchemaItem{Name: name, DataSchema: events.SchemaURL(h.SchemaBaseURL, name)})
+	}
+	return out, nil
+}
+
+func (h *EventsHandler) getSchema(ctx context.Context, p *eventSchemaParams) (*eventSchemaOutput, error) {
+	name, ok := events.SchemaName(p.Name)
+	if !ok {
+		return nil, huma.Error404NotFound("unknown event type")
+	}
+	data, ok := events.Schema(name)
+	if !ok {
+		return nil, huma.Error404NotFound("unknown event type")
+	}
+	return &eventSchemaOutput{ContentType: "application/schema+json", Body: data}, nil
+}
@@ -0,0 +1,250 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/faciam-dev/gcfm/internal/events"
+	"github.com/faciam-dev/gcfm/pkg/tenant"
+)
+
+// failedEvent is the API view of a DLQ entry.
+type failedEvent struct {
+	ID             int64      `json:"id"`
+	EventID        string     `json:"eventId"`
+	Type           string     `json:"type"`
+	Sink           string     `json:"sink"`
+	Attempts       int        `json:"attempts"`
+	LastError      string     `json:"lastError,omitempty"`
+	InsertedAt     time.Time  `json:"insertedAt"`
+	ReplayAttempts int        `json:"replayAttempts"`
+	NextReplayAt   *time.Time `json:"nextReplayAt,omitempty"`
+	// Event is the CloudEvent as the sink would have received it. It is
+	// only set when inspecting a single entry.
+	Event *events.CloudEvent `json:"event,omitempty"`
+}
+
+type failedFilterParams struct {
+	Sink   string    `query:"sink" doc:"Only entries of this sink"`
+	Type   string    `query:"type" doc:"Only events of this type"`
+	Before time.Time `query:"before" doc:"Only entries inserted before this time (RFC3339)"`
+}
+
+func (p failedFilterParams) filter(tid string) events.FailedFilter {
+	return events.FailedFilter{Tenant: tid, Sink: p.Sink, Name: p.Type, Before: p.Before}
+}
+
+type failedListParams struct {
+	failedFilterParams
+	BeforeID int64 `query:"before_id" doc:"Page cursor: the nextBeforeId of the previous page"`
+	Limit    int   `query:"limit" minimum:"0" maximum:"500" doc:"Defaults to 50"`
+}
+
+type failedListOutput struct {
+	Body struct {
+		Items        []failedEvent `json:"items"`
+		NextBeforeID int64         `json:"nextBeforeId,omitempty"`
+	}
+}
+
+type failedIDParams struct {
+	ID int64 `path:"id"`
+}
+
+type failedOutput struct{ Body failedEvent }
+
+type failedReplayOutput struct {
+	Body struct {
+		Replayed bool `json:"replayed"`
+	}
+}
+
+type failedCountOutput struct {
+	Body struct {
+		Count int64 `json:"count"`
+	}
+}
+
+func registerFailedEvents(api huma.API, h *EventsHandler) {
+	huma.Register(api, huma.Operation{
+		OperationID: "listFailedEvents",
+		Method:      http.MethodGet,
+		Path:        "/v1/events/failed",
+		Summary:     "List events in the DLQ",
+		Tags:        []string{"Events"},
+	}, h.listFailed)
+	huma.Register(api, huma.Operation{
+		OperationID: "purgeFailedEvents",
+		Method:      http.MethodDelete,
+		Path:        "/v1/events/failed",
+		Summary:     "Delete the DLQ entries matching the filters",
+		Tags:        []string{"Events"},
+	}, h.purgeFailed)
+	huma.Register(api, huma.Operation{
+		OperationID: "scheduleFailedEvents",
+		Method:      http.MethodPost,
+		Path:        "/v1/events/failed/replay",
+		Summary:     "Schedule the DLQ entries matching the filters for replay",
+		Description: "The replay worker picks the entries up on its next pass with a fresh backoff.",
+		Tags:        []string{"Events"},
+	}, h.scheduleFailed)
+	huma.Register(api, huma.Operation{
+		OperationID: "getFailedEvent",
+		Method:      http.MethodGet,
+		Path:        "/v1/events/failed/{id}",
+		Summary:     "Inspect a DLQ entry",
+		Tags:        []string{"Events"},
+		Errors:      []int{http.StatusNotFound},
+	}, h.getFailed)
+	huma.Register(api, huma.Operation{
+		OperationID:   "deleteFailedEvent",
+		Method:        http.MethodDelete,
+		Path:          "/v1/events/failed/{id}",
+		Summary:       "Delete a DLQ entry",
+		Tags:          []string{"Events"},
+		DefaultStatus: http.StatusNoContent,
+		Errors:        []int{http.StatusNotFound},
+	}, h.deleteFailed)
+	huma.Register(api, huma.Operation{
+		OperationID: "replayFailedEvent",
+		Method:      http.MethodPost,
+		Path:        "/v1/events/failed/{id}/replay",
+		Summary:     "Replay a DLQ entry now",
+		Description: "The entry is removed when its sink accepts the event. A sink error is returned as 502, " +
+			"and an entry another worker is replaying as 409.",
+		Tags:   []string{"Events"},
+		Errors: []int{http.StatusNotFound, http.StatusConflict, http.StatusBadGateway},
+	}, h.replayFailed)
+}
+
+func toFailedEvent(f events.FailedEvent) failedEvent {
+	return failedEvent{
+		ID:             f.ID,
+		EventID:        f.Event.ID,
+		Type:           f.Name,
+		Sink:           f.Sink,
+		Attempts:       f.Attempts,
+		LastError:      f.LastError,
+		InsertedAt:     f.InsertedAt,
+		ReplayAttempts: f.ReplayAttempts,
+		NextReplayAt:   f.NextReplayAt,
+	}
+}
+
+// dlq returns the DLQ store, or an error when events are not stored.
+func (h *EventsHandler) dlq() (*events.SQLDLQ, error) {
+	if h.Replayer == nil || h.Replayer.DLQ == nil {
+		return nil, huma.NewError(http.StatusNotImplemented, "event DLQ not configured")
+	}
+	return h.Replayer.DLQ, nil
+}
+
+func failedError(err error) error {
+	if errors.Is(err, events.ErrFailedEventNotFound) {
+		return huma.Error404NotFound("failed event not found")
+	}
+	return err
+}
+
+func (h *EventsHandler) listFailed(ctx context.Context, p *failedListParams) (*failedListOutput, error) {
+	q, err := h.dlq()
+	if err != nil {
+		return nil, err
+	}
+	f := p.filter(tenant.FromContext(ctx))
+	f.BeforeID = p.BeforeID
+	f.Limit = p.Limit
+	if f.Limit == 0 {
+		f.Limit = 50
+	}
+	rows, err := q.List(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	out := &failedListOutput{}
+	out.Body.Items = make([]failedEvent, len(rows))
+	for i, r := range rows {
+		out.Body.Items[i] = toFailedEvent(r)
+	}
+	if len(rows) == f.Limit {
+		out.Body.NextBeforeID = rows[len(rows)-1].ID
+	}
+	return out, nil
+}
+
+func (h *EventsHandler) getFailed(ctx context.Context, p *failedIDParams) (*failedOutput, error) {
+	q, err := h.dlq()
+	if err != nil {
+		return nil, err
+	}
+	f, err := q.Get(ctx, tenant.FromContext(ctx), p.ID)
+	if err != nil {
+		return nil, failedError(err)
+	}
+	res := toFailedEvent(f)
+	if ce, err := f.Event.CloudEvent(); err == nil {
+		res.Event = &ce
+	}
+	return &failedOutput{Body: res}, nil
+}
+
+func (h *EventsHandler) deleteFailed(ctx context.Context, p *failedIDParams) (*struct{}, error) {
+	q, err := h.dlq()
+	if err != nil {
+		return nil, err
+	}
+	if err := q.Delete(ctx, tenant.FromContext(ctx), p.ID); err != nil {
+		return nil, failedError(err)
+	}
+	return &struct{}{}, nil
+}
+
+func (h *EventsHandler) purgeFailed(ctx context.Context, p *failedFilterParams) (*failedCountOutput, error) {
+	q, err := h.dlq()
+	if err != nil {
+		return nil, err
+	}
+	n, err := q.Purge(ctx, p.filter(tenant.FromContext(ctx)))
+	if err != nil {
+		return nil, err
+	}
+	out := &failedCountOutput{}
+	out.Body.Count = n
+	return out, nil
+}
+
+func (h *EventsHandler) scheduleFailed(ctx context.Context, p *failedFilterParams) (*failedCountOutput, error) {
+	q, err := h.dlq()
+	if err != nil {
+		return nil, err
+	}
+	n, err := q.Schedule(ctx, p.filter(tenant.FromContext(ctx)), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	out := &failedCountOutput{}
+	out.Body.Count = n
+	return out, nil
+}
+
+func (h *EventsHandler) replayFailed(ctx context.Context, p *failedIDParams) (*failedReplayOutput, error) {
+	if _, err := h.dlq(); err != nil {
+		return nil, err
+	}
+	err := h.Replayer.ReplayNow(ctx, tenant.FromContext(ctx), p.ID)
+	if errors.Is(err, events.ErrFailedEventNotFound) {
+		return nil, failedError(err)
+	}
+	if errors.Is(err, events.ErrFailedEventLeased) {
+		return nil, huma.Error409Conflict("failed event is being replayed")
+	}
+	if err != nil {
+		return nil, huma.NewError(http.StatusBadGateway, err.Error())
+	}
+	out := &failedReplayOutput{}
+	out.Body.Replayed = true
+	return out, nil
+}
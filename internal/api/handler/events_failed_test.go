@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/faciam-dev/gcfm/internal/events"
+	"github.com/faciam-dev/gcfm/pkg/tenant"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+)
+
+var failedCols = []string{"id", "tenant_id", "name", "sink", "payload", "attempts", "last_error", "inserted_at", "replay_attempts", "next_replay_at"}
+
+func failedHandler(t *testing.T) (*EventsHandler, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	dlq := &events.SQLDLQ{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_"}
+	return &EventsHandler{Replayer: events.NewReplayer(dlq, events.NewDispatcher(events.Config{}, nil), events.ReplayConfig{})}, mock
+}
+
+func TestFailedEventsNotConfigured(t *testing.T) {
+	h := &EventsHandler{}
+	_, err := h.listFailed(context.Background(), &failedListParams{})
+	var se huma.StatusError
+	if !errors.As(err, &se) || se.GetStatus() != http.StatusNotImplemented {
+		t.Fatalf("expected 501, got %v", err)
+	}
+}
+
+func TestListFailedEventsPages(t *testing.T) {
+	h, mock := failedHandler(t)
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	payload := `{"name":"cf.field.created","time":"2026-05-01T11:00:00Z","id":"e1"}`
+	mock.ExpectQuery("SELECT .* FROM `gcfm_events_failed` WHERE `tenant_id` = \\? AND `sink` = \\? AND `id` < \\? ORDER BY `id` DESC LIMIT 2").
+		WithArgs("t1", "webhook", int64(10)).
+		WillReturnRows(sqlmock.NewRows(failedCols).
+			AddRow(9, "t1", "cf.field.created", "webhook", payload, 3, "503", now, 0, nil).
+			AddRow(7, "t1", "cf.field.created", "webhook", payload, 3, "503", now, 1, now))
+
+	ctx := tenant.WithTenant(context.Background(), "t1")
+	p := &failedListParams{failedFilterParams: failedFilterParams{Sink: "webhook"}, BeforeID: 10, Limit: 2}
+	out, err := h.listFailed(ctx, p)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(out.Body.Items) != 2 || out.Body.NextBeforeID != 7 {
+		t.Fatalf("unexpected page: %+v", out.Body)
+	}
+	if it := out.Body.Items[0]; it.EventID != "e1" || it.NextReplayAt != nil || it.Event != nil {
+		t.Fatalf("unexpected item: %+v", it)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
+
+func TestFailedEventOtherTenantNotFound(t *testing.T) {
+	h, mock := failedHandler(t)
+	mock.ExpectQuery("SELECT .* FROM `gcfm_events_failed` WHERE `tenant_id` = \\? AND `id` = \\?").
+		WithArgs("t2", int64(4)).
+		WillReturnRows(sqlmock.NewRows(failedCols))
+
+	ctx := tenant.WithTenant(context.Background(), "t2")
+	_, err := h.replayFailed(ctx, &failedIDParams{ID: 4})
+	var se huma.StatusError
+	if !errors.As(err, &se) || se.GetStatus() != http.StatusNotFound {
+		t.Fatalf("expected 404, got %v", err)
+	}
+}
+
+func TestReplayFailedEventLeased(t *testing.T) {
+	h, mock := failedHandler(t)
+	payload := `{"name":"cf.field.created","time":"2026-05-01T11:00:00Z","id":"e1"}`
+	mock.ExpectQuery("SELECT .* FROM `gcfm_events_failed` WHERE `tenant_id` = \\? AND `id` = \\?").
+		WithArgs("t1", int64(4)).
+		WillReturnRows(sqlmock.NewRows(failedCols).
+			AddRow(4, "t1", "cf.field.created", "webhook", payload, 3, "503", time.Now(), 0, nil))
+	mock.ExpectExec("UPDATE `gcfm_events_failed` SET `leased_until` = \\?").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ctx := tenant.WithTenant(context.Background(), "t1")
+	_, err := h.replayFailed(ctx, &failedIDParams{ID: 4})
+	var se huma.StatusError
+	if !errors.As(err, &se) || se.GetStatus() != http.StatusConflict {
+		t.Fatalf("expected 409, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
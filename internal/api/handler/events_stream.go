@@ -0,0 +1,324 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/faciam-dev/gcfm/internal/events"
+	"github.com/faciam-dev/gcfm/internal/logger"
+	"github.com/faciam-dev/gcfm/pkg/tenant"
+	"golang.org/x/net/websocket"
+)
+
+type eventStreamParams struct {
+	LastEventID string `header:"Last-Event-ID" doc:"Resume after this event id"`
+	// After is the query form of Last-Event-ID for clients that cannot set
+	// headers on the first request, such as EventSource.
+	After string `query:"after" doc:"Resume after this event id; 0 replays every retained event"`
+	Types string `query:"types" doc:"Comma separated event type globs, e.g. cf.field.*"`
+	// Upgrade selects the WebSocket transport.
+	Upgrade string `header:"Upgrade" doc:"websocket to receive the events over a WebSocket instead of SSE"`
+}
+
+func registerEventStream(api huma.API, h *EventsHandler) {
+	huma.Register(api, huma.Operation{
+		OperationID: "streamEvents",
+		Method:      http.MethodGet,
+		Path:        "/v1/events/stream",
+		Summary:     "Stream the tenant's events as Server-Sent Events or over a WebSocket",
+		Description: "Every message carries the CloudEvent as data, its type as event and a resumable id. " +
+			"Without Last-Event-ID the stream starts with the next event. Events of resources the " +
+			"caller cannot read are left out. A request with Upgrade: websocket receives the same " +
+			"events as JSON text messages {\"id\": ..., \"event\": ...}.",
+		Tags:   []string{"Events"},
+		Errors: []int{http.StatusUnprocessableEntity, http.StatusNotImplemented},
+	}, h.stream)
+}
+
+// streamCapability returns the capability needed to receive events of type t.
+func streamCapability(t string) string {
+	if strings.HasPrefix(t, events.TypeAuditPrefix) {
+		return "audit:list"
+	}
+	return "custom_fields:list"
+}
+
+func (h *EventsHandler) stream(ctx context.Context, p *eventStreamParams) (*huma.StreamResponse, error) {
+	if h.Outbox == nil {
+		return nil, huma.NewError(http.StatusNotImplemented, "event stream requires the events outbox")
+	}
+	sub := events.Subscription{Events: splitList(p.Types)}
+	m, err := sub.Compile()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	tid := tenant.FromContext(ctx)
+	last := p.LastEventID
+	if last == "" {
+		last = p.After
+	}
+	var after int64
+	if last == "" {
+		if after, err = h.Outbox.LastID(ctx, tid); err != nil {
+			return nil, err
+		}
+	} else if after, err = strconv.ParseInt(last, 10, 64); err != nil || after < 0 {
+		return nil, huma.Error422UnprocessableEntity("invalid event id " + strconv.Quote(last))
+	}
+	if strings.EqualFold(p.Upgrade, "websocket") {
+		return &huma.StreamResponse{Body: func(hctx huma.Context) {
+			r, w := humachi.Unwrap(hctx)
+			if _, ok := w.(http.Hijacker); !ok {
+				hctx.SetStatus(http.StatusNotImplemented)
+				return
+			}
+			websocket.Server{
+				Handshake: func(_ *websocket.Config, r *http.Request) error { return h.checkOrigin(r) },
+				Handler: func(conn *websocket.Conn) {
+					if err := h.streamWebSocket(hctx.Context(), conn, tid, after, m); err != nil {
+						logger.L.Error("event stream", "tenant", tid, "transport", "websocket", "err", err)
+					}
+				},
+			}.ServeHTTP(w, r)
+		}}, nil
+	}
+	return &huma.StreamResponse{Body: func(hctx huma.Context) {
+		hctx.SetHeader("Content-Type", "text/event-stream")
+		hctx.SetHeader("Cache-Control", "no-cache")
+		hctx.SetHeader("Connection", "keep-alive")
+		w := hctx.BodyWriter()
+		flush := func() {}
+		if rw, ok := w.(http.ResponseWriter); ok {
+			rc := http.NewResponseController(rw)
+			// The server's WriteTimeout would otherwise end the stream.
+			_ = rc.SetWriteDeadline(time.Time{})
+			flush = func() { _ = rc.Flush() }
+		}
+		if err := h.streamEvents(hctx.Context(), w, flush, tid, after, m); err != nil {
+			logger.L.Error("event stream", "tenant", tid, "err", err)
+		}
+	}}, nil
+}
+
+// checkOrigin rejects WebSocket handshakes from browser origins that are not
+// allowed, so that other sites cannot open streams with the user's cookies.
+func (h *EventsHandler) checkOrigin(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" || slices.Contains(h.AllowedOrigins, "*") || slices.Contains(h.AllowedOrigins, origin) {
+		return nil
+	}
+	return fmt.Errorf("origin %q is not allowed", origin)
+}
+
+// streamWriter is the transport of an event stream.
+type streamWriter interface {
+	// start opens the stream; retry is the reconnect delay for clients.
+	start(retry time.Duration) error
+	event(se events.StreamEvent) error
+	keepalive() error
+	flush()
+}
+
+// sseWriter writes Server-Sent Events.
+type sseWriter struct {
+	w       io.Writer
+	flushFn func()
+}
+
+func (s sseWriter) start(retry time.Duration) error {
+	// Tell EventSource clients to reconnect after one poll interval.
+	_, err := fmt.Fprintf(s.w, "retry: %d\n\n", retry.Milliseconds())
+	return err
+}
+
+func (s sseWriter) event(se events.StreamEvent) error { return writeStreamEvent(s.w, se) }
+
+func (s sseWriter) keepalive() error {
+	_, err := io.WriteString(s.w, ": keepalive\n\n")
+	return err
+}
+
+func (s sseWriter) flush() { s.flushFn() }
+
+// wsMessage is a WebSocket stream message. Keepalives carry neither field.
+type wsMessage struct {
+	ID    string             `json:"id,omitempty"`
+	Event *events.CloudEvent `json:"event,omitempty"`
+}
+
+// wsWriter sends each event as one JSON text message.
+type wsWriter struct {
+	conn *websocket.Conn
+}
+
+func (s wsWriter) start(time.Duration) error { return nil }
+
+func (s wsWriter) event(se events.StreamEvent) error {
+	ce, err := se.Event.CloudEvent()
+	if err != nil {
+		return err
+	}
+	return websocket.JSON.Send(s.conn, wsMessage{ID: strconv.FormatInt(se.ID, 10), Event: &ce})
+}
+
+func (s wsWriter) keepalive() error { return websocket.JSON.Send(s.conn, wsMessage{}) }
+
+func (s wsWriter) flush() {}
+
+// streamEvents writes the events of tid stored after the outbox row after to
+// w as Server-Sent Events until ctx is cancelled or a write fails.
+func (h *EventsHandler) streamEvents(ctx context.Context, w io.Writer, flush func(), tid string, after int64, m *events.Matcher) error {
+	return h.pollStream(ctx, sseWriter{w: w, flushFn: flush}, tid, after, m)
+}
+
+// streamWebSocket sends the events of tid stored after the outbox row after
+// over conn until the client closes it or ctx is cancelled.
+func (h *EventsHandler) streamWebSocket(ctx context.Context, conn *websocket.Conn, tid string, after int64, m *events.Matcher) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// The server's deadlines still apply to the hijacked connection.
+	_ = conn.SetDeadline(time.Time{})
+	go func() {
+		// Messages from the client are ignored; reading detects the close.
+		var msg []byte
+		for websocket.Message.Receive(conn, &msg) == nil {
+		}
+		cancel()
+	}()
+	err := h.pollStream(ctx, wsWriter{conn: conn}, tid, after, m)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// pollStream writes the events of tid stored after the outbox row after to
+// w until ctx is cancelled or a write fails.
+//
+// Outbox ids are allocated before their transaction commits, so a row can
+// become visible after rows with higher ids. Every poll therefore re-reads
+// the rows after floor, the newest id before which every row has been seen
+// for at least cfg.Window, and skips the ids it already handled.
+func (h *EventsHandler) pollStream(ctx context.Context, w streamWriter, tid string, after int64, m *events.Matcher) error {
+	cfg := h.Stream
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.Keepalive <= 0 {
+		cfg.Keepalive = 30 * time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	allowed := map[string]bool{}
+	can := func(t string) bool {
+		if h.Can == nil {
+			return true
+		}
+		c := streamCapability(t)
+		ok, seen := allowed[c]
+		if !seen {
+			ok = h.Can(ctx, c)
+			allowed[c] = ok
+		}
+		return ok
+	}
+
+	if err := w.start(cfg.PollInterval); err != nil {
+		return err
+	}
+	w.flush()
+	poll := time.NewTicker(cfg.PollInterval)
+	defer poll.Stop()
+	floor := after
+	// seen holds the ids after floor already handled, with the time they
+	// were first read.
+	seen := map[int64]time.Time{}
+	lastWrite := time.Now()
+	for {
+		now := time.Now()
+		for cursor := floor; ; {
+			batch, err := h.Outbox.Since(ctx, tid, cursor, cfg.BatchSize)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+			for _, se := range batch {
+				cursor = se.ID
+				if _, ok := seen[se.ID]; ok {
+					continue
+				}
+				seen[se.ID] = now
+				if se.Event.Name == "" || !can(se.Event.Name) || !m.Match(se.Event) {
+					continue
+				}
+				if err := w.event(se); err != nil {
+					return err
+				}
+				lastWrite = time.Now()
+			}
+			w.flush()
+			if len(batch) < cfg.BatchSize {
+				break
+			}
+		}
+		floor = advanceFloor(floor, seen, now.Add(-cfg.Window))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-poll.C:
+		}
+		if time.Since(lastWrite) >= cfg.Keepalive {
+			if err := w.keepalive(); err != nil {
+				return err
+			}
+			w.flush()
+			lastWrite = time.Now()
+		}
+	}
+}
+
+// advanceFloor moves floor over the lowest ids of seen that were first read
+// before cutoff, removing them, and returns the new floor.
+func advanceFloor(floor int64, seen map[int64]time.Time, cutoff time.Time) int64 {
+	ids := make([]int64, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		if seen[id].After(cutoff) {
+			break
+		}
+		floor = id
+		delete(seen, id)
+	}
+	return floor
+}
+
+func writeStreamEvent(w io.Writer, se events.StreamEvent) error {
+	ce, err := se.Event.CloudEvent()
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(ce)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", se.ID, se.Event.Name, b)
+	return err
+}
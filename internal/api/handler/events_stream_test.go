@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/faciam-dev/gcfm/internal/events"
+	"github.com/faciam-dev/gcfm/pkg/tenant"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/net/websocket"
+)
+
+// cancelWriter cancels the stream once events events have been written, or
+// after the first one when events is 0.
+type cancelWriter struct {
+	bytes.Buffer
+	events int
+	cancel context.CancelFunc
+}
+
+func (w *cancelWriter) Write(p []byte) (int, error) {
+	if bytes.HasPrefix(p, []byte("id: ")) {
+		w.events--
+		if w.events <= 0 {
+			defer w.cancel()
+		}
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestStreamEventsFiltersByCapabilityAndType(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT `id`, `payload` FROM `gcfm_events_outbox` WHERE `tenant_id` = \\? AND `id` > \\? ORDER BY `id` ASC LIMIT 100").
+		WithArgs("t1", int64(4)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "payload"}).
+			AddRow(5, `{"name":"cf.audit.update","id":"a5","time":"2026-05-01T12:00:00Z"}`).
+			AddRow(6, `not json`).
+			AddRow(7, `{"name":"cf.scan","id":"s7","time":"2026-05-01T12:00:00Z"}`).
+			AddRow(8, `{"name":"cf.field.created","id":"f8","time":"2026-05-01T12:00:00Z","tenant":"t1"}`))
+
+	var asked []string
+	h := &EventsHandler{
+		Outbox: &events.Outbox{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_"},
+		Stream: events.StreamConfig{PollInterval: time.Hour},
+		Can: func(_ context.Context, c string) bool {
+			asked = append(asked, c)
+			return c == "custom_fields:list"
+		},
+	}
+	m, err := events.Subscription{Events: []string{"cf.field.*", "cf.audit.*"}}.Compile()
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &cancelWriter{cancel: cancel}
+	if err := h.streamEvents(ctx, w, func() {}, "t1", 4, m); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	out := w.String()
+	if !strings.HasPrefix(out, "retry: 3600000\n\n") {
+		t.Fatalf("missing retry: %q", out)
+	}
+	if strings.Count(out, "id: ") != 1 || !strings.Contains(out, "id: 8\nevent: cf.field.created\ndata: {") || !strings.Contains(out, `"id":"f8"`) {
+		t.Fatalf("unexpected stream: %q", out)
+	}
+	if len(asked) != 2 {
+		t.Fatalf("capabilities checked %v", asked)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
+
+func TestStreamEventsSendsLateCommits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	q := "SELECT `id`, `payload` FROM `gcfm_events_outbox` WHERE `tenant_id` = \\? AND `id` > \\? ORDER BY `id` ASC LIMIT 100"
+	ev := func(id string) string {
+		return `{"name":"cf.field.created","id":"` + id + `","time":"2026-05-01T12:00:00Z","tenant":"t1"}`
+	}
+	// Row 6 commits after row 7 and only shows up on the second poll.
+	mock.ExpectQuery(q).WithArgs("t1", int64(4)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "payload"}).AddRow(5, ev("f5")).AddRow(7, ev("f7")))
+	mock.ExpectQuery(q).WithArgs("t1", int64(4)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "payload"}).AddRow(5, ev("f5")).AddRow(6, ev("f6")).AddRow(7, ev("f7")))
+
+	h := &EventsHandler{
+		Outbox: &events.Outbox{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_"},
+		Stream: events.StreamConfig{PollInterval: time.Millisecond, Window: time.Hour},
+	}
+	m, err := events.Subscription{}.Compile()
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &cancelWriter{events: 3, cancel: cancel}
+	if err := h.streamEvents(ctx, w, func() {}, "t1", 4, m); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	out := w.String()
+	i5, i7, i6 := strings.Index(out, "id: 5\n"), strings.Index(out, "id: 7\n"), strings.Index(out, "id: 6\n")
+	if strings.Count(out, "id: ") != 3 || i5 < 0 || i7 < i5 || i6 < i7 {
+		t.Fatalf("unexpected stream: %q", out)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
+
+func TestAdvanceFloorStopsAtRecentIDs(t *testing.T) {
+	now := time.Now()
+	seen := map[int64]time.Time{
+		5:  now.Add(-time.Minute),
+		7:  now.Add(-time.Minute),
+		9:  now,
+		12: now.Add(-time.Minute),
+	}
+	floor := advanceFloor(4, seen, now.Add(-time.Second))
+	if floor != 7 || len(seen) != 2 {
+		t.Fatalf("floor = %d, seen = %v", floor, seen)
+	}
+}
+
+func TestStreamOverWebSocket(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT `id`, `payload` FROM `gcfm_events_outbox`").
+		WithArgs("", int64(4)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "payload"}).
+			AddRow(5, `{"name":"cf.field.created","id":"f5","time":"2026-05-01T12:00:00Z"}`))
+	for i := 0; i < 50; i++ {
+		mock.ExpectQuery("SELECT `id`, `payload` FROM `gcfm_events_outbox`").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "payload"}))
+	}
+
+	r := chi.NewRouter()
+	api := humachi.New(r, huma.DefaultConfig("test", "1.0"))
+	h := &EventsHandler{
+		Outbox: &events.Outbox{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_"},
+		Stream: events.StreamConfig{PollInterval: 10 * time.Millisecond},
+	}
+	registerEventStream(api, h)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	h.AllowedOrigins = []string{srv.URL}
+
+	if _, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/v1/events/stream?after=4", "", "https://evil.example"); err == nil {
+		t.Fatal("dial from an unlisted origin succeeded")
+	}
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/v1/events/stream?after=4", "", srv.URL)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	var msg wsMessage
+	if err := websocket.JSON.Receive(conn, &msg); err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+	if msg.ID != "5" || msg.Event == nil || msg.Event.ID != "f5" || msg.Event.Type != "cf.field.created" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+}
+
+func TestStreamRejectsBadRequests(t *testing.T) {
+	ctx := tenant.WithTenant(context.Background(), "t1")
+	var se huma.StatusError
+	_, err := (&EventsHandler{}).stream(ctx, &eventStreamParams{})
+	if !errors.As(err, &se) || se.GetStatus() != http.StatusNotImplemented {
+		t.Fatalf("expected 501, got %v", err)
+	}
+	h := &EventsHandler{Outbox: &events.Outbox{}}
+	for _, p := range []eventStreamParams{{LastEventID: "abc"}, {After: "-1"}, {Types: "cf.[field"}} {
+		_, err := h.stream(ctx, &p)
+		if !errors.As(err, &se) || se.GetStatus() != http.StatusUnprocessableEntity {
+			t.Errorf("%+v: expected 422, got %v", p, err)
+		}
+	}
+}
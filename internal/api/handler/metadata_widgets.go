@@ -2,9 +2,12 @@ package handler
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"time"
 
 	humago "github.com/danielgtaylor/huma/v2"
@@ -13,9 +16,19 @@ import (
 	widgetsrepo "github.com/faciam-dev/gcfm/internal/repository/widgets"
 	"github.com/faciam-dev/gcfm/internal/server/middleware"
 	"github.com/faciam-dev/gcfm/internal/util"
+	"github.com/faciam-dev/gcfm/pkg/audit"
 	"github.com/faciam-dev/gcfm/pkg/tenant"
 )
 
+// widgetRepoError maps a missing widget to 404 and passes other repository
+// errors through as internal errors.
+func widgetRepoError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return humago.Error404NotFound("not found")
+	}
+	return err
+}
+
 type WidgetNotifier interface {
 	NotifyWidgetChanged(ctx context.Context, id string) error
 	NotifyWidgetRemoved(ctx context.Context, id string) error
@@ -30,6 +43,7 @@ type WidgetHandler struct {
 	Repo     widgetsrepo.Repo
 	Notifier WidgetNotifier
 	Auth     Authz
+	Recorder *audit.Recorder
 }
 
 type listWidgetParams struct {
@@ -65,22 +79,30 @@ type WidgetPatch struct {
 	Description *string `json:"description,omitempty"`
 }
 
+type tenantToggleInput struct {
+	ID   string `path:"id"`
+	Body struct {
+		Enabled bool `json:"enabled"`
+	}
+}
+
 type widgetOut struct{ Body widgetItem }
 
 type widgetItem struct {
-	ID           string         `json:"id"`
-	Name         string         `json:"name"`
-	Version      string         `json:"version"`
-	Type         string         `json:"type"`
-	Scopes       []string       `json:"scopes"`
-	Enabled      bool           `json:"enabled"`
-	Description  *string        `json:"description,omitempty"`
-	Capabilities []string       `json:"capabilities,omitempty"`
-	Homepage     *string        `json:"homepage,omitempty"`
-	Meta         map[string]any `json:"meta,omitempty"`
-	TenantScope  string         `json:"tenant_scope"`
-	Tenants      []string       `json:"tenants"`
-	UpdatedAt    string         `json:"updated_at"`
+	ID              string         `json:"id"`
+	Name            string         `json:"name"`
+	Version         string         `json:"version"`
+	Type            string         `json:"type"`
+	Scopes          []string       `json:"scopes"`
+	Enabled         bool           `json:"enabled"`
+	Description     *string        `json:"description,omitempty"`
+	Capabilities    []string       `json:"capabilities,omitempty"`
+	Homepage        *string        `json:"homepage,omitempty"`
+	Meta            map[string]any `json:"meta,omitempty"`
+	TenantScope     string         `json:"tenant_scope"`
+	Tenants         []string       `json:"tenants"`
+	UpdatedAt       string         `json:"updated_at"`
+	DisabledTenants []string       `json:"disabled_tenants,omitempty"`
 }
 
 func RegisterWidget(api humago.API, h *WidgetHandler) {
@@ -108,6 +130,14 @@ func RegisterWidget(api humago.API, h *WidgetHandler) {
 		Summary:     "Patch widget",
 		Tags:        []string{"Metadata"},
 	}, h.patch)
+
+	humago.Register(api, humago.Operation{
+		OperationID: "SetWidgetTenantEnabled",
+		Method:      http.MethodPatch,
+		Path:        "/v1/metadata/widgets/{id}/tenant",
+		Summary:     "Enable or disable a widget for the current tenant",
+		Tags:        []string{"Metadata"},
+	}, h.setTenantEnabled)
 }
 
 func (h *WidgetHandler) list(ctx context.Context, p *listWidgetParams) (*widgetsOut, error) {
@@ -171,18 +201,23 @@ func (h *WidgetHandler) list(ctx context.Context, p *listWidgetParams) (*widgets
 		items = make([]widgets.Widget, len(rows))
 		for i, r := range rows {
 			items[i] = widgets.Widget{
-				ID:           r.ID,
-				Name:         r.Name,
-				Version:      r.Version,
-				Type:         r.Type,
-				Scopes:       r.Scopes,
-				Enabled:      r.Enabled,
-				Description:  util.Deref(r.Description),
-				Capabilities: r.Capabilities,
-				Homepage:     util.Deref(r.Homepage),
-				UpdatedAt:    r.UpdatedAt,
-				Meta:         r.Meta,
-				Tenants:      r.Tenants,
+				ID:              r.ID,
+				Name:            r.Name,
+				Version:         r.Version,
+				Type:            r.Type,
+				Scopes:          r.Scopes,
+				Enabled:         r.Enabled,
+				Description:     util.Deref(r.Description),
+				Capabilities:    r.Capabilities,
+				Homepage:        util.Deref(r.Homepage),
+				UpdatedAt:       r.UpdatedAt,
+				Meta:            r.Meta,
+				Tenants:         r.Tenants,
+				TenantScope:     r.TenantScope,
+				DisabledTenants: r.DisabledTenants,
+			}
+			if tenantID != "" && slices.Contains(r.DisabledTenants, tenantID) {
+				items[i].Enabled = false
 			}
 		}
 	} else {
@@ -214,9 +249,16 @@ func (h *WidgetHandler) delete(ctx context.Context, in *deleteWidgetInput) (*str
 	if h.Repo == nil {
 		return nil, humago.NewError(http.StatusNotImplemented, "repository not configured")
 	}
+	var before any
+	if h.Recorder != nil {
+		if row, err := h.Repo.GetByID(ctx, in.ID); err == nil {
+			before = toWidgetItem(row)
+		}
+	}
 	if err := h.Repo.Remove(ctx, in.ID); err != nil {
-		return nil, humago.Error404NotFound("not found")
+		return nil, widgetRepoError(err)
 	}
+	h.record(ctx, "delete", in.ID, before, nil)
 	if h.Notifier != nil {
 		_ = h.Notifier.NotifyWidgetRemoved(ctx, in.ID)
 	}
@@ -232,8 +274,9 @@ func (h *WidgetHandler) patch(ctx context.Context, in *patchWidgetInput) (*widge
 	}
 	row, err := h.Repo.GetByID(ctx, in.ID)
 	if err != nil {
-		return nil, humago.Error404NotFound("not found")
+		return nil, widgetRepoError(err)
 	}
+	before := toWidgetItem(row)
 	if in.Body.Enabled != nil {
 		row.Enabled = *in.Body.Enabled
 	}
@@ -252,24 +295,90 @@ func (h *WidgetHandler) patch(ctx context.Context, in *patchWidgetInput) (*widge
 	}
 	out := &widgetOut{}
 	out.Body = toWidgetItem(row)
+	h.record(ctx, "update", row.ID, before, out.Body)
+	return out, nil
+}
+
+func (h *WidgetHandler) setTenantEnabled(ctx context.Context, in *tenantToggleInput) (*widgetOut, error) {
+	if h.Auth != nil && !(h.Auth.HasCapability(ctx, "plugins:write") || h.Auth.HasCapability(ctx, "widgets:write")) {
+		return nil, humago.NewError(http.StatusForbidden, "forbidden")
+	}
+	if h.Repo == nil {
+		return nil, humago.NewError(http.StatusNotImplemented, "repository not configured")
+	}
+	tenantID := tenant.FromContext(ctx)
+	if tenantID == "" {
+		return nil, humago.Error400BadRequest("tenant required")
+	}
+	if err := h.Repo.SetTenantEnabled(ctx, in.ID, tenantID, in.Body.Enabled); err != nil {
+		return nil, widgetRepoError(err)
+	}
+	row, err := h.Repo.GetByID(ctx, in.ID)
+	if err != nil {
+		return nil, err
+	}
+	action := "tenant_disable"
+	if in.Body.Enabled {
+		action = "tenant_enable"
+	}
+	h.record(ctx, action, row.ID, nil, map[string]any{"tenant": tenantID, "enabled": in.Body.Enabled})
+	if h.Reg != nil {
+		w := widgets.Widget{
+			ID:              row.ID,
+			Name:            row.Name,
+			Version:         row.Version,
+			Type:            row.Type,
+			Scopes:          row.Scopes,
+			Enabled:         row.Enabled,
+			Description:     util.Deref(row.Description),
+			Capabilities:    row.Capabilities,
+			Homepage:        util.Deref(row.Homepage),
+			UpdatedAt:       row.UpdatedAt,
+			Meta:            row.Meta,
+			Tenants:         row.Tenants,
+			TenantScope:     row.TenantScope,
+			DisabledTenants: row.DisabledTenants,
+		}
+		_ = h.Reg.Upsert(ctx, w)
+	}
+	if h.Notifier != nil {
+		_ = h.Notifier.NotifyWidgetChanged(ctx, row.ID)
+	}
+	out := &widgetOut{}
+	out.Body = toWidgetItem(row)
 	return out, nil
 }
 
+func (h *WidgetHandler) record(ctx context.Context, action, id string, before, after any) {
+	if h.Recorder == nil {
+		return
+	}
+	_ = h.Recorder.Record(ctx, audit.Event{
+		ResourceType: audit.ResourceWidget,
+		ResourceID:   id,
+		Action:       action,
+		Actor:        middleware.UserFromContext(ctx),
+		Before:       before,
+		After:        after,
+	})
+}
+
 func toWidgetItem(r widgetsrepo.Row) widgetItem {
 	return widgetItem{
-		ID:           r.ID,
-		Name:         r.Name,
-		Version:      r.Version,
-		Type:         r.Type,
-		Scopes:       r.Scopes,
-		Enabled:      r.Enabled,
-		Description:  r.Description,
-		Capabilities: r.Capabilities,
-		Homepage:     r.Homepage,
-		Meta:         r.Meta,
-		TenantScope:  r.TenantScope,
-		Tenants:      r.Tenants,
-		UpdatedAt:    r.UpdatedAt.Format(time.RFC3339),
+		ID:              r.ID,
+		Name:            r.Name,
+		Version:         r.Version,
+		Type:            r.Type,
+		Scopes:          r.Scopes,
+		Enabled:         r.Enabled,
+		Description:     r.Description,
+		Capabilities:    r.Capabilities,
+		Homepage:        r.Homepage,
+		Meta:            r.Meta,
+		TenantScope:     r.TenantScope,
+		Tenants:         r.Tenants,
+		UpdatedAt:       r.UpdatedAt.Format(time.RFC3339),
+		DisabledTenants: r.DisabledTenants,
 	}
 }
 
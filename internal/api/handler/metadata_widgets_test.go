@@ -2,10 +2,16 @@ package handler
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"net/http"
 	"testing"
 	"time"
 
+	humago "github.com/danielgtaylor/huma/v2"
 	widgetreg "github.com/faciam-dev/gcfm/internal/registry/widgets"
+	widgetsrepo "github.com/faciam-dev/gcfm/internal/repository/widgets"
+	"github.com/faciam-dev/gcfm/pkg/tenant"
 )
 
 func TestWidgetHandlerListFallback(t *testing.T) {
@@ -31,3 +37,28 @@ func TestWidgetHandlerListFallback(t *testing.T) {
 		t.Fatalf("expected 1 widget, got %d", len(out.Body.Widgets))
 	}
 }
+
+type toggleErrRepo struct {
+	widgetsrepo.Repo
+	err error
+}
+
+func (r toggleErrRepo) SetTenantEnabled(context.Context, string, string, bool) error { return r.err }
+
+func TestWidgetHandlerSetTenantEnabledErrors(t *testing.T) {
+	ctx := tenant.WithTenant(context.Background(), "t1")
+	in := &tenantToggleInput{ID: "w"}
+
+	h := &WidgetHandler{Repo: toggleErrRepo{err: sql.ErrNoRows}}
+	_, err := h.setTenantEnabled(ctx, in)
+	var se humago.StatusError
+	if !errors.As(err, &se) || se.GetStatus() != http.StatusNotFound {
+		t.Fatalf("missing widget: got %v, want 404", err)
+	}
+
+	boom := errors.New("connection refused")
+	h = &WidgetHandler{Repo: toggleErrRepo{err: boom}}
+	if _, err := h.setTenantEnabled(ctx, in); !errors.Is(err, boom) {
+		t.Fatalf("repository failure: got %v, want the repository error", err)
+	}
+}
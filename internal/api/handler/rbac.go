@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -470,7 +472,7 @@ func (h *RBACHandler) createUser(ctx context.Context, in *createUserInput) (*use
 	actor := middleware.UserFromContext(ctx)
 	if h.Recorder != nil {
 		payload := map[string]any{"id": id, "tenant_id": tid, "username": in.Body.Username, "roles": roles}
-		_ = h.Recorder.WriteTableJSON(ctx, actor, "CREATE", h.t("users"), payload)
+		_ = h.Recorder.Record(ctx, audit.Event{ResourceType: audit.ResourceUser, ResourceID: strconv.FormatInt(id, 10), Action: "create", Actor: actor, After: payload})
 	}
 	out := schema.User{ID: id, TenantID: tid, Username: in.Body.Username, Roles: roles, CreatedAt: created}
 	return &userOutput{Body: out}, nil
@@ -514,6 +516,9 @@ func (h *RBACHandler) createRole(ctx context.Context, in *createRoleInput) (*rol
 	if in.Body.Comment != nil {
 		r.Comment = *in.Body.Comment
 	}
+	if h.Recorder != nil {
+		_ = h.Recorder.Record(ctx, audit.Event{ResourceType: audit.ResourceRole, ResourceID: strconv.FormatInt(id, 10), Action: "create", Actor: middleware.UserFromContext(ctx), After: r})
+	}
 	return &roleOutput{Body: r}, nil
 }
 
@@ -541,12 +546,29 @@ func (h *RBACHandler) deleteRole(ctx context.Context, p *roleIDParam) (*struct{}
 	if row.Count > 0 {
 		return nil, huma.Error409Conflict("role has policies")
 	}
+	var before *schema.Role
+	if h.Recorder != nil {
+		var role struct {
+			Name    string         `db:"name"`
+			Comment sql.NullString `db:"comment"`
+		}
+		if err := query.New(h.DB, h.t("roles"), h.Dialect).
+			Select("name", "comment").
+			Where("id", p.ID).
+			WithContext(ctx).
+			First(&role); err == nil {
+			before = &schema.Role{ID: p.ID, Name: role.Name, Comment: role.Comment.String}
+		}
+	}
 	if _, err := query.New(h.DB, h.t("roles"), h.Dialect).
 		Where("id", p.ID).
 		Delete(); err != nil {
 		return nil, err
 	}
 	scheduleEnforcerReload(ctx, h.DB)
+	if before != nil {
+		_ = h.Recorder.Record(ctx, audit.Event{ResourceType: audit.ResourceRole, ResourceID: strconv.FormatInt(p.ID, 10), Action: "delete", Actor: middleware.UserFromContext(ctx), Before: before})
+	}
 	return &struct{}{}, nil
 }
 
@@ -651,8 +673,19 @@ func (h *RBACHandler) putRoleMembers(ctx context.Context, in *roleMembersInput)
 	scheduleEnforcerReload(ctx, h.DB)
 	actor := middleware.UserFromContext(ctx)
 	if h.Recorder != nil {
-		payload := map[string]any{"object": "role-members", "role_id": in.ID, "user_ids": in.Body.UserIDs}
-		_ = h.Recorder.WriteJSON(ctx, actor, "rbac", payload)
+		before := make([]int64, 0, len(existing))
+		for id := range existing {
+			before = append(before, id)
+		}
+		sort.Slice(before, func(i, j int) bool { return before[i] < before[j] })
+		_ = h.Recorder.Record(ctx, audit.Event{
+			ResourceType: audit.ResourceRole,
+			ResourceID:   strconv.FormatInt(in.ID, 10),
+			Action:       "update_members",
+			Actor:        actor,
+			Before:       map[string]any{"user_ids": before},
+			After:        map[string]any{"user_ids": in.Body.UserIDs},
+		})
 	}
 	return &struct{}{}, nil
 }
@@ -705,8 +738,8 @@ func (h *RBACHandler) addRolePolicy(ctx context.Context, in *policyInput) (*sche
 	scheduleEnforcerReload(ctx, h.DB)
 	actor := middleware.UserFromContext(ctx)
 	if h.Recorder != nil {
-		payload := map[string]any{"object": "role-policies", "role_id": in.ID, "path": in.Body.Path, "method": in.Body.Method}
-		_ = h.Recorder.WriteJSON(ctx, actor, "rbac", payload)
+		payload := map[string]any{"role_id": in.ID, "path": in.Body.Path, "method": in.Body.Method}
+		_ = h.Recorder.Record(ctx, audit.Event{ResourceType: audit.ResourcePolicy, ResourceID: policyResourceID(in.ID, in.Body.Method, in.Body.Path), Action: "create", Actor: actor, After: payload})
 	}
 	return &in.Body, nil
 }
@@ -733,13 +766,18 @@ func (h *RBACHandler) deleteRolePolicy(ctx context.Context, p *policyParams) (*s
 		scheduleEnforcerReload(ctx, h.DB)
 		actor := middleware.UserFromContext(ctx)
 		if h.Recorder != nil {
-			payload := map[string]any{"object": "role-policies", "role_id": p.ID, "path": p.Path, "method": p.Method}
-			_ = h.Recorder.WriteJSON(ctx, actor, "rbac", payload)
+			payload := map[string]any{"role_id": p.ID, "path": p.Path, "method": p.Method}
+			_ = h.Recorder.Record(ctx, audit.Event{ResourceType: audit.ResourcePolicy, ResourceID: policyResourceID(p.ID, p.Method, p.Path), Action: "delete", Actor: actor, Before: payload})
 		}
 	}
 	return &struct{}{}, nil
 }
 
+// policyResourceID identifies a role policy in audit records.
+func policyResourceID(roleID int64, method, path string) string {
+	return fmt.Sprintf("%d:%s %s", roleID, method, path)
+}
+
 func isDuplicateErr(err error) bool {
 	if err == nil {
 		return false
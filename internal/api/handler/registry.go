@@ -3,16 +3,24 @@ package handler
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/danielgtaylor/huma/v2"
+	"github.com/faciam-dev/gcfm/internal/events"
+	widgetreg "github.com/faciam-dev/gcfm/internal/registry/widgets"
 	"github.com/faciam-dev/gcfm/internal/server/middleware"
 	"github.com/faciam-dev/gcfm/pkg/audit"
+	"github.com/faciam-dev/gcfm/pkg/notifier"
 	"github.com/faciam-dev/gcfm/pkg/schema"
 	"github.com/faciam-dev/gcfm/pkg/snapshot"
+	"github.com/faciam-dev/gcfm/pkg/tenant"
 	sdk "github.com/faciam-dev/gcfm/sdk"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
 )
 
 // snapshotBaseDir defines the directory where registry snapshots are stored.
@@ -22,9 +30,16 @@ const snapshotBaseDir = "./snapshots"
 type RegistryHandler struct {
 	DB          *sql.DB
 	Driver      string
+	Dialect     ormdriver.Dialect
 	DSN         string
 	Recorder    *audit.Recorder
 	TablePrefix string
+	// WidgetRegistry supplies widget config schemas used to validate
+	// widget_config values before applying.
+	WidgetRegistry widgetreg.Registry
+	// Service applies the registry. When nil, every apply uses a new
+	// service recording to Recorder.
+	Service sdk.Service
 }
 
 type applyInput struct {
@@ -39,6 +54,17 @@ type snapshotInput struct {
 	Body schema.SnapshotRequest
 }
 
+type registryAtParams struct {
+	Time string `query:"time" required:"true" doc:"RFC3339 timestamp to reconstruct the registry at"`
+}
+
+type registryAtOutput struct {
+	ContentType  string `header:"Content-Type"`
+	BaseSnapshot string `header:"X-Base-Snapshot"`
+	Replayed     int    `header:"X-Replayed-Changes"`
+	Body         []byte
+}
+
 func RegisterRegistry(api huma.API, h *RegistryHandler) {
 	huma.Register(api, huma.Operation{
 		OperationID: "applyRegistry",
@@ -54,13 +80,55 @@ func RegisterRegistry(api huma.API, h *RegistryHandler) {
 		Summary:     "Create registry snapshot",
 		Tags:        []string{"Registry"},
 	}, h.snapshot)
+	huma.Register(api, huma.Operation{
+		OperationID: "getRegistryAt",
+		Method:      http.MethodGet,
+		Path:        "/v1/registry/at",
+		Summary:     "Reconstruct registry yaml at a point in time",
+		Description: "Replays audit log entries on top of the nearest earlier snapshot.",
+		Tags:        []string{"Registry"},
+		Responses: map[string]*huma.Response{
+			"200": {
+				Content: map[string]*huma.MediaType{
+					"text/yaml": {Schema: &huma.Schema{Type: "string"}},
+				},
+			},
+		},
+	}, h.at)
+}
+
+// SDKEvents passes the field events of SDK applies to the global
+// dispatcher.
+var SDKEvents = notifier.EmitterFunc(func(ctx context.Context, e notifier.Event) {
+	events.Emit(ctx, events.Event(e))
+})
+
+// sdkService returns svc, or a service recording to rec when svc is nil.
+func sdkService(svc sdk.Service, rec *audit.Recorder) sdk.Service {
+	if svc != nil {
+		return svc
+	}
+	return sdk.New(sdk.ServiceConfig{Recorder: rec, Events: SDKEvents})
 }
 
 func (h *RegistryHandler) apply(ctx context.Context, in *applyInput) (*applyOutput, error) {
-	svc := sdk.New(sdk.ServiceConfig{Recorder: h.Recorder})
+	svc := sdkService(h.Service, h.Recorder)
 	actor := middleware.UserFromContext(ctx)
-	rep, err := svc.Apply(ctx, sdk.DBConfig{Driver: h.Driver, DSN: h.DSN, Schema: "public", TablePrefix: h.TablePrefix}, []byte(in.Body.YAML), sdk.ApplyOptions{DryRun: in.Body.DryRun, Actor: actor})
+	opts := sdk.ApplyOptions{DryRun: in.Body.DryRun, Actor: actor}
+	if h.WidgetRegistry != nil {
+		tid := tenant.FromContext(ctx)
+		opts.WidgetSchema = h.WidgetRegistry.ConfigSchema
+		opts.WidgetAvailable = func(id string) error { return h.WidgetRegistry.Check(tid, id) }
+	}
+	rep, err := svc.Apply(ctx, sdk.DBConfig{Driver: h.Driver, DSN: h.DSN, Schema: "public", TablePrefix: h.TablePrefix}, []byte(in.Body.YAML), opts)
 	if err != nil {
+		var ferr *sdk.WidgetConfigError
+		if errors.As(err, &ferr) {
+			return nil, widgetConfigError(fmt.Sprintf("yaml.%s.%s.display.widget_config", ferr.Table, ferr.Column), err)
+		}
+		if errors.Is(err, widgetreg.ErrWidgetNotInstalled) || errors.Is(err, widgetreg.ErrWidgetNotInTenant) || errors.Is(err, widgetreg.ErrWidgetDisabled) {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
 		return nil, err
 	}
 	return &applyOutput{Body: rep}, nil
@@ -88,7 +156,27 @@ func (h *RegistryHandler) snapshot(ctx context.Context, in *snapshotInput) (*str
 	if err := snapshot.Export(ctx, h.DB, "public", h.Driver, h.TablePrefix, snapshot.LocalDir{Path: absDest}); err != nil {
 		return nil, err
 	}
-	actor := middleware.UserFromContext(ctx)
-	_ = h.Recorder.Write(ctx, actor, nil, nil)
+	_ = h.Recorder.Record(ctx, audit.Event{
+		ResourceType: audit.ResourceRegistry,
+		Action:       "export",
+		Actor:        middleware.UserFromContext(ctx),
+		After:        map[string]any{"dest": relPath},
+	})
 	return &struct{}{}, nil
 }
+
+func (h *RegistryHandler) at(ctx context.Context, p *registryAtParams) (*registryAtOutput, error) {
+	t, err := time.Parse(time.RFC3339, p.Time)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity("time must be an RFC3339 timestamp")
+	}
+	res, err := snapshot.RegistryAt(ctx, h.DB, h.Dialect, h.TablePrefix, tenant.FromContext(ctx), t)
+	if err != nil {
+		return nil, err
+	}
+	out := &registryAtOutput{ContentType: "text/yaml", Replayed: res.Replayed, Body: res.YAML}
+	if res.Base != nil {
+		out.BaseSnapshot = res.Base.Semver
+	}
+	return out, nil
+}
@@ -3,12 +3,15 @@ package handler
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/faciam-dev/gcfm/internal/server/middleware"
+	"github.com/faciam-dev/gcfm/internal/snapshotbundle"
 	"github.com/faciam-dev/gcfm/pkg/audit"
 	"github.com/faciam-dev/gcfm/pkg/schema"
 	"github.com/faciam-dev/gcfm/pkg/snapshot"
@@ -26,6 +29,33 @@ type SnapshotHandler struct {
 	DSN         string
 	Recorder    *audit.Recorder
 	TablePrefix string
+	// Bundles collects and restores full tenant configuration bundles. When
+	// nil, only registry snapshots are supported.
+	Bundles *snapshotbundle.Service
+	// Can reports whether the caller holds a capability of CapMatrix. Bundle
+	// parts shared by every tenant are only restored for callers that may
+	// change roles, targets and widgets directly. When nil, they are never
+	// restored.
+	Can func(ctx context.Context, capKey string) bool
+	// Service applies registry snapshots. When nil, every apply uses a new
+	// service recording to Recorder.
+	Service sdk.Service
+}
+
+// globalRestoreCaps are the capabilities required to restore
+// snapshotbundle.GlobalParts.
+var globalRestoreCaps = []string{"rules:write", "targets:update", "widgets:write"}
+
+func (h *SnapshotHandler) canRestoreGlobal(ctx context.Context) bool {
+	if h.Can == nil {
+		return false
+	}
+	for _, c := range globalRestoreCaps {
+		if !h.Can(ctx, c) {
+			return false
+		}
+	}
+	return true
 }
 
 type snapshotListOutput struct{ Body []schema.Snapshot }
@@ -44,8 +74,27 @@ type snapshotDetailOutput struct {
 }
 
 type snapshotApplyParams struct {
+	Ver     string `path:"ver"`
+	Include string `query:"include" doc:"Comma separated bundle parts to restore: registry, widget_policies, widgets, rbac, databases, targets. Defaults to all parts in the snapshot."`
+}
+
+type snapshotApplyOutput struct{ Body schema.SnapshotApplyResult }
+
+type snapshotTagParams struct {
 	Ver string `path:"ver"`
+	Tag string `path:"tag"`
+}
+
+type snapshotLineageOutput struct{ Body []snapshot.Promotion }
+
+type snapshotRetentionOutput struct{ Body schema.SnapshotRetention }
+
+type snapshotRetentionInput struct{ Body schema.SnapshotRetention }
+
+type snapshotPruneParams struct {
+	DryRun bool `query:"dry_run"`
 }
+type snapshotPruneOutput struct{ Body schema.SnapshotPruneResult }
 
 func RegisterSnapshot(api huma.API, h *SnapshotHandler) {
 	huma.Register(api, huma.Operation{
@@ -63,6 +112,28 @@ func RegisterSnapshot(api huma.API, h *SnapshotHandler) {
 		Tags:        []string{"Snapshot"},
 	}, h.create)
 
+	huma.Register(api, huma.Operation{
+		OperationID: "getSnapshotRetention",
+		Method:      http.MethodGet,
+		Path:        "/v1/snapshots/retention",
+		Summary:     "Get snapshot retention policy",
+		Tags:        []string{"Snapshot"},
+	}, h.getRetention)
+	huma.Register(api, huma.Operation{
+		OperationID: "putSnapshotRetention",
+		Method:      http.MethodPut,
+		Path:        "/v1/snapshots/retention",
+		Summary:     "Set snapshot retention policy",
+		Tags:        []string{"Snapshot"},
+	}, h.putRetention)
+	huma.Register(api, huma.Operation{
+		OperationID: "pruneSnapshots",
+		Method:      http.MethodPost,
+		Path:        "/v1/snapshots/prune",
+		Summary:     "Prune snapshots using the retention policy",
+		Tags:        []string{"Snapshot"},
+	}, h.prune)
+
 	huma.Register(api, huma.Operation{
 		OperationID: "getSnapshot",
 		Method:      http.MethodGet,
@@ -72,7 +143,8 @@ func RegisterSnapshot(api huma.API, h *SnapshotHandler) {
 		Responses: map[string]*huma.Response{
 			"200": {
 				Content: map[string]*huma.MediaType{
-					"text/yaml": {Schema: &huma.Schema{Type: "string"}},
+					"text/yaml":         {Schema: &huma.Schema{Type: "string"}},
+					"application/x-tar": {Schema: &huma.Schema{Type: "string", Format: "binary"}},
 				},
 			},
 		},
@@ -85,6 +157,29 @@ func RegisterSnapshot(api huma.API, h *SnapshotHandler) {
 		Summary:     "Apply registry snapshot",
 		Tags:        []string{"Snapshot"},
 	}, h.apply)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "tagSnapshot",
+		Method:      http.MethodPut,
+		Path:        "/v1/snapshots/{ver}/tags/{tag}",
+		Summary:     "Tag registry snapshot",
+		Tags:        []string{"Snapshot"},
+	}, h.tag)
+	huma.Register(api, huma.Operation{
+		OperationID:   "untagSnapshot",
+		Method:        http.MethodDelete,
+		Path:          "/v1/snapshots/{ver}/tags/{tag}",
+		Summary:       "Remove snapshot tag",
+		Tags:          []string{"Snapshot"},
+		DefaultStatus: http.StatusNoContent,
+	}, h.untag)
+	huma.Register(api, huma.Operation{
+		OperationID: "getSnapshotLineage",
+		Method:      http.MethodGet,
+		Path:        "/v1/snapshots/{ver}/lineage",
+		Summary:     "List promotions of a snapshot",
+		Tags:        []string{"Snapshot"},
+	}, h.lineage)
 }
 
 func (h *SnapshotHandler) list(ctx context.Context, _ *snapshotListParams) (*snapshotListOutput, error) {
@@ -93,9 +188,13 @@ func (h *SnapshotHandler) list(ctx context.Context, _ *snapshotListParams) (*sna
 	if err != nil {
 		return nil, err
 	}
+	tags, err := snapshot.Tags(ctx, h.DB, h.Dialect, h.TablePrefix, tid)
+	if err != nil {
+		return nil, err
+	}
 	out := make([]schema.Snapshot, len(recs))
 	for i, r := range recs {
-		out[i] = schema.Snapshot{ID: r.ID, Semver: r.Semver, TakenAt: r.TakenAt, Author: r.Author}
+		out[i] = schema.Snapshot{ID: r.ID, Semver: r.Semver, TakenAt: r.TakenAt, Author: r.Author, Tags: tags[r.ID]}
 	}
 	return &snapshotListOutput{Body: out}, nil
 }
@@ -113,7 +212,21 @@ func (h *SnapshotHandler) create(ctx context.Context, in *snapshotCreateInput) (
 	if err != nil {
 		return nil, err
 	}
-	comp, err := snapshot.Encode(data)
+	payload := data
+	if in.Body.Bundle {
+		if h.Bundles == nil {
+			return nil, huma.Error422UnprocessableEntity("bundle snapshots are not supported by this server")
+		}
+		b, err := h.Bundles.Collect(ctx, tid)
+		if err != nil {
+			return nil, err
+		}
+		if payload, err = snapshot.EncodeBundle(b); err != nil {
+			return nil, err
+		}
+		data = b.Registry
+	}
+	comp, err := snapshot.Encode(payload)
 	if err != nil {
 		return nil, err
 	}
@@ -144,6 +257,9 @@ func (h *SnapshotHandler) create(ctx context.Context, in *snapshotCreateInput) (
 		prev, err := snapshot.Get(ctx, h.DB, h.Dialect, h.TablePrefix, tid, last)
 		if err == nil {
 			prevY, err := snapshot.Decode(prev.YAML)
+			if err == nil {
+				prevY, err = snapshot.RegistryYAML(prevY)
+			}
 			if err == nil {
 				ch, err := snapshot.DiffYaml(prevY, data)
 				if err == nil {
@@ -167,11 +283,18 @@ func (h *SnapshotHandler) get(ctx context.Context, p *snapshotDetailParams) (*sn
 	if err != nil {
 		return nil, err
 	}
+	if snapshot.IsBundle(y) {
+		return &snapshotDetailOutput{ContentType: "application/x-tar", Body: y}, nil
+	}
 	return &snapshotDetailOutput{ContentType: "text/yaml", Body: y}, nil
 }
 
-func (h *SnapshotHandler) apply(ctx context.Context, p *snapshotApplyParams) (*struct{}, error) {
+func (h *SnapshotHandler) apply(ctx context.Context, p *snapshotApplyParams) (*snapshotApplyOutput, error) {
 	tid := tenant.FromContext(ctx)
+	parts, err := snapshot.ParseInclude(p.Include)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
 	rec, err := snapshot.Get(ctx, h.DB, h.Dialect, h.TablePrefix, tid, p.Ver)
 	if err != nil {
 		return nil, err
@@ -180,13 +303,20 @@ func (h *SnapshotHandler) apply(ctx context.Context, p *snapshotApplyParams) (*s
 	if err != nil {
 		return nil, err
 	}
+	actor := middleware.UserFromContext(ctx)
+	if snapshot.IsBundle(data) {
+		return h.applyBundle(ctx, tid, actor, p, data, parts)
+	}
+	if p.Include != "" && (len(parts) != 1 || parts[0] != snapshot.PartRegistry) {
+		return nil, huma.Error422UnprocessableEntity("snapshot " + p.Ver + " only contains the registry")
+	}
 	current, err := snapshot.SnapshotYaml(ctx, h.DB, h.Driver, h.TablePrefix, tid)
 	if err != nil {
 		return nil, err
 	}
-	svc := sdk.New(sdk.ServiceConfig{Recorder: h.Recorder})
-	actor := middleware.UserFromContext(ctx)
-	if _, err := svc.Apply(ctx, sdk.DBConfig{Driver: h.Driver, DSN: h.DSN, Schema: "public", TablePrefix: h.TablePrefix}, data, sdk.ApplyOptions{Actor: actor}); err != nil {
+	svc := sdkService(h.Service, h.Recorder)
+	rep, err := svc.Apply(ctx, sdk.DBConfig{Driver: h.Driver, DSN: h.DSN, Schema: "public", TablePrefix: h.TablePrefix}, data, snapshot.RestoreOptions(tid, actor))
+	if err != nil {
 		return nil, err
 	}
 	ch, err := snapshot.DiffYaml(current, data)
@@ -195,5 +325,164 @@ func (h *SnapshotHandler) apply(ctx context.Context, p *snapshotApplyParams) (*s
 		summary := fmt.Sprintf("+%d -%d", rep.Added, rep.Deleted)
 		_ = h.Recorder.WriteAction(ctx, actor, "rollback", p.Ver, summary)
 	}
+	return &snapshotApplyOutput{Body: schema.SnapshotApplyResult{
+		Parts:   []string{snapshot.PartRegistry},
+		Added:   rep.Added,
+		Deleted: rep.Deleted,
+		Updated: rep.Updated,
+	}}, nil
+}
+
+func (h *SnapshotHandler) applyBundle(ctx context.Context, tid, actor string, p *snapshotApplyParams, data []byte, parts []string) (*snapshotApplyOutput, error) {
+	if h.Bundles == nil {
+		return nil, huma.Error422UnprocessableEntity("bundle snapshots are not supported by this server")
+	}
+	b, err := snapshot.DecodeBundle(data)
+	if err != nil {
+		return nil, err
+	}
+	global := h.canRestoreGlobal(ctx)
+	var withheld []string
+	if p.Include == "" {
+		parts = nil
+		for _, part := range b.Parts() {
+			if !global && snapshotbundle.IsGlobalPart(part) {
+				withheld = append(withheld, part+" (shared by all tenants)")
+				continue
+			}
+			parts = append(parts, part)
+		}
+	}
+	res, err := h.Bundles.Restore(ctx, tid, b, parts, snapshotbundle.RestoreOptions{Actor: actor, Global: global})
+	if err != nil {
+		if errors.Is(err, snapshotbundle.ErrPartUnavailable) {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		if errors.Is(err, snapshotbundle.ErrGlobalPart) {
+			return nil, huma.Error403Forbidden(err.Error())
+		}
+		return nil, err
+	}
+	res.Skipped = append(res.Skipped, withheld...)
+	_ = h.Recorder.WriteAction(ctx, actor, "rollback", p.Ver, fmt.Sprintf(`{"parts":%q}`, strings.Join(res.Parts, ",")))
+	return &snapshotApplyOutput{Body: schema.SnapshotApplyResult{
+		Parts:   res.Parts,
+		Added:   res.Registry.Added,
+		Deleted: res.Registry.Deleted,
+		Updated: res.Registry.Updated,
+		Skipped: res.Skipped,
+	}}, nil
+}
+
+func (h *SnapshotHandler) getRetention(ctx context.Context, _ *struct{}) (*snapshotRetentionOutput, error) {
+	p, err := snapshot.GetRetention(ctx, h.DB, h.Dialect, h.TablePrefix, tenant.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	out := schema.SnapshotRetention{KeepLast: p.KeepLast}
+	if p.KeepWithin > 0 {
+		out.KeepWithin = p.KeepWithin.String()
+	}
+	return &snapshotRetentionOutput{Body: out}, nil
+}
+
+func (h *SnapshotHandler) putRetention(ctx context.Context, in *snapshotRetentionInput) (*snapshotRetentionOutput, error) {
+	if in.Body.KeepLast < 0 {
+		return nil, huma.Error422UnprocessableEntity("keepLast must not be negative")
+	}
+	var within time.Duration
+	if in.Body.KeepWithin != "" {
+		d, err := time.ParseDuration(in.Body.KeepWithin)
+		if err != nil || d < 0 {
+			return nil, huma.Error422UnprocessableEntity("keepWithin must be a positive duration")
+		}
+		within = d
+	}
+	p := snapshot.RetentionPolicy{Tenant: tenant.FromContext(ctx), KeepLast: in.Body.KeepLast, KeepWithin: within}
+	if err := snapshot.SetRetention(ctx, h.DB, h.Dialect, h.TablePrefix, p); err != nil {
+		return nil, err
+	}
+	actor := middleware.UserFromContext(ctx)
+	_ = h.Recorder.WriteAction(ctx, actor, "snapshot_retention", p.Tenant, fmt.Sprintf(`{"keep_last":%d,"keep_within":%q}`, p.KeepLast, p.KeepWithin.String()))
+	return &snapshotRetentionOutput{Body: in.Body}, nil
+}
+
+func (h *SnapshotHandler) prune(ctx context.Context, in *snapshotPruneParams) (*snapshotPruneOutput, error) {
+	tid := tenant.FromContext(ctx)
+	p, err := snapshot.GetRetention(ctx, h.DB, h.Dialect, h.TablePrefix, tid)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Enabled() {
+		return nil, huma.Error422UnprocessableEntity("no retention policy configured for tenant")
+	}
+	res, err := snapshot.Prune(ctx, h.DB, h.Dialect, h.TablePrefix, p, time.Now(), in.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	out := schema.SnapshotPruneResult{DryRun: res.DryRun, Kept: res.Kept, Bytes: res.Bytes, Deleted: make([]schema.Snapshot, len(res.Deleted))}
+	for i, r := range res.Deleted {
+		out.Deleted[i] = schema.Snapshot{ID: r.ID, Semver: r.Semver, TakenAt: r.TakenAt, Author: r.Author}
+	}
+	if !res.DryRun && len(res.Deleted) > 0 {
+		actor := middleware.UserFromContext(ctx)
+		_ = h.Recorder.WriteAction(ctx, actor, "snapshot_prune", tid, fmt.Sprintf(`{"deleted":%d,"bytes":%d}`, len(res.Deleted), res.Bytes))
+	}
+	return &snapshotPruneOutput{Body: out}, nil
+}
+
+func (h *SnapshotHandler) tag(ctx context.Context, p *snapshotTagParams) (*struct{}, error) {
+	tid := tenant.FromContext(ctx)
+	if err := snapshot.ValidateTag(p.Tag); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	rec, err := snapshot.Get(ctx, h.DB, h.Dialect, h.TablePrefix, tid, p.Ver)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, huma.Error404NotFound("snapshot not found")
+		}
+		return nil, err
+	}
+	if err := snapshot.SetTag(ctx, h.DB, h.Dialect, h.TablePrefix, tid, p.Tag, rec.ID); err != nil {
+		return nil, err
+	}
+	actor := middleware.UserFromContext(ctx)
+	_ = h.Recorder.WriteAction(ctx, actor, "snapshot_tag", p.Ver, fmt.Sprintf(`{"tag":%q}`, p.Tag))
 	return &struct{}{}, nil
 }
+
+func (h *SnapshotHandler) untag(ctx context.Context, p *snapshotTagParams) (*struct{}, error) {
+	tid := tenant.FromContext(ctx)
+	rec, err := snapshot.GetByTag(ctx, h.DB, h.Dialect, h.TablePrefix, tid, p.Tag)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, huma.Error404NotFound("tag not found")
+		}
+		return nil, err
+	}
+	if rec.Semver != p.Ver {
+		return nil, huma.Error409Conflict(fmt.Sprintf("tag %s points at snapshot %s", p.Tag, rec.Semver))
+	}
+	if err := snapshot.DeleteTag(ctx, h.DB, h.Dialect, h.TablePrefix, tid, p.Tag); err != nil {
+		return nil, err
+	}
+	actor := middleware.UserFromContext(ctx)
+	_ = h.Recorder.WriteAction(ctx, actor, "snapshot_untag", p.Ver, fmt.Sprintf(`{"tag":%q}`, p.Tag))
+	return nil, nil
+}
+
+func (h *SnapshotHandler) lineage(ctx context.Context, p *snapshotDetailParams) (*snapshotLineageOutput, error) {
+	tid := tenant.FromContext(ctx)
+	rec, err := snapshot.Get(ctx, h.DB, h.Dialect, h.TablePrefix, tid, p.Ver)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, huma.Error404NotFound("snapshot not found")
+		}
+		return nil, err
+	}
+	out, err := snapshot.Lineage(ctx, h.DB, h.Dialect, h.TablePrefix, rec.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &snapshotLineageOutput{Body: out}, nil
+}
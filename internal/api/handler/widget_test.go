@@ -1,6 +1,14 @@
 package handler
 
-import "testing"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/danielgtaylor/huma/v2"
+	widgetreg "github.com/faciam-dev/gcfm/internal/registry/widgets"
+)
 
 func TestCanonicalizeWidgetID(t *testing.T) {
 	tests := []struct {
@@ -23,3 +31,25 @@ func TestCanonicalizeWidgetID(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateWidgetConfigReportsPointers(t *testing.T) {
+	reg := widgetreg.NewInMemory()
+	_ = reg.Upsert(context.Background(), widgetreg.Widget{
+		ID:   "rating",
+		Name: "Rating",
+		Type: "widget",
+		Meta: map[string]any{"config_schema": `{"type":"object","properties":{"max":{"type":"integer","maximum":10}}}`},
+	})
+	h := &CustomFieldHandler{WidgetRegistry: reg}
+	if err := h.validateWidgetConfig("plugin://rating", json.RawMessage(`{"max":5}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := h.validateWidgetConfig("plugin://rating", json.RawMessage(`{"max":50}`))
+	var se *huma.ErrorModel
+	if !errors.As(err, &se) {
+		t.Fatalf("expected huma error, got %v", err)
+	}
+	if se.Status != 422 || len(se.Errors) != 1 || se.Errors[0].Location != "display.widget_config/max" {
+		t.Fatalf("unexpected error: %+v", se)
+	}
+}
@@ -0,0 +1,127 @@
+package auditlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+)
+
+var exportColumns = []string{
+	"id", "tenant_id", "actor", "action", "table_name", "column_name", "resource_type",
+	"resource_id", "request_id", "revert_of", "before_json", "after_json", "added_count",
+	"removed_count", "change_count", "applied_at",
+}
+
+func TestExportJSONL(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	ts := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT .* FROM `gcfm_audit_logs` as `l` WHERE `l`.`tenant_id` = \\? AND `l`.`resource_type` IN \\(\\?\\)").
+		WithArgs("t1", "role").
+		WillReturnRows(sqlmock.NewRows(exportColumns).
+			AddRow(2, "t1", "alice", "delete", "", "", "role", "7", "req-1", nil, `{"name":"ops"}`, `{}`, 0, 1, 1, ts).
+			AddRow(1, "t1", "alice", "create", "", "", "role", "7", "", nil, `{}`, `{"name":"ops"}`, 1, 0, 0, ts))
+
+	repo := Repo{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_"}
+	min := 1
+	var buf bytes.Buffer
+	n, err := repo.Export(context.Background(), &buf, FormatJSONL, Filter{Tenant: "t1", ResourceTypes: []string{"role"}, MinChanges: &min})
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("exported %d rows, want 1", n)
+	}
+	var e Entry
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatalf("decode %q: %v", buf.String(), err)
+	}
+	if e.ID != 2 || e.RequestID != "req-1" || string(e.BeforeJSON) != `{"name":"ops"}` || !e.AppliedAt.Equal(ts) {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT .* FROM `gcfm_audit_logs`").
+		WillReturnRows(sqlmock.NewRows(exportColumns).
+			AddRow(1, "t1", "bob", "add", "posts", "title", "custom_field", "posts.title", "", 5, `{}`, `{"a":1}`, 1, 0, 1, "2026-03-01 12:00:00"))
+
+	repo := Repo{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_"}
+	var buf bytes.Buffer
+	if _, err := repo.Export(context.Background(), &buf, FormatCSV, Filter{Tenant: "t1"}); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "id,tenant,applied_at,") {
+		t.Fatalf("unexpected csv:\n%s", buf.String())
+	}
+	if !strings.HasPrefix(lines[1], "1,t1,2026-03-01T12:00:00Z,bob,add,posts,title,custom_field,posts.title,") {
+		t.Fatalf("unexpected row: %s", lines[1])
+	}
+}
+
+func TestExportUnknownFormat(t *testing.T) {
+	repo := Repo{}
+	if _, err := repo.Export(context.Background(), &bytes.Buffer{}, "xml", Filter{}); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
+
+func TestExportPagesWithCursor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	ts := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	first := sqlmock.NewRows(exportColumns)
+	for id := exportPageSize + 1; id > 1; id-- {
+		first.AddRow(id, "t1", "alice", "add", "posts", "title", "custom_field", "posts.title", "", nil, `{}`, `{}`, 1, 0, 1, ts)
+	}
+	mock.ExpectQuery("SELECT .* FROM `gcfm_audit_logs` as `l` WHERE `l`.`tenant_id` = \\? ORDER BY").
+		WithArgs("t1").
+		WillReturnRows(first)
+	// The second page continues after the last row of the first one.
+	mock.ExpectQuery("SELECT .* FROM `gcfm_audit_logs` as `l` WHERE `l`.`tenant_id` = \\? AND .*`l`.`applied_at` < \\?.*`l`.`id` < \\?").
+		WithArgs("t1", ts, ts, int64(2)).
+		WillReturnRows(sqlmock.NewRows(exportColumns).
+			AddRow(1, "t1", "alice", "add", "posts", "body", "custom_field", "posts.body", "", nil, `{}`, `{}`, 1, 0, 1, ts))
+
+	repo := Repo{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_"}
+	var buf bytes.Buffer
+	n, err := repo.Export(context.Background(), &buf, FormatJSONL, Filter{Tenant: "t1"})
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if n != exportPageSize+1 {
+		t.Fatalf("exported %d rows, want %d", n, exportPageSize+1)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var last Entry
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if last.ID != 1 || last.ColumnName != "body" {
+		t.Fatalf("last entry = %+v", last)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
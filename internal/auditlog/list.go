@@ -0,0 +1,228 @@
+package auditlog
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/faciam-dev/goquent/orm/driver"
+	"github.com/faciam-dev/goquent/orm/query"
+)
+
+// Filter selects the audit log rows of one tenant. Zero values match
+// everything.
+type Filter struct {
+	Tenant        string
+	Actions       []string
+	Actor         string
+	Table         string
+	Column        string
+	ResourceTypes []string
+	ResourceID    string
+	// From is inclusive and To exclusive.
+	From time.Time
+	To   time.Time
+	// MinChanges and MaxChanges bound change_count. They are not part of the
+	// SQL query; callers check rows with Pass.
+	MinChanges *int
+	MaxChanges *int
+	// After continues a listing below the given position.
+	After *Cursor
+}
+
+// Cursor is the position of a row in the applied_at desc, id desc order used
+// by List.
+type Cursor struct {
+	AppliedAt time.Time
+	ID        int64
+}
+
+// Pass reports whether changeCount lies within the MinChanges/MaxChanges
+// bounds of f.
+func (f Filter) Pass(changeCount int) bool {
+	if f.MinChanges != nil && changeCount < *f.MinChanges {
+		return false
+	}
+	if f.MaxChanges != nil && changeCount > *f.MaxChanges {
+		return false
+	}
+	return true
+}
+
+// Entry is an audit log row returned by List. Actor is resolved to the
+// username when it refers to a known user.
+type Entry struct {
+	ID           int64           `json:"id"`
+	Tenant       string          `json:"tenant"`
+	Actor        string          `json:"actor"`
+	Action       string          `json:"action"`
+	TableName    string          `json:"tableName,omitempty"`
+	ColumnName   string          `json:"columnName,omitempty"`
+	ResourceType string          `json:"resourceType,omitempty"`
+	ResourceID   string          `json:"resourceId,omitempty"`
+	RequestID    string          `json:"requestId,omitempty"`
+	RevertOf     int64           `json:"revertOf,omitempty"`
+	BeforeJSON   json.RawMessage `json:"beforeJson"`
+	AfterJSON    json.RawMessage `json:"afterJson"`
+	AddedCount   int             `json:"addedCount"`
+	RemovedCount int             `json:"removedCount"`
+	ChangeCount  int             `json:"changeCount"`
+	AppliedAt    time.Time       `json:"appliedAt"`
+}
+
+// Cursor returns the position of e.
+func (e Entry) Cursor() Cursor {
+	return Cursor{AppliedAt: e.AppliedAt, ID: e.ID}
+}
+
+// List returns up to limit rows matching f, newest first.
+func (r *Repo) List(ctx context.Context, f Filter, limit int) ([]Entry, error) {
+	_, isPg := r.Dialect.(driver.PostgresDialect)
+	actorSub := actorSubquery(r.TablePrefix, isPg)
+	coalesceBefore := "CAST(COALESCE(l.before_json, JSON_OBJECT()) AS CHAR)"
+	coalesceAfter := "CAST(COALESCE(l.after_json , JSON_OBJECT()) AS CHAR)"
+	if isPg {
+		coalesceBefore = "COALESCE(l.before_json, '{}'::jsonb)::text"
+		coalesceAfter = "COALESCE(l.after_json , '{}'::jsonb)::text"
+	}
+
+	q := query.New(r.DB, r.TablePrefix+"audit_logs as l", r.Dialect).
+		Select("l.id", "l.tenant_id").
+		SelectRaw("COALESCE("+actorSub+", l.actor) as actor").
+		Select("l.action").
+		SelectRaw("COALESCE(l.table_name, '') as table_name").
+		SelectRaw("COALESCE(l.column_name, '') as column_name").
+		SelectRaw("COALESCE(l.resource_type, '') as resource_type").
+		SelectRaw("COALESCE(l.resource_id, '') as resource_id").
+		SelectRaw("COALESCE(l.request_id, '') as request_id").
+		Select("l.revert_of").
+		SelectRaw(coalesceBefore+" as before_json").
+		SelectRaw(coalesceAfter+" as after_json").
+		Select("l.added_count", "l.removed_count", "l.change_count", "l.applied_at")
+	f.apply(q)
+	q.OrderBy("l.applied_at", "desc").OrderBy("l.id", "desc").Limit(limit)
+
+	var rows []struct {
+		ID           int64         `db:"id"`
+		Tenant       string        `db:"tenant_id"`
+		Actor        string        `db:"actor"`
+		Action       string        `db:"action"`
+		TableName    string        `db:"table_name"`
+		ColumnName   string        `db:"column_name"`
+		ResourceType string        `db:"resource_type"`
+		ResourceID   string        `db:"resource_id"`
+		RequestID    string        `db:"request_id"`
+		RevertOf     sql.NullInt64 `db:"revert_of"`
+		BeforeJSON   []byte        `db:"before_json"`
+		AfterJSON    []byte        `db:"after_json"`
+		AddedCount   int           `db:"added_count"`
+		RemovedCount int           `db:"removed_count"`
+		ChangeCount  int           `db:"change_count"`
+		AppliedAt    any           `db:"applied_at"`
+	}
+	if err := q.WithContext(ctx).Get(&rows); err != nil {
+		return nil, err
+	}
+	out := make([]Entry, len(rows))
+	for i, row := range rows {
+		t, err := ParseTime(row.AppliedAt)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = Entry{
+			ID:           row.ID,
+			Tenant:       row.Tenant,
+			Actor:        row.Actor,
+			Action:       row.Action,
+			TableName:    row.TableName,
+			ColumnName:   row.ColumnName,
+			ResourceType: row.ResourceType,
+			ResourceID:   row.ResourceID,
+			RequestID:    row.RequestID,
+			RevertOf:     row.RevertOf.Int64,
+			BeforeJSON:   append(json.RawMessage(nil), row.BeforeJSON...),
+			AfterJSON:    append(json.RawMessage(nil), row.AfterJSON...),
+			AddedCount:   row.AddedCount,
+			RemovedCount: row.RemovedCount,
+			ChangeCount:  row.ChangeCount,
+			AppliedAt:    t,
+		}
+	}
+	return out, nil
+}
+
+// actorSubquery selects the username of the user referenced by l.actor.
+func actorSubquery(prefix string, isPg bool) string {
+	if isPg {
+		return "(SELECT username FROM " + prefix + "users u WHERE u.id::text = l.actor)"
+	}
+	return "(SELECT username FROM " + prefix + "users u WHERE u.id = CAST(l.actor AS UNSIGNED))"
+}
+
+func (f Filter) apply(q *query.Query) {
+	q.Where("l.tenant_id", f.Tenant)
+	if f.Actor != "" {
+		q.Where("l.actor", f.Actor)
+	}
+	if f.Table != "" {
+		q.Where("l.table_name", f.Table)
+	}
+	if f.Column != "" {
+		q.Where("l.column_name", f.Column)
+	}
+	if len(f.Actions) > 0 {
+		q.WhereIn("l.action", f.Actions)
+	}
+	if len(f.ResourceTypes) > 0 {
+		q.WhereIn("l.resource_type", f.ResourceTypes)
+	}
+	if f.ResourceID != "" {
+		q.Where("l.resource_id", f.ResourceID)
+	}
+	if !f.From.IsZero() {
+		q.Where("l.applied_at", ">=", f.From)
+	}
+	if !f.To.IsZero() {
+		q.Where("l.applied_at", "<", f.To)
+	}
+	if c := f.After; c != nil {
+		q.WhereGroup(func(g *query.Query) {
+			g.Where("l.applied_at", "<", c.AppliedAt)
+			g.OrWhereGroup(func(g2 *query.Query) {
+				g2.Where("l.applied_at", "=", c.AppliedAt)
+				g2.Where("l.id", "<", c.ID)
+			})
+		})
+	}
+}
+
+// ParseTime converts a value returned from the database into a time.Time.
+// Drivers like the MySQL driver may return []byte or string for TIMESTAMP
+// columns when parseTime is disabled.
+func ParseTime(v any) (time.Time, error) {
+	if v == nil {
+		return time.Time{}, nil
+	}
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case []byte:
+		return parseTimeString(string(t))
+	case string:
+		return parseTimeString(t)
+	default:
+		return time.Time{}, fmt.Errorf("unsupported time type %T", v)
+	}
+}
+
+func parseTimeString(s string) (time.Time, error) {
+	layouts := []string{time.RFC3339Nano, "2006-01-02 15:04:05.000000000", "2006-01-02 15:04:05", time.RFC3339}
+	for _, l := range layouts {
+		if ts, err := time.Parse(l, s); err == nil {
+			return ts, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse time: %s", s)
+}
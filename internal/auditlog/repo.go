@@ -11,10 +11,15 @@ import (
 // Record represents a single audit log entry in the database.
 type Record struct {
 	ID           int64
+	Tenant       string `db:"tenant_id"`
 	Actor        string
 	Action       string
 	TableName    string
 	ColumnName   string
+	ResourceType string         `db:"resource_type"`
+	ResourceID   string         `db:"resource_id"`
+	RequestID    string         `db:"request_id"`
+	RevertOf     sql.NullInt64  `db:"revert_of"`
 	BeforeJSON   sql.NullString `db:"before_json"`
 	AfterJSON    sql.NullString `db:"after_json"`
 	AddedCount   int
@@ -32,6 +37,15 @@ type Repo struct {
 
 // FindByID returns a record by its ID.
 func (r *Repo) FindByID(ctx context.Context, id int64) (Record, error) {
+	return r.find(ctx, "", id)
+}
+
+// FindInTenant is like FindByID but only finds records of tenant.
+func (r *Repo) FindInTenant(ctx context.Context, tenant string, id int64) (Record, error) {
+	return r.find(ctx, tenant, id)
+}
+
+func (r *Repo) find(ctx context.Context, tenant string, id int64) (Record, error) {
 	if r == nil || r.DB == nil {
 		return Record{}, sql.ErrConnDone
 	}
@@ -55,16 +69,23 @@ func (r *Repo) FindByID(ctx context.Context, id int64) (Record, error) {
 	}
 
 	q := query.New(r.DB, logs+" as l", r.Dialect).
-		Select("l.id").
+		Select("l.id", "l.tenant_id").
 		SelectRaw("COALESCE("+actorSub+", l.actor) as actor").
 		Select("l.action").
 		SelectRaw("COALESCE(l.table_name, '') as table_name").
 		SelectRaw("COALESCE(l.column_name, '') as column_name").
+		SelectRaw("COALESCE(l.resource_type, '') as resource_type").
+		SelectRaw("COALESCE(l.resource_id, '') as resource_id").
+		SelectRaw("COALESCE(l.request_id, '') as request_id").
+		Select("l.revert_of").
 		SelectRaw(coalesceBefore+" as before_json").
 		SelectRaw(coalesceAfter+" as after_json").
 		Select("l.added_count", "l.removed_count", "l.change_count", "l.applied_at").
 		Where("l.id", id).
 		WithContext(ctx)
+	if tenant != "" {
+		q.Where("l.tenant_id", tenant)
+	}
 
 	var rec Record
 	if err := q.First(&rec); err != nil {
@@ -0,0 +1,205 @@
+package auditlog
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/faciam-dev/goquent/orm/driver"
+	"github.com/faciam-dev/goquent/orm/query"
+)
+
+// Stats dimensions accepted in StatsQuery.GroupBy.
+const (
+	GroupActor        = "actor"
+	GroupTable        = "table"
+	GroupColumn       = "column"
+	GroupAction       = "action"
+	GroupResourceType = "resource_type"
+)
+
+// Stats time buckets accepted in StatsQuery.Bucket.
+const (
+	BucketHour  = "hour"
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+// statsGroups maps a dimension to its result alias and column expression.
+var statsGroups = map[string]struct{ alias, expr string }{
+	GroupTable:        {"g_table", "COALESCE(l.table_name, '')"},
+	GroupColumn:       {"g_column", "COALESCE(l.column_name, '')"},
+	GroupAction:       {"g_action", "l.action"},
+	GroupResourceType: {"g_resource_type", "COALESCE(l.resource_type, '')"},
+}
+
+// mysqlBuckets truncates applied_at with DATE_FORMAT; weeks start on Monday
+// like date_trunc('week', ...) on PostgreSQL. applied_at is replaced by
+// mysqlUTC so that buckets do not depend on the session time zone.
+var mysqlBuckets = map[string]string{
+	BucketHour:  "DATE_FORMAT(l.applied_at, '%Y-%m-%d %H:00:00')",
+	BucketDay:   "DATE_FORMAT(l.applied_at, '%Y-%m-%d 00:00:00')",
+	BucketWeek:  "DATE_FORMAT(DATE_SUB(l.applied_at, INTERVAL WEEKDAY(l.applied_at) DAY), '%Y-%m-%d 00:00:00')",
+	BucketMonth: "DATE_FORMAT(l.applied_at, '%Y-%m-01 00:00:00')",
+}
+
+// mysqlUTC converts the TIMESTAMP column applied_at to a UTC DATETIME.
+// UNIX_TIMESTAMP reads the stored UTC value and DATE_ADD on a literal does
+// no time zone conversion, unlike CONVERT_TZ, which needs the zone tables
+// for named session zones.
+const mysqlUTC = "DATE_ADD('1970-01-01 00:00:00', INTERVAL UNIX_TIMESTAMP(l.applied_at) SECOND)"
+
+// bucketExpr returns the expression truncating applied_at to bucket in UTC.
+func bucketExpr(bucket string, isPg bool) string {
+	if isPg {
+		return "date_trunc('" + bucket + "', l.applied_at AT TIME ZONE 'UTC')"
+	}
+	return strings.ReplaceAll(mysqlBuckets[bucket], "l.applied_at", mysqlUTC)
+}
+
+// StatsQuery describes an aggregation over the audit log.
+type StatsQuery struct {
+	Filter
+	// GroupBy lists the dimensions to group by. Without dimensions and
+	// bucket a single total row is returned.
+	GroupBy []string
+	// Bucket optionally groups rows by truncated applied_at.
+	Bucket string
+	// Limit caps the number of returned groups; zero means no limit. With a
+	// bucket the newest buckets are kept.
+	Limit int
+}
+
+// Validate reports unknown or repeated dimensions and unknown buckets.
+func (s StatsQuery) Validate() error {
+	seen := make(map[string]bool, len(s.GroupBy))
+	for _, g := range s.GroupBy {
+		if _, ok := statsGroups[g]; !ok && g != GroupActor {
+			return fmt.Errorf("unknown group %q", g)
+		}
+		if seen[g] {
+			return fmt.Errorf("group %q given twice", g)
+		}
+		seen[g] = true
+	}
+	if s.Bucket != "" {
+		if _, ok := mysqlBuckets[s.Bucket]; !ok {
+			return fmt.Errorf("unknown bucket %q", s.Bucket)
+		}
+	}
+	return nil
+}
+
+// Stat is one aggregated group. Only the dimensions that were grouped by are
+// set; Actor is resolved to the username when it refers to a known user.
+type Stat struct {
+	Bucket       *time.Time `json:"bucket,omitempty"`
+	Actor        string     `json:"actor,omitempty"`
+	Table        string     `json:"table,omitempty"`
+	Column       string     `json:"column,omitempty"`
+	Action       string     `json:"action,omitempty"`
+	ResourceType string     `json:"resourceType,omitempty"`
+	// Entries is the number of audit rows in the group.
+	Entries int64 `json:"entries"`
+	// Changes is the sum of change_count over the group.
+	Changes int64 `json:"changes"`
+}
+
+// Stats aggregates the rows matching s.Filter in the database. Groups are
+// ordered by bucket and then by descending entry count, so grouping by table
+// and column without a bucket lists the most churned fields first. Buckets
+// are truncated in UTC. It reports true when s.Limit cut off further groups;
+// with a bucket the oldest groups are the ones left out.
+func (r *Repo) Stats(ctx context.Context, s StatsQuery) ([]Stat, bool, error) {
+	if err := s.Validate(); err != nil {
+		return nil, false, err
+	}
+	_, isPg := r.Dialect.(driver.PostgresDialect)
+
+	q := query.New(r.DB, r.TablePrefix+"audit_logs as l", r.Dialect)
+	var groups []string
+	if s.Bucket != "" {
+		q.SelectRaw(bucketExpr(s.Bucket, isPg) + " as g_bucket")
+		groups = append(groups, "g_bucket")
+	}
+	for _, g := range s.GroupBy {
+		if g == GroupActor {
+			q.SelectRaw("COALESCE(" + actorSubquery(r.TablePrefix, isPg) + ", l.actor) as g_actor")
+			groups = append(groups, "g_actor")
+			continue
+		}
+		col := statsGroups[g]
+		q.SelectRaw(col.expr + " as " + col.alias)
+		groups = append(groups, col.alias)
+	}
+	// SUM yields DECIMAL on MySQL; cast it so both dialects return integers.
+	sum := "CAST(COALESCE(SUM(l.change_count), 0) AS SIGNED)"
+	if isPg {
+		sum = "COALESCE(SUM(l.change_count), 0)"
+	}
+	q.SelectRaw("COUNT(*) as entries").SelectRaw(sum + " as changes")
+	s.Filter.After = nil
+	s.Filter.apply(q)
+	if s.MinChanges != nil {
+		q.Where("l.change_count", ">=", *s.MinChanges)
+	}
+	if s.MaxChanges != nil {
+		q.Where("l.change_count", "<=", *s.MaxChanges)
+	}
+	if len(groups) > 0 {
+		q.GroupBy(groups...)
+	}
+	// Newest buckets first so that the limit drops the oldest ones; the
+	// result is put back into ascending order below.
+	if s.Bucket != "" {
+		q.OrderByRaw("g_bucket DESC")
+	}
+	q.OrderByRaw("entries DESC")
+	if s.Limit > 0 {
+		q.Limit(s.Limit + 1)
+	}
+
+	var rows []struct {
+		Bucket       any    `db:"g_bucket"`
+		Actor        string `db:"g_actor"`
+		Table        string `db:"g_table"`
+		Column       string `db:"g_column"`
+		Action       string `db:"g_action"`
+		ResourceType string `db:"g_resource_type"`
+		Entries      int64  `db:"entries"`
+		Changes      int64  `db:"changes"`
+	}
+	if err := q.WithContext(ctx).Get(&rows); err != nil {
+		return nil, false, err
+	}
+	truncated := s.Limit > 0 && len(rows) > s.Limit
+	if truncated {
+		rows = rows[:s.Limit]
+	}
+	out := make([]Stat, len(rows))
+	for i, row := range rows {
+		out[i] = Stat{
+			Actor:        row.Actor,
+			Table:        row.Table,
+			Column:       row.Column,
+			Action:       row.Action,
+			ResourceType: row.ResourceType,
+			Entries:      row.Entries,
+			Changes:      row.Changes,
+		}
+		if s.Bucket != "" {
+			t, err := ParseTime(row.Bucket)
+			if err != nil {
+				return nil, false, err
+			}
+			out[i].Bucket = &t
+		}
+	}
+	if s.Bucket != "" {
+		sort.SliceStable(out, func(i, j int) bool { return out[i].Bucket.Before(*out[j].Bucket) })
+	}
+	return out, truncated, nil
+}
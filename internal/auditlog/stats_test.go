@@ -0,0 +1,122 @@
+package auditlog
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+)
+
+func TestStatsMySQL(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT DATE_FORMAT\\(DATE_ADD\\('1970-01-01 00:00:00', INTERVAL UNIX_TIMESTAMP\\(l.applied_at\\) SECOND\\), '%Y-%m-%d 00:00:00'\\) as g_bucket, COALESCE\\(l.table_name, ''\\) as g_table, COUNT\\(\\*\\) as entries, .* "+
+		"WHERE `l`.`tenant_id` = \\? AND `l`.`change_count` >= \\? GROUP BY `g_bucket`, `g_table` ORDER BY g_bucket DESC, entries DESC LIMIT 11").
+		WithArgs("t1", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"g_bucket", "g_table", "entries", "changes"}).
+			AddRow("2026-03-02 00:00:00", "users", 1, 1).
+			AddRow("2026-03-01 00:00:00", "posts", 3, 5))
+
+	repo := Repo{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_"}
+	min := 1
+	stats, truncated, err := repo.Stats(context.Background(), StatsQuery{
+		Filter:  Filter{Tenant: "t1", MinChanges: &min},
+		GroupBy: []string{GroupTable},
+		Bucket:  BucketDay,
+		Limit:   10,
+	})
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if truncated {
+		t.Fatal("unexpected truncation")
+	}
+	if len(stats) != 2 || stats[0].Table != "posts" || stats[0].Entries != 3 || stats[0].Changes != 5 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if want := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC); stats[0].Bucket == nil || !stats[0].Bucket.Equal(want) {
+		t.Fatalf("unexpected bucket: %v", stats[0].Bucket)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
+
+func TestStatsPostgresActor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT date_trunc\\('week', l.applied_at AT TIME ZONE 'UTC'\\) as g_bucket, COALESCE\\(\\(SELECT username FROM gcfm_users u WHERE u.id::text = l.actor\\), l.actor\\) as g_actor, .* GROUP BY \"g_bucket\", \"g_actor\"").
+		WithArgs("t1").
+		WillReturnRows(sqlmock.NewRows([]string{"g_bucket", "g_actor", "entries", "changes"}).
+			AddRow(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), "alice", 4, 9))
+
+	repo := Repo{DB: db, Dialect: ormdriver.PostgresDialect{}, TablePrefix: "gcfm_"}
+	stats, _, err := repo.Stats(context.Background(), StatsQuery{
+		Filter:  Filter{Tenant: "t1"},
+		GroupBy: []string{GroupActor},
+		Bucket:  BucketWeek,
+	})
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if len(stats) != 1 || stats[0].Actor != "alice" || stats[0].Changes != 9 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestStatsLimitKeepsNewestBuckets(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("ORDER BY g_bucket DESC, entries DESC LIMIT 4").
+		WithArgs("t1").
+		WillReturnRows(sqlmock.NewRows([]string{"g_bucket", "g_action", "entries", "changes"}).
+			AddRow("2026-03-03 00:00:00", "update", 2, 2).
+			AddRow("2026-03-02 00:00:00", "update", 5, 5).
+			AddRow("2026-03-02 00:00:00", "add", 1, 1).
+			AddRow("2026-03-01 00:00:00", "update", 7, 7))
+
+	repo := Repo{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_"}
+	stats, truncated, err := repo.Stats(context.Background(), StatsQuery{
+		Filter:  Filter{Tenant: "t1"},
+		GroupBy: []string{GroupAction},
+		Bucket:  BucketDay,
+		Limit:   3,
+	})
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if !truncated {
+		t.Fatal("expected truncation")
+	}
+	var got []string
+	for _, s := range stats {
+		got = append(got, s.Bucket.Format("01-02")+" "+s.Action)
+	}
+	if want := "[03-02 update 03-02 add 03-03 update]"; fmt.Sprint(got) != want {
+		t.Fatalf("stats = %v, want %s", got, want)
+	}
+}
+
+func TestStatsValidate(t *testing.T) {
+	if err := (StatsQuery{GroupBy: []string{"tenant"}}).Validate(); err == nil {
+		t.Fatal("expected error for unknown group")
+	}
+	if err := (StatsQuery{GroupBy: []string{GroupTable, GroupTable}}).Validate(); err == nil {
+		t.Fatal("expected error for repeated group")
+	}
+	if err := (StatsQuery{Bucket: "year"}).Validate(); err == nil {
+		t.Fatal("expected error for unknown bucket")
+	}
+}
@@ -13,6 +13,7 @@ import (
 	cfhuma "github.com/faciam-dev/gcfm/internal/huma"
 	"github.com/faciam-dev/gcfm/internal/logger"
 	sm "github.com/faciam-dev/gcfm/internal/server/middleware"
+	"github.com/faciam-dev/gcfm/pkg/audit"
 	"github.com/faciam-dev/gcfm/pkg/tenant"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -20,6 +21,8 @@ import (
 type Handler struct {
 	Repo *UserRepo
 	JWT  *JWT
+	// Recorder, when set, receives login and failed login events.
+	Recorder *audit.Recorder
 }
 
 type loginBody struct {
@@ -73,10 +76,12 @@ func (h *Handler) login(ctx context.Context, in *loginInput) (*loginOutput, erro
 	}
 	if u == nil {
 		logger.L.Info("user not found", "username", in.Body.Username)
+		h.recordLogin(ctx, "login_failed", in.Body.Username, tenantID, "unknown user")
 		return nil, huma.Error401Unauthorized("invalid credentials")
 	}
 	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(in.Body.Password)) != nil {
 		logger.L.Info("password mismatch", "username", in.Body.Username)
+		h.recordLogin(ctx, "login_failed", in.Body.Username, tenantID, "password mismatch")
 		return nil, huma.Error401Unauthorized("invalid credentials")
 	}
 	roles, err := h.Repo.GetRoles(ctx, u.ID)
@@ -99,9 +104,32 @@ func (h *Handler) login(ctx context.Context, in *loginInput) (*loginOutput, erro
 		return nil, err
 	}
 	logger.L.Info("user logged in", "userID", u.ID, "tenant", tenantID)
+	h.recordLogin(ctx, "login", strconv.FormatUint(u.ID, 10), tenantID, "")
 	return &loginOutput{Body: tokenResponse{AccessToken: tok, ExpiresAt: time.Now().Add(h.JWT.exp)}}, nil
 }
 
+// recordLogin writes a session event to the audit log. Failed attempts are
+// attributed to the submitted username since no user was authenticated.
+func (h *Handler) recordLogin(ctx context.Context, action, actor, tenantID, reason string) {
+	if h.Recorder == nil {
+		return
+	}
+	var after any
+	if reason != "" {
+		after = map[string]string{"reason": reason}
+	}
+	if err := h.Recorder.Record(ctx, audit.Event{
+		ResourceType: audit.ResourceSession,
+		ResourceID:   actor,
+		Action:       action,
+		Actor:        actor,
+		Tenant:       tenantID,
+		After:        after,
+	}); err != nil {
+		logger.L.Error("audit login", "err", err)
+	}
+}
+
 type refreshInput struct{}
 
 func (h *Handler) refresh(ctx context.Context, _ *refreshInput) (*loginOutput, error) {
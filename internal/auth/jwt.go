@@ -3,6 +3,7 @@ package auth
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -20,11 +21,20 @@ type Claims struct {
 	jwt.RegisteredClaims
 	TenantID string   `json:"tid,omitempty"`
 	Roles    []string `json:"roles,omitempty"`
+	// Scope is the space separated OAuth scope claim, e.g. of service
+	// tokens issued by an external identity provider.
+	Scope string `json:"scope,omitempty"`
 }
 
 // GetTenantID returns the tenant ID claim.
 func (c *Claims) GetTenantID() string { return c.TenantID }
 
+// GetRoles returns the roles claim.
+func (c *Claims) GetRoles() []string { return c.Roles }
+
+// GetScopes returns the scopes of the scope claim.
+func (c *Claims) GetScopes() []string { return strings.Fields(c.Scope) }
+
 // NewJWT returns a new JWT handler.
 func NewJWT(secret string, exp time.Duration) *JWT {
 	return &JWT{secret: []byte(secret), exp: exp}
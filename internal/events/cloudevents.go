@@ -0,0 +1,129 @@
+package events
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// SpecVersion is the CloudEvents specification version emitted by the sinks.
+const SpecVersion = "1.0"
+
+// DefaultSource is the CloudEvents source used when Config.Source is empty.
+const DefaultSource = "/gcfm"
+
+// DefaultSchemaBaseURL is where the JSON Schemas of the event types are
+// published. The dataschema attribute is <base>/<schema>.json.
+const DefaultSchemaBaseURL = "https://faciam-dev.github.io/gcfm/schemas/events/v1"
+
+// Content types of the CloudEvents HTTP bindings.
+const (
+	ContentTypeStructured = "application/cloudevents+json"
+	ContentTypeJSON       = "application/json"
+)
+
+// CloudEvent is the structured-mode JSON representation of an Event.
+// Tenant is carried as the "tenant" extension attribute.
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	DataSchema      string          `json:"dataschema,omitempty"`
+	Tenant          string          `json:"tenant,omitempty"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// CloudEvent converts e into its CloudEvents envelope.
+func (e Event) CloudEvent() (CloudEvent, error) {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return CloudEvent{}, err
+	}
+	src := e.Source
+	if src == "" {
+		src = DefaultSource
+	}
+	return CloudEvent{
+		SpecVersion:     SpecVersion,
+		ID:              e.ID,
+		Source:          src,
+		Type:            e.Name,
+		Subject:         e.Subject,
+		Time:            e.Time.UTC(),
+		DataContentType: ContentTypeJSON,
+		DataSchema:      e.DataSchema,
+		Tenant:          e.Tenant,
+		Data:            data,
+	}, nil
+}
+
+// attributes lists the context attributes of c that are set, keyed by their
+// CloudEvents name. They become ce-* headers in binary mode.
+func (c CloudEvent) attributes() [][2]string {
+	attrs := [][2]string{
+		{"specversion", c.SpecVersion},
+		{"id", c.ID},
+		{"source", c.Source},
+		{"type", c.Type},
+		{"time", c.Time.Format(time.RFC3339Nano)},
+	}
+	for _, kv := range [][2]string{{"subject", c.Subject}, {"dataschema", c.DataSchema}, {"tenant", c.Tenant}} {
+		if kv[1] != "" {
+			attrs = append(attrs, kv)
+		}
+	}
+	return attrs
+}
+
+// SetHTTPBinary sets the ce-* headers and content type of the binary HTTP
+// binding. The request body is c.Data.
+func (c CloudEvent) SetHTTPBinary(h http.Header) {
+	for _, kv := range c.attributes() {
+		h.Set("ce-"+kv[0], kv[1])
+	}
+	h.Set("Content-Type", c.DataContentType)
+}
+
+// KafkaHeaders returns the ce_* headers of the Kafka binary binding.
+func (c CloudEvent) KafkaHeaders() []sarama.RecordHeader {
+	attrs := c.attributes()
+	out := make([]sarama.RecordHeader, 0, len(attrs)+1)
+	for _, kv := range attrs {
+		out = append(out, sarama.RecordHeader{Key: []byte("ce_" + kv[0]), Value: []byte(kv[1])})
+	}
+	return append(out, sarama.RecordHeader{Key: []byte("content-type"), Value: []byte(c.DataContentType)})
+}
+
+// FromHTTPBinary rebuilds an envelope from the headers and body of a binary
+// mode request.
+func FromHTTPBinary(h http.Header, body []byte) CloudEvent {
+	c := CloudEvent{
+		SpecVersion:     h.Get("ce-specversion"),
+		ID:              h.Get("ce-id"),
+		Source:          h.Get("ce-source"),
+		Type:            h.Get("ce-type"),
+		Subject:         h.Get("ce-subject"),
+		DataContentType: h.Get("Content-Type"),
+		DataSchema:      h.Get("ce-dataschema"),
+		Tenant:          h.Get("ce-tenant"),
+		Data:            body,
+	}
+	c.Time, _ = time.Parse(time.RFC3339Nano, h.Get("ce-time"))
+	return c
+}
+
+// SchemaName returns the name of the JSON Schema describing the data of
+// events of type t. All cf.audit.<action> events share "cf.audit".
+func SchemaName(t string) (string, bool) {
+	if strings.HasPrefix(t, TypeAuditPrefix) {
+		return "cf.audit", true
+	}
+	return t, schemaFiles[t]
+}
@@ -0,0 +1,307 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/faciam-dev/gcfm/pkg/tenant"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+	"github.com/faciam-dev/goquent/orm/query"
+)
+
+var (
+	// ErrFailedEventNotFound is returned when a DLQ entry does not exist.
+	ErrFailedEventNotFound = errors.New("events: failed event not found")
+	// ErrFailedEventLeased is returned when a DLQ entry is being replayed
+	// by another worker.
+	ErrFailedEventLeased = errors.New("events: failed event is being replayed")
+)
+
+// SQLDLQ stores failed events in the events_failed table, one row per event
+// and sink.
+type SQLDLQ struct {
+	DB          *sql.DB
+	Dialect     ormdriver.Dialect
+	TablePrefix string
+	// ReplayDelay schedules new rows for automatic replay after this delay.
+	// When zero, rows are only replayed on request.
+	ReplayDelay time.Duration
+}
+
+func (q *SQLDLQ) table() *query.Query {
+	return query.New(q.DB, q.TablePrefix+"events_failed", q.Dialect)
+}
+
+// Store inserts the event that sink failed to accept. The row belongs to
+// e.Tenant, or to the tenant of ctx when e has none.
+func (q *SQLDLQ) Store(ctx context.Context, e Event, sink string, attempts int, lastErr string) error {
+	if q == nil || q.DB == nil {
+		return nil
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	tid := e.Tenant
+	if tid == "" {
+		tid = tenant.FromContext(ctx)
+	}
+	if tid == "" {
+		tid = "default"
+	}
+	row := map[string]any{
+		"tenant_id":  tid,
+		"event_id":   e.ID,
+		"name":       e.Name,
+		"sink":       sink,
+		"payload":    string(data),
+		"attempts":   attempts,
+		"last_error": lastErr,
+	}
+	if q.ReplayDelay > 0 {
+		row["next_replay_at"] = time.Now().UTC().Add(q.ReplayDelay)
+	}
+	_, err = q.table().WithContext(ctx).Insert(row)
+	return err
+}
+
+// FailedEvent is an entry of the DLQ.
+type FailedEvent struct {
+	ID     int64
+	Tenant string
+	Name   string
+	// Sink is the sink that failed. Rows stored before sinks were recorded
+	// have none and are replayed to every sink.
+	Sink           string
+	Event          Event
+	Attempts       int
+	LastError      string
+	InsertedAt     time.Time
+	ReplayAttempts int
+	// NextReplayAt is when the replay worker retries the row; nil when the
+	// row is not scheduled.
+	NextReplayAt *time.Time
+}
+
+// FailedFilter selects DLQ entries. Zero fields match everything except
+// Tenant, which is applied unless AllTenants is set.
+type FailedFilter struct {
+	Tenant string
+	// AllTenants ignores Tenant. It is meant for operator tools and is never
+	// set from API input.
+	AllTenants bool
+	Sink       string
+	Name       string
+	// Before matches rows inserted before this time.
+	Before time.Time
+	// BeforeID pages through List: only rows with a smaller ID match.
+	BeforeID int64
+	Limit    int
+}
+
+func (f FailedFilter) apply(q *query.Query) *query.Query {
+	if !f.AllTenants {
+		q = q.Where("tenant_id", f.Tenant)
+	}
+	if f.Sink != "" {
+		q = q.Where("sink", f.Sink)
+	}
+	if f.Name != "" {
+		q = q.Where("name", f.Name)
+	}
+	if !f.Before.IsZero() {
+		q = q.Where("inserted_at", "<", f.Before.UTC())
+	}
+	if f.BeforeID > 0 {
+		q = q.Where("id", "<", f.BeforeID)
+	}
+	return q
+}
+
+type failedRow struct {
+	ID             int64          `db:"id"`
+	Tenant         string         `db:"tenant_id"`
+	Name           string         `db:"name"`
+	Sink           string         `db:"sink"`
+	Payload        []byte         `db:"payload"`
+	Attempts       int            `db:"attempts"`
+	LastError      sql.NullString `db:"last_error"`
+	InsertedAt     time.Time      `db:"inserted_at"`
+	ReplayAttempts int            `db:"replay_attempts"`
+	NextReplayAt   sql.NullTime   `db:"next_replay_at"`
+}
+
+var failedColumns = []string{"id", "tenant_id", "name", "sink", "payload", "attempts", "last_error", "inserted_at", "replay_attempts", "next_replay_at"}
+
+func (r failedRow) failed() FailedEvent {
+	f := FailedEvent{
+		ID:             r.ID,
+		Tenant:         r.Tenant,
+		Name:           r.Name,
+		Sink:           r.Sink,
+		Attempts:       r.Attempts,
+		LastError:      r.LastError.String,
+		InsertedAt:     r.InsertedAt,
+		ReplayAttempts: r.ReplayAttempts,
+	}
+	if r.NextReplayAt.Valid {
+		t := r.NextReplayAt.Time
+		f.NextReplayAt = &t
+	}
+	// A payload that does not decode still lists; replaying it fails.
+	_ = json.Unmarshal(r.Payload, &f.Event)
+	return f
+}
+
+// List returns the entries selected by f, newest first.
+func (q *SQLDLQ) List(ctx context.Context, f FailedFilter) ([]FailedEvent, error) {
+	qy := f.apply(q.table().Select(failedColumns...)).OrderBy("id", "desc")
+	if f.Limit > 0 {
+		qy = qy.Limit(f.Limit)
+	}
+	var rows []failedRow
+	if err := qy.WithContext(ctx).Get(&rows); err != nil {
+		return nil, err
+	}
+	out := make([]FailedEvent, len(rows))
+	for i, r := range rows {
+		out[i] = r.failed()
+	}
+	return out, nil
+}
+
+// Get returns the entry id of tenant.
+func (q *SQLDLQ) Get(ctx context.Context, tenant string, id int64) (FailedEvent, error) {
+	var rows []failedRow
+	if err := q.table().Select(failedColumns...).
+		Where("tenant_id", tenant).
+		Where("id", id).
+		WithContext(ctx).
+		Get(&rows); err != nil {
+		return FailedEvent{}, err
+	}
+	if len(rows) == 0 {
+		return FailedEvent{}, ErrFailedEventNotFound
+	}
+	return rows[0].failed(), nil
+}
+
+// Delete removes the entry id of tenant.
+func (q *SQLDLQ) Delete(ctx context.Context, tenant string, id int64) error {
+	res, err := q.table().Where("tenant_id", tenant).Where("id", id).WithContext(ctx).Delete()
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrFailedEventNotFound
+	}
+	return nil
+}
+
+// Purge deletes the entries selected by f and returns how many were removed.
+func (q *SQLDLQ) Purge(ctx context.Context, f FailedFilter) (int64, error) {
+	res, err := f.apply(q.table()).WithContext(ctx).Delete()
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Schedule makes the entries selected by f due for replay at the given time
+// and resets their backoff. It returns the number of entries scheduled.
+func (q *SQLDLQ) Schedule(ctx context.Context, f FailedFilter, at time.Time) (int64, error) {
+	res, err := f.apply(q.table()).WithContext(ctx).
+		Update(map[string]any{"next_replay_at": at.UTC(), "replay_attempts": 0})
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// unleased restricts qy to entries no worker holds a lease on at now.
+func unleased(qy *query.Query, now time.Time) *query.Query {
+	return qy.WhereGroup(func(g *query.Query) {
+		g.WhereNull("leased_until").OrWhere("leased_until", "<=", now)
+	})
+}
+
+// due returns up to limit unleased entries of any tenant scheduled at or
+// before now.
+func (q *SQLDLQ) due(ctx context.Context, now time.Time, limit int) ([]FailedEvent, error) {
+	var rows []failedRow
+	if err := unleased(q.table().Select(failedColumns...), now).
+		Where("next_replay_at", "<=", now).
+		OrderBy("next_replay_at", "asc").
+		OrderBy("id", "asc").
+		Limit(limit).
+		WithContext(ctx).
+		Get(&rows); err != nil {
+		return nil, err
+	}
+	out := make([]FailedEvent, len(rows))
+	for i, r := range rows {
+		out[i] = r.failed()
+	}
+	return out, nil
+}
+
+// claim leases the entry id until the given time so other replay workers
+// skip it. With due set only an entry scheduled at or before now is
+// claimed. It reports whether this caller won the entry.
+func (q *SQLDLQ) claim(ctx context.Context, id int64, now, until time.Time, due bool) (bool, error) {
+	qy := unleased(q.table().Where("id", id), now)
+	if due {
+		qy = qy.Where("next_replay_at", "<=", now)
+	}
+	res, err := qy.WithContext(ctx).Update(map[string]any{"leased_until": until})
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// replayFailed records a failed replay and releases the lease. A nil next
+// leaves the entry unscheduled.
+func (q *SQLDLQ) replayFailed(ctx context.Context, id int64, attempts int, lastErr string, next *time.Time) error {
+	data := map[string]any{"replay_attempts": attempts, "last_error": lastErr, "next_replay_at": nil, "leased_until": nil}
+	if next != nil {
+		data["next_replay_at"] = *next
+	}
+	_, err := q.table().Where("id", id).WithContext(ctx).Update(data)
+	return err
+}
+
+// DLQStat describes the entries of one sink.
+type DLQStat struct {
+	Sink   string
+	Depth  int64
+	Oldest time.Time
+}
+
+// Stats returns the number of entries and the oldest insertion time per
+// sink, across tenants.
+func (q *SQLDLQ) Stats(ctx context.Context) ([]DLQStat, error) {
+	var rows []struct {
+		Sink   string    `db:"sink"`
+		Depth  int64     `db:"depth"`
+		Oldest time.Time `db:"oldest"`
+	}
+	if err := q.table().
+		Select("sink").
+		SelectRaw("COUNT(*) AS depth").
+		SelectRaw("MIN(inserted_at) AS oldest").
+		GroupBy("sink").
+		WithContext(ctx).
+		Get(&rows); err != nil {
+		return nil, err
+	}
+	out := make([]DLQStat, len(rows))
+	for i, r := range rows {
+		out[i] = DLQStat{Sink: r.Sink, Depth: r.Depth, Oldest: r.Oldest}
+	}
+	return out, nil
+}
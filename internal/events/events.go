@@ -3,22 +3,33 @@ package events
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
 	"time"
 
-	ormdriver "github.com/faciam-dev/goquent/orm/driver"
-	"github.com/faciam-dev/goquent/orm/query"
+	"github.com/faciam-dev/gcfm/internal/logger"
+	"github.com/faciam-dev/gcfm/pkg/tenant"
+	"github.com/google/uuid"
 )
 
 // Default is the global dispatcher used by Emit.
 var Default *Dispatcher
 
-// Event represents a notification payload.
+// Event represents a notification payload. Sinks publish it as a
+// CloudEvent whose type is Name; see Event.CloudEvent.
 type Event struct {
 	Name string    `json:"name"`
 	Time time.Time `json:"time"`
 	Data any       `json:"data"`
-	ID   string    `json:"id"`
+	// ID is unique per event. The dispatcher generates one when empty.
+	ID string `json:"id"`
+	// Subject names the affected resource, e.g. "posts.title".
+	Subject string `json:"subject,omitempty"`
+	// Tenant defaults to the tenant of the emitting context.
+	Tenant     string `json:"tenant,omitempty"`
+	Source     string `json:"source,omitempty"`
+	DataSchema string `json:"dataschema,omitempty"`
 }
 
 // Sink publishes events.
@@ -26,9 +37,23 @@ type Sink interface {
 	Emit(ctx context.Context, e Event) error
 }
 
-// DLQ stores failed events.
+// NamedSink is implemented by sinks that report a stable name. The relay
+// uses it to remember which sinks already accepted an outbox row.
+type NamedSink interface {
+	Name() string
+}
+
+// SinkName returns the name of s, falling back to its Go type.
+func SinkName(s Sink) string {
+	if n, ok := s.(NamedSink); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", s)
+}
+
+// DLQ stores events a sink failed to accept after all attempts.
 type DLQ interface {
-	Store(ctx context.Context, e Event, attempts int, lastErr string) error
+	Store(ctx context.Context, e Event, sink string, attempts int, lastErr string) error
 }
 
 // Dispatcher broadcasts events to multiple sinks with retries.
@@ -37,6 +62,9 @@ type Dispatcher struct {
 	maxAttempts  int
 	initialDelay time.Duration
 	dlq          DLQ
+	outbox       *Outbox
+	source       string
+	schemaBase   string
 }
 
 // Config provides dispatcher settings.
@@ -46,7 +74,25 @@ type Config struct {
 		Redis   RedisConfig   `yaml:"redis"`
 		Kafka   KafkaConfig   `yaml:"kafka"`
 	} `yaml:"sinks"`
-	Retry RetryConfig `yaml:"retry"`
+	// Source is the CloudEvents source attribute; it defaults to
+	// DefaultSource.
+	Source string `yaml:"source"`
+	// SchemaBaseURL is the location of the published event schemas; it
+	// defaults to DefaultSchemaBaseURL.
+	SchemaBaseURL string       `yaml:"schema_base_url"`
+	Retry         RetryConfig  `yaml:"retry"`
+	Outbox        OutboxConfig `yaml:"outbox"`
+	Replay        ReplayConfig `yaml:"replay"`
+	Stream        StreamConfig `yaml:"stream"`
+	Audit         AuditConfig  `yaml:"audit"`
+	// TenantWebhooks configures delivery to webhooks registered by tenants.
+	TenantWebhooks TenantWebhookConfig `yaml:"tenant_webhooks"`
+}
+
+// AuditConfig controls how audit log rows are published.
+type AuditConfig struct {
+	// Forward publishes every new audit row as a cf.audit.<action> event.
+	Forward bool `yaml:"forward"`
 }
 
 type RetryConfig struct {
@@ -63,11 +109,42 @@ func NewDispatcher(cfg Config, dlq DLQ, sinks ...Sink) *Dispatcher {
 	if cfg.Retry.InitialDelay > 0 {
 		d.initialDelay = cfg.Retry.InitialDelay
 	}
+	d.source = cfg.Source
+	if d.source == "" {
+		d.source = DefaultSource
+	}
+	d.schemaBase = cfg.SchemaBaseURL
 	d.sinks = append(d.sinks, sinks...)
 	d.dlq = dlq
 	return d
 }
 
+// prepare fills in the CloudEvents attributes e does not set.
+func (d *Dispatcher) prepare(ctx context.Context, e Event) Event {
+	if e.ID == "" {
+		e.ID = uuid.NewString()
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if e.Tenant == "" {
+		e.Tenant = tenant.FromContext(ctx)
+	}
+	if e.Source == "" {
+		e.Source = d.source
+	}
+	if e.DataSchema == "" {
+		e.DataSchema = SchemaURL(d.schemaBase, e.Name)
+	}
+	return e
+}
+
+// UseOutbox makes Dispatch store events in o instead of sending them from
+// the calling process. A Relay delivers them afterwards.
+func (d *Dispatcher) UseOutbox(o *Outbox) {
+	d.outbox = o
+}
+
 // Emit sends an event using the global dispatcher if set.
 func Emit(ctx context.Context, e Event) {
 	if Default != nil {
@@ -75,14 +152,84 @@ func Emit(ctx context.Context, e Event) {
 	}
 }
 
-// Dispatch sends the event to all sinks asynchronously.
+// ErrNoOutbox is returned by EmitTx when events are not stored in an
+// outbox. Callers should Emit the event after committing instead.
+var ErrNoOutbox = errors.New("events: no outbox configured")
+
+// EmitTx stores e in the outbox of the global dispatcher within tx, so the
+// event is only published if tx commits.
+func EmitTx(ctx context.Context, tx *sql.Tx, e Event) error {
+	if Default == nil || Default.outbox == nil {
+		return ErrNoOutbox
+	}
+	return Default.outbox.Enqueue(ctx, tx, Default.prepare(ctx, e))
+}
+
+// Dispatch sends the event to all sinks. With an outbox the event is stored
+// for the relay; otherwise each sink is called asynchronously.
 func (d *Dispatcher) Dispatch(ctx context.Context, e Event) {
+	e = d.prepare(ctx, e)
+	if d.outbox != nil {
+		err := d.outbox.Enqueue(ctx, d.outbox.DB, e)
+		if err == nil {
+			return
+		}
+		logger.L.Error("enqueue event", "name", e.Name, "err", err)
+	}
 	for _, s := range d.sinks {
 		sink := s
 		go d.retrySend(ctx, sink, e)
 	}
 }
 
+// sinkError is the failure of one sink to accept an event.
+type sinkError struct {
+	sink string
+	err  error
+}
+
+func (e sinkError) Error() string { return e.sink + ": " + e.err.Error() }
+func (e sinkError) Unwrap() error { return e.err }
+
+// joinSinkErrors joins failures into one error, or returns nil.
+func joinSinkErrors(failed []sinkError) error {
+	errs := make([]error, len(failed))
+	for i, f := range failed {
+		errs[i] = f
+	}
+	return errors.Join(errs...)
+}
+
+// deliver makes one attempt to send e to every sink not listed in skip. It
+// returns the names of all sinks that have accepted the event so far and
+// the failures of the others.
+func (d *Dispatcher) deliver(ctx context.Context, e Event, skip []string) ([]string, []sinkError) {
+	delivered := append([]string(nil), skip...)
+	var failed []sinkError
+	for _, s := range d.sinks {
+		name := SinkName(s)
+		if slices.Contains(skip, name) {
+			continue
+		}
+		if err := s.Emit(ctx, e); err != nil {
+			failed = append(failed, sinkError{sink: name, err: err})
+			continue
+		}
+		delivered = append(delivered, name)
+	}
+	return delivered, failed
+}
+
+// sink returns the sink with the given name.
+func (d *Dispatcher) sink(name string) (Sink, bool) {
+	for _, s := range d.sinks {
+		if SinkName(s) == name {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
 func (d *Dispatcher) retrySend(ctx context.Context, s Sink, e Event) {
 	delay := d.initialDelay
 	var err error
@@ -94,33 +241,6 @@ func (d *Dispatcher) retrySend(ctx context.Context, s Sink, e Event) {
 		delay *= 2
 	}
 	if d.dlq != nil {
-		_ = d.dlq.Store(ctx, e, d.maxAttempts, err.Error())
-	}
-}
-
-// SQLDLQ stores failed events in the database.
-type SQLDLQ struct {
-	DB          *sql.DB
-	Dialect     ormdriver.Dialect
-	TablePrefix string
-}
-
-// Store inserts the failed event.
-func (q *SQLDLQ) Store(ctx context.Context, e Event, attempts int, lastErr string) error {
-	if q == nil || q.DB == nil {
-		return nil
-	}
-	data, err := json.Marshal(e)
-	if err != nil {
-		return err
+		_ = d.dlq.Store(ctx, e, SinkName(s), d.maxAttempts, err.Error())
 	}
-	tbl := q.TablePrefix + "events_failed"
-	qy := query.New(q.DB, tbl, q.Dialect).WithContext(ctx)
-	_, err = qy.Insert(map[string]any{
-		"name":       e.Name,
-		"payload":    string(data),
-		"attempts":   attempts,
-		"last_error": lastErr,
-	})
-	return err
 }
@@ -2,7 +2,6 @@ package events
 
 import (
 	"context"
-	"encoding/json"
 
 	"github.com/IBM/sarama"
 )
@@ -12,9 +11,13 @@ type KafkaConfig struct {
 	Enabled bool     `yaml:"enabled"`
 	Brokers []string `yaml:"brokers"`
 	Topic   string   `yaml:"topic"`
+	// Subscribe selects the events produced to the topic.
+	Subscribe Subscription `yaml:"subscribe"`
 }
 
-// KafkaSink publishes events to Kafka.
+// KafkaSink publishes events to Kafka using the binary CloudEvents binding:
+// the value is the event data and the attributes are ce_* headers. Messages
+// are keyed by subject so changes to one resource stay ordered.
 type KafkaSink struct {
 	Producer sarama.AsyncProducer
 	Topic    string
@@ -33,15 +36,25 @@ func NewKafkaSink(c KafkaConfig) (*KafkaSink, error) {
 	return &KafkaSink{Producer: prod, Topic: c.Topic}, nil
 }
 
+// Name implements NamedSink.
+func (s *KafkaSink) Name() string { return "kafka" }
+
 func (s *KafkaSink) Emit(ctx context.Context, e Event) error {
 	if s == nil || s.Producer == nil {
 		return nil
 	}
-	data, err := json.Marshal(e)
+	ce, err := e.CloudEvent()
 	if err != nil {
 		return err
 	}
-	msg := &sarama.ProducerMessage{Topic: s.Topic, Value: sarama.ByteEncoder(data)}
+	msg := &sarama.ProducerMessage{
+		Topic:   s.Topic,
+		Value:   sarama.ByteEncoder(ce.Data),
+		Headers: ce.KafkaHeaders(),
+	}
+	if ce.Subject != "" {
+		msg.Key = sarama.StringEncoder(ce.Subject)
+	}
 	select {
 	case s.Producer.Input() <- msg:
 		return nil
@@ -0,0 +1,363 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/faciam-dev/gcfm/internal/logger"
+	"github.com/faciam-dev/gcfm/pkg/tenant"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+	"github.com/faciam-dev/goquent/orm/query"
+)
+
+// Outbox row states. Failed rows have been handed to the DLQ and are not
+// retried by the relay.
+const (
+	OutboxPending   = "pending"
+	OutboxDelivered = "delivered"
+	OutboxFailed    = "failed"
+)
+
+// OutboxConfig configures the transactional outbox and its relay.
+type OutboxConfig struct {
+	// Disabled dispatches events directly from the emitting process, as
+	// before the outbox existed.
+	Disabled bool `yaml:"disabled"`
+	// PollInterval is how often the relay looks for due rows.
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// Lease is how long a relay owns claimed rows before another replica
+	// may take them over.
+	Lease time.Duration `yaml:"lease"`
+	// BatchSize is the number of rows claimed per poll.
+	BatchSize int `yaml:"batch_size"`
+	// Retention is how long delivered rows are kept.
+	Retention time.Duration `yaml:"retention"`
+}
+
+// execer is satisfied by *sql.DB and *sql.Tx.
+type execer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	Exec(query string, args ...any) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Outbox persists events in the events_outbox table. Rows are written in the
+// caller's transaction and delivered later by a Relay.
+type Outbox struct {
+	DB          *sql.DB
+	Dialect     ormdriver.Dialect
+	TablePrefix string
+}
+
+func (o *Outbox) table() string { return o.TablePrefix + "events_outbox" }
+
+// Ready reports whether the events_outbox table can be read, i.e. whether
+// migration 0009 has been applied.
+func (o *Outbox) Ready(ctx context.Context) error {
+	var rows []struct {
+		ID int64 `db:"id"`
+	}
+	return query.New(o.DB, o.table(), o.Dialect).Select("id").Limit(1).WithContext(ctx).Get(&rows)
+}
+
+// Enqueue stores e for delivery using exec, which may be a transaction. The
+// row belongs to e.Tenant, or to the tenant of ctx when e has none.
+func (o *Outbox) Enqueue(ctx context.Context, exec execer, e Event) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	tid := e.Tenant
+	if tid == "" {
+		tid = tenant.FromContext(ctx)
+	}
+	_, err = query.New(exec, o.table(), o.Dialect).WithContext(ctx).Insert(map[string]any{
+		"tenant_id":       tid,
+		"name":            e.Name,
+		"event_id":        e.ID,
+		"payload":         string(data),
+		"status":          OutboxPending,
+		"next_attempt_at": time.Now().UTC(),
+	})
+	return err
+}
+
+// OutboxRow is a claimed outbox entry.
+type OutboxRow struct {
+	ID             int64
+	Tenant         string
+	Event          Event
+	Attempts       int
+	DeliveredSinks []string
+}
+
+// Claim leases up to limit due rows to owner until now+lease. Rows leased by
+// another owner are skipped until their lease expires, so several relays can
+// poll the same table.
+func (o *Outbox) Claim(ctx context.Context, owner string, now time.Time, lease time.Duration, limit int) ([]OutboxRow, error) {
+	now = now.UTC()
+	var due []struct {
+		ID int64 `db:"id"`
+	}
+	if err := query.New(o.DB, o.table(), o.Dialect).
+		Select("id").
+		Where("status", OutboxPending).
+		Where("next_attempt_at", "<=", now).
+		WhereGroup(func(g *query.Query) {
+			g.WhereNull("lease_until").OrWhere("lease_until", "<", now)
+		}).
+		OrderBy("id", "asc").
+		Limit(limit).
+		WithContext(ctx).
+		Get(&due); err != nil {
+		return nil, err
+	}
+	var claimed []int64
+	for _, d := range due {
+		res, err := query.New(o.DB, o.table(), o.Dialect).
+			Where("id", d.ID).
+			Where("status", OutboxPending).
+			WhereGroup(func(g *query.Query) {
+				g.WhereNull("lease_until").OrWhere("lease_until", "<", now)
+			}).
+			WithContext(ctx).
+			Update(map[string]any{"lease_owner": owner, "lease_until": now.Add(lease)})
+		if err != nil {
+			return nil, err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 1 {
+			claimed = append(claimed, d.ID)
+		}
+	}
+	if len(claimed) == 0 {
+		return nil, nil
+	}
+	var rows []struct {
+		ID             int64  `db:"id"`
+		Tenant         string `db:"tenant_id"`
+		Payload        []byte `db:"payload"`
+		Attempts       int    `db:"attempts"`
+		DeliveredSinks string `db:"delivered_sinks"`
+	}
+	if err := query.New(o.DB, o.table(), o.Dialect).
+		Select("id", "tenant_id", "payload", "attempts", "delivered_sinks").
+		WhereIn("id", claimed).
+		Where("lease_owner", owner).
+		OrderBy("id", "asc").
+		WithContext(ctx).
+		Get(&rows); err != nil {
+		return nil, err
+	}
+	out := make([]OutboxRow, 0, len(rows))
+	for _, r := range rows {
+		row := OutboxRow{ID: r.ID, Tenant: r.Tenant, Attempts: r.Attempts, DeliveredSinks: splitSinks(r.DeliveredSinks)}
+		if err := json.Unmarshal(r.Payload, &row.Event); err != nil {
+			// A row that cannot be decoded will never succeed.
+			_ = o.finish(ctx, owner, r.ID, OutboxFailed, r.Attempts, nil, err.Error(), now)
+			continue
+		}
+		out = append(out, row)
+	}
+	return out, nil
+}
+
+// finish records the outcome of a delivery attempt and releases the lease.
+func (o *Outbox) finish(ctx context.Context, owner string, id int64, status string, attempts int, delivered []string, lastErr string, now time.Time) error {
+	data := map[string]any{
+		"status":          status,
+		"attempts":        attempts,
+		"delivered_sinks": strings.Join(delivered, ","),
+		"lease_owner":     nil,
+		"lease_until":     nil,
+	}
+	if lastErr != "" {
+		data["last_error"] = lastErr
+	}
+	if status == OutboxDelivered {
+		data["delivered_at"] = now
+	}
+	_, err := query.New(o.DB, o.table(), o.Dialect).
+		Where("id", id).
+		Where("lease_owner", owner).
+		WithContext(ctx).
+		Update(data)
+	return err
+}
+
+// retry schedules another attempt at next and releases the lease.
+func (o *Outbox) retry(ctx context.Context, owner string, id int64, attempts int, delivered []string, lastErr string, next time.Time) error {
+	_, err := query.New(o.DB, o.table(), o.Dialect).
+		Where("id", id).
+		Where("lease_owner", owner).
+		WithContext(ctx).
+		Update(map[string]any{
+			"attempts":        attempts,
+			"delivered_sinks": strings.Join(delivered, ","),
+			"last_error":      lastErr,
+			"next_attempt_at": next,
+			"lease_owner":     nil,
+			"lease_until":     nil,
+		})
+	return err
+}
+
+// Prune removes rows delivered before cutoff and returns how many were
+// deleted.
+func (o *Outbox) Prune(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := query.New(o.DB, o.table(), o.Dialect).
+		Where("status", OutboxDelivered).
+		Where("delivered_at", "<", cutoff.UTC()).
+		WithContext(ctx).
+		Delete()
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func splitSinks(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// Relay delivers outbox rows to the sinks of a Dispatcher. Each row is
+// retried with exponential backoff until it reaches the dispatcher's
+// attempt limit, at which point it is stored in the DLQ and marked failed.
+// Sinks that already accepted a row are not called again on retry.
+type Relay struct {
+	Outbox     *Outbox
+	Dispatcher *Dispatcher
+	// Owner identifies this relay in lease columns. It defaults to
+	// hostname-pid.
+	Owner        string
+	PollInterval time.Duration
+	Lease        time.Duration
+	BatchSize    int
+	Retention    time.Duration
+	// Now returns the current time; tests may override it.
+	Now func() time.Time
+
+	lastPrune time.Time
+}
+
+// NewRelay creates a relay for d and o using cfg, filling in defaults.
+func NewRelay(o *Outbox, d *Dispatcher, cfg OutboxConfig) *Relay {
+	r := &Relay{
+		Outbox:       o,
+		Dispatcher:   d,
+		PollInterval: time.Second,
+		Lease:        30 * time.Second,
+		BatchSize:    50,
+		Retention:    24 * time.Hour,
+	}
+	if cfg.PollInterval > 0 {
+		r.PollInterval = cfg.PollInterval
+	}
+	if cfg.Lease > 0 {
+		r.Lease = cfg.Lease
+	}
+	if cfg.BatchSize > 0 {
+		r.BatchSize = cfg.BatchSize
+	}
+	if cfg.Retention > 0 {
+		r.Retention = cfg.Retention
+	}
+	host, _ := os.Hostname()
+	r.Owner = host + "-" + strconv.Itoa(os.Getpid())
+	return r
+}
+
+func (r *Relay) now() time.Time {
+	if r.Now != nil {
+		return r.Now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+// Run polls the outbox until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	t := time.NewTicker(r.PollInterval)
+	defer t.Stop()
+	for {
+		for {
+			n, err := r.RunOnce(ctx)
+			if err != nil {
+				logger.L.Error("outbox relay", "err", err)
+			}
+			// Keep draining while batches come back full.
+			if err != nil || n < r.BatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// RunOnce claims one batch of due rows, delivers them and returns the number
+// of rows processed.
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	now := r.now()
+	if r.Retention > 0 && now.Sub(r.lastPrune) > time.Hour {
+		r.lastPrune = now
+		if _, err := r.Outbox.Prune(ctx, now.Add(-r.Retention)); err != nil {
+			logger.L.Error("prune outbox", "err", err)
+		}
+	}
+	rows, err := r.Outbox.Claim(ctx, r.Owner, now, r.Lease, r.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for _, row := range rows {
+		if err := r.deliver(ctx, row); err != nil {
+			return 0, err
+		}
+	}
+	return len(rows), nil
+}
+
+func (r *Relay) deliver(ctx context.Context, row OutboxRow) error {
+	d := r.Dispatcher
+	ectx := tenant.WithTenant(ctx, row.Tenant)
+	delivered, failed := d.deliver(ectx, row.Event, row.DeliveredSinks)
+	attempts := row.Attempts + 1
+	now := r.now()
+	if len(failed) == 0 {
+		return r.Outbox.finish(ctx, r.Owner, row.ID, OutboxDelivered, attempts, delivered, "", now)
+	}
+	sendErr := joinSinkErrors(failed)
+	if attempts >= d.maxAttempts {
+		if d.dlq != nil {
+			for _, f := range failed {
+				if err := d.dlq.Store(ectx, row.Event, f.sink, attempts, f.err.Error()); err != nil {
+					// Keep the row pending so the event is not lost. Sinks
+					// already handed to the DLQ count as delivered.
+					return r.Outbox.retry(ctx, r.Owner, row.ID, row.Attempts, delivered, err.Error(), now.Add(d.initialDelay))
+				}
+				delivered = append(delivered, f.sink)
+			}
+		}
+		return r.Outbox.finish(ctx, r.Owner, row.ID, OutboxFailed, attempts, delivered, sendErr.Error(), now)
+	}
+	delay := d.initialDelay << (attempts - 1)
+	return r.Outbox.retry(ctx, r.Owner, row.ID, attempts, delivered, sendErr.Error(), now.Add(delay))
+}
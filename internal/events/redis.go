@@ -12,9 +12,11 @@ type RedisConfig struct {
 	Enabled bool   `yaml:"enabled"`
 	DSN     string `yaml:"dsn"`
 	Channel string `yaml:"channel"`
+	// Subscribe selects the events published to the channel.
+	Subscribe Subscription `yaml:"subscribe"`
 }
 
-// RedisSink publishes events via Redis Pub/Sub.
+// RedisSink publishes events via Redis Pub/Sub as structured CloudEvents.
 type RedisSink struct {
 	Client  *redis.Client
 	Channel string
@@ -32,11 +34,18 @@ func NewRedisSink(c RedisConfig) (*RedisSink, error) {
 	return &RedisSink{Client: redis.NewClient(opt), Channel: c.Channel}, nil
 }
 
+// Name implements NamedSink.
+func (s *RedisSink) Name() string { return "redis" }
+
 func (s *RedisSink) Emit(ctx context.Context, e Event) error {
 	if s == nil || s.Client == nil {
 		return nil
 	}
-	data, err := json.Marshal(e)
+	ce, err := e.CloudEvent()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(ce)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,220 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/faciam-dev/gcfm/internal/logger"
+	"github.com/faciam-dev/gcfm/pkg/metrics"
+	"github.com/faciam-dev/gcfm/pkg/tenant"
+)
+
+// ReplayConfig configures the automatic replay of DLQ entries.
+type ReplayConfig struct {
+	// Enabled schedules new DLQ entries for replay. Without it, entries
+	// are only replayed when scheduled or replayed through the API.
+	Enabled bool `yaml:"enabled"`
+	// Interval is how often the worker looks for due entries and refreshes
+	// the DLQ metrics.
+	Interval time.Duration `yaml:"interval"`
+	// InitialDelay is the wait before the first replay; it doubles after
+	// every failed replay up to MaxDelay.
+	InitialDelay time.Duration `yaml:"initial_delay"`
+	MaxDelay     time.Duration `yaml:"max_delay"`
+	// MaxAttempts is the number of automatic replays after which an entry
+	// stays in the DLQ until replayed by hand.
+	MaxAttempts int `yaml:"max_attempts"`
+	BatchSize   int `yaml:"batch_size"`
+}
+
+// Replayer re-sends DLQ entries to the sink that failed them. Every entry
+// backs off on its own, and once a sink fails during a pass its remaining
+// entries wait for the next pass.
+type Replayer struct {
+	DLQ          *SQLDLQ
+	Dispatcher   *Dispatcher
+	Enabled      bool
+	Interval     time.Duration
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	MaxAttempts  int
+	BatchSize    int
+	// Lease is how long a claimed entry is hidden from other workers.
+	Lease time.Duration
+	// Now returns the current time; tests may override it.
+	Now func() time.Time
+}
+
+// NewReplayer creates a replayer for the entries of q using cfg, filling in
+// defaults.
+func NewReplayer(q *SQLDLQ, d *Dispatcher, cfg ReplayConfig) *Replayer {
+	r := &Replayer{
+		DLQ:          q,
+		Dispatcher:   d,
+		Enabled:      cfg.Enabled,
+		Interval:     30 * time.Second,
+		InitialDelay: time.Minute,
+		MaxDelay:     time.Hour,
+		MaxAttempts:  10,
+		BatchSize:    100,
+		Lease:        time.Minute,
+	}
+	if cfg.Interval > 0 {
+		r.Interval = cfg.Interval
+	}
+	if cfg.InitialDelay > 0 {
+		r.InitialDelay = cfg.InitialDelay
+	}
+	if cfg.MaxDelay > 0 {
+		r.MaxDelay = cfg.MaxDelay
+	}
+	if cfg.MaxAttempts > 0 {
+		r.MaxAttempts = cfg.MaxAttempts
+	}
+	if cfg.BatchSize > 0 {
+		r.BatchSize = cfg.BatchSize
+	}
+	return r
+}
+
+func (r *Replayer) now() time.Time {
+	if r.Now != nil {
+		return r.Now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+// backoff returns the delay before the next replay after the given number
+// of failed replays.
+func (r *Replayer) backoff(failures int) time.Duration {
+	d := r.InitialDelay
+	for i := 0; i < failures && d < r.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, r.MaxDelay)
+}
+
+// Run replays due entries and refreshes the DLQ metrics until ctx is
+// cancelled.
+func (r *Replayer) Run(ctx context.Context) {
+	t := time.NewTicker(r.Interval)
+	defer t.Stop()
+	for {
+		if err := r.UpdateMetrics(ctx); err != nil {
+			logger.L.Error("dlq metrics", "err", err)
+		}
+		if _, err := r.RunOnce(ctx); err != nil {
+			logger.L.Error("dlq replay", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// RunOnce replays one batch of due entries and returns how many were
+// delivered.
+func (r *Replayer) RunOnce(ctx context.Context) (int, error) {
+	now := r.now()
+	rows, err := r.DLQ.due(ctx, now, r.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	failing := map[string]bool{}
+	replayed := 0
+	for _, f := range rows {
+		if failing[f.Sink] {
+			continue
+		}
+		ok, err := r.DLQ.claim(ctx, f.ID, now, now.Add(r.Lease), true)
+		if err != nil {
+			return replayed, err
+		}
+		if !ok {
+			continue
+		}
+		if err := r.replay(ctx, f, true); err != nil {
+			failing[f.Sink] = true
+			continue
+		}
+		replayed++
+	}
+	return replayed, nil
+}
+
+// ReplayNow replays the entry id of tenant immediately. The entry is removed
+// when the sink accepts it; otherwise the sink's error is returned and the
+// entry's backoff continues if it is scheduled or replay is enabled. It
+// returns ErrFailedEventLeased when another worker is replaying the entry.
+func (r *Replayer) ReplayNow(ctx context.Context, tid string, id int64) error {
+	f, err := r.DLQ.Get(ctx, tid, id)
+	if err != nil {
+		return err
+	}
+	now := r.now()
+	ok, err := r.DLQ.claim(ctx, f.ID, now, now.Add(r.Lease), false)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrFailedEventLeased
+	}
+	return r.replay(ctx, f, r.Enabled || f.NextReplayAt != nil)
+}
+
+// replay sends f to its sink and records the outcome. With schedule, a
+// failed entry is scheduled again unless it ran out of attempts.
+func (r *Replayer) replay(ctx context.Context, f FailedEvent, schedule bool) error {
+	sendErr := r.Dispatcher.replay(tenant.WithTenant(ctx, f.Tenant), f)
+	if sendErr == nil {
+		metrics.EventsDLQReplays.WithLabelValues(f.Sink, "delivered").Inc()
+		return r.DLQ.Delete(ctx, f.Tenant, f.ID)
+	}
+	metrics.EventsDLQReplays.WithLabelValues(f.Sink, "failed").Inc()
+	attempts := f.ReplayAttempts + 1
+	var next *time.Time
+	if schedule && attempts < r.MaxAttempts {
+		t := r.now().Add(r.backoff(attempts))
+		next = &t
+	}
+	if err := r.DLQ.replayFailed(ctx, f.ID, attempts, sendErr.Error(), next); err != nil {
+		logger.L.Error("record dlq replay", "id", f.ID, "err", err)
+	}
+	return sendErr
+}
+
+// UpdateMetrics refreshes the DLQ depth and age gauges.
+func (r *Replayer) UpdateMetrics(ctx context.Context) error {
+	stats, err := r.DLQ.Stats(ctx)
+	if err != nil {
+		return err
+	}
+	now := r.now()
+	metrics.EventsDLQDepth.Reset()
+	metrics.EventsDLQOldestAge.Reset()
+	for _, s := range stats {
+		metrics.EventsDLQDepth.WithLabelValues(s.Sink).Set(float64(s.Depth))
+		metrics.EventsDLQOldestAge.WithLabelValues(s.Sink).Set(now.Sub(s.Oldest).Seconds())
+	}
+	return nil
+}
+
+// replay sends the event of f to the sink that failed it, or to every sink
+// for entries without one.
+func (d *Dispatcher) replay(ctx context.Context, f FailedEvent) error {
+	if f.Event.Name == "" {
+		return fmt.Errorf("entry %d has no decodable event", f.ID)
+	}
+	if f.Sink == "" {
+		_, failed := d.deliver(ctx, f.Event, nil)
+		return joinSinkErrors(failed)
+	}
+	s, ok := d.sink(f.Sink)
+	if !ok {
+		return fmt.Errorf("sink %q is not configured", f.Sink)
+	}
+	return s.Emit(ctx, f.Event)
+}
@@ -0,0 +1,55 @@
+package events
+
+import (
+	"embed"
+	"sort"
+)
+
+// schemaFS holds the JSON Schemas of the event data, one file per schema.
+// It is the only copy in the tree: `make event-schemas` and the Pages
+// workflow publish these files under docs/schemas/events/v1.
+//
+//go:embed schemas/v1/*.json
+var schemaFS embed.FS
+
+// schemaFiles lists the event types with a schema of their own.
+var schemaFiles = map[string]bool{
+	TypeFieldCreated: true,
+	TypeFieldUpdated: true,
+	TypeFieldDeleted: true,
+	TypeScan:         true,
+}
+
+// Schema returns the JSON Schema with the given name, as returned by
+// SchemaName.
+func Schema(name string) ([]byte, bool) {
+	data, err := schemaFS.ReadFile("schemas/v1/" + name + ".json")
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// SchemaNames lists the names of all published schemas.
+func SchemaNames() []string {
+	entries, _ := schemaFS.ReadDir("schemas/v1")
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name()[:len(e.Name())-len(".json")])
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SchemaURL returns the dataschema URI of events of type t below base, or ""
+// when no schema describes t.
+func SchemaURL(base, t string) string {
+	name, ok := SchemaName(t)
+	if !ok {
+		return ""
+	}
+	if base == "" {
+		base = DefaultSchemaBaseURL
+	}
+	return base + "/" + name + ".json"
+}
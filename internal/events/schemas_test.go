@@ -0,0 +1,97 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+
+	"github.com/faciam-dev/gcfm/pkg/registry"
+)
+
+// compileSchema compiles the named event schema with a full JSON Schema
+// 2020-12 validator.
+func compileSchema(t *testing.T, name string) *jsonschema.Schema {
+	t.Helper()
+	raw, ok := Schema(name)
+	if !ok {
+		t.Fatalf("%s: no schema", name)
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	c := jsonschema.NewCompiler()
+	url := SchemaURL("", name)
+	if err := c.AddResource(url, doc); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	sch, err := c.Compile(url)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return sch
+}
+
+func validateData(sch *jsonschema.Schema, data []byte) error {
+	inst, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	return sch.Validate(inst)
+}
+
+func TestSchemasCompile(t *testing.T) {
+	for _, name := range SchemaNames() {
+		compileSchema(t, name)
+	}
+}
+
+func TestSchemasValidateEventData(t *testing.T) {
+	meta := &registry.FieldMeta{DBID: 1, TableName: "posts", ColumnName: "title", DataType: "varchar(255)"}
+	changed := *meta
+	changed.DataType = "text"
+	samples := []Event{
+		FieldEvent(TypeFieldCreated, nil, meta),
+		FieldEvent(TypeFieldUpdated, meta, &changed),
+		FieldEvent(TypeFieldDeleted, meta, nil),
+		{Name: TypeScan, Data: ScanCompleted{DBID: 1, Tables: 2, Total: 3, Inserted: 2, Updated: 1}},
+		{Name: TypeAuditPrefix + "update", Data: AuditRecord{ID: 9, Tenant: "t1", Actor: "1", Action: "update", Before: json.RawMessage(`{"a":1}`)}},
+	}
+	for _, e := range samples {
+		name, ok := SchemaName(e.Name)
+		if !ok {
+			t.Fatalf("%s: no schema", e.Name)
+		}
+		sch := compileSchema(t, name)
+		data, err := json.Marshal(e.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := validateData(sch, data); err != nil {
+			t.Errorf("%s: %v\n%s", e.Name, err, data)
+		}
+	}
+}
+
+func TestSchemasRejectWrongShape(t *testing.T) {
+	sch := compileSchema(t, TypeFieldDeleted)
+	if err := validateData(sch, []byte(`{"table":"posts","column":"title"}`)); err == nil {
+		t.Fatal("expected the pre-CloudEvents payload to fail validation")
+	}
+	// The field metadata is only checked through $ref.
+	bad := []byte(`{"dbId":1,"table":"posts","column":"title","before":{"dbId":"x"},"after":null}`)
+	if err := validateData(sch, bad); err == nil {
+		t.Fatal("expected an invalid before object to fail validation")
+	}
+}
+
+func TestSchemaURL(t *testing.T) {
+	if got := SchemaURL("", "cf.audit.login"); got != DefaultSchemaBaseURL+"/cf.audit.json" {
+		t.Fatalf("SchemaURL = %q", got)
+	}
+	if got := SchemaURL("https://example.com/s", "cf.unknown"); got != "" {
+		t.Fatalf("SchemaURL = %q, want empty", got)
+	}
+}
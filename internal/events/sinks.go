@@ -0,0 +1,38 @@
+package events
+
+import "github.com/faciam-dev/gcfm/internal/logger"
+
+// NewSinks creates the sinks enabled in cfg, each restricted to its
+// subscription. Sinks that fail to connect are logged and left out; an
+// invalid subscription is an error.
+func NewSinks(cfg Config) ([]Sink, error) {
+	var sinks []Sink
+	add := func(s Sink, sub Subscription) error {
+		s, err := Subscribe(s, sub)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, s)
+		return nil
+	}
+	if wh := NewWebhookSink(cfg.Sinks.Webhook); wh != nil {
+		if err := add(wh, cfg.Sinks.Webhook.Subscribe); err != nil {
+			return nil, err
+		}
+	}
+	if rs, err := NewRedisSink(cfg.Sinks.Redis); err == nil && rs != nil {
+		if err := add(rs, cfg.Sinks.Redis.Subscribe); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		logger.L.Error("redis sink", "err", err)
+	}
+	if ks, err := NewKafkaSink(cfg.Sinks.Kafka); err == nil && ks != nil {
+		if err := add(ks, cfg.Sinks.Kafka.Subscribe); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		logger.L.Error("kafka sink", "err", err)
+	}
+	return sinks, nil
+}
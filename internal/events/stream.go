@@ -0,0 +1,87 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/faciam-dev/goquent/orm/query"
+)
+
+// StreamConfig configures GET /v1/events/stream.
+type StreamConfig struct {
+	// PollInterval is how often an open stream looks for new outbox rows.
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// Keepalive is the interval of SSE comments sent on idle streams.
+	Keepalive time.Duration `yaml:"keepalive"`
+	// BatchSize is the number of rows read per poll.
+	BatchSize int `yaml:"batch_size"`
+	// Window is how long an open stream keeps re-reading rows behind its
+	// newest event. Outbox ids are assigned when a row is inserted but
+	// become visible when its transaction commits, so a lower id can appear
+	// after a higher one; it is still sent if it commits within Window.
+	Window time.Duration `yaml:"window"`
+}
+
+// StreamEvent is an event read back from the outbox. ID is the outbox row id;
+// it increases with every event of a tenant and resumes a stream.
+type StreamEvent struct {
+	ID    int64
+	Event Event
+}
+
+// Since returns up to limit events of tenant stored after the row afterID,
+// oldest first. Rows are returned whatever their delivery state, until the
+// relay prunes them after the outbox retention.
+func (o *Outbox) Since(ctx context.Context, tenant string, afterID int64, limit int) ([]StreamEvent, error) {
+	var rows []struct {
+		ID      int64  `db:"id"`
+		Payload []byte `db:"payload"`
+	}
+	if err := query.New(o.DB, o.table(), o.Dialect).
+		Select("id", "payload").
+		Where("tenant_id", tenant).
+		Where("id", ">", afterID).
+		OrderBy("id", "asc").
+		Limit(limit).
+		WithContext(ctx).
+		Get(&rows); err != nil {
+		return nil, err
+	}
+	out := make([]StreamEvent, 0, len(rows))
+	for _, r := range rows {
+		se := StreamEvent{ID: r.ID}
+		// A payload that does not decode keeps a zero Event so that
+		// readers still move past the row.
+		_ = json.Unmarshal(r.Payload, &se.Event)
+		out = append(out, se)
+	}
+	return out, nil
+}
+
+// LastID returns the id of the newest outbox row of tenant, or 0 when it has
+// none.
+func (o *Outbox) LastID(ctx context.Context, tenant string) (int64, error) {
+	var rows []struct {
+		ID int64 `db:"id"`
+	}
+	if err := query.New(o.DB, o.table(), o.Dialect).
+		Select("id").
+		Where("tenant_id", tenant).
+		OrderBy("id", "desc").
+		Limit(1).
+		WithContext(ctx).
+		Get(&rows); err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	return rows[0].ID, nil
+}
+
+// Outbox returns the outbox events are stored in, or nil when they are
+// dispatched directly.
+func (d *Dispatcher) Outbox() *Outbox {
+	return d.outbox
+}
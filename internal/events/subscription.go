@@ -0,0 +1,189 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/faciam-dev/gcfm/internal/logger"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker"
+)
+
+// Subscription selects the events a sink receives. Empty fields match
+// everything, so a zero Subscription subscribes to all events.
+type Subscription struct {
+	// Events are glob patterns on the event type, e.g. "cf.field.*".
+	Events []string `yaml:"events" json:"events,omitempty"`
+	// Tenants restricts delivery to events of the listed tenants.
+	Tenants []string `yaml:"tenants" json:"tenants,omitempty"`
+	// Filter is a CEL expression evaluated against the event. It sees the
+	// CloudEvents attributes as the string map event (event.type,
+	// event.subject, event.tenant, ...) and the decoded data as data, e.g.
+	// `event.subject.startsWith("posts.") && data.after.DataType == "text"`.
+	Filter string `yaml:"filter" json:"filter,omitempty"`
+}
+
+// Matcher is a compiled Subscription.
+type Matcher struct {
+	events  []string
+	tenants []string
+	prg     cel.Program
+}
+
+const (
+	// filterCostLimit bounds both the estimated cost of a filter, checked
+	// when it is compiled, and the actual cost of each evaluation.
+	filterCostLimit = 1_000_000
+	// filterMaxSize is the size assumed for strings, lists and maps of the
+	// event when estimating the cost of a filter.
+	filterMaxSize = 1000
+	// filterTimeout bounds the evaluation of a filter; comprehensions
+	// check for it every filterInterruptFrequency iterations.
+	filterTimeout            = 100 * time.Millisecond
+	filterInterruptFrequency = 100
+)
+
+// filterSizes estimates every value of unknown size at up to filterMaxSize.
+type filterSizes struct{}
+
+func (filterSizes) EstimateSize(checker.AstNode) *checker.SizeEstimate {
+	return &checker.SizeEstimate{Min: 0, Max: filterMaxSize}
+}
+
+func (filterSizes) EstimateCallCost(string, string, *checker.AstNode, []checker.AstNode) *checker.CallEstimate {
+	return nil
+}
+
+var (
+	celEnvOnce sync.Once
+	celEnv     *cel.Env
+	celEnvErr  error
+)
+
+func filterEnv() (*cel.Env, error) {
+	celEnvOnce.Do(func() {
+		celEnv, celEnvErr = cel.NewEnv(
+			cel.Variable("event", cel.MapType(cel.StringType, cel.StringType)),
+			cel.Variable("data", cel.DynType),
+			// Data is decoded from JSON, so numbers are doubles.
+			cel.CrossTypeNumericComparisons(true),
+		)
+	})
+	return celEnv, celEnvErr
+}
+
+// Compile validates s and prepares it for matching.
+func (s Subscription) Compile() (*Matcher, error) {
+	for _, p := range s.Events {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("event pattern %q: %w", p, err)
+		}
+	}
+	m := &Matcher{events: s.Events, tenants: s.Tenants}
+	if s.Filter == "" {
+		return m, nil
+	}
+	env, err := filterEnv()
+	if err != nil {
+		return nil, err
+	}
+	ast, iss := env.Compile(s.Filter)
+	if iss.Err() != nil {
+		return nil, fmt.Errorf("filter: %w", iss.Err())
+	}
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, fmt.Errorf("filter: must evaluate to bool, got %s", ast.OutputType())
+	}
+	est, err := env.EstimateCost(ast, filterSizes{})
+	if err != nil {
+		return nil, fmt.Errorf("filter: %w", err)
+	}
+	if est.Max > filterCostLimit {
+		return nil, fmt.Errorf("filter: estimated cost %d exceeds the limit of %d", est.Max, filterCostLimit)
+	}
+	m.prg, err = env.Program(ast,
+		cel.CostLimit(filterCostLimit),
+		cel.InterruptCheckFrequency(filterInterruptFrequency),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("filter: %w", err)
+	}
+	return m, nil
+}
+
+// Match reports whether e is selected. A filter that fails to evaluate,
+// e.g. because it reads a field the event data lacks, does not match.
+func (m *Matcher) Match(e Event) bool {
+	if m == nil {
+		return true
+	}
+	if len(m.events) > 0 && !slices.ContainsFunc(m.events, func(p string) bool {
+		ok, _ := path.Match(p, e.Name)
+		return ok
+	}) {
+		return false
+	}
+	if len(m.tenants) > 0 && !slices.Contains(m.tenants, e.Tenant) {
+		return false
+	}
+	if m.prg == nil {
+		return true
+	}
+	var data any
+	if raw, err := json.Marshal(e.Data); err == nil {
+		_ = json.Unmarshal(raw, &data)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), filterTimeout)
+	defer cancel()
+	out, _, err := m.prg.ContextEval(ctx, map[string]any{
+		"event": map[string]string{
+			"id":      e.ID,
+			"type":    e.Name,
+			"source":  e.Source,
+			"subject": e.Subject,
+			"tenant":  e.Tenant,
+		},
+		"data": data,
+	})
+	if err != nil {
+		logger.L.Debug("event filter", "name", e.Name, "err", err)
+		return false
+	}
+	ok, _ := out.Value().(bool)
+	return ok
+}
+
+// subscribedSink delivers only the events its matcher selects. Skipped
+// events count as delivered.
+type subscribedSink struct {
+	Sink
+	m *Matcher
+}
+
+// Subscribe restricts s to the events selected by sub. s is returned as is
+// when sub is empty.
+func Subscribe(s Sink, sub Subscription) (Sink, error) {
+	if len(sub.Events) == 0 && len(sub.Tenants) == 0 && sub.Filter == "" {
+		return s, nil
+	}
+	m, err := sub.Compile()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", SinkName(s), err)
+	}
+	return subscribedSink{Sink: s, m: m}, nil
+}
+
+// Name implements NamedSink.
+func (s subscribedSink) Name() string { return SinkName(s.Sink) }
+
+func (s subscribedSink) Emit(ctx context.Context, e Event) error {
+	if !s.m.Match(e) {
+		return nil
+	}
+	return s.Sink.Emit(ctx, e)
+}
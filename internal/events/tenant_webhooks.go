@@ -0,0 +1,521 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/faciam-dev/gcfm/internal/logger"
+	ccrypto "github.com/faciam-dev/gcfm/pkg/crypto"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+	"github.com/faciam-dev/goquent/orm/query"
+)
+
+// Delivery outcomes recorded in the webhook delivery log.
+const (
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// ErrWebhookNotFound is returned when a tenant webhook does not exist.
+var ErrWebhookNotFound = errors.New("events: webhook not found")
+
+// TenantWebhookConfig configures the delivery to tenant webhooks.
+type TenantWebhookConfig struct {
+	// AllowPrivateNetworks lets webhooks reach loopback, link-local and
+	// private addresses, e.g. in development. It is off by default so that
+	// tenants cannot reach internal services or cloud metadata endpoints.
+	AllowPrivateNetworks bool `yaml:"allow_private_networks"`
+	// CacheTTL is how long the webhooks of a tenant are cached between
+	// deliveries; it defaults to 10s. Changes take effect after it expires.
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+	// DeliveryRetention is how long delivery log entries are kept; it
+	// defaults to 7 days.
+	DeliveryRetention time.Duration `yaml:"delivery_retention"`
+}
+
+// TenantWebhook is an endpoint registered by a tenant at runtime. It
+// receives the tenant's own events selected by Events and Filter.
+type TenantWebhook struct {
+	ID     int64
+	Tenant string
+	URL    string
+	// Secret signs deliveries like WebhookConfig.Secret.
+	Secret    string
+	Events    []string
+	Filter    string
+	Mode      string
+	Enabled   bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Subscription returns the events w subscribes to.
+func (w TenantWebhook) Subscription() Subscription {
+	return Subscription{Events: w.Events, Filter: w.Filter}
+}
+
+// WebhookDelivery is an entry of the delivery log of a tenant webhook.
+type WebhookDelivery struct {
+	ID           int64
+	WebhookID    int64
+	EventID      string
+	EventType    string
+	Status       string
+	ResponseCode int
+	Error        string
+	Duration     time.Duration
+	CreatedAt    time.Time
+}
+
+// NewWebhookSecret returns a random signing secret.
+func NewWebhookSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// secretPrefix marks secrets encrypted with CF_ENC_KEY. Rows without it were
+// written before secrets were encrypted and are read as they are.
+const secretPrefix = "enc:"
+
+func sealSecret(secret string) (string, error) {
+	enc, err := ccrypto.Encrypt([]byte(secret))
+	if err != nil {
+		return "", err
+	}
+	return secretPrefix + base64.StdEncoding.EncodeToString(enc), nil
+}
+
+func openSecret(stored string) (string, error) {
+	if !strings.HasPrefix(stored, secretPrefix) {
+		return stored, nil
+	}
+	enc, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, secretPrefix))
+	if err != nil {
+		return "", err
+	}
+	plain, err := ccrypto.Decrypt(enc)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// WebhookStore persists tenant webhooks and their delivery log. Secrets are
+// encrypted with the key in CF_ENC_KEY.
+type WebhookStore struct {
+	DB          *sql.DB
+	Dialect     ormdriver.Dialect
+	TablePrefix string
+}
+
+func (s *WebhookStore) hooks() *query.Query {
+	return query.New(s.DB, s.TablePrefix+"event_webhooks", s.Dialect)
+}
+
+func (s *WebhookStore) log() *query.Query {
+	return query.New(s.DB, s.TablePrefix+"event_webhook_deliveries", s.Dialect)
+}
+
+type webhookRow struct {
+	ID        int64          `db:"id"`
+	Tenant    string         `db:"tenant_id"`
+	URL       string         `db:"url"`
+	Secret    string         `db:"secret"`
+	Events    string         `db:"event_types"`
+	Filter    sql.NullString `db:"filter_expr"`
+	Mode      string         `db:"mode"`
+	Enabled   bool           `db:"enabled"`
+	CreatedAt time.Time      `db:"created_at"`
+	UpdatedAt time.Time      `db:"updated_at"`
+}
+
+var webhookColumns = []string{"id", "tenant_id", "url", "secret", "event_types", "filter_expr", "mode", "enabled", "created_at", "updated_at"}
+
+func (r webhookRow) webhook() (TenantWebhook, error) {
+	secret, err := openSecret(r.Secret)
+	if err != nil {
+		return TenantWebhook{}, fmt.Errorf("webhook %d secret: %w", r.ID, err)
+	}
+	w := TenantWebhook{
+		ID:        r.ID,
+		Tenant:    r.Tenant,
+		URL:       r.URL,
+		Secret:    secret,
+		Filter:    r.Filter.String,
+		Mode:      r.Mode,
+		Enabled:   r.Enabled,
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: r.UpdatedAt,
+	}
+	if r.Events != "" {
+		w.Events = strings.Split(r.Events, ",")
+	}
+	return w, nil
+}
+
+func webhookData(w TenantWebhook) map[string]any {
+	mode := w.Mode
+	if mode == "" {
+		mode = ModeStructured
+	}
+	return map[string]any{
+		"url":         w.URL,
+		"event_types": strings.Join(w.Events, ","),
+		"filter_expr": w.Filter,
+		"mode":        mode,
+		"enabled":     w.Enabled,
+	}
+}
+
+// List returns the webhooks of tenant. With enabledOnly, disabled webhooks
+// are left out.
+func (s *WebhookStore) List(ctx context.Context, tenant string, enabledOnly bool) ([]TenantWebhook, error) {
+	q := s.hooks().Select(webhookColumns...).Where("tenant_id", tenant)
+	if enabledOnly {
+		q = q.Where("enabled", true)
+	}
+	var rows []webhookRow
+	if err := q.OrderBy("id", "asc").WithContext(ctx).Get(&rows); err != nil {
+		return nil, err
+	}
+	out := make([]TenantWebhook, len(rows))
+	for i, r := range rows {
+		w, err := r.webhook()
+		if err != nil {
+			return nil, err
+		}
+		out[i] = w
+	}
+	return out, nil
+}
+
+// Get returns the webhook id of tenant.
+func (s *WebhookStore) Get(ctx context.Context, tenant string, id int64) (TenantWebhook, error) {
+	var rows []webhookRow
+	if err := s.hooks().Select(webhookColumns...).
+		Where("tenant_id", tenant).
+		Where("id", id).
+		WithContext(ctx).
+		Get(&rows); err != nil {
+		return TenantWebhook{}, err
+	}
+	if len(rows) == 0 {
+		return TenantWebhook{}, ErrWebhookNotFound
+	}
+	return rows[0].webhook()
+}
+
+// Create stores w and returns it as stored. A secret is generated when w
+// has none.
+func (s *WebhookStore) Create(ctx context.Context, w TenantWebhook) (TenantWebhook, error) {
+	if w.Secret == "" {
+		secret, err := NewWebhookSecret()
+		if err != nil {
+			return TenantWebhook{}, err
+		}
+		w.Secret = secret
+	}
+	sealed, err := sealSecret(w.Secret)
+	if err != nil {
+		return TenantWebhook{}, err
+	}
+	data := webhookData(w)
+	data["tenant_id"] = w.Tenant
+	data["secret"] = sealed
+	id, err := s.hooks().WithContext(ctx).InsertGetId(data)
+	if err != nil {
+		return TenantWebhook{}, err
+	}
+	return s.Get(ctx, w.Tenant, id)
+}
+
+// Update replaces the endpoint, subscription and state of w. The secret is
+// kept; see RotateSecret.
+func (s *WebhookStore) Update(ctx context.Context, w TenantWebhook) (TenantWebhook, error) {
+	if _, err := s.Get(ctx, w.Tenant, w.ID); err != nil {
+		return TenantWebhook{}, err
+	}
+	data := webhookData(w)
+	data["updated_at"] = time.Now().UTC()
+	if _, err := s.hooks().Where("tenant_id", w.Tenant).Where("id", w.ID).WithContext(ctx).Update(data); err != nil {
+		return TenantWebhook{}, err
+	}
+	return s.Get(ctx, w.Tenant, w.ID)
+}
+
+// RotateSecret replaces the signing secret of the webhook and returns it.
+func (s *WebhookStore) RotateSecret(ctx context.Context, tenant string, id int64) (TenantWebhook, error) {
+	w, err := s.Get(ctx, tenant, id)
+	if err != nil {
+		return TenantWebhook{}, err
+	}
+	if w.Secret, err = NewWebhookSecret(); err != nil {
+		return TenantWebhook{}, err
+	}
+	sealed, err := sealSecret(w.Secret)
+	if err != nil {
+		return TenantWebhook{}, err
+	}
+	if _, err := s.hooks().Where("tenant_id", tenant).Where("id", id).WithContext(ctx).
+		Update(map[string]any{"secret": sealed, "updated_at": time.Now().UTC()}); err != nil {
+		return TenantWebhook{}, err
+	}
+	return w, nil
+}
+
+// Delete removes the webhook and its delivery log.
+func (s *WebhookStore) Delete(ctx context.Context, tenant string, id int64) error {
+	res, err := s.hooks().Where("tenant_id", tenant).Where("id", id).WithContext(ctx).Delete()
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrWebhookNotFound
+	}
+	_, err = s.log().Where("webhook_id", id).WithContext(ctx).Delete()
+	return err
+}
+
+// RecordDelivery appends d to the delivery log.
+func (s *WebhookStore) RecordDelivery(ctx context.Context, tenant string, d WebhookDelivery) error {
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = time.Now().UTC()
+	}
+	_, err := s.log().WithContext(ctx).Insert(map[string]any{
+		"webhook_id":    d.WebhookID,
+		"tenant_id":     tenant,
+		"event_id":      d.EventID,
+		"event_type":    d.EventType,
+		"status":        d.Status,
+		"response_code": d.ResponseCode,
+		"error":         d.Error,
+		"duration_ms":   d.Duration.Milliseconds(),
+		"created_at":    d.CreatedAt,
+	})
+	return err
+}
+
+// PruneDeliveries removes delivery log entries older than cutoff and returns
+// how many were removed.
+func (s *WebhookStore) PruneDeliveries(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := s.log().Where("created_at", "<", cutoff).WithContext(ctx).Delete()
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Deliveries returns the latest deliveries of the webhook id of tenant,
+// newest first.
+func (s *WebhookStore) Deliveries(ctx context.Context, tenant string, id int64, limit int) ([]WebhookDelivery, error) {
+	var rows []struct {
+		ID           int64          `db:"id"`
+		WebhookID    int64          `db:"webhook_id"`
+		EventID      string         `db:"event_id"`
+		EventType    string         `db:"event_type"`
+		Status       string         `db:"status"`
+		ResponseCode int            `db:"response_code"`
+		Error        sql.NullString `db:"error"`
+		DurationMS   int64          `db:"duration_ms"`
+		CreatedAt    time.Time      `db:"created_at"`
+	}
+	if err := s.log().
+		Select("id", "webhook_id", "event_id", "event_type", "status", "response_code", "error", "duration_ms", "created_at").
+		Where("tenant_id", tenant).
+		Where("webhook_id", id).
+		OrderBy("id", "desc").
+		Limit(limit).
+		WithContext(ctx).
+		Get(&rows); err != nil {
+		return nil, err
+	}
+	out := make([]WebhookDelivery, len(rows))
+	for i, r := range rows {
+		out[i] = WebhookDelivery{
+			ID:           r.ID,
+			WebhookID:    r.WebhookID,
+			EventID:      r.EventID,
+			EventType:    r.EventType,
+			Status:       r.Status,
+			ResponseCode: r.ResponseCode,
+			Error:        r.Error.String,
+			Duration:     time.Duration(r.DurationMS) * time.Millisecond,
+			CreatedAt:    r.CreatedAt,
+		}
+	}
+	return out, nil
+}
+
+// delivered reports whether the event was already delivered to the webhook.
+func (s *WebhookStore) delivered(ctx context.Context, id int64, eventID string) (bool, error) {
+	n, err := s.log().
+		Where("webhook_id", id).
+		Where("event_id", eventID).
+		Where("status", DeliverySucceeded).
+		WithContext(ctx).
+		Count()
+	return n > 0, err
+}
+
+// TenantWebhookSink delivers every event to the enabled webhooks its tenant
+// registered, logging each attempt. When one endpoint fails the sink fails,
+// and a retry skips the endpoints that already succeeded.
+type TenantWebhookSink struct {
+	Store  *WebhookStore
+	Client *http.Client
+	// CacheTTL is how long the webhooks of a tenant are reused before they
+	// are listed again.
+	CacheTTL time.Duration
+	// Retention is how long delivery log entries are kept; zero keeps them.
+	Retention time.Duration
+
+	mu        sync.Mutex
+	tenants   map[string]*tenantHooks
+	lastPrune time.Time
+}
+
+// tenantHooks caches the enabled webhooks of a tenant with their compiled
+// subscriptions. Entries expire after CacheTTL and are swept on refresh, so
+// matchers of deleted or changed webhooks do not accumulate.
+type tenantHooks struct {
+	hooks    []TenantWebhook
+	matchers []*Matcher
+	loaded   time.Time
+}
+
+// NewTenantWebhookSink returns a sink for the webhooks in store. Unless
+// cfg.AllowPrivateNetworks is set, deliveries to internal addresses are
+// refused.
+func NewTenantWebhookSink(store *WebhookStore, cfg TenantWebhookConfig) *TenantWebhookSink {
+	client := guardedClient(5 * time.Second)
+	if cfg.AllowPrivateNetworks {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	s := &TenantWebhookSink{
+		Store:     store,
+		Client:    client,
+		CacheTTL:  10 * time.Second,
+		Retention: 7 * 24 * time.Hour,
+		lastPrune: time.Now(),
+	}
+	if cfg.CacheTTL > 0 {
+		s.CacheTTL = cfg.CacheTTL
+	}
+	if cfg.DeliveryRetention > 0 {
+		s.Retention = cfg.DeliveryRetention
+	}
+	return s
+}
+
+// Name implements NamedSink.
+func (s *TenantWebhookSink) Name() string { return "tenant-webhooks" }
+
+// hooks returns the enabled webhooks of tenant and their matchers, listing
+// them from the store when the cached entry has expired. Webhooks whose
+// filter does not compile have a nil matcher.
+func (s *TenantWebhookSink) hooks(ctx context.Context, tenant string) (*tenantHooks, error) {
+	now := time.Now()
+	s.mu.Lock()
+	if th, ok := s.tenants[tenant]; ok && now.Sub(th.loaded) < s.CacheTTL {
+		s.mu.Unlock()
+		return th, nil
+	}
+	s.mu.Unlock()
+
+	hooks, err := s.Store.List(ctx, tenant, true)
+	if err != nil {
+		return nil, err
+	}
+	th := &tenantHooks{hooks: hooks, matchers: make([]*Matcher, len(hooks)), loaded: now}
+	for i, w := range hooks {
+		m, err := w.Subscription().Compile()
+		if err != nil {
+			// Filters are validated on registration; skip rather than
+			// retry an event that can never match.
+			logger.L.Warn("tenant webhook filter", "id", w.ID, "err", err)
+			continue
+		}
+		th.matchers[i] = m
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tenants == nil {
+		s.tenants = map[string]*tenantHooks{}
+	}
+	for t, old := range s.tenants {
+		if now.Sub(old.loaded) >= s.CacheTTL {
+			delete(s.tenants, t)
+		}
+	}
+	s.tenants[tenant] = th
+	return th, nil
+}
+
+// prune removes expired delivery log entries at most once an hour.
+func (s *TenantWebhookSink) prune(ctx context.Context) {
+	if s.Retention <= 0 {
+		return
+	}
+	now := time.Now()
+	s.mu.Lock()
+	due := now.Sub(s.lastPrune) > time.Hour
+	if due {
+		s.lastPrune = now
+	}
+	s.mu.Unlock()
+	if !due {
+		return
+	}
+	if _, err := s.Store.PruneDeliveries(ctx, now.UTC().Add(-s.Retention)); err != nil {
+		logger.L.Error("prune webhook deliveries", "err", err)
+	}
+}
+
+func (s *TenantWebhookSink) Emit(ctx context.Context, e Event) error {
+	if s == nil || s.Store == nil || e.Tenant == "" {
+		return nil
+	}
+	s.prune(ctx)
+	th, err := s.hooks(ctx, e.Tenant)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i, w := range th.hooks {
+		if m := th.matchers[i]; m == nil || !m.Match(e) {
+			continue
+		}
+		if done, err := s.Store.delivered(ctx, w.ID, e.ID); err != nil {
+			errs = append(errs, err)
+			continue
+		} else if done {
+			continue
+		}
+		wh := &WebhookSink{Endpoint: w.URL, Secret: w.Secret, Client: s.Client, Binary: w.Mode == ModeBinary}
+		start := time.Now()
+		code, err := wh.post(ctx, e)
+		d := WebhookDelivery{WebhookID: w.ID, EventID: e.ID, EventType: e.Name, Status: DeliverySucceeded, ResponseCode: code, Duration: time.Since(start)}
+		if err != nil {
+			d.Status = DeliveryFailed
+			d.Error = err.Error()
+			errs = append(errs, fmt.Errorf("webhook %d: %w", w.ID, err))
+		}
+		if lerr := s.Store.RecordDelivery(ctx, e.Tenant, d); lerr != nil {
+			logger.L.Error("record webhook delivery", "id", w.ID, "err", lerr)
+		}
+	}
+	return errors.Join(errs...)
+}
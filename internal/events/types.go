@@ -0,0 +1,68 @@
+package events
+
+import (
+	"encoding/json"
+
+	"github.com/faciam-dev/gcfm/pkg/registry"
+)
+
+// Event types emitted by gcfm.
+const (
+	TypeFieldCreated = "cf.field.created"
+	TypeFieldUpdated = "cf.field.updated"
+	TypeFieldDeleted = "cf.field.deleted"
+	TypeScan         = "cf.scan"
+	// TypeAuditPrefix prefixes the cf.audit.<action> events forwarded from
+	// the audit log.
+	TypeAuditPrefix = "cf.audit."
+)
+
+// FieldChange is the data of the cf.field.* events. Before is nil for
+// created fields and After is nil for deleted ones.
+type FieldChange struct {
+	DBID   int64               `json:"dbId"`
+	Table  string              `json:"table"`
+	Column string              `json:"column"`
+	Before *registry.FieldMeta `json:"before"`
+	After  *registry.FieldMeta `json:"after"`
+}
+
+// FieldEvent builds a cf.field.* event for a change from before to after.
+func FieldEvent(typ string, before, after *registry.FieldMeta) Event {
+	m := after
+	if m == nil {
+		m = before
+	}
+	return Event{
+		Name:    typ,
+		Subject: m.TableName + "." + m.ColumnName,
+		Data:    FieldChange{DBID: m.DBID, Table: m.TableName, Column: m.ColumnName, Before: before, After: after},
+	}
+}
+
+// ScanCompleted is the data of cf.scan events.
+type ScanCompleted struct {
+	DBID     int64 `json:"dbId"`
+	Tables   int   `json:"tables"`
+	Total    int   `json:"total"`
+	Inserted int   `json:"inserted"`
+	Updated  int   `json:"updated"`
+	Skipped  int   `json:"skipped"`
+}
+
+// AuditRecord is the data of cf.audit.<action> events.
+type AuditRecord struct {
+	ID           int64           `json:"id"`
+	Tenant       string          `json:"tenant"`
+	Actor        string          `json:"actor"`
+	Action       string          `json:"action"`
+	TableName    string          `json:"tableName,omitempty"`
+	ColumnName   string          `json:"columnName,omitempty"`
+	ResourceType string          `json:"resourceType,omitempty"`
+	ResourceID   string          `json:"resourceId,omitempty"`
+	RequestID    string          `json:"requestId,omitempty"`
+	RevertOf     int64           `json:"revertOf,omitempty"`
+	Before       json.RawMessage `json:"before"`
+	After        json.RawMessage `json:"after"`
+	ChangeCount  int             `json:"changeCount"`
+}
@@ -18,13 +18,26 @@ type WebhookConfig struct {
 	Endpoint string        `yaml:"endpoint"`
 	Secret   string        `yaml:"secret"`
 	Timeout  time.Duration `yaml:"timeout"`
+	// Mode selects the CloudEvents HTTP binding: "structured" (default)
+	// posts the whole envelope, "binary" posts the data with ce-* headers.
+	Mode string `yaml:"mode"`
+	// Subscribe selects the events sent to the endpoint.
+	Subscribe Subscription `yaml:"subscribe"`
 }
 
+// Webhook content modes.
+const (
+	ModeStructured = "structured"
+	ModeBinary     = "binary"
+)
+
 // WebhookSink posts events to an HTTP endpoint.
 type WebhookSink struct {
 	Endpoint string
 	Secret   string
 	Client   *http.Client
+	// Binary selects the binary CloudEvents binding.
+	Binary bool
 }
 
 // NewWebhookSink creates a WebhookSink from config.
@@ -36,22 +49,42 @@ func NewWebhookSink(c WebhookConfig) *WebhookSink {
 	if c.Timeout == 0 {
 		cli.Timeout = 5 * time.Second
 	}
-	return &WebhookSink{Endpoint: c.Endpoint, Secret: c.Secret, Client: cli}
+	return &WebhookSink{Endpoint: c.Endpoint, Secret: c.Secret, Client: cli, Binary: c.Mode == ModeBinary}
 }
 
+// Name implements NamedSink.
+func (s *WebhookSink) Name() string { return "webhook" }
+
 func (s *WebhookSink) Emit(ctx context.Context, e Event) error {
 	if s == nil {
 		return nil
 	}
-	data, err := json.Marshal(e)
+	_, err := s.post(ctx, e)
+	return err
+}
+
+// post sends e and returns the response status code, or 0 when no response
+// was received.
+func (s *WebhookSink) post(ctx context.Context, e Event) (int, error) {
+	ce, err := e.CloudEvent()
 	if err != nil {
-		return err
+		return 0, err
+	}
+	data := []byte(ce.Data)
+	if !s.Binary {
+		if data, err = json.Marshal(ce); err != nil {
+			return 0, err
+		}
 	}
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Endpoint, bytes.NewReader(data))
 	if err != nil {
-		return err
+		return 0, err
+	}
+	if s.Binary {
+		ce.SetHTTPBinary(req.Header)
+	} else {
+		req.Header.Set("Content-Type", ContentTypeStructured)
 	}
-	req.Header.Set("Content-Type", "application/json")
 	if s.Secret != "" {
 		h := hmac.New(sha256.New, []byte(s.Secret))
 		h.Write(data)
@@ -60,13 +93,13 @@ func (s *WebhookSink) Emit(ctx context.Context, e Event) error {
 	}
 	resp, err := s.Client.Do(req)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if err := resp.Body.Close(); err != nil {
-		return err
+		return resp.StatusCode, err
 	}
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("webhook: %s", resp.Status)
+		return resp.StatusCode, fmt.Errorf("webhook: %s", resp.Status)
 	}
-	return nil
+	return resp.StatusCode, nil
 }
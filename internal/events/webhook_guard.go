@@ -0,0 +1,88 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedAddress is returned when a tenant webhook points at a loopback,
+// link-local, private or otherwise internal address.
+var ErrBlockedAddress = errors.New("events: webhook address not allowed")
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598, which
+// net.IP.IsPrivate does not cover.
+var sharedAddressSpace = &net.IPNet{IP: net.IP{100, 64, 0, 0}, Mask: net.CIDRMask(10, 32)}
+
+// blockedIP reports whether tenant webhooks may not reach ip.
+func blockedIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
+
+// Resolver looks up the addresses of a host, like net.Resolver.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// CheckWebhookURL validates the endpoint of a tenant webhook: it must be an
+// absolute http or https URL whose host resolves only to public addresses.
+// A nil resolver uses net.DefaultResolver. The addresses are checked again
+// on every delivery, so a host that later resolves elsewhere is still
+// refused.
+func CheckWebhookURL(ctx context.Context, raw string, r Resolver) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if blockedIP(ip) {
+			return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+		}
+		return nil
+	}
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	addrs, err := r.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", host, err)
+	}
+	for _, a := range addrs {
+		if blockedIP(a.IP) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrBlockedAddress, host, a.IP)
+		}
+	}
+	return nil
+}
+
+// guardedClient returns an HTTP client that refuses to connect to blocked
+// addresses. The check runs on the address actually dialled, after name
+// resolution, so DNS rebinding cannot redirect a delivery. Proxies from the
+// environment are not used since they would hide the destination.
+func guardedClient(timeout time.Duration) *http.Client {
+	d := &net.Dialer{
+		Timeout: timeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || blockedIP(ip) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+			}
+			return nil
+		},
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.Proxy = nil
+	tr.DialContext = d.DialContext
+	return &http.Client{Timeout: timeout, Transport: tr}
+}
@@ -121,17 +121,19 @@ func (s *RedisSubscriber) loop(ctx context.Context) error {
 
 func toWidget(r widgetsrepo.Row) Widget {
 	return Widget{
-		ID:           r.ID,
-		Name:         r.Name,
-		Version:      r.Version,
-		Type:         r.Type,
-		Scopes:       r.Scopes,
-		Enabled:      r.Enabled,
-		Description:  util.Deref(r.Description),
-		Capabilities: r.Capabilities,
-		Homepage:     util.Deref(r.Homepage),
-		Meta:         r.Meta,
-		Tenants:      r.Tenants,
-		UpdatedAt:    r.UpdatedAt,
+		ID:              r.ID,
+		Name:            r.Name,
+		Version:         r.Version,
+		Type:            r.Type,
+		Scopes:          r.Scopes,
+		Enabled:         r.Enabled,
+		Description:     util.Deref(r.Description),
+		Capabilities:    r.Capabilities,
+		Homepage:        util.Deref(r.Homepage),
+		Meta:            r.Meta,
+		Tenants:         r.Tenants,
+		UpdatedAt:       r.UpdatedAt,
+		TenantScope:     r.TenantScope,
+		DisabledTenants: r.DisabledTenants,
 	}
 }
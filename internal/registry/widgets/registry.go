@@ -4,12 +4,14 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/faciam-dev/gcfm/internal/util"
+	"github.com/faciam-dev/gcfm/pkg/widgetconfig"
 )
 
 var builtinWidgets = map[string]struct{}{
@@ -42,6 +44,37 @@ type Widget struct {
 	UpdatedAt    time.Time      `json:"updated_at"`
 	Meta         map[string]any `json:"meta,omitempty"`
 	Tenants      []string       `json:"-"`
+	TenantScope  string         `json:"-"`
+	// DisabledTenants lists tenants that switched the widget off.
+	DisabledTenants []string `json:"disabled_tenants,omitempty"`
+}
+
+var (
+	// ErrWidgetNotInstalled indicates that no widget with the ID exists.
+	ErrWidgetNotInstalled = errors.New("widget not installed")
+	// ErrWidgetNotInTenant indicates the widget is installed only for other tenants.
+	ErrWidgetNotInTenant = errors.New("widget not installed for tenant")
+	// ErrWidgetDisabled indicates the widget is disabled globally or for the tenant.
+	ErrWidgetDisabled = errors.New("widget disabled")
+)
+
+// Available reports whether the widget may be used by tenant. Widgets scoped
+// to specific tenants are only visible to those tenants and a tenant may
+// switch off any widget for itself.
+func (w Widget) Available(tenant string) error {
+	if !w.Enabled {
+		return ErrWidgetDisabled
+	}
+	if tenant == "" {
+		return nil
+	}
+	if (w.TenantScope == "tenant" || contains(w.Scopes, "tenant")) && len(w.Tenants) > 0 && !contains(w.Tenants, tenant) {
+		return ErrWidgetNotInTenant
+	}
+	if contains(w.DisabledTenants, tenant) {
+		return ErrWidgetDisabled
+	}
+	return nil
 }
 
 type Event struct {
@@ -66,6 +99,8 @@ type Registry interface {
 	Subscribe() (<-chan Event, func())
 	Has(id string) bool
 	DefaultConfig(id string) []byte
+	ConfigSchema(id string) map[string]any
+	Check(tenant, id string) error
 }
 
 type inMemory struct {
@@ -106,6 +141,9 @@ func (r *inMemory) List(ctx context.Context, opt Options) ([]Widget, int, string
 				continue
 			}
 		}
+		if opt.Tenant != "" && contains(w.DisabledTenants, opt.Tenant) {
+			w.Enabled = false
+		}
 		if opt.Q != "" {
 			q := strings.ToLower(opt.Q)
 			if !strings.Contains(strings.ToLower(w.ID), q) &&
@@ -211,6 +249,33 @@ func (r *inMemory) DefaultConfig(id string) []byte {
 	return nil
 }
 
+// Check reports why widget id cannot be used by tenant, or nil when it can.
+// Built-in widgets are always available.
+func (r *inMemory) Check(tenant, id string) error {
+	id = strings.ToLower(id)
+	if _, ok := builtinWidgets[id]; ok {
+		return nil
+	}
+	r.mu.RLock()
+	w, ok := r.items[id]
+	r.mu.RUnlock()
+	if !ok {
+		return ErrWidgetNotInstalled
+	}
+	return w.Available(tenant)
+}
+
+// ConfigSchema returns the JSON Schema declared by the widget manifest for
+// widget_config, or nil when the widget declares none.
+func (r *inMemory) ConfigSchema(id string) map[string]any {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if w, ok := r.items[id]; ok {
+		return widgetconfig.SchemaFromMeta(w.Meta)
+	}
+	return nil
+}
+
 func broadcast(subs map[chan Event]struct{}, ev Event) {
 	for ch := range subs {
 		select {
@@ -2,6 +2,7 @@ package widgets
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 )
@@ -56,3 +57,29 @@ func TestHasBuiltin(t *testing.T) {
 		t.Fatalf("expected builtin widget to be known")
 	}
 }
+
+func TestCheckTenantAvailability(t *testing.T) {
+	r := NewInMemory()
+	ctx := context.Background()
+	r.Upsert(ctx, Widget{ID: "shared", Enabled: true, Scopes: []string{"system"}, DisabledTenants: []string{"t2"}})
+	r.Upsert(ctx, Widget{ID: "private", Enabled: true, Scopes: []string{"tenant"}, Tenants: []string{"t1"}})
+	r.Upsert(ctx, Widget{ID: "off", Enabled: false, Scopes: []string{"system"}})
+
+	cases := []struct {
+		tenant, id string
+		want       error
+	}{
+		{"t1", "text-input", nil},
+		{"t1", "shared", nil},
+		{"t2", "shared", ErrWidgetDisabled},
+		{"t1", "private", nil},
+		{"t2", "private", ErrWidgetNotInTenant},
+		{"t1", "off", ErrWidgetDisabled},
+		{"t1", "missing", ErrWidgetNotInstalled},
+	}
+	for _, c := range cases {
+		if err := r.Check(c.tenant, c.id); !errors.Is(err, c.want) {
+			t.Fatalf("Check(%s, %s)=%v want %v", c.tenant, c.id, err, c.want)
+		}
+	}
+}
@@ -47,7 +47,7 @@ func (r *MySQLRepo) applyFilters(q *query.Query, f Filter) {
 // List returns widgets matching the filter.
 func (r *MySQLRepo) List(ctx context.Context, f Filter) ([]Row, int, error) {
 	q := query.New(r.DB, r.table(), ormdriver.MySQLDialect{}).
-		Select("id", "name", "version", "type", "scopes", "enabled", "description", "capabilities", "homepage", "meta", "tenant_scope", "tenants", "updated_at")
+		Select("id", "name", "version", "type", "scopes", "enabled", "description", "capabilities", "homepage", "meta", "tenant_scope", "tenants", "disabled_tenants", "updated_at")
 	r.applyFilters(q, f)
 	q.OrderBy("updated_at", "desc")
 	if f.Limit > 0 {
@@ -66,6 +66,7 @@ func (r *MySQLRepo) List(ctx context.Context, f Filter) ([]Row, int, error) {
 		Meta         []byte         `db:"meta"`
 		TenantScope  string         `db:"tenant_scope"`
 		Tenants      []byte         `db:"tenants"`
+		Disabled     []byte         `db:"disabled_tenants"`
 		UpdatedAt    time.Time      `db:"updated_at"`
 	}
 	var rs []dbRow
@@ -101,6 +102,11 @@ func (r *MySQLRepo) List(ctx context.Context, f Filter) ([]Row, int, error) {
 		if err := json.Unmarshal(r0.Tenants, &rr.Tenants); err != nil {
 			return nil, 0, fmt.Errorf("failed to unmarshal tenants for id %s: %w", r0.ID, err)
 		}
+		if len(r0.Disabled) > 0 {
+			if err := json.Unmarshal(r0.Disabled, &rr.DisabledTenants); err != nil {
+				return nil, 0, fmt.Errorf("failed to unmarshal disabled tenants for id %s: %w", r0.ID, err)
+			}
+		}
 		rr.UpdatedAt = r0.UpdatedAt
 		items = append(items, rr)
 	}
@@ -135,6 +141,15 @@ func (r *MySQLRepo) GetETagAndLastMod(ctx context.Context, f Filter) (string, ti
 
 // Upsert inserts or updates a widget.
 func (r *MySQLRepo) Upsert(ctx context.Context, rr Row) error {
+	return r.upsert(ctx, r.DB, rr)
+}
+
+// UpsertTx is like Upsert but writes within tx.
+func (r *MySQLRepo) UpsertTx(ctx context.Context, tx *sql.Tx, rr Row) error {
+	return r.upsert(ctx, tx, rr)
+}
+
+func (r *MySQLRepo) upsert(ctx context.Context, db execer, rr Row) error {
 	scopes, _ := json.Marshal(rr.Scopes)
 	caps, _ := json.Marshal(rr.Capabilities)
 	tenants, _ := json.Marshal(rr.Tenants)
@@ -154,7 +169,7 @@ func (r *MySQLRepo) Upsert(ctx context.Context, rr Row) error {
 		"tenants":      tenants,
 		"updated_at":   time.Now(),
 	}
-	_, err := query.New(r.DB, r.table(), ormdriver.MySQLDialect{}).WithContext(ctx).
+	_, err := query.New(db, r.table(), ormdriver.MySQLDialect{}).WithContext(ctx).
 		Upsert([]map[string]any{data}, []string{"id"}, []string{"name", "version", "type", "scopes", "enabled", "description", "capabilities", "homepage", "meta", "tenant_scope", "tenants", "updated_at"})
 	return err
 }
@@ -168,7 +183,7 @@ func (r *MySQLRepo) Remove(ctx context.Context, id string) error {
 // GetByID retrieves a widget by ID.
 func (r *MySQLRepo) GetByID(ctx context.Context, id string) (Row, error) {
 	q := query.New(r.DB, r.table(), ormdriver.MySQLDialect{}).
-		Select("id", "name", "version", "type", "scopes", "enabled", "description", "capabilities", "homepage", "meta", "tenant_scope", "tenants", "updated_at").
+		Select("id", "name", "version", "type", "scopes", "enabled", "description", "capabilities", "homepage", "meta", "tenant_scope", "tenants", "disabled_tenants", "updated_at").
 		Where("id", id)
 	var r0 struct {
 		ID           string         `db:"id"`
@@ -183,6 +198,7 @@ func (r *MySQLRepo) GetByID(ctx context.Context, id string) (Row, error) {
 		Meta         []byte         `db:"meta"`
 		TenantScope  string         `db:"tenant_scope"`
 		Tenants      []byte         `db:"tenants"`
+		Disabled     []byte         `db:"disabled_tenants"`
 		UpdatedAt    time.Time      `db:"updated_at"`
 	}
 	if err := q.WithContext(ctx).First(&r0); err != nil {
@@ -215,6 +231,49 @@ func (r *MySQLRepo) GetByID(ctx context.Context, id string) (Row, error) {
 	if err := json.Unmarshal(r0.Tenants, &rr.Tenants); err != nil {
 		return Row{}, fmt.Errorf("failed to unmarshal tenants: %w", err)
 	}
+	if len(r0.Disabled) > 0 {
+		if err := json.Unmarshal(r0.Disabled, &rr.DisabledTenants); err != nil {
+			return Row{}, fmt.Errorf("failed to unmarshal disabled tenants: %w", err)
+		}
+	}
 	rr.UpdatedAt = r0.UpdatedAt
 	return rr, nil
 }
+
+// SetTenantEnabled toggles the widget for a single tenant. The row is locked
+// while its tenant list is rewritten so that concurrent toggles do not
+// overwrite each other. A missing widget yields sql.ErrNoRows.
+func (r *MySQLRepo) SetTenantEnabled(ctx context.Context, id, tenant string, enabled bool) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if err := r.SetTenantEnabledTx(ctx, tx, id, tenant, enabled); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetTenantEnabledTx is like SetTenantEnabled but writes within tx.
+func (r *MySQLRepo) SetTenantEnabledTx(ctx context.Context, tx *sql.Tx, id, tenant string, enabled bool) error {
+	tbl := "`" + r.table() + "`"
+	var raw []byte
+	if err := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT disabled_tenants FROM %s WHERE id = ? FOR UPDATE", tbl), id).Scan(&raw); err != nil {
+		return err
+	}
+	var list []string
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return fmt.Errorf("failed to unmarshal disabled tenants: %w", err)
+		}
+	}
+	disabled, err := json.Marshal(toggleTenant(list, tenant, enabled))
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET disabled_tenants = ?, updated_at = ? WHERE id = ?", tbl), disabled, time.Now(), id); err != nil {
+		return err
+	}
+	return nil
+}
@@ -47,7 +47,7 @@ func (r *PGRepo) applyFilters(q *query.Query, f Filter) {
 // List returns widgets matching the filter.
 func (r *PGRepo) List(ctx context.Context, f Filter) ([]Row, int, error) {
 	q := query.New(r.DB, r.table(), ormdriver.PostgresDialect{}).
-		Select("id", "name", "version", "type", "scopes", "enabled", "description", "capabilities", "homepage", "meta", "tenant_scope", "tenants", "updated_at")
+		Select("id", "name", "version", "type", "scopes", "enabled", "description", "capabilities", "homepage", "meta", "tenant_scope", "tenants", "disabled_tenants", "updated_at")
 	r.applyFilters(q, f)
 	q.OrderBy("updated_at", "desc")
 	if f.Limit > 0 {
@@ -66,6 +66,7 @@ func (r *PGRepo) List(ctx context.Context, f Filter) ([]Row, int, error) {
 		Meta         []byte         `db:"meta"`
 		TenantScope  string         `db:"tenant_scope"`
 		Tenants      pq.StringArray `db:"tenants"`
+		Disabled     pq.StringArray `db:"disabled_tenants"`
 		UpdatedAt    time.Time      `db:"updated_at"`
 	}
 	var rs []dbRow
@@ -75,16 +76,17 @@ func (r *PGRepo) List(ctx context.Context, f Filter) ([]Row, int, error) {
 	items := make([]Row, 0, len(rs))
 	for _, r0 := range rs {
 		rr := Row{
-			ID:           r0.ID,
-			Name:         r0.Name,
-			Version:      r0.Version,
-			Type:         r0.Type,
-			Scopes:       []string(r0.Scopes),
-			Enabled:      r0.Enabled,
-			Capabilities: []string(r0.Capabilities),
-			TenantScope:  r0.TenantScope,
-			Tenants:      []string(r0.Tenants),
-			UpdatedAt:    r0.UpdatedAt,
+			ID:              r0.ID,
+			Name:            r0.Name,
+			Version:         r0.Version,
+			Type:            r0.Type,
+			Scopes:          []string(r0.Scopes),
+			Enabled:         r0.Enabled,
+			Capabilities:    []string(r0.Capabilities),
+			TenantScope:     r0.TenantScope,
+			Tenants:         []string(r0.Tenants),
+			UpdatedAt:       r0.UpdatedAt,
+			DisabledTenants: []string(r0.Disabled),
 		}
 		if r0.Description.Valid {
 			rr.Description = &r0.Description.String
@@ -128,6 +130,15 @@ func (r *PGRepo) GetETagAndLastMod(ctx context.Context, f Filter) (string, time.
 
 // Upsert inserts or updates a widget.
 func (r *PGRepo) Upsert(ctx context.Context, rr Row) error {
+	return r.upsert(ctx, r.DB, rr)
+}
+
+// UpsertTx is like Upsert but writes within tx.
+func (r *PGRepo) UpsertTx(ctx context.Context, tx *sql.Tx, rr Row) error {
+	return r.upsert(ctx, tx, rr)
+}
+
+func (r *PGRepo) upsert(ctx context.Context, db execer, rr Row) error {
 	metaBytes, _ := json.Marshal(rr.Meta)
 	data := map[string]any{
 		"id":           rr.ID,
@@ -144,7 +155,7 @@ func (r *PGRepo) Upsert(ctx context.Context, rr Row) error {
 		"tenants":      pq.Array(rr.Tenants),
 		"updated_at":   time.Now(),
 	}
-	_, err := query.New(r.DB, r.table(), ormdriver.PostgresDialect{}).WithContext(ctx).
+	_, err := query.New(db, r.table(), ormdriver.PostgresDialect{}).WithContext(ctx).
 		Upsert([]map[string]any{data}, []string{"id"}, []string{"name", "version", "type", "scopes", "enabled", "description", "capabilities", "homepage", "meta", "tenant_scope", "tenants", "updated_at"})
 	return err
 }
@@ -158,7 +169,7 @@ func (r *PGRepo) Remove(ctx context.Context, id string) error {
 // GetByID retrieves a widget by ID.
 func (r *PGRepo) GetByID(ctx context.Context, id string) (Row, error) {
 	q := query.New(r.DB, r.table(), ormdriver.PostgresDialect{}).
-		Select("id", "name", "version", "type", "scopes", "enabled", "description", "capabilities", "homepage", "meta", "tenant_scope", "tenants", "updated_at").
+		Select("id", "name", "version", "type", "scopes", "enabled", "description", "capabilities", "homepage", "meta", "tenant_scope", "tenants", "disabled_tenants", "updated_at").
 		Where("id", id)
 	var r0 struct {
 		ID           string         `db:"id"`
@@ -173,22 +184,24 @@ func (r *PGRepo) GetByID(ctx context.Context, id string) (Row, error) {
 		Meta         []byte         `db:"meta"`
 		TenantScope  string         `db:"tenant_scope"`
 		Tenants      pq.StringArray `db:"tenants"`
+		Disabled     pq.StringArray `db:"disabled_tenants"`
 		UpdatedAt    time.Time      `db:"updated_at"`
 	}
 	if err := q.WithContext(ctx).First(&r0); err != nil {
 		return Row{}, err
 	}
 	rr := Row{
-		ID:           r0.ID,
-		Name:         r0.Name,
-		Version:      r0.Version,
-		Type:         r0.Type,
-		Scopes:       []string(r0.Scopes),
-		Enabled:      r0.Enabled,
-		Capabilities: []string(r0.Capabilities),
-		TenantScope:  r0.TenantScope,
-		Tenants:      []string(r0.Tenants),
-		UpdatedAt:    r0.UpdatedAt,
+		ID:              r0.ID,
+		Name:            r0.Name,
+		Version:         r0.Version,
+		Type:            r0.Type,
+		Scopes:          []string(r0.Scopes),
+		Enabled:         r0.Enabled,
+		Capabilities:    []string(r0.Capabilities),
+		TenantScope:     r0.TenantScope,
+		Tenants:         []string(r0.Tenants),
+		UpdatedAt:       r0.UpdatedAt,
+		DisabledTenants: []string(r0.Disabled),
 	}
 	if r0.Description.Valid {
 		rr.Description = &r0.Description.String
@@ -201,3 +214,32 @@ func (r *PGRepo) GetByID(ctx context.Context, id string) (Row, error) {
 	}
 	return rr, nil
 }
+
+// SetTenantEnabled toggles the widget for a single tenant. The row is locked
+// while its tenant list is rewritten so that concurrent toggles do not
+// overwrite each other. A missing widget yields sql.ErrNoRows.
+func (r *PGRepo) SetTenantEnabled(ctx context.Context, id, tenant string, enabled bool) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if err := r.SetTenantEnabledTx(ctx, tx, id, tenant, enabled); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetTenantEnabledTx is like SetTenantEnabled but writes within tx.
+func (r *PGRepo) SetTenantEnabledTx(ctx context.Context, tx *sql.Tx, id, tenant string, enabled bool) error {
+	tbl := pq.QuoteIdentifier(r.table())
+	var disabled pq.StringArray
+	if err := tx.QueryRowContext(ctx, fmt.Sprintf(`SELECT disabled_tenants FROM %s WHERE id = $1 FOR UPDATE`, tbl), id).Scan(&disabled); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET disabled_tenants = $1, updated_at = $2 WHERE id = $3`, tbl),
+		pq.Array(toggleTenant(disabled, tenant, enabled)), time.Now(), id); err != nil {
+		return err
+	}
+	return nil
+}
@@ -88,3 +88,31 @@ func TestPGRepoGetByID(t *testing.T) {
 		t.Fatalf("unexpected row: %s", string(b))
 	}
 }
+
+func TestPGRepoSetTenantEnabledLocksRow(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	repo := NewPGRepo(db, "gcfm_")
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT disabled_tenants FROM "gcfm_widgets" WHERE id = \$1 FOR UPDATE`).WithArgs("a").
+		WillReturnRows(sqlmock.NewRows([]string{"disabled_tenants"}).AddRow(pq.StringArray{"t1", "t2"}))
+	mock.ExpectExec(`UPDATE "gcfm_widgets" SET disabled_tenants`).
+		WithArgs(pq.Array([]string{"t2"}), sqlmock.AnyArg(), "a").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := repo.SetTenantEnabled(context.Background(), "a", "t1", true); err != nil {
+		t.Fatalf("SetTenantEnabled: %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT disabled_tenants`).WithArgs("missing").WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+	if err := repo.SetTenantEnabled(context.Background(), "missing", "t1", true); err != sql.ErrNoRows {
+		t.Fatalf("expected sql.ErrNoRows, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,9 +2,20 @@ package widgetsrepo
 
 import (
 	"context"
+	"database/sql"
 	"time"
 )
 
+// execer is satisfied by *sql.DB and *sql.Tx.
+type execer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	Exec(query string, args ...any) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
 // Filter represents query parameters for listing widgets.
 type Filter struct {
 	Tenant  string
@@ -28,7 +39,11 @@ type Row struct {
 	Meta         map[string]any
 	TenantScope  string
 	Tenants      []string
-	UpdatedAt    time.Time
+	// DisabledTenants lists tenants that switched the widget off. It is
+	// maintained through SetTenantEnabled and never written by Upsert, so
+	// re-uploading a widget keeps tenant choices intact.
+	DisabledTenants []string
+	UpdatedAt       time.Time
 }
 
 // Repo defines the widget repository interface.
@@ -38,4 +53,27 @@ type Repo interface {
 	Upsert(ctx context.Context, r Row) error
 	Remove(ctx context.Context, id string) error
 	GetByID(ctx context.Context, id string) (Row, error)
+	SetTenantEnabled(ctx context.Context, id, tenant string, enabled bool) error
+}
+
+// TxRepo is implemented by repositories that can write within a caller's
+// transaction, e.g. to restore widgets atomically with other configuration.
+type TxRepo interface {
+	UpsertTx(ctx context.Context, tx *sql.Tx, r Row) error
+	SetTenantEnabledTx(ctx context.Context, tx *sql.Tx, id, tenant string, enabled bool) error
+}
+
+// toggleTenant returns list with tenant removed when enabled is true or added
+// when enabled is false.
+func toggleTenant(list []string, tenant string, enabled bool) []string {
+	out := make([]string, 0, len(list)+1)
+	for _, t := range list {
+		if t != tenant {
+			out = append(out, t)
+		}
+	}
+	if !enabled {
+		out = append(out, tenant)
+	}
+	return out
 }
@@ -1,34 +1,102 @@
 package server
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"os"
+	"strconv"
 
 	"github.com/faciam-dev/gcfm/internal/events"
 	"github.com/faciam-dev/gcfm/internal/logger"
+	"github.com/faciam-dev/gcfm/pkg/audit"
 	"github.com/faciam-dev/goquent/orm/driver"
 )
 
-// initEvents initializes the global events dispatcher.
-func initEvents(db *sql.DB, dialect driver.Dialect, tablePrefix string) {
+// initEvents initializes the global events dispatcher and returns its
+// configuration and the DLQ replayer, which is nil without a database.
+func initEvents(db *sql.DB, dialect driver.Dialect, tablePrefix string) (events.Config, *events.Replayer) {
 	evtConf, err := events.LoadConfig(os.Getenv("CF_EVENTS_CONFIG"))
 	if err != nil {
 		logger.L.Error("Failed to load events configuration", "err", err)
 		os.Exit(1)
 	}
-	var sinks []events.Sink
-	if wh := events.NewWebhookSink(evtConf.Sinks.Webhook); wh != nil {
-		sinks = append(sinks, wh)
+	sinks, err := events.NewSinks(evtConf)
+	if err != nil {
+		logger.L.Error("Invalid event subscription", "err", err)
+		os.Exit(1)
+	}
+	if db != nil {
+		sinks = append(sinks, events.NewTenantWebhookSink(&events.WebhookStore{DB: db, Dialect: dialect, TablePrefix: tablePrefix}, evtConf.TenantWebhooks))
+	}
+	dlq := &events.SQLDLQ{DB: db, Dialect: dialect, TablePrefix: tablePrefix}
+	events.Default = events.NewDispatcher(evtConf, dlq, sinks...)
+	if db == nil {
+		return evtConf, nil
+	}
+	if !evtConf.Outbox.Disabled {
+		outbox := &events.Outbox{DB: db, Dialect: dialect, TablePrefix: tablePrefix}
+		// Without the table every transactional write would fail, so fall
+		// back to direct dispatch until the migration has been applied.
+		if err := outbox.Ready(context.Background()); err != nil {
+			logger.L.Warn("events outbox unavailable, dispatching events directly; apply migration 0009 to enable it", "err", err)
+		} else {
+			events.Default.UseOutbox(outbox)
+			go events.NewRelay(outbox, events.Default, evtConf.Outbox).Run(context.Background())
+		}
 	}
-	if rs, err := events.NewRedisSink(evtConf.Sinks.Redis); err == nil && rs != nil {
-		sinks = append(sinks, rs)
-	} else if err != nil {
-		logger.L.Error("redis sink", "err", err)
+	replayer := events.NewReplayer(dlq, events.Default, evtConf.Replay)
+	if replayer.Enabled {
+		dlq.ReplayDelay = replayer.InitialDelay
 	}
-	if ks, err := events.NewKafkaSink(evtConf.Sinks.Kafka); err == nil && ks != nil {
-		sinks = append(sinks, ks)
-	} else if err != nil {
-		logger.L.Error("kafka sink", "err", err)
+	go replayer.Run(context.Background())
+	return evtConf, replayer
+}
+
+// auditSubject names the resource of an audit row, e.g. "role/7" or
+// "posts.title". It is empty when the row names no resource, so that the
+// event carries no subject.
+func auditSubject(e audit.ChainEntry) string {
+	switch {
+	case e.ResourceID != "" && e.ResourceType != "":
+		return e.ResourceType + "/" + e.ResourceID
+	case e.ResourceID != "":
+		return e.ResourceID
+	case e.TableName != "" && e.ColumnName != "":
+		return e.TableName + "." + e.ColumnName
+	}
+	return e.TableName
+}
+
+// forwardAudit publishes an audit row as a cf.audit.<action> event. The
+// event outlives the request that wrote the row, so cancellation of ctx is
+// not propagated.
+func forwardAudit(ctx context.Context, id int64, e audit.ChainEntry) {
+	raw := func(s string) json.RawMessage {
+		if s == "" {
+			return nil
+		}
+		return json.RawMessage(s)
 	}
-	events.Default = events.NewDispatcher(evtConf, &events.SQLDLQ{DB: db, Dialect: dialect, TablePrefix: tablePrefix}, sinks...)
+	events.Emit(context.WithoutCancel(ctx), events.Event{
+		Name:    events.TypeAuditPrefix + e.Action,
+		ID:      "audit-" + strconv.FormatInt(id, 10),
+		Subject: auditSubject(e),
+		Tenant:  e.TenantID,
+		Data: events.AuditRecord{
+			ID:           id,
+			Tenant:       e.TenantID,
+			Actor:        e.Actor,
+			Action:       e.Action,
+			TableName:    e.TableName,
+			ColumnName:   e.ColumnName,
+			ResourceType: e.ResourceType,
+			ResourceID:   e.ResourceID,
+			RequestID:    e.RequestID,
+			RevertOf:     e.RevertOf,
+			Before:       raw(e.BeforeJSON),
+			After:        raw(e.AfterJSON),
+			ChangeCount:  e.ChangeCount,
+		},
+	})
 }
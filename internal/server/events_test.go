@@ -0,0 +1,24 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/faciam-dev/gcfm/pkg/audit"
+)
+
+func TestAuditSubject(t *testing.T) {
+	cases := []struct {
+		e    audit.ChainEntry
+		want string
+	}{
+		{audit.ChainEntry{ResourceType: "role", ResourceID: "7"}, "role/7"},
+		{audit.ChainEntry{TableName: "posts", ColumnName: "title"}, "posts.title"},
+		{audit.ChainEntry{TableName: "registry"}, "registry"},
+		{audit.ChainEntry{Action: "login"}, ""},
+	}
+	for _, c := range cases {
+		if got := auditSubject(c.e); got != c.want {
+			t.Errorf("auditSubject(%+v) = %q, want %q", c.e, got, c.want)
+		}
+	}
+}
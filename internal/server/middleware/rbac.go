@@ -12,8 +12,14 @@ import (
 type RoleResolver func(ctx context.Context, user string) ([]string, error)
 
 // RBAC enforces access where either the user or any of their roles is allowed.
+// Operations that require scopes are authorized by RequireScopes instead,
+// which applies the same policy to callers lacking the scopes.
 func RBAC(enf *casbin.Enforcer, resolve RoleResolver) func(huma.Context, func(huma.Context)) {
 	return func(ctx huma.Context, next func(huma.Context)) {
+		if scoped(ctx.Operation()) {
+			next(ctx)
+			return
+		}
 		r, w := humachi.Unwrap(ctx)
 		sub := UserFromContext(r.Context())
 		obj := r.URL.Path
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// ScopesMetadataKey is the operation metadata key listing the scopes an
+// operation requires. RBAC leaves such operations to the scope check, which
+// applies the RBAC policy to callers lacking the scopes.
+const ScopesMetadataKey = "scopes"
+
+// ScopePolicy decides which scopes the caller of a request holds. A scope is
+// granted by the scope claim of the JWT or by a role the caller has. A scope
+// ending in ":write" also grants the scope without that suffix.
+type ScopePolicy struct {
+	// RoleScopes maps role names to the scopes they grant. Roles come from
+	// the JWT roles claim and from Roles.
+	RoleScopes map[string][]string
+	Roles      RoleResolver
+	// Allowed reports whether the RBAC policy permits the request itself,
+	// given its method and path. Callers without the scope may still call
+	// the operations their policy allows, one operation at a time, as
+	// before the operation required a scope.
+	Allowed func(ctx context.Context, method, path string) bool
+}
+
+// Has reports whether the caller of ctx holds scope.
+func (p ScopePolicy) Has(ctx context.Context, scope string) bool {
+	wanted := []string{scope, scope + ":write"}
+	var roles []string
+	if c, ok := ctx.Value(claimsKey).(interface {
+		GetScopes() []string
+		GetRoles() []string
+	}); ok {
+		if slices.ContainsFunc(c.GetScopes(), func(s string) bool { return slices.Contains(wanted, s) }) {
+			return true
+		}
+		roles = append(roles, c.GetRoles()...)
+	}
+	if len(p.RoleScopes) > 0 {
+		if p.Roles != nil {
+			if rs, err := p.Roles(ctx, UserFromContext(ctx)); err == nil {
+				roles = append(roles, rs...)
+			}
+		}
+		for _, r := range roles {
+			if slices.ContainsFunc(p.RoleScopes[r], func(s string) bool { return slices.Contains(wanted, s) }) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RequireScopes returns a factory of middlewares that reject requests whose
+// caller lacks one of the given scopes, and whose request p.Allowed does not
+// permit, with 403 naming the missing scope.
+func RequireScopes(api huma.API, p ScopePolicy) func(scopes ...string) func(huma.Context, func(huma.Context)) {
+	return func(scopes ...string) func(huma.Context, func(huma.Context)) {
+		return func(ctx huma.Context, next func(huma.Context)) {
+			allowed := func() bool {
+				return p.Allowed != nil && p.Allowed(ctx.Context(), ctx.Method(), ctx.URL().Path)
+			}
+			for _, s := range scopes {
+				if !p.Has(ctx.Context(), s) && !allowed() {
+					_ = huma.WriteErr(api, ctx, http.StatusForbidden, "missing scope "+s)
+					return
+				}
+			}
+			next(ctx)
+		}
+	}
+}
+
+// scoped reports whether op is authorized by RequireScopes.
+func scoped(op *huma.Operation) bool {
+	if op == nil {
+		return false
+	}
+	s, ok := op.Metadata[ScopesMetadataKey].([]string)
+	return ok && len(s) > 0
+}
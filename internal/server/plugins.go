@@ -17,6 +17,7 @@ import (
 	pluginsvc "github.com/faciam-dev/gcfm/internal/service/plugins"
 	pluginhandlers "github.com/faciam-dev/gcfm/internal/transport/http/handlers"
 	"github.com/faciam-dev/gcfm/internal/util"
+	"github.com/faciam-dev/gcfm/pkg/audit"
 	"github.com/go-chi/chi/v5"
 	"github.com/redis/go-redis/v9"
 )
@@ -71,17 +72,25 @@ func loadPluginConfig() pluginConfig {
 	return cfg
 }
 
+// newWidgetsRepo returns the widget repository for driver or nil when the
+// driver has no widget storage.
+func newWidgetsRepo(db *sql.DB, driver, tablePrefix string) widgetsrepo.Repo {
+	if db == nil {
+		return nil
+	}
+	switch driver {
+	case "postgres":
+		return widgetsrepo.NewPGRepo(db, tablePrefix)
+	case "mysql":
+		return widgetsrepo.NewMySQLRepo(db, tablePrefix)
+	}
+	return nil
+}
+
 // setupPluginRoutes registers plugin and widget endpoints.
-func setupPluginRoutes(api huma.API, r chi.Router, db *sql.DB, driver, tablePrefix string, wreg widgetreg.Registry, e *casbin.Enforcer, resolver func(context.Context, string) ([]string, error)) {
+func setupPluginRoutes(api huma.API, r chi.Router, db *sql.DB, driver, tablePrefix string, wreg widgetreg.Registry, e *casbin.Enforcer, resolver func(context.Context, string) ([]string, error), rec *audit.Recorder) {
 	cfg := loadPluginConfig()
-	var wrepo widgetsrepo.Repo
-	if db != nil {
-		if driver == "postgres" {
-			wrepo = widgetsrepo.NewPGRepo(db, tablePrefix)
-		} else if driver == "mysql" {
-			wrepo = widgetsrepo.NewMySQLRepo(db, tablePrefix)
-		}
-	}
+	wrepo := newWidgetsRepo(db, driver, tablePrefix)
 	var (
 		rdb      *redis.Client
 		notifier pluginsvc.WidgetsNotifier
@@ -96,9 +105,9 @@ func setupPluginRoutes(api huma.API, r chi.Router, db *sql.DB, driver, tablePref
 	}
 	az := authz{Enf: e, Resolve: resolver}
 	uploader := &pluginsvc.Uploader{Repo: wrepo, Notifier: notifier, Logger: logger.L, AcceptExt: cfg.AcceptExt, TmpDir: cfg.TmpDir, StoreDir: cfg.StoreDir}
-	ph := &pluginhandlers.Handlers{Auth: az, Cfg: pluginhandlers.Config{PluginsMaxUploadMB: cfg.MaxUploadMB}, PluginUploader: uploader}
+	ph := &pluginhandlers.Handlers{Auth: az, Cfg: pluginhandlers.Config{PluginsMaxUploadMB: cfg.MaxUploadMB}, PluginUploader: uploader, Recorder: rec}
 	ph.RegisterPluginRoutes(api)
-	wh := &handler.WidgetHandler{Reg: wreg, Repo: wrepo, Notifier: notifier, Auth: az}
+	wh := &handler.WidgetHandler{Reg: wreg, Repo: wrepo, Notifier: notifier, Auth: az, Recorder: rec}
 	handler.RegisterWidget(api, wh)
 	r.Get("/v1/metadata/widgets/stream", wh.Stream)
 	if wrepo != nil {
@@ -109,18 +118,20 @@ func setupPluginRoutes(api huma.API, r chi.Router, db *sql.DB, driver, tablePref
 			ws := make([]widgetreg.Widget, len(rows))
 			for i, r := range rows {
 				ws[i] = widgetreg.Widget{
-					ID:           r.ID,
-					Name:         r.Name,
-					Version:      r.Version,
-					Type:         r.Type,
-					Scopes:       r.Scopes,
-					Enabled:      r.Enabled,
-					Description:  util.Deref(r.Description),
-					Capabilities: r.Capabilities,
-					Homepage:     util.Deref(r.Homepage),
-					Meta:         r.Meta,
-					Tenants:      r.Tenants,
-					UpdatedAt:    r.UpdatedAt,
+					ID:              r.ID,
+					Name:            r.Name,
+					Version:         r.Version,
+					Type:            r.Type,
+					Scopes:          r.Scopes,
+					Enabled:         r.Enabled,
+					Description:     util.Deref(r.Description),
+					Capabilities:    r.Capabilities,
+					Homepage:        util.Deref(r.Homepage),
+					Meta:            r.Meta,
+					Tenants:         r.Tenants,
+					UpdatedAt:       r.UpdatedAt,
+					TenantScope:     r.TenantScope,
+					DisabledTenants: r.DisabledTenants,
 				}
 			}
 			if _, _, err := wreg.ApplyDiff(context.Background(), ws, nil); err != nil {
@@ -39,6 +39,40 @@ func initEnforcer(db *sql.DB, dialect driver.Dialect, tablePrefix string) (*casb
 	return e, nil
 }
 
+// enforcerReloader returns a function that replaces the policies of e with
+// the defaults and the roles currently stored in the database. The new
+// policies are loaded before e is touched so that a failed load leaves e
+// unchanged.
+func enforcerReloader(e *casbin.Enforcer, db *sql.DB, dialect driver.Dialect, tablePrefix string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		fresh, err := initEnforcer(nil, dialect, tablePrefix)
+		if err != nil {
+			return err
+		}
+		if err := rbac.Load(ctx, db, dialect, tablePrefix, fresh); err != nil {
+			return err
+		}
+		policies, err := fresh.GetPolicy()
+		if err != nil {
+			return err
+		}
+		groupings, err := fresh.GetGroupingPolicy()
+		if err != nil {
+			return err
+		}
+		e.ClearPolicy()
+		if _, err := e.AddPolicies(policies); err != nil {
+			return err
+		}
+		if len(groupings) > 0 {
+			if _, err := e.AddGroupingPolicies(groupings); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
 // roleResolver returns a function that resolves roles for a given user.
 func roleResolver(db *sql.DB, dialect driver.Dialect, tablePrefix string) func(context.Context, string) ([]string, error) {
 	return func(ctx context.Context, user string) ([]string, error) {
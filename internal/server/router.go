@@ -15,6 +15,7 @@ import (
 	"github.com/faciam-dev/gcfm/internal/api/handler"
 	"github.com/faciam-dev/gcfm/internal/auth"
 	capabilitydomain "github.com/faciam-dev/gcfm/internal/domain/capability"
+	"github.com/faciam-dev/gcfm/internal/events"
 	capmongoadapter "github.com/faciam-dev/gcfm/internal/infrastructure/capability/mongo"
 	"github.com/faciam-dev/gcfm/internal/logger"
 	"github.com/faciam-dev/gcfm/internal/monitordb"
@@ -23,6 +24,7 @@ import (
 	widgetreg "github.com/faciam-dev/gcfm/internal/registry/widgets"
 	"github.com/faciam-dev/gcfm/internal/server/middleware"
 	"github.com/faciam-dev/gcfm/internal/server/reserved"
+	"github.com/faciam-dev/gcfm/internal/snapshotbundle"
 	capabilityusecase "github.com/faciam-dev/gcfm/internal/usecase/capability"
 	"github.com/faciam-dev/gcfm/meta/sqlmetastore"
 	"github.com/faciam-dev/gcfm/pkg/audit"
@@ -35,7 +37,17 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// New builds the API server. The connections it opens for background work,
+// such as the Redis client of the targets signal, live as long as the
+// process; NewWithCleanup releases them.
 func New(db *sql.DB, cfg DBConfig) huma.API {
+	api, _ := NewWithCleanup(db, cfg)
+	return api
+}
+
+// NewWithCleanup is like New and also returns a function that stops the
+// target watcher of the server and closes the connections it opened.
+func NewWithCleanup(db *sql.DB, cfg DBConfig) (huma.API, func()) {
 	r := chi.NewRouter()
 
 	_, file, _, _ := runtime.Caller(0)
@@ -44,9 +56,10 @@ func New(db *sql.DB, cfg DBConfig) huma.API {
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   allowedOrigins(),
 		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Request-ID"},
 		AllowCredentials: true,
 	}))
+	r.Use(middleware.RequestID)
 
 	driver := cfg.Driver
 	dsn := cfg.DSN
@@ -63,9 +76,11 @@ func New(db *sql.DB, cfg DBConfig) huma.API {
 	// Apply tenant middleware to all endpoints, including login.
 	api.UseMiddleware(middleware.ExtractTenant(api))
 
+	rec := &audit.Recorder{DB: db, Dialect: dialect, TablePrefix: cfg.TablePrefix, HashChain: true}
+
 	// Register login & refresh handlers before applying auth middleware so
 	// that they remain publicly accessible.
-	auth.Register(api, &auth.Handler{Repo: &auth.UserRepo{DB: db, Dialect: dialect, TablePrefix: cfg.TablePrefix}, JWT: jwtHandler})
+	auth.Register(api, &auth.Handler{Repo: &auth.UserRepo{DB: db, Dialect: dialect, TablePrefix: cfg.TablePrefix}, JWT: jwtHandler, Recorder: rec})
 
 	// Apply authentication middleware for subsequent endpoints.
 	api.UseMiddleware(auth.Middleware(api, jwtHandler))
@@ -82,9 +97,10 @@ func New(db *sql.DB, cfg DBConfig) huma.API {
 	}
 	setupMetrics(api, r, db, dialect, cfg.TablePrefix)
 
-	rec := &audit.Recorder{DB: db, Dialect: dialect, TablePrefix: cfg.TablePrefix}
-
-	initEvents(db, dialect, cfg.TablePrefix)
+	evtConf, replayer := initEvents(db, dialect, cfg.TablePrefix)
+	if evtConf.Audit.Forward {
+		rec.Forward = forwardAudit
+	}
 	var mongoCli *mongo.Client
 	if driver == "mongo" && dsn != "" {
 		cli, err := mongo.Connect(context.Background(), options.Client().ApplyURI(dsn))
@@ -112,12 +128,50 @@ func New(db *sql.DB, cfg DBConfig) huma.API {
 		logger.L.Warn("load widget policy", "err", err)
 	}
 	go wpStore.Watch(context.Background())
-	handler.Register(api, &handler.CustomFieldHandler{DB: db, Mongo: mongoCli, Driver: driver, Dialect: dialect, Recorder: rec, Schema: schema, TablePrefix: cfg.TablePrefix, WidgetRegistry: wreg, PolicyStore: wpStore})
+	fields := &handler.CustomFieldHandler{DB: db, Mongo: mongoCli, Driver: driver, Dialect: dialect, Recorder: rec, Schema: schema, TablePrefix: cfg.TablePrefix, WidgetRegistry: wreg, PolicyStore: wpStore}
+	handler.Register(api, fields)
 	handler.RegisterWidgetPolicy(api, &handler.WidgetPolicyHandler{Store: wpStore, Registry: wreg, PolicyPath: policyPath})
 	handler.RegisterCustomFieldValidators(api)
-	handler.RegisterRegistry(api, &handler.RegistryHandler{DB: db, Driver: driver, DSN: dsn, Recorder: rec, TablePrefix: cfg.TablePrefix})
-	handler.RegisterSnapshot(api, &handler.SnapshotHandler{DB: db, Driver: driver, Dialect: dialect, DSN: dsn, Recorder: rec, TablePrefix: cfg.TablePrefix})
-	handler.RegisterAudit(api, &handler.AuditHandler{DB: db, Dialect: dialect, TablePrefix: cfg.TablePrefix})
+	targetMeta := sqlmetastore.NewSQLMetaStoreWithPrefix(db, driver, schema, cfg.TablePrefix)
+	targetCtx, stopTargets := context.WithCancel(context.Background())
+	targetSig, closeSig := targetSignal(targetCtx, cfg, targetMeta)
+	cleanup := func() {
+		stopTargets()
+		closeSig()
+	}
+	sdkSvc, targetHealth := targetService(targetCtx, rec, targetMeta, targetSig)
+	handler.RegisterRegistry(api, &handler.RegistryHandler{DB: db, Driver: driver, Dialect: dialect, DSN: dsn, Recorder: rec, TablePrefix: cfg.TablePrefix, WidgetRegistry: wreg, Service: sdkSvc})
+	var can func(context.Context, string) bool
+	if e != nil {
+		can = authz{Enf: e, Resolve: resolver}.HasCapability
+	}
+	bundles := &snapshotbundle.Service{
+		DB:          db,
+		Driver:      driver,
+		Dialect:     dialect,
+		DSN:         dsn,
+		TablePrefix: cfg.TablePrefix,
+		Widgets:     newWidgetsRepo(db, driver, cfg.TablePrefix),
+		Policy:      wpStore,
+		Targets:     sqlmetastore.NewSQLMetaStoreWithPrefix(db, driver, schema, cfg.TablePrefix),
+		Recorder:    rec,
+	}
+	if e != nil {
+		bundles.ReloadRBAC = enforcerReloader(e, db, dialect, cfg.TablePrefix)
+	}
+	handler.RegisterSnapshot(api, &handler.SnapshotHandler{DB: db, Driver: driver, Dialect: dialect, DSN: dsn, Recorder: rec, TablePrefix: cfg.TablePrefix, Bundles: bundles, Can: can, Service: sdkSvc})
+	handler.RegisterEvents(api, &handler.EventsHandler{
+		SchemaBaseURL:  evtConf.SchemaBaseURL,
+		Webhooks:       &events.WebhookStore{DB: db, Dialect: dialect, TablePrefix: cfg.TablePrefix},
+		WebhookConfig:  evtConf.TenantWebhooks,
+		Replayer:       replayer,
+		Outbox:         events.Default.Outbox(),
+		Stream:         evtConf.Stream,
+		AllowedOrigins: allowedOrigins(),
+		Can:            can,
+		Recorder:       rec,
+	})
+	handler.RegisterAudit(api, &handler.AuditHandler{DB: db, Dialect: dialect, TablePrefix: cfg.TablePrefix, Fields: fields})
 	handler.RegisterRBAC(api, &handler.RBACHandler{DB: db, Dialect: dialect, PasswordCost: bcrypt.DefaultCost, TablePrefix: cfg.TablePrefix, Recorder: rec})
 	handler.RegisterMetadata(api, &handler.MetadataHandler{DB: db, Dialect: dialect, TablePrefix: cfg.TablePrefix})
 	dbRepo := &monitordb.Repo{DB: db, Driver: driver, Dialect: dialect, TablePrefix: cfg.TablePrefix}
@@ -130,15 +184,15 @@ func New(db *sql.DB, cfg DBConfig) huma.API {
 	handler.RegisterDatabase(api, &handler.DatabaseHandler{Repo: dbRepo, Recorder: rec, Enf: e, Capabilities: capSvc})
 	handler.RegisterPlugins(api, &handler.PluginHandler{UC: plugin.Usecase{Repo: &fsrepo.Repository{}}})
 
-	setupPluginRoutes(api, r, db, driver, cfg.TablePrefix, wreg, e, resolver)
-	// simple scope middleware placeholder; integrates with JWT claims if available
-	scope := func(scopes ...string) func(huma.Context, func(huma.Context)) {
-		return func(ctx huma.Context, next func(huma.Context)) {
-			next(ctx)
-		}
-	}
-	admintargets.RegisterRoutes(api, admintargets.Deps{Meta: sqlmetastore.NewSQLMetaStore(db, driver, schema), Rec: rec, Auth: scope})
-	return api
+	setupPluginRoutes(api, r, db, driver, cfg.TablePrefix, wreg, e, resolver, rec)
+	admintargets.RegisterRoutes(api, admintargets.Deps{
+		Meta:   targetMeta,
+		Rec:    rec,
+		Auth:   scopeAuth(api, e, resolver),
+		Health: targetHealth,
+		Notify: targetNotify(targetSig),
+	})
+	return api, cleanup
 }
 
 type authz struct {
@@ -154,6 +208,15 @@ func (a authz) HasCapability(ctx context.Context, capKey string) bool {
 	if !ok {
 		return false
 	}
+	return a.Allows(ctx, capDef.Method, capDef.Path)
+}
+
+// Allows reports whether the policy lets the caller or one of its roles call
+// method on path.
+func (a authz) Allows(ctx context.Context, method, path string) bool {
+	if a.Enf == nil {
+		return false
+	}
 	user := middleware.UserFromContext(ctx)
 	subjects := []string{user}
 	if a.Resolve != nil {
@@ -162,7 +225,7 @@ func (a authz) HasCapability(ctx context.Context, capKey string) bool {
 		}
 	}
 	for _, s := range subjects {
-		if ok, _ := a.Enf.Enforce(s, capDef.Path, capDef.Method); ok {
+		if ok, _ := a.Enf.Enforce(s, path, method); ok {
 			return true
 		}
 	}
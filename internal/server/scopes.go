@@ -0,0 +1,55 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/faciam-dev/gcfm/internal/logger"
+	"github.com/faciam-dev/gcfm/internal/server/middleware"
+	"gopkg.in/yaml.v3"
+)
+
+// scopesConfig is the file named by CF_SCOPES_CONFIG:
+//
+//	roles:
+//	  operator: [admin:targets]
+//	  platform: [admin:targets:write]
+type scopesConfig struct {
+	Roles map[string][]string `yaml:"roles"`
+}
+
+// loadRoleScopes reads the role to scope mapping from path. An empty path
+// yields no mapping.
+func loadRoleScopes(path string) (map[string][]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Clean(path)) // #nosec G304 -- path cleaned prior to read
+	if err != nil {
+		return nil, err
+	}
+	var c scopesConfig
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return c.Roles, nil
+}
+
+// scopeAuth returns the scope middleware factory used by the admin routes.
+func scopeAuth(api huma.API, e *casbin.Enforcer, resolver middleware.RoleResolver) func(scopes ...string) func(huma.Context, func(huma.Context)) {
+	roleScopes, err := loadRoleScopes(os.Getenv("CF_SCOPES_CONFIG"))
+	if err != nil {
+		logger.L.Error("Failed to load scopes configuration", "err", err)
+		os.Exit(1)
+	}
+	p := middleware.ScopePolicy{
+		RoleScopes: roleScopes,
+		Roles:      resolver,
+	}
+	if e != nil {
+		p.Allowed = authz{Enf: e, Resolve: resolver}.Allows
+	}
+	return middleware.RequireScopes(api, p)
+}
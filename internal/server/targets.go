@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/faciam-dev/gcfm/internal/api/handler"
+	"github.com/faciam-dev/gcfm/internal/logger"
+	metapkg "github.com/faciam-dev/gcfm/meta"
+	"github.com/faciam-dev/gcfm/pkg/audit"
+	"github.com/faciam-dev/gcfm/sdk"
+)
+
+// targetsPollInterval is how often the shared service rereads the targets
+// when only TARGETS_NOTIFY_BACKEND is set; signals deliver changes sooner.
+const targetsPollInterval = time.Minute
+
+// targetsRelayInterval is how often the Redis signal checks the targets
+// version for changes made without publishing them.
+const targetsRelayInterval = 5 * time.Second
+
+// targetSignal returns the target change signal selected by
+// TARGETS_NOTIFY_BACKEND and a function releasing it: "postgres" listens for
+// the notifications of a PostgreSQL meta database, "redis" uses REDIS_URL and
+// TARGETS_REDIS_CHANNEL and relays the changes of meta until ctx is done. It
+// returns nil when no backend is set.
+func targetSignal(ctx context.Context, cfg DBConfig, meta metapkg.MetaStore) (sdk.TargetSignal, func()) {
+	switch b := os.Getenv("TARGETS_NOTIFY_BACKEND"); b {
+	case "":
+		return nil, func() {}
+	case "postgres":
+		if cfg.Driver != "postgres" {
+			logger.L.Error("TARGETS_NOTIFY_BACKEND=postgres requires a PostgreSQL database", "driver", cfg.Driver)
+			os.Exit(1)
+		}
+		return sdk.PostgresTargetSignal{DSN: cfg.DSN, TablePrefix: cfg.TablePrefix}, func() {}
+	case "redis":
+		opt, err := redis.ParseURL(os.Getenv("REDIS_URL"))
+		if err != nil {
+			logger.L.Error("parse redis url", "err", err)
+			os.Exit(1)
+		}
+		client := redis.NewClient(opt)
+		sig := sdk.RedisTargetSignal{Client: client, Channel: os.Getenv("TARGETS_REDIS_CHANNEL")}
+		go sig.Relay(ctx, meta, targetsRelayInterval)
+		return sig, func() { _ = client.Close() }
+	default:
+		logger.L.Error("Invalid TARGETS_NOTIFY_BACKEND", "value", b)
+		os.Exit(1)
+		return nil, nil
+	}
+}
+
+// targetNotify returns the admin API hook publishing committed target
+// changes, which only the Redis signal needs. Other writers are covered by
+// the relay, a little later.
+func targetNotify(sig sdk.TargetSignal) func(context.Context, string) {
+	rs, ok := sig.(sdk.RedisTargetSignal)
+	if !ok {
+		return nil
+	}
+	return func(ctx context.Context, ver string) {
+		if err := rs.Publish(ctx, ver); err != nil {
+			logger.L.Warn("publish target change", "err", err)
+		}
+	}
+}
+
+// targetService returns the SDK service shared by the API handlers. When
+// TARGET_HEALTH_INTERVAL or sig is set it follows the targets stored in meta
+// until ctx is done, refetching on sig and every interval. With
+// TARGET_HEALTH_INTERVAL it also probes them every interval and returns their
+// health for the admin API; otherwise the health is nil.
+func targetService(ctx context.Context, rec *audit.Recorder, meta metapkg.MetaStore, sig sdk.TargetSignal) (sdk.Service, func() []sdk.TargetHealth) {
+	cfg := sdk.ServiceConfig{Recorder: rec, Events: handler.SDKEvents}
+	v := os.Getenv("TARGET_HEALTH_INTERVAL")
+	if v == "" {
+		svc := sdk.New(cfg)
+		if sig != nil {
+			svc.StartTargetWatcher(ctx, sdk.NewWatchingMetaDBProvider(meta, sig), targetsPollInterval)
+		}
+		return svc, nil
+	}
+	interval, err := time.ParseDuration(v)
+	if err != nil || interval <= 0 {
+		logger.L.Error("Invalid TARGET_HEALTH_INTERVAL", "value", v, "err", err)
+		os.Exit(1)
+	}
+	cfg.Failover = sdk.FailoverPolicy{OpenAfterFailures: 3, OpenDuration: interval}
+	svc := sdk.New(cfg)
+	svc.StartTargetWatcher(ctx, sdk.NewWatchingMetaDBProvider(meta, sig), interval)
+	svc.StartHealthProber(ctx, sdk.HealthProbeConfig{Interval: interval, JitterRatio: 0.1})
+	return svc, svc.TargetHealth
+}
@@ -0,0 +1,458 @@
+// Package snapshotbundle collects and restores tenant configuration bundles
+// (see snapshot.Bundle) from the metadata database.
+package snapshotbundle
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/faciam-dev/gcfm/internal/logger"
+	widgetsrepo "github.com/faciam-dev/gcfm/internal/repository/widgets"
+	metapkg "github.com/faciam-dev/gcfm/meta"
+	"github.com/faciam-dev/gcfm/pkg/audit"
+	"github.com/faciam-dev/gcfm/pkg/registry"
+	"github.com/faciam-dev/gcfm/pkg/snapshot"
+	tenantpkg "github.com/faciam-dev/gcfm/pkg/tenant"
+	"github.com/faciam-dev/gcfm/pkg/widgetpolicy"
+	"github.com/faciam-dev/gcfm/sdk"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+	"github.com/faciam-dev/goquent/orm/query"
+)
+
+// Service reads and writes the configuration captured in bundles. Widgets,
+// Policy and Targets are optional; parts depending on a missing source are
+// skipped when collecting and rejected when restoring.
+type Service struct {
+	DB          *sql.DB
+	Driver      string
+	Dialect     ormdriver.Dialect
+	DSN         string
+	TablePrefix string
+	Widgets     widgetsrepo.Repo
+	Policy      *widgetpolicy.Store
+	Targets     metapkg.MetaStore
+	Recorder    *audit.Recorder
+	// ReloadRBAC, when set, is called after a restore that changed roles so
+	// that the running enforcer picks up the restored policies.
+	ReloadRBAC func(ctx context.Context) error
+}
+
+// ErrPartUnavailable is returned by Restore when a requested part is missing
+// from the bundle or cannot be restored by the service.
+var ErrPartUnavailable = errors.New("bundle part unavailable")
+
+// ErrGlobalPart is returned by Restore when a part shared by every tenant is
+// requested without RestoreOptions.Global.
+var ErrGlobalPart = errors.New("bundle part is shared by all tenants")
+
+// GlobalParts lists the parts whose configuration is shared by every tenant.
+// Restoring them from one tenant's bundle changes every other tenant too.
+var GlobalParts = []string{snapshot.PartWidgetPolicies, snapshot.PartRBAC, snapshot.PartTargets}
+
+// IsGlobalPart reports whether part is one of GlobalParts.
+func IsGlobalPart(part string) bool { return slices.Contains(GlobalParts, part) }
+
+// RestoreOptions controls Restore.
+type RestoreOptions struct {
+	Actor string
+	// Global allows restoring GlobalParts and the definitions of widgets.
+	// Without it those parts are rejected and the widgets part only
+	// restores whether existing widgets are enabled for the tenant.
+	Global bool
+}
+
+// RestoreReport summarises a bundle restore.
+type RestoreReport struct {
+	Parts    []string       `json:"parts"`
+	Registry sdk.DiffReport `json:"registry"`
+	// Skipped lists items that could not be restored, such as target labels
+	// for targets that do not exist in the destination.
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+func (s *Service) t(name string) string { return s.TablePrefix + name }
+
+// Collect captures the configuration of tenant. RBAC roles and target labels
+// are global and therefore identical for every tenant.
+func (s *Service) Collect(ctx context.Context, tenant string) (*snapshot.Bundle, error) {
+	b := &snapshot.Bundle{Manifest: snapshot.BundleManifest{Tenant: tenant}}
+	var err error
+	if b.Registry, err = snapshot.SnapshotYaml(ctx, s.DB, s.Driver, s.TablePrefix, tenant); err != nil {
+		return nil, fmt.Errorf("registry: %w", err)
+	}
+	if s.Policy != nil {
+		b.WidgetPolicy = s.Policy.Get()
+	}
+	if s.Widgets != nil {
+		if b.Widgets, err = s.collectWidgets(ctx, tenant); err != nil {
+			return nil, fmt.Errorf("widgets: %w", err)
+		}
+	}
+	if b.Roles, err = s.collectRoles(ctx); err != nil {
+		return nil, fmt.Errorf("rbac: %w", err)
+	}
+	if b.Databases, err = s.collectDatabases(ctx, tenant); err != nil {
+		return nil, fmt.Errorf("databases: %w", err)
+	}
+	if s.Targets != nil {
+		if b.TargetLabels, err = s.collectTargets(ctx); err != nil {
+			return nil, fmt.Errorf("targets: %w", err)
+		}
+	}
+	return b, nil
+}
+
+// Restore applies the selected parts of b to tenant. Every requested part
+// must be present in the bundle, have a configured destination and, for
+// GlobalParts, be allowed by opts; nothing is written otherwise.
+//
+// All database writes happen in one transaction. The widget policy file is
+// written, audit entries are recorded and RBAC is reloaded once it has
+// committed.
+func (s *Service) Restore(ctx context.Context, tenant string, b *snapshot.Bundle, parts []string, opts RestoreOptions) (RestoreReport, error) {
+	rep := RestoreReport{}
+	var widgets widgetsrepo.TxRepo
+	for _, p := range parts {
+		if !b.Has(p) {
+			return rep, fmt.Errorf("%w: bundle does not contain %s", ErrPartUnavailable, p)
+		}
+		if IsGlobalPart(p) && !opts.Global {
+			return rep, fmt.Errorf("%w: restoring %s requires permission to change it for every tenant", ErrGlobalPart, p)
+		}
+		if p == snapshot.PartWidgets && s.Widgets != nil {
+			widgets, _ = s.Widgets.(widgetsrepo.TxRepo)
+		}
+		switch {
+		case p == snapshot.PartWidgetPolicies && s.Policy == nil,
+			p == snapshot.PartWidgets && widgets == nil,
+			p == snapshot.PartTargets && s.Targets == nil:
+			return rep, fmt.Errorf("%w: restoring %s is not supported by this server", ErrPartUnavailable, p)
+		}
+	}
+	var existingTargets map[string]metapkg.TargetRow
+	if slices.Contains(parts, snapshot.PartTargets) {
+		rows, _, _, err := s.Targets.ListTargets(ctx)
+		if err != nil {
+			return rep, fmt.Errorf("%s: %w", snapshot.PartTargets, err)
+		}
+		existingTargets = make(map[string]metapkg.TargetRow, len(rows))
+		for _, r := range rows {
+			existingTargets[r.Key] = r.TargetRow
+		}
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return rep, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	var changes []registry.Change
+	for _, p := range parts {
+		var (
+			skipped []string
+			err     error
+		)
+		switch p {
+		case snapshot.PartRegistry:
+			changes, err = snapshot.ApplyYamlTx(ctx, tx, s.Driver, s.TablePrefix, tenant, b.Registry)
+			rep.Registry = sdk.CalculateDiff(changes)
+		case snapshot.PartWidgets:
+			skipped, err = s.restoreWidgets(ctx, tx, widgets, tenant, b.Widgets, opts.Global)
+		case snapshot.PartRBAC:
+			err = s.restoreRoles(ctx, tx, b.Roles)
+		case snapshot.PartDatabases:
+			err = s.restoreDatabases(ctx, tx, tenant, b.Databases)
+		case snapshot.PartTargets:
+			skipped, err = s.restoreTargets(ctx, tx, existingTargets, b.TargetLabels)
+		}
+		if err != nil {
+			return RestoreReport{}, fmt.Errorf("%s: %w", p, err)
+		}
+		rep.Skipped = append(rep.Skipped, skipped...)
+	}
+	if err := tx.Commit(); err != nil {
+		return RestoreReport{}, err
+	}
+
+	ctx = tenantpkg.WithTenant(ctx, tenant)
+	for _, c := range changes {
+		_ = s.Recorder.Write(ctx, opts.Actor, c.Old, c.New)
+	}
+	for _, p := range parts {
+		if p == snapshot.PartWidgetPolicies {
+			// The policy lives in a file and cannot join the transaction.
+			if err := s.Policy.Save(b.WidgetPolicy); err != nil {
+				return rep, fmt.Errorf("%s: %w", p, err)
+			}
+		}
+		rep.Parts = append(rep.Parts, p)
+	}
+	if slices.Contains(parts, snapshot.PartRBAC) && s.ReloadRBAC != nil {
+		if err := s.ReloadRBAC(ctx); err != nil {
+			logger.L.Error("reload rbac after bundle restore", "err", err)
+		}
+	}
+	if len(rep.Parts) > 0 {
+		_ = s.Recorder.Record(ctx, audit.Event{
+			ResourceType: audit.ResourceSnapshot,
+			Action:       "bundle_restore",
+			Actor:        opts.Actor,
+			Tenant:       tenant,
+			After:        map[string]any{"parts": rep.Parts, "skipped": rep.Skipped},
+		})
+	}
+	return rep, nil
+}
+
+func (s *Service) collectWidgets(ctx context.Context, tenant string) ([]snapshot.BundleWidget, error) {
+	rows, _, err := s.Widgets.List(ctx, widgetsrepo.Filter{Tenant: tenant})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]snapshot.BundleWidget, 0, len(rows))
+	for _, r := range rows {
+		w := snapshot.BundleWidget{
+			ID:            r.ID,
+			Name:          r.Name,
+			Version:       r.Version,
+			Type:          r.Type,
+			Scopes:        r.Scopes,
+			Enabled:       r.Enabled,
+			Capabilities:  r.Capabilities,
+			Meta:          r.Meta,
+			TenantScope:   r.TenantScope,
+			Tenants:       r.Tenants,
+			TenantEnabled: !slices.Contains(r.DisabledTenants, tenant),
+		}
+		if r.Description != nil {
+			w.Description = *r.Description
+		}
+		if r.Homepage != nil {
+			w.Homepage = *r.Homepage
+		}
+		out = append(out, w)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// restoreWidgets sets whether each widget is enabled for tenant. Widget
+// definitions are shared by every tenant and are only written when global is
+// set; otherwise widgets missing from the destination are returned as
+// skipped.
+func (s *Service) restoreWidgets(ctx context.Context, tx *sql.Tx, repo widgetsrepo.TxRepo, tenant string, widgets []snapshot.BundleWidget, global bool) ([]string, error) {
+	var skipped []string
+	for _, w := range widgets {
+		if global {
+			row := widgetsrepo.Row{
+				ID:           w.ID,
+				Name:         w.Name,
+				Version:      w.Version,
+				Type:         w.Type,
+				Scopes:       w.Scopes,
+				Enabled:      w.Enabled,
+				Capabilities: w.Capabilities,
+				Meta:         w.Meta,
+				TenantScope:  w.TenantScope,
+				Tenants:      w.Tenants,
+			}
+			if w.Description != "" {
+				row.Description = &w.Description
+			}
+			if w.Homepage != "" {
+				row.Homepage = &w.Homepage
+			}
+			if err := repo.UpsertTx(ctx, tx, row); err != nil {
+				return skipped, fmt.Errorf("widget %s: %w", w.ID, err)
+			}
+		}
+		err := repo.SetTenantEnabledTx(ctx, tx, w.ID, tenant, w.TenantEnabled)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			skipped = append(skipped, "widget "+w.ID)
+		case err != nil:
+			return skipped, fmt.Errorf("widget %s: %w", w.ID, err)
+		}
+	}
+	return skipped, nil
+}
+
+func (s *Service) collectRoles(ctx context.Context) ([]snapshot.BundleRole, error) {
+	var roles []struct {
+		ID      int64          `db:"id"`
+		Name    string         `db:"name"`
+		Comment sql.NullString `db:"comment"`
+	}
+	if err := query.New(s.DB, s.t("roles"), s.Dialect).
+		Select("id", "name", "comment").
+		OrderBy("name", "asc").
+		WithContext(ctx).
+		Get(&roles); err != nil {
+		return nil, err
+	}
+	var pols []struct {
+		RoleID int64  `db:"role_id"`
+		Path   string `db:"path"`
+		Method string `db:"method"`
+	}
+	if err := query.New(s.DB, s.t("role_policies"), s.Dialect).
+		Select("role_id", "path", "method").
+		OrderBy("path", "asc").
+		OrderBy("method", "asc").
+		WithContext(ctx).
+		Get(&pols); err != nil {
+		return nil, err
+	}
+	byRole := map[int64][]snapshot.BundlePolicy{}
+	for _, p := range pols {
+		byRole[p.RoleID] = append(byRole[p.RoleID], snapshot.BundlePolicy{Path: p.Path, Method: p.Method})
+	}
+	out := make([]snapshot.BundleRole, 0, len(roles))
+	for _, r := range roles {
+		out = append(out, snapshot.BundleRole{Name: r.Name, Comment: r.Comment.String, Policies: byRole[r.ID]})
+	}
+	return out, nil
+}
+
+// restoreRoles creates missing roles and replaces the policies of every role
+// in the bundle. Roles absent from the bundle are left untouched so that
+// user assignments are never dropped implicitly.
+func (s *Service) restoreRoles(ctx context.Context, tx *sql.Tx, roles []snapshot.BundleRole) error {
+	for _, r := range roles {
+		var row struct {
+			ID int64 `db:"id"`
+		}
+		err := query.New(tx, s.t("roles"), s.Dialect).Select("id").Where("name", r.Name).WithContext(ctx).First(&row)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			data := map[string]any{"name": r.Name}
+			if r.Comment != "" {
+				data["comment"] = r.Comment
+			}
+			if row.ID, err = query.New(tx, s.t("roles"), s.Dialect).WithContext(ctx).InsertGetId(data); err != nil {
+				return fmt.Errorf("role %s: %w", r.Name, err)
+			}
+		case err != nil:
+			return fmt.Errorf("role %s: %w", r.Name, err)
+		default:
+			if _, err := query.New(tx, s.t("roles"), s.Dialect).Where("id", row.ID).WithContext(ctx).
+				Update(map[string]any{"comment": sql.NullString{String: r.Comment, Valid: r.Comment != ""}}); err != nil {
+				return fmt.Errorf("role %s: %w", r.Name, err)
+			}
+		}
+		if _, err := query.New(tx, s.t("role_policies"), s.Dialect).Where("role_id", row.ID).WithContext(ctx).Delete(); err != nil {
+			return fmt.Errorf("role %s: %w", r.Name, err)
+		}
+		if len(r.Policies) == 0 {
+			continue
+		}
+		batch := make([]map[string]any, 0, len(r.Policies))
+		for _, p := range r.Policies {
+			batch = append(batch, map[string]any{"role_id": row.ID, "path": p.Path, "method": p.Method})
+		}
+		if _, err := query.New(tx, s.t("role_policies"), s.Dialect).WithContext(ctx).InsertBatch(batch); err != nil {
+			return fmt.Errorf("role %s: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s *Service) collectDatabases(ctx context.Context, tenant string) ([]snapshot.BundleDatabase, error) {
+	var rows []struct {
+		Name   string         `db:"name"`
+		Driver string         `db:"driver"`
+		Schema sql.NullString `db:"schema_name"`
+	}
+	if err := query.New(s.DB, s.t("monitored_databases"), s.Dialect).
+		Select("name", "driver", "schema_name").
+		Where("tenant_id", tenant).
+		OrderBy("name", "asc").
+		WithContext(ctx).
+		Get(&rows); err != nil {
+		return nil, err
+	}
+	out := make([]snapshot.BundleDatabase, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, snapshot.BundleDatabase{Name: r.Name, Driver: r.Driver, Schema: r.Schema.String})
+	}
+	return out, nil
+}
+
+// restoreDatabases creates missing monitored databases without a connection
+// string and updates the driver and schema of existing ones. Stored
+// credentials are never modified.
+func (s *Service) restoreDatabases(ctx context.Context, tx *sql.Tx, tenant string, dbs []snapshot.BundleDatabase) error {
+	for _, d := range dbs {
+		schema := sql.NullString{String: d.Schema, Valid: d.Schema != ""}
+		var row struct {
+			ID int64 `db:"id"`
+		}
+		err := query.New(tx, s.t("monitored_databases"), s.Dialect).
+			Select("id").
+			Where("tenant_id", tenant).
+			Where("name", d.Name).
+			WithContext(ctx).
+			First(&row)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			_, err = query.New(tx, s.t("monitored_databases"), s.Dialect).WithContext(ctx).Insert(map[string]any{
+				"tenant_id":   tenant,
+				"name":        d.Name,
+				"driver":      d.Driver,
+				"schema_name": schema,
+			})
+		case err == nil:
+			_, err = query.New(tx, s.t("monitored_databases"), s.Dialect).
+				Where("id", row.ID).
+				WithContext(ctx).
+				Update(map[string]any{"driver": d.Driver, "schema_name": schema})
+		}
+		if err != nil {
+			return fmt.Errorf("database %s: %w", d.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s *Service) collectTargets(ctx context.Context) ([]snapshot.BundleTargetLabels, error) {
+	rows, _, _, err := s.Targets.ListTargets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]snapshot.BundleTargetLabels, 0, len(rows))
+	for _, r := range rows {
+		labels := append([]string{}, r.Labels...)
+		sort.Strings(labels)
+		out = append(out, snapshot.BundleTargetLabels{Key: r.Key, Labels: labels})
+	}
+	return out, nil
+}
+
+// restoreTargets replaces the labels of existing targets. Targets are not
+// created because bundles carry no connection strings; their keys are
+// returned as skipped.
+func (s *Service) restoreTargets(ctx context.Context, tx *sql.Tx, existing map[string]metapkg.TargetRow, targets []snapshot.BundleTargetLabels) ([]string, error) {
+	var skipped []string
+	changed := false
+	for _, t := range targets {
+		row, ok := existing[t.Key]
+		if !ok {
+			skipped = append(skipped, "target "+t.Key)
+			continue
+		}
+		if err := s.Targets.UpsertTarget(ctx, tx, row, t.Labels); err != nil {
+			return skipped, fmt.Errorf("target %s: %w", t.Key, err)
+		}
+		changed = true
+	}
+	if changed {
+		if _, err := s.Targets.BumpTargetsVersion(ctx, tx); err != nil {
+			return skipped, err
+		}
+	}
+	return skipped, nil
+}
@@ -0,0 +1,106 @@
+package snapshotbundle
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/faciam-dev/gcfm/pkg/snapshot"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+)
+
+func bundleWith(parts ...string) *snapshot.Bundle {
+	b := &snapshot.Bundle{}
+	for _, p := range parts {
+		b.Manifest.Documents = append(b.Manifest.Documents, snapshot.BundleEntry{Name: p + ".yaml"})
+	}
+	return b
+}
+
+func TestRestoreRejectsGlobalPartsWithoutPermission(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	s := &Service{DB: db, Driver: "mysql", Dialect: ormdriver.MySQLDialect{}}
+	b := bundleWith(snapshot.PartRBAC)
+	b.Roles = []snapshot.BundleRole{{Name: "editor"}}
+
+	_, err = s.Restore(context.Background(), "t1", b, []string{snapshot.PartRBAC}, RestoreOptions{Actor: "alice"})
+	if !errors.Is(err, ErrGlobalPart) {
+		t.Fatalf("err = %v, want ErrGlobalPart", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unexpected queries: %v", err)
+	}
+}
+
+func TestRestoreRollsBackEveryPartOnFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	reloaded := false
+	s := &Service{DB: db, Driver: "mysql", Dialect: ormdriver.MySQLDialect{}, ReloadRBAC: func(context.Context) error {
+		reloaded = true
+		return nil
+	}}
+	b := bundleWith(snapshot.PartRBAC, snapshot.PartDatabases)
+	b.Roles = []snapshot.BundleRole{{Name: "editor"}}
+	b.Databases = []snapshot.BundleDatabase{{Name: "main", Driver: "mysql"}}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .*roles").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("UPDATE .*roles").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM .*role_policies").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT .*monitored_databases").WillReturnError(errors.New("boom"))
+	mock.ExpectRollback()
+
+	_, err = s.Restore(context.Background(), "t1", b, []string{snapshot.PartRBAC, snapshot.PartDatabases}, RestoreOptions{Global: true})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if reloaded {
+		t.Fatal("rbac must not be reloaded after a failed restore")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestRestoreReloadsRBACAfterCommit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	committed := false
+	s := &Service{DB: db, Driver: "mysql", Dialect: ormdriver.MySQLDialect{}, ReloadRBAC: func(context.Context) error {
+		committed = mock.ExpectationsWereMet() == nil
+		return nil
+	}}
+	b := bundleWith(snapshot.PartRBAC)
+	b.Roles = []snapshot.BundleRole{{Name: "editor", Policies: []snapshot.BundlePolicy{{Path: "/v1/custom-fields", Method: "GET"}}}}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .*roles").WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec("INSERT INTO .*roles").WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectExec("DELETE FROM .*role_policies").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO .*role_policies").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	rep, err := s.Restore(context.Background(), "t1", b, []string{snapshot.PartRBAC}, RestoreOptions{Global: true})
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if !committed {
+		t.Fatal("rbac was not reloaded after commit")
+	}
+	if len(rep.Parts) != 1 || rep.Parts[0] != snapshot.PartRBAC {
+		t.Fatalf("parts = %v", rep.Parts)
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"time"
 
 	huma "github.com/faciam-dev/gcfm/internal/huma"
+	"github.com/faciam-dev/gcfm/internal/server/middleware"
 	"github.com/faciam-dev/gcfm/internal/service/plugins"
+	"github.com/faciam-dev/gcfm/pkg/audit"
 )
 
 // Authz defines capability checks used by handlers.
@@ -26,6 +28,7 @@ type Handlers struct {
 	Auth           Authz
 	Cfg            Config
 	PluginUploader *plugins.Uploader
+	Recorder       *audit.Recorder
 }
 
 // UploadPluginResponse represents response body.
@@ -122,6 +125,15 @@ func (h *Handlers) uploadPlugin(ctx context.Context, in *uploadPluginInput) (*up
 	}
 
 	dto := toWidgetDTO(w)
+	if h.Recorder != nil {
+		_ = h.Recorder.Record(ctx, audit.Event{
+			ResourceType: audit.ResourcePlugin,
+			ResourceID:   w.ID,
+			Action:       "upload",
+			Actor:        middleware.UserFromContext(ctx),
+			After:        dto,
+		})
+	}
 	out := &uploadPluginOutput{Body: UploadPluginResponse{OK: true, Widget: dto}}
 	out.Headers.XUploadedSize = strconv.FormatInt(w.PackageSize, 10)
 	return out, nil
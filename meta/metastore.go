@@ -3,6 +3,7 @@ package meta
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/faciam-dev/gcfm/pkg/registry"
@@ -32,6 +33,9 @@ type TargetRow struct {
 	ConnMaxIdle  time.Duration
 	ConnMaxLife  time.Duration
 	IsDefault    bool
+	// Draining targets receive no new work. It is reported by ListTargets
+	// and only changed by SetTargetDraining.
+	Draining bool
 }
 
 // TargetRowWithLabels combines a target row with its labels.
@@ -40,6 +44,24 @@ type TargetRowWithLabels struct {
 	Labels []string
 }
 
+// ErrTargetNotFound is returned when a change names a target that does not
+// exist.
+var ErrTargetNotFound = errors.New("target not found")
+
+// DefaultTablePrefix prefixes the meta tables unless another prefix is set.
+const DefaultTablePrefix = "gcfm_"
+
+// TargetsChannel returns the PostgreSQL NOTIFY channel on which
+// BumpTargetsVersion announces the new targets version of the meta tables
+// named with tablePrefix, so deployments sharing a database do not signal
+// each other.
+func TargetsChannel(tablePrefix string) string {
+	if tablePrefix == "" {
+		tablePrefix = DefaultTablePrefix
+	}
+	return tablePrefix + "targets"
+}
+
 // MetaStore abstracts persistence of metadata including custom fields and targets.
 type MetaStore interface {
 	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
@@ -52,5 +74,6 @@ type MetaStore interface {
 	DeleteTarget(ctx context.Context, tx *sql.Tx, key string) error
 	ListTargets(ctx context.Context) ([]TargetRowWithLabels, string, string, error)
 	SetDefaultTarget(ctx context.Context, tx *sql.Tx, key string) error
+	SetTargetDraining(ctx context.Context, tx *sql.Tx, key string, draining bool) error
 	BumpTargetsVersion(ctx context.Context, tx *sql.Tx) (string, error)
 }
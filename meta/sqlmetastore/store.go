@@ -3,6 +3,7 @@ package sqlmetastore
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -19,11 +20,21 @@ type SQLMetaStore struct {
 	db     *sql.DB
 	driver string
 	schema string
+	prefix string
 }
 
 // NewSQLMetaStore initializes a SQLMetaStore with the given connection.
 func NewSQLMetaStore(db *sql.DB, driver, schema string) *SQLMetaStore {
-	return &SQLMetaStore{db: db, driver: driver, schema: schema}
+	return NewSQLMetaStoreWithPrefix(db, driver, schema, metapkg.DefaultTablePrefix)
+}
+
+// NewSQLMetaStoreWithPrefix initializes a SQLMetaStore whose tables are named
+// with prefix instead of gcfm_.
+func NewSQLMetaStoreWithPrefix(db *sql.DB, driver, schema, prefix string) *SQLMetaStore {
+	if prefix == "" {
+		prefix = metapkg.DefaultTablePrefix
+	}
+	return &SQLMetaStore{db: db, driver: driver, schema: schema, prefix: prefix}
 }
 
 // BeginTx starts a transaction using the underlying database.
@@ -33,7 +44,7 @@ func (s *SQLMetaStore) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.T
 
 // table returns a fully qualified table name for metadata tables.
 func (s *SQLMetaStore) table(name string) string {
-	tbl := "gcfm_" + name
+	tbl := s.prefix + name
 	if s.schema != "" {
 		return fmt.Sprintf("%s.%s", s.schema, tbl)
 	}
@@ -362,7 +373,7 @@ func (s *SQLMetaStore) ListTargets(ctx context.Context) ([]metapkg.TargetRowWith
 	lblTbl := s.table("target_labels")
 	verTbl := s.table("target_config_version")
 
-	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`SELECT t.key, t.driver, t.dsn, t.schema_name, t.max_open_conns, t.max_idle_conns, t.conn_max_idle_ms, t.conn_max_life_ms, t.is_default, l.label FROM %s t LEFT JOIN %s l ON t.key=l.key ORDER BY t.key`, tbl, lblTbl))
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`SELECT t.key, t.driver, t.dsn, t.schema_name, t.max_open_conns, t.max_idle_conns, t.conn_max_idle_ms, t.conn_max_life_ms, t.is_default, t.draining, l.label FROM %s t LEFT JOIN %s l ON t.key=l.key ORDER BY t.key`, tbl, lblTbl))
 	if err != nil {
 		return nil, "", "", err
 	}
@@ -373,7 +384,7 @@ func (s *SQLMetaStore) ListTargets(ctx context.Context) ([]metapkg.TargetRowWith
 	for rows.Next() {
 		var r metapkg.TargetRowWithLabels
 		var label sql.NullString
-		if err := rows.Scan(&r.Key, &r.Driver, &r.DSN, &r.Schema, &r.MaxOpenConns, &r.MaxIdleConns, &r.ConnMaxIdle, &r.ConnMaxLife, &r.IsDefault, &label); err != nil {
+		if err := rows.Scan(&r.Key, &r.Driver, &r.DSN, &r.Schema, &r.MaxOpenConns, &r.MaxIdleConns, &r.ConnMaxIdle, &r.ConnMaxLife, &r.IsDefault, &r.Draining, &label); err != nil {
 			return nil, "", "", err
 		}
 		r.ConnMaxIdle = time.Duration(r.ConnMaxIdle) * time.Millisecond
@@ -452,6 +463,56 @@ func (s *SQLMetaStore) SetDefaultTarget(ctx context.Context, tx *sql.Tx, key str
 	return nil
 }
 
+// SetTargetDraining marks the given key as draining or not. It returns
+// metapkg.ErrTargetNotFound when no target has the key.
+func (s *SQLMetaStore) SetTargetDraining(ctx context.Context, tx *sql.Tx, key string, draining bool) error {
+	ownTx := false
+	if tx == nil {
+		var err error
+		tx, err = s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		ownTx = true
+	}
+	tbl := s.table("targets")
+	var q, exists string
+	switch s.driver {
+	case "postgres":
+		q = fmt.Sprintf("UPDATE %s SET draining=$1 WHERE key=$2", tbl)
+		exists = fmt.Sprintf("SELECT 1 FROM %s WHERE key=$1", tbl)
+	case "mysql":
+		q = fmt.Sprintf("UPDATE %s SET draining=? WHERE key=?", tbl)
+		exists = fmt.Sprintf("SELECT 1 FROM %s WHERE key=?", tbl)
+	default:
+		q = fmt.Sprintf("UPDATE %s SET draining=? WHERE key=?", tbl)
+		exists = fmt.Sprintf("SELECT 1 FROM %s WHERE key=?", tbl)
+	}
+	res, err := tx.ExecContext(ctx, q, draining, key)
+	if err == nil {
+		// MySQL does not count rows left unchanged, so a row that already
+		// had the state is looked up before reporting it missing.
+		var n int64
+		if n, err = res.RowsAffected(); err == nil && n == 0 {
+			var one int
+			err = tx.QueryRowContext(ctx, exists, key).Scan(&one)
+			if errors.Is(err, sql.ErrNoRows) {
+				err = metapkg.ErrTargetNotFound
+			}
+		}
+	}
+	if err != nil {
+		if ownTx {
+			_ = tx.Rollback()
+		}
+		return err
+	}
+	if ownTx {
+		return tx.Commit()
+	}
+	return nil
+}
+
 // BumpTargetsVersion updates and returns a new configuration version.
 func (s *SQLMetaStore) BumpTargetsVersion(ctx context.Context, tx *sql.Tx) (string, error) {
 	ownTx := false
@@ -480,6 +541,15 @@ func (s *SQLMetaStore) BumpTargetsVersion(ctx context.Context, tx *sql.Tx) (stri
 		}
 		return "", err
 	}
+	if s.driver == "postgres" {
+		// Delivered to listeners when the transaction commits.
+		if _, err := tx.ExecContext(ctx, "SELECT pg_notify($1, $2)", metapkg.TargetsChannel(s.prefix), ver); err != nil {
+			if ownTx {
+				_ = tx.Rollback()
+			}
+			return "", err
+		}
+	}
 	if ownTx {
 		if err := tx.Commit(); err != nil {
 			return "", err
@@ -3,9 +3,11 @@ package sqlmetastore
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	metapkg "github.com/faciam-dev/gcfm/meta"
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -53,6 +55,7 @@ func newTestStore(t *testing.T) *SQLMetaStore {
         conn_max_idle_ms BIGINT DEFAULT 0,
         conn_max_life_ms BIGINT DEFAULT 0,
         is_default BOOLEAN DEFAULT FALSE,
+        draining BOOLEAN NOT NULL DEFAULT FALSE,
         updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
     );
     CREATE TABLE gcfm_target_labels (
@@ -158,6 +161,24 @@ func TestSQLMetaStore_Targets(t *testing.T) {
 	if len(rows[0].Labels) == 0 {
 		t.Fatalf("labels not loaded")
 	}
+	if err := store.SetTargetDraining(ctx, nil, "b", true); err != nil {
+		t.Fatalf("set draining: %v", err)
+	}
+	if err := store.SetTargetDraining(ctx, nil, "b", true); err != nil {
+		t.Fatalf("set draining again: %v", err)
+	}
+	if err := store.SetTargetDraining(ctx, nil, "missing", true); !errors.Is(err, metapkg.ErrTargetNotFound) {
+		t.Fatalf("set draining of missing target: err = %v", err)
+	}
+	rows, _, _, err = store.ListTargets(ctx)
+	if err != nil {
+		t.Fatalf("list draining: %v", err)
+	}
+	for _, r := range rows {
+		if r.Draining != (r.Key == "b") {
+			t.Fatalf("target %s draining=%v", r.Key, r.Draining)
+		}
+	}
 	if err := store.DeleteTarget(ctx, nil, "b"); err != nil {
 		t.Fatalf("delete: %v", err)
 	}
@@ -176,3 +197,25 @@ func TestSQLMetaStore_Targets(t *testing.T) {
 		t.Fatalf("expected 1 row, got %d", len(rows))
 	}
 }
+
+func TestSQLMetaStore_BumpTargetsVersionNotifiesPrefixedChannel(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE app_target_config_version SET version=\$1`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`SELECT pg_notify\(\$1, \$2\)`).WithArgs("app_targets", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	store := NewSQLMetaStoreWithPrefix(db, "postgres", "", "app_")
+	if _, err := store.BumpTargetsVersion(context.Background(), nil); err != nil {
+		t.Fatalf("bump: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if ch := metapkg.TargetsChannel(""); ch != "gcfm_targets" {
+		t.Fatalf("default channel = %s", ch)
+	}
+}
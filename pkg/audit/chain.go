@@ -0,0 +1,490 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/faciam-dev/gcfm/pkg/tenant"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+	"github.com/faciam-dev/goquent/orm/query"
+)
+
+// ChainEntry is the content of an audit row covered by its hash.
+type ChainEntry struct {
+	TenantID     string
+	Actor        string
+	Action       string
+	TableName    string
+	ColumnName   string
+	ResourceType string
+	ResourceID   string
+	RequestID    string
+	// RevertOf is the ID of the row this change reverts, or zero.
+	RevertOf     int64
+	BeforeJSON   string
+	AfterJSON    string
+	AddedCount   int
+	RemovedCount int
+	ChangeCount  int
+	// AppliedAt is stored with second precision so that it survives the
+	// round trip through every supported column type.
+	AppliedAt time.Time
+}
+
+// Canonical returns the normalized JSON form of e. JSON payloads are parsed
+// first so that the result does not depend on how the database reformats
+// JSON columns. Resource fields and the time are only included when set so
+// that rows written before they existed keep their hash.
+func (e ChainEntry) Canonical() string {
+	m := map[string]any{
+		"tenant_id":     e.TenantID,
+		"actor":         e.Actor,
+		"action":        e.Action,
+		"table_name":    e.TableName,
+		"column_name":   e.ColumnName,
+		"before_json":   jsonValue(e.BeforeJSON),
+		"after_json":    jsonValue(e.AfterJSON),
+		"added_count":   e.AddedCount,
+		"removed_count": e.RemovedCount,
+		"change_count":  e.ChangeCount,
+	}
+	for k, v := range map[string]string{"resource_type": e.ResourceType, "resource_id": e.ResourceID, "request_id": e.RequestID} {
+		if v != "" {
+			m[k] = v
+		}
+	}
+	if e.RevertOf != 0 {
+		m["revert_of"] = e.RevertOf
+	}
+	if !e.AppliedAt.IsZero() {
+		m["applied_at"] = e.AppliedAt.UTC().Format(time.RFC3339)
+	}
+	b, _ := json.Marshal(m)
+	return NormalizeJSON(b)
+}
+
+func jsonValue(s string) any {
+	if s == "" {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	return v
+}
+
+// ChainHash returns the hex encoded SHA-256 of prev followed by the
+// canonical content of e.
+func ChainHash(prev string, e ChainEntry) string {
+	sum := sha256.Sum256([]byte(prev + "\n" + e.Canonical()))
+	return hex.EncodeToString(sum[:])
+}
+
+func entryFromRow(row map[string]any) ChainEntry {
+	str := func(k string) string {
+		switch v := row[k].(type) {
+		case string:
+			return v
+		case sql.NullString:
+			return v.String
+		}
+		return ""
+	}
+	num := func(k string) int { return int(toInt64(row[k])) }
+	appliedAt, _ := row["applied_at"].(time.Time)
+	return ChainEntry{
+		TenantID:     str("tenant_id"),
+		Actor:        str("actor"),
+		Action:       str("action"),
+		TableName:    str("table_name"),
+		ColumnName:   str("column_name"),
+		ResourceType: str("resource_type"),
+		ResourceID:   str("resource_id"),
+		RequestID:    str("request_id"),
+		RevertOf:     toInt64(row["revert_of"]),
+		BeforeJSON:   str("before_json"),
+		AfterJSON:    str("after_json"),
+		AddedCount:   num("added_count"),
+		RemovedCount: num("removed_count"),
+		ChangeCount:  num("change_count"),
+		AppliedAt:    appliedAt,
+	}
+}
+
+// toInt64 converts the integer representations used by callers and
+// returned by database drivers. Other values yield zero.
+func toInt64(v any) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case uint64:
+		return int64(n)
+	case sql.NullInt64:
+		return n.Int64
+	case []byte:
+		i, _ := strconv.ParseInt(string(n), 10, 64)
+		return i
+	case string:
+		i, _ := strconv.ParseInt(n, 10, 64)
+		return i
+	}
+	return 0
+}
+
+// parseAppliedAt converts an applied_at value returned by a driver. The
+// MySQL driver returns []byte unless parseTime is enabled.
+func parseAppliedAt(v any) time.Time {
+	switch t := v.(type) {
+	case time.Time:
+		return t
+	case []byte:
+		return parseAppliedAtString(string(t))
+	case string:
+		return parseAppliedAtString(t)
+	}
+	return time.Time{}
+}
+
+func parseAppliedAtString(s string) time.Time {
+	for _, l := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(l, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (r *Recorder) insert(ctx context.Context, tbl string, row map[string]any) error {
+	id, err := r.insertRow(ctx, nil, tbl, row)
+	if err == nil {
+		r.forward(ctx, id, row)
+	}
+	return err
+}
+
+// insertRow inserts row within tx, or on its own when tx is nil, and
+// returns its id when it is needed for chaining or forwarding.
+func (r *Recorder) insertRow(ctx context.Context, tx *sql.Tx, tbl string, row map[string]any) (int64, error) {
+	if id := RevertOfFromContext(ctx); id != 0 {
+		row["revert_of"] = id
+	}
+	var db execer = r.DB
+	if tx != nil {
+		db = tx
+	}
+	switch {
+	case r.HashChain:
+		return r.insertChained(ctx, tx, tbl, row)
+	case r.Forward != nil:
+		return query.New(db, tbl, r.Dialect).WithContext(ctx).InsertGetId(row)
+	default:
+		_, err := query.New(db, tbl, r.Dialect).WithContext(ctx).Insert(row)
+		return 0, err
+	}
+}
+
+func (r *Recorder) forward(ctx context.Context, id int64, row map[string]any) {
+	if r.Forward == nil {
+		return
+	}
+	e := entryFromRow(row)
+	if e.TenantID == "" {
+		e.TenantID = defaultTenant(ctx)
+	}
+	r.Forward(ctx, id, e)
+}
+
+// execer is satisfied by *sql.DB and *sql.Tx.
+type execer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	Exec(query string, args ...any) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func defaultTenant(ctx context.Context) string {
+	if tid := tenant.FromContext(ctx); tid != "" {
+		return tid
+	}
+	return "default"
+}
+
+// insertChained inserts row while holding the lock on the tenant's chain
+// head so that concurrent writers append in a well defined order. Within tx
+// the lock is held until tx ends; without it a transaction of its own is
+// used.
+func (r *Recorder) insertChained(ctx context.Context, tx *sql.Tx, tbl string, row map[string]any) (id int64, err error) {
+	if _, ok := row["tenant_id"]; !ok {
+		row["tenant_id"] = defaultTenant(ctx)
+	}
+	tid, _ := row["tenant_id"].(string)
+	row["applied_at"] = time.Now().UTC().Truncate(time.Second)
+	heads := r.TablePrefix + "audit_chain_heads"
+
+	own := tx == nil
+	if own {
+		if tx, err = r.DB.BeginTx(ctx, nil); err != nil {
+			return 0, err
+		}
+		defer func() {
+			if err != nil {
+				_ = tx.Rollback()
+			}
+		}()
+	}
+	if _, err = query.New(tx, heads, r.Dialect).WithContext(ctx).
+		InsertOrIgnore([]map[string]any{{"tenant_id": tid, "last_id": 0, "last_hash": ""}}); err != nil {
+		return 0, err
+	}
+	var head struct {
+		LastHash string `db:"last_hash"`
+	}
+	if err = query.New(tx, heads, r.Dialect).
+		Select("last_hash").
+		Where("tenant_id", tid).
+		LockForUpdate().
+		WithContext(ctx).
+		First(&head); err != nil {
+		return 0, err
+	}
+	hash := ChainHash(head.LastHash, entryFromRow(row))
+	row["prev_hash"] = head.LastHash
+	row["hash"] = hash
+	id, err = query.New(tx, tbl, r.Dialect).WithContext(ctx).InsertGetId(row)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = query.New(tx, heads, r.Dialect).
+		Where("tenant_id", tid).
+		WithContext(ctx).
+		Update(map[string]any{"last_id": id, "last_hash": hash, "updated_at": time.Now().UTC()}); err != nil {
+		return 0, err
+	}
+	if !own {
+		return id, nil
+	}
+	return id, tx.Commit()
+}
+
+// ChainHead is the newest link of a tenant's audit chain.
+type ChainHead struct {
+	Tenant   string `db:"tenant_id" json:"tenant"`
+	LastID   int64  `db:"last_id" json:"lastId"`
+	LastHash string `db:"last_hash" json:"hash"`
+}
+
+// ChainBreak identifies the first row at which a chain no longer verifies.
+type ChainBreak struct {
+	ID     int64
+	Reason string
+}
+
+// ChainStatus is the result of VerifyChain.
+type ChainStatus struct {
+	Tenant string
+	// Checked counts the rows whose hash verified.
+	Checked int
+	// Unhashed counts rows written before hash chaining was enabled.
+	Unhashed int
+	Broken   *ChainBreak
+}
+
+type chainRow struct {
+	ID           int64          `db:"id"`
+	TenantID     string         `db:"tenant_id"`
+	Actor        sql.NullString `db:"actor"`
+	Action       sql.NullString `db:"action"`
+	TableName    sql.NullString `db:"table_name"`
+	ColumnName   sql.NullString `db:"column_name"`
+	ResourceType sql.NullString `db:"resource_type"`
+	ResourceID   sql.NullString `db:"resource_id"`
+	RequestID    sql.NullString `db:"request_id"`
+	RevertOf     sql.NullInt64  `db:"revert_of"`
+	BeforeJSON   sql.NullString `db:"before_json"`
+	AfterJSON    sql.NullString `db:"after_json"`
+	AddedCount   sql.NullInt64  `db:"added_count"`
+	RemovedCount sql.NullInt64  `db:"removed_count"`
+	ChangeCount  sql.NullInt64  `db:"change_count"`
+	AppliedAt    any            `db:"applied_at"`
+	PrevHash     sql.NullString `db:"prev_hash"`
+	Hash         sql.NullString `db:"hash"`
+}
+
+func (c chainRow) entry() ChainEntry {
+	return ChainEntry{
+		TenantID:     c.TenantID,
+		Actor:        c.Actor.String,
+		Action:       c.Action.String,
+		TableName:    c.TableName.String,
+		ColumnName:   c.ColumnName.String,
+		ResourceType: c.ResourceType.String,
+		ResourceID:   c.ResourceID.String,
+		RequestID:    c.RequestID.String,
+		RevertOf:     c.RevertOf.Int64,
+		BeforeJSON:   c.BeforeJSON.String,
+		AfterJSON:    c.AfterJSON.String,
+		AddedCount:   int(c.AddedCount.Int64),
+		RemovedCount: int(c.RemovedCount.Int64),
+		ChangeCount:  int(c.ChangeCount.Int64),
+		AppliedAt:    parseAppliedAt(c.AppliedAt),
+	}
+}
+
+const chainBatchSize = 500
+
+// VerifyChain walks the audit chain of tenant in id order and reports the
+// first row whose content or link to its predecessor does not match. The
+// chain starts at the first hashed row; rows without a hash, written before
+// chaining was enabled or by a Recorder without HashChain, are counted as
+// unhashed and skipped. Editing or deleting a hashed row still breaks the
+// link of the next hashed row.
+func VerifyChain(ctx context.Context, db *sql.DB, dialect ormdriver.Dialect, prefix, tenantID string) (ChainStatus, error) {
+	st := ChainStatus{Tenant: tenantID}
+	textCast := "CAST(%s AS CHAR)"
+	if _, ok := dialect.(ormdriver.PostgresDialect); ok {
+		textCast = "%s::text"
+	}
+	var (
+		prev    string
+		lastID  int64
+		started bool
+	)
+	for {
+		var rows []chainRow
+		err := query.New(db, prefix+"audit_logs", dialect).
+			Select("id", "tenant_id", "actor", "action", "table_name", "column_name", "resource_type", "resource_id", "request_id", "revert_of").
+			SelectRaw(fmt.Sprintf(textCast, "before_json")+" AS before_json").
+			SelectRaw(fmt.Sprintf(textCast, "after_json")+" AS after_json").
+			Select("added_count", "removed_count", "change_count", "applied_at", "prev_hash", "hash").
+			Where("tenant_id", tenantID).
+			Where("id", ">", lastID).
+			OrderBy("id", "asc").
+			Limit(chainBatchSize).
+			WithContext(ctx).
+			Get(&rows)
+		if err != nil {
+			return st, err
+		}
+		for _, r := range rows {
+			lastID = r.ID
+			if !r.Hash.Valid || r.Hash.String == "" {
+				st.Unhashed++
+				continue
+			}
+			started = true
+			if r.PrevHash.String != prev {
+				st.Broken = &ChainBreak{ID: r.ID, Reason: "prev_hash does not match the preceding row; rows before it were deleted or modified"}
+				return st, nil
+			}
+			if ChainHash(prev, r.entry()) != r.Hash.String {
+				st.Broken = &ChainBreak{ID: r.ID, Reason: "content does not match its hash; the row was modified"}
+				return st, nil
+			}
+			prev = r.Hash.String
+			st.Checked++
+		}
+		if len(rows) < chainBatchSize {
+			break
+		}
+	}
+	var head ChainHead
+	err := query.New(db, prefix+"audit_chain_heads", dialect).
+		Select("tenant_id", "last_id", "last_hash").
+		Where("tenant_id", tenantID).
+		WithContext(ctx).
+		First(&head)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		if started {
+			st.Broken = &ChainBreak{ID: lastID, Reason: "chain head is missing"}
+		}
+	case err != nil:
+		return st, err
+	case head.LastHash != prev:
+		st.Broken = &ChainBreak{ID: head.LastID, Reason: "chain head does not match the last row; rows at the end of the chain were deleted"}
+	}
+	return st, nil
+}
+
+// ChainHeads returns the head of every tenant chain.
+func ChainHeads(ctx context.Context, db *sql.DB, dialect ormdriver.Dialect, prefix string) ([]ChainHead, error) {
+	var heads []ChainHead
+	err := query.New(db, prefix+"audit_chain_heads", dialect).
+		Select("tenant_id", "last_id", "last_hash").
+		OrderBy("tenant_id", "asc").
+		WithContext(ctx).
+		Get(&heads)
+	if err != nil {
+		return nil, err
+	}
+	return heads, nil
+}
+
+// CheckAnchor reports whether the row recorded by an exported anchor still
+// carries the anchored hash.
+func CheckAnchor(ctx context.Context, db *sql.DB, dialect ormdriver.Dialect, prefix string, a ChainHead) error {
+	var row struct {
+		Hash sql.NullString `db:"hash"`
+	}
+	err := query.New(db, prefix+"audit_logs", dialect).
+		Select("hash").
+		Where("tenant_id", a.Tenant).
+		Where("id", a.LastID).
+		WithContext(ctx).
+		First(&row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("anchored row %d of tenant %s was deleted", a.LastID, a.Tenant)
+	}
+	if err != nil {
+		return err
+	}
+	if row.Hash.String != a.LastHash {
+		return fmt.Errorf("anchored row %d of tenant %s no longer matches its anchor hash", a.LastID, a.Tenant)
+	}
+	return nil
+}
+
+// AnchorDest receives exported chain anchors. snapshot.LocalDir and
+// snapshot.S3 satisfy it.
+type AnchorDest interface {
+	Write(ctx context.Context, name string, data []byte) error
+}
+
+// Anchor is the document written by ExportAnchors.
+type Anchor struct {
+	ExportedAt time.Time   `json:"exportedAt"`
+	Heads      []ChainHead `json:"heads"`
+}
+
+// ExportAnchors writes the current head of every tenant chain to dest. An
+// external copy of the heads proves later that the chain was not truncated
+// or rewritten. It returns the name of the written object.
+func ExportAnchors(ctx context.Context, db *sql.DB, dialect ormdriver.Dialect, prefix string, dest AnchorDest, now time.Time) (string, error) {
+	heads, err := ChainHeads(ctx, db, dialect, prefix)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(Anchor{ExportedAt: now.UTC(), Heads: heads}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("audit-anchor-%s.json", now.UTC().Format("20060102T150405Z"))
+	if err := dest.Write(ctx, name, data); err != nil {
+		return "", err
+	}
+	return name, nil
+}
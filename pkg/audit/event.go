@@ -0,0 +1,134 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/faciam-dev/gcfm/pkg/metrics"
+)
+
+// Resource types recorded in audit events.
+const (
+	ResourceCustomField  = "custom_field"
+	ResourceRegistry     = "registry"
+	ResourceSnapshot     = "snapshot"
+	ResourceRole         = "role"
+	ResourcePolicy       = "policy"
+	ResourceUser         = "user"
+	ResourceDatabase     = "database"
+	ResourceTarget       = "target"
+	ResourceWidget       = "widget"
+	ResourceWidgetPolicy = "widget_policy"
+	ResourcePlugin       = "plugin"
+	ResourceSession      = "session"
+	ResourceEventWebhook = "event_webhook"
+)
+
+// Event is a change to any audited resource. Before and After are encoded
+// as JSON; a nil value is stored as NULL.
+type Event struct {
+	ResourceType string
+	ResourceID   string
+	Action       string
+	Actor        string
+	// Tenant defaults to the tenant stored in the context.
+	Tenant string
+	// RequestID defaults to the request ID stored in the context.
+	RequestID string
+	Before    any
+	After     any
+}
+
+type requestIDKey struct{}
+
+// WithRequestID stores the ID of the current request in ctx.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx.
+func RequestIDFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(requestIDKey{}).(string)
+	return v
+}
+
+type revertOfKey struct{}
+
+// WithRevertOf marks the changes recorded with ctx as reverting the audit
+// row with the given ID.
+func WithRevertOf(ctx context.Context, id int64) context.Context {
+	return context.WithValue(ctx, revertOfKey{}, id)
+}
+
+// RevertOfFromContext returns the audit row ID stored by WithRevertOf.
+func RevertOfFromContext(ctx context.Context) int64 {
+	v, _ := ctx.Value(revertOfKey{}).(int64)
+	return v
+}
+
+func nullJSON(v any) (sql.NullString, []byte, error) {
+	if v == nil {
+		return sql.NullString{}, nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return sql.NullString{}, nil, err
+	}
+	if string(b) == "null" {
+		return sql.NullString{}, nil, nil
+	}
+	return sql.NullString{String: string(b), Valid: true}, b, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// Record writes ev to the audit log.
+func (r *Recorder) Record(ctx context.Context, ev Event) error {
+	if r == nil || r.DB == nil {
+		return nil
+	}
+	tid := ev.Tenant
+	if tid == "" {
+		tid = defaultTenant(ctx)
+	}
+	reqID := ev.RequestID
+	if reqID == "" {
+		reqID = RequestIDFromContext(ctx)
+	}
+	beforeJSON, before, err := nullJSON(ev.Before)
+	if err != nil {
+		return err
+	}
+	afterJSON, after, err := nullJSON(ev.After)
+	if err != nil {
+		return err
+	}
+	var addCnt, delCnt int
+	if before != nil || after != nil {
+		_, addCnt, delCnt = UnifiedDiff(before, after)
+	}
+	err = r.insert(ctx, r.TablePrefix+"audit_logs", map[string]any{
+		"tenant_id":     tid,
+		"actor":         ev.Actor,
+		"action":        ev.Action,
+		"table_name":    sql.NullString{},
+		"column_name":   sql.NullString{},
+		"resource_type": ev.ResourceType,
+		"resource_id":   nullString(ev.ResourceID),
+		"request_id":    nullString(reqID),
+		"before_json":   beforeJSON,
+		"after_json":    afterJSON,
+		"added_count":   addCnt,
+		"removed_count": delCnt,
+		"change_count":  addCnt + delCnt,
+	})
+	if err == nil {
+		metrics.AuditEvents.WithLabelValues(ev.Action).Inc()
+	} else {
+		metrics.AuditErrors.WithLabelValues(ev.Action).Inc()
+	}
+	return err
+}
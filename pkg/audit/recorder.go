@@ -12,7 +12,6 @@ import (
 	"github.com/faciam-dev/gcfm/pkg/registry"
 	"github.com/faciam-dev/gcfm/pkg/tenant"
 	ormdriver "github.com/faciam-dev/goquent/orm/driver"
-	"github.com/faciam-dev/goquent/orm/query"
 )
 
 // Recorder writes audit logs to the database.
@@ -20,6 +19,13 @@ type Recorder struct {
 	DB          *sql.DB
 	Dialect     ormdriver.Dialect
 	TablePrefix string
+	// HashChain links every row to the previous row of the same tenant
+	// through prev_hash/hash so that edits and deletions can be detected
+	// with VerifyChain.
+	HashChain bool
+	// Forward, when set, is called with every row after it has been
+	// written, e.g. to publish it as an event.
+	Forward func(ctx context.Context, id int64, e ChainEntry)
 }
 
 // enableVerboseAuditLogs controls whether detailed diff information is logged
@@ -27,11 +33,51 @@ type Recorder struct {
 // troubleshooting is needed.
 var enableVerboseAuditLogs = false
 
-// Write records a single field change.
+// Write records a single custom field change. Other resources are recorded
+// with Record.
 func (r *Recorder) Write(ctx context.Context, actor string, old, new *registry.FieldMeta) error {
 	if r == nil || r.DB == nil {
 		return nil
 	}
+	action, row, err := fieldRow(ctx, actor, old, new)
+	if err != nil {
+		return err
+	}
+	err = r.insert(ctx, r.TablePrefix+"audit_logs", row)
+	countWrite(action, err)
+	return err
+}
+
+// WriteTx records a single custom field change like Write, but within tx so
+// that the entry is committed or rolled back together with the change. The
+// returned function forwards the entry and must be called once tx has
+// committed; it is never nil.
+func (r *Recorder) WriteTx(ctx context.Context, tx *sql.Tx, actor string, old, new *registry.FieldMeta) (func(), error) {
+	if r == nil || r.DB == nil {
+		return func() {}, nil
+	}
+	action, row, err := fieldRow(ctx, actor, old, new)
+	if err != nil {
+		return func() {}, err
+	}
+	id, err := r.insertRow(ctx, tx, r.TablePrefix+"audit_logs", row)
+	countWrite(action, err)
+	if err != nil {
+		return func() {}, err
+	}
+	return func() { r.forward(ctx, id, row) }, nil
+}
+
+func countWrite(action string, err error) {
+	if err == nil {
+		metrics.AuditEvents.WithLabelValues(action).Inc()
+	} else {
+		metrics.AuditErrors.WithLabelValues(action).Inc()
+	}
+}
+
+// fieldRow builds the audit_logs row for a custom field change.
+func fieldRow(ctx context.Context, actor string, old, new *registry.FieldMeta) (string, map[string]any, error) {
 	var action string
 	switch {
 	case old == nil && new != nil:
@@ -46,13 +92,13 @@ func (r *Recorder) Write(ctx context.Context, actor string, old, new *registry.F
 	if old != nil {
 		before, err = json.Marshal(old)
 		if err != nil {
-			return err
+			return "", nil, err
 		}
 	}
 	if new != nil {
 		after, err = json.Marshal(new)
 		if err != nil {
-			return err
+			return "", nil, err
 		}
 	}
 	table := ""
@@ -78,7 +124,6 @@ func (r *Recorder) Write(ctx context.Context, actor string, old, new *registry.F
 			summary, beforeNorm, afterNorm, addCnt, delCnt, strings.Join(lines, "\n"))
 	}
 
-	tbl := r.TablePrefix + "audit_logs"
 	var beforeJSON sql.NullString
 	if before != nil {
 		beforeJSON = sql.NullString{String: string(before), Valid: true}
@@ -91,24 +136,25 @@ func (r *Recorder) Write(ctx context.Context, actor string, old, new *registry.F
 	} else {
 		afterJSON = sql.NullString{Valid: false}
 	}
-	_, err = query.New(r.DB, tbl, r.Dialect).WithContext(ctx).Insert(map[string]any{
+	var resourceType, resourceID string
+	if table != "" {
+		resourceType, resourceID = ResourceCustomField, table+"."+column
+	}
+	return action, map[string]any{
 		"tenant_id":     tenant.FromContext(ctx),
 		"actor":         actor,
 		"action":        action,
 		"table_name":    table,
 		"column_name":   column,
+		"resource_type": nullString(resourceType),
+		"resource_id":   nullString(resourceID),
+		"request_id":    nullString(RequestIDFromContext(ctx)),
 		"before_json":   beforeJSON,
 		"after_json":    afterJSON,
 		"added_count":   addCnt,
 		"removed_count": delCnt,
 		"change_count":  addCnt + delCnt,
-	})
-	if err == nil {
-		metrics.AuditEvents.WithLabelValues(action).Inc()
-	} else {
-		metrics.AuditErrors.WithLabelValues(action).Inc()
-	}
-	return err
+	}, nil
 }
 
 // WriteAction inserts a generic audit log entry for high level actions like
@@ -120,11 +166,14 @@ func (r *Recorder) WriteAction(ctx context.Context, actor, action, targetVer, di
 	}
 	tbl := r.TablePrefix + "audit_logs"
 	before := sql.NullString{Valid: diffSummary != "", String: diffSummary}
-	_, err := query.New(r.DB, tbl, r.Dialect).WithContext(ctx).Insert(map[string]any{
+	err := r.insert(ctx, tbl, map[string]any{
 		"actor":         actor,
 		"action":        action,
 		"table_name":    "registry",
 		"column_name":   targetVer,
+		"resource_type": ResourceSnapshot,
+		"resource_id":   nullString(targetVer),
+		"request_id":    nullString(RequestIDFromContext(ctx)),
 		"before_json":   before,
 		"after_json":    sql.NullString{Valid: false},
 		"added_count":   0,
@@ -149,7 +198,7 @@ func (r *Recorder) WriteJSON(ctx context.Context, actor, action string, payload
 		return err
 	}
 	tbl := r.TablePrefix + "audit_logs"
-	_, err = query.New(r.DB, tbl, r.Dialect).WithContext(ctx).Insert(map[string]any{
+	err = r.insert(ctx, tbl, map[string]any{
 		"actor":         actor,
 		"action":        action,
 		"table_name":    sql.NullString{Valid: false},
@@ -178,7 +227,7 @@ func (r *Recorder) WriteTableJSON(ctx context.Context, actor, action, table stri
 		return err
 	}
 	tbl := r.TablePrefix + "audit_logs"
-	_, err = query.New(r.DB, tbl, r.Dialect).WithContext(ctx).Insert(map[string]any{
+	err = r.insert(ctx, tbl, map[string]any{
 		"actor":         actor,
 		"action":        action,
 		"table_name":    table,
@@ -93,6 +93,14 @@ var (
 		},
 		[]string{"op"},
 	)
+	TargetCallLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cf_target_call_seconds",
+			Help:    "Latency of successful calls to a target",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"key"},
+	)
 	TargetQueryHits = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "cf_target_query_hits",
@@ -115,6 +123,49 @@ var (
 		},
 		[]string{"key", "state"},
 	)
+	TargetProbeLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cf_target_probe_seconds",
+			Help:    "Latency of active target health probes",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"key", "status"},
+	)
+	SnapshotsPruned = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cf_snapshots_pruned_total",
+			Help: "Number of registry snapshots removed by retention",
+		},
+		[]string{"tenant"},
+	)
+	SnapshotPrunedBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cf_snapshot_pruned_bytes_total",
+			Help: "Bytes of snapshot data reclaimed by retention",
+		},
+		[]string{"tenant"},
+	)
+	EventsDLQDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cf_events_dlq_depth",
+			Help: "Number of failed events waiting in the DLQ per sink",
+		},
+		[]string{"sink"},
+	)
+	EventsDLQOldestAge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cf_events_dlq_oldest_age_seconds",
+			Help: "Age of the oldest failed event in the DLQ per sink",
+		},
+		[]string{"sink"},
+	)
+	EventsDLQReplays = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cf_events_dlq_replays_total",
+			Help: "Replays of failed events per sink and result",
+		},
+		[]string{"sink", "result"},
+	)
 )
 
 func init() {
@@ -131,9 +182,16 @@ func init() {
 		TargetLabels,
 		TargetOpLatency,
 		TargetQueryLatency,
+		TargetCallLatency,
 		TargetQueryHits,
 		TargetFailures,
 		TargetState,
+		TargetProbeLatency,
+		SnapshotsPruned,
+		SnapshotPrunedBytes,
+		EventsDLQDepth,
+		EventsDLQOldestAge,
+		EventsDLQReplays,
 	)
 }
 
@@ -16,6 +16,78 @@ var mysql0002Up string
 //go:embed sql/mysql/0002_custom_field_types.down.sql
 var mysql0002Down string
 
+//go:embed sql/mysql/0003_widget_tenant_toggles.up.sql
+var mysql0003Up string
+
+//go:embed sql/mysql/0003_widget_tenant_toggles.down.sql
+var mysql0003Down string
+
+//go:embed sql/mysql/0004_snapshot_retention.up.sql
+var mysql0004Up string
+
+//go:embed sql/mysql/0004_snapshot_retention.down.sql
+var mysql0004Down string
+
+//go:embed sql/mysql/0005_snapshot_promotions.up.sql
+var mysql0005Up string
+
+//go:embed sql/mysql/0005_snapshot_promotions.down.sql
+var mysql0005Down string
+
+//go:embed sql/mysql/0006_audit_hash_chain.up.sql
+var mysql0006Up string
+
+//go:embed sql/mysql/0006_audit_hash_chain.down.sql
+var mysql0006Down string
+
+//go:embed sql/mysql/0007_audit_resources.up.sql
+var mysql0007Up string
+
+//go:embed sql/mysql/0007_audit_resources.down.sql
+var mysql0007Down string
+
+//go:embed sql/mysql/0008_audit_revert.up.sql
+var mysql0008Up string
+
+//go:embed sql/mysql/0008_audit_revert.down.sql
+var mysql0008Down string
+
+//go:embed sql/mysql/0009_events_outbox.up.sql
+var mysql0009Up string
+
+//go:embed sql/mysql/0009_events_outbox.down.sql
+var mysql0009Down string
+
+//go:embed sql/mysql/0010_event_webhooks.up.sql
+var mysql0010Up string
+
+//go:embed sql/mysql/0010_event_webhooks.down.sql
+var mysql0010Down string
+
+//go:embed sql/mysql/0011_events_failed_replay.up.sql
+var mysql0011Up string
+
+//go:embed sql/mysql/0011_events_failed_replay.down.sql
+var mysql0011Down string
+
+//go:embed sql/mysql/0012_events_outbox_stream.up.sql
+var mysql0012Up string
+
+//go:embed sql/mysql/0012_events_outbox_stream.down.sql
+var mysql0012Down string
+
+//go:embed sql/mysql/0013_target_draining.up.sql
+var mysql0013Up string
+
+//go:embed sql/mysql/0013_target_draining.down.sql
+var mysql0013Down string
+
+//go:embed sql/mysql/0014_events_failed_lease.up.sql
+var mysql0014Up string
+
+//go:embed sql/mysql/0014_events_failed_lease.down.sql
+var mysql0014Down string
+
 // PostgreSQL migration files
 //
 //go:embed sql/postgres/0001_init.up.sql
@@ -30,12 +102,108 @@ var pg0002Up string
 //go:embed sql/postgres/0002_custom_field_types.down.sql
 var pg0002Down string
 
+//go:embed sql/postgres/0003_widget_tenant_toggles.up.sql
+var pg0003Up string
+
+//go:embed sql/postgres/0003_widget_tenant_toggles.down.sql
+var pg0003Down string
+
+//go:embed sql/postgres/0004_snapshot_retention.up.sql
+var pg0004Up string
+
+//go:embed sql/postgres/0004_snapshot_retention.down.sql
+var pg0004Down string
+
+//go:embed sql/postgres/0005_snapshot_promotions.up.sql
+var pg0005Up string
+
+//go:embed sql/postgres/0005_snapshot_promotions.down.sql
+var pg0005Down string
+
+//go:embed sql/postgres/0006_audit_hash_chain.up.sql
+var pg0006Up string
+
+//go:embed sql/postgres/0006_audit_hash_chain.down.sql
+var pg0006Down string
+
+//go:embed sql/postgres/0007_audit_resources.up.sql
+var pg0007Up string
+
+//go:embed sql/postgres/0007_audit_resources.down.sql
+var pg0007Down string
+
+//go:embed sql/postgres/0008_audit_revert.up.sql
+var pg0008Up string
+
+//go:embed sql/postgres/0008_audit_revert.down.sql
+var pg0008Down string
+
+//go:embed sql/postgres/0009_events_outbox.up.sql
+var pg0009Up string
+
+//go:embed sql/postgres/0009_events_outbox.down.sql
+var pg0009Down string
+
+//go:embed sql/postgres/0010_event_webhooks.up.sql
+var pg0010Up string
+
+//go:embed sql/postgres/0010_event_webhooks.down.sql
+var pg0010Down string
+
+//go:embed sql/postgres/0011_events_failed_replay.up.sql
+var pg0011Up string
+
+//go:embed sql/postgres/0011_events_failed_replay.down.sql
+var pg0011Down string
+
+//go:embed sql/postgres/0012_events_outbox_stream.up.sql
+var pg0012Up string
+
+//go:embed sql/postgres/0012_events_outbox_stream.down.sql
+var pg0012Down string
+
+//go:embed sql/postgres/0013_target_draining.up.sql
+var pg0013Up string
+
+//go:embed sql/postgres/0013_target_draining.down.sql
+var pg0013Down string
+
+//go:embed sql/postgres/0014_events_failed_lease.up.sql
+var pg0014Up string
+
+//go:embed sql/postgres/0014_events_failed_lease.down.sql
+var pg0014Down string
+
 var defaultMigrations = []Migration{
 	{Version: 1, SemVer: "0.3", UpSQL: mysql0001Up, DownSQL: mysql0001Down},
 	{Version: 2, SemVer: "0.4", UpSQL: mysql0002Up, DownSQL: mysql0002Down},
+	{Version: 3, SemVer: "0.5", UpSQL: mysql0003Up, DownSQL: mysql0003Down},
+	{Version: 4, SemVer: "0.6", UpSQL: mysql0004Up, DownSQL: mysql0004Down},
+	{Version: 5, SemVer: "0.7", UpSQL: mysql0005Up, DownSQL: mysql0005Down},
+	{Version: 6, SemVer: "0.8", UpSQL: mysql0006Up, DownSQL: mysql0006Down},
+	{Version: 7, SemVer: "0.9", UpSQL: mysql0007Up, DownSQL: mysql0007Down},
+	{Version: 8, SemVer: "1.0", UpSQL: mysql0008Up, DownSQL: mysql0008Down},
+	{Version: 9, SemVer: "1.1", UpSQL: mysql0009Up, DownSQL: mysql0009Down},
+	{Version: 10, SemVer: "1.2", UpSQL: mysql0010Up, DownSQL: mysql0010Down},
+	{Version: 11, SemVer: "1.3", UpSQL: mysql0011Up, DownSQL: mysql0011Down},
+	{Version: 12, SemVer: "1.4", UpSQL: mysql0012Up, DownSQL: mysql0012Down},
+	{Version: 13, SemVer: "1.5", UpSQL: mysql0013Up, DownSQL: mysql0013Down},
+	{Version: 14, SemVer: "1.6", UpSQL: mysql0014Up, DownSQL: mysql0014Down},
 }
 
 var postgresMigrations = []Migration{
 	{Version: 1, SemVer: "0.3", UpSQL: pg0001Up, DownSQL: pg0001Down},
 	{Version: 2, SemVer: "0.4", UpSQL: pg0002Up, DownSQL: pg0002Down},
+	{Version: 3, SemVer: "0.5", UpSQL: pg0003Up, DownSQL: pg0003Down},
+	{Version: 4, SemVer: "0.6", UpSQL: pg0004Up, DownSQL: pg0004Down},
+	{Version: 5, SemVer: "0.7", UpSQL: pg0005Up, DownSQL: pg0005Down},
+	{Version: 6, SemVer: "0.8", UpSQL: pg0006Up, DownSQL: pg0006Down},
+	{Version: 7, SemVer: "0.9", UpSQL: pg0007Up, DownSQL: pg0007Down},
+	{Version: 8, SemVer: "1.0", UpSQL: pg0008Up, DownSQL: pg0008Down},
+	{Version: 9, SemVer: "1.1", UpSQL: pg0009Up, DownSQL: pg0009Down},
+	{Version: 10, SemVer: "1.2", UpSQL: pg0010Up, DownSQL: pg0010Down},
+	{Version: 11, SemVer: "1.3", UpSQL: pg0011Up, DownSQL: pg0011Down},
+	{Version: 12, SemVer: "1.4", UpSQL: pg0012Up, DownSQL: pg0012Down},
+	{Version: 13, SemVer: "1.5", UpSQL: pg0013Up, DownSQL: pg0013Down},
+	{Version: 14, SemVer: "1.6", UpSQL: pg0014Up, DownSQL: pg0014Down},
 }
@@ -0,0 +1,4 @@
+ALTER TABLE gcfm_widgets
+    ADD COLUMN IF NOT EXISTS disabled_tenants JSON NOT NULL DEFAULT (JSON_ARRAY());
+
+INSERT IGNORE INTO gcfm_registry_schema_version(version, semver) VALUES (3,'0.5');
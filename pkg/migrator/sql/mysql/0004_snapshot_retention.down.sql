@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS gcfm_registry_snapshot_tags;
+DROP TABLE IF EXISTS gcfm_snapshot_retention;
+
+DELETE FROM gcfm_registry_schema_version WHERE version = 4;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS gcfm_snapshot_retention (
+    tenant_id VARCHAR(64) PRIMARY KEY,
+    keep_last INT NOT NULL DEFAULT 0,
+    keep_within_seconds BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS gcfm_registry_snapshot_tags (
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    tag VARCHAR(64) NOT NULL,
+    snapshot_id BIGINT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (tenant_id, tag),
+    KEY idx_snapshot_tags_snapshot (snapshot_id),
+    FOREIGN KEY (snapshot_id) REFERENCES gcfm_registry_snapshots(id) ON DELETE CASCADE
+);
+
+INSERT IGNORE INTO gcfm_registry_schema_version(version, semver) VALUES (4,'0.6');
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS gcfm_snapshot_promotions;
+
+DELETE FROM gcfm_registry_schema_version WHERE version = 5;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS gcfm_snapshot_promotions (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    tag VARCHAR(64) NOT NULL,
+    source_tenant VARCHAR(64) NOT NULL,
+    source_snapshot_id BIGINT NOT NULL,
+    source_semver VARCHAR(32) NOT NULL,
+    dest_tenant VARCHAR(64) NOT NULL,
+    dest_snapshot_id BIGINT NOT NULL,
+    dest_semver VARCHAR(32) NOT NULL,
+    actor VARCHAR(64),
+    promoted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_snapshot_promotions_source (source_snapshot_id),
+    KEY idx_snapshot_promotions_dest (dest_snapshot_id)
+);
+
+INSERT IGNORE INTO gcfm_registry_schema_version(version, semver) VALUES (5,'0.7');
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS gcfm_audit_chain_heads;
+ALTER TABLE gcfm_audit_logs
+    DROP COLUMN hash,
+    DROP COLUMN prev_hash;
+
+DELETE FROM gcfm_registry_schema_version WHERE version = 6;
@@ -0,0 +1,7 @@
+ALTER TABLE gcfm_audit_logs
+    DROP INDEX idx_gcfm_audit_resource,
+    DROP COLUMN request_id,
+    DROP COLUMN resource_id,
+    DROP COLUMN resource_type;
+
+DELETE FROM gcfm_registry_schema_version WHERE version = 7;
@@ -0,0 +1,18 @@
+ALTER TABLE gcfm_audit_logs
+    ADD COLUMN resource_type VARCHAR(64) NULL,
+    ADD COLUMN resource_id VARCHAR(255) NULL,
+    ADD COLUMN request_id VARCHAR(64) NULL,
+    ADD INDEX idx_gcfm_audit_resource (tenant_id, resource_type, applied_at, id);
+
+-- Classify rows written before resource types existed. Hashed rows are left
+-- untouched so that their chain hash keeps verifying.
+UPDATE gcfm_audit_logs
+   SET resource_type = 'custom_field', resource_id = CONCAT(table_name, '.', column_name)
+ WHERE resource_type IS NULL AND hash IS NULL
+   AND action IN ('add', 'update', 'delete')
+   AND table_name IS NOT NULL AND table_name <> '' AND table_name <> 'registry';
+UPDATE gcfm_audit_logs
+   SET resource_type = 'snapshot', resource_id = column_name
+ WHERE resource_type IS NULL AND hash IS NULL AND table_name = 'registry';
+
+INSERT IGNORE INTO gcfm_registry_schema_version(version, semver) VALUES (7,'0.9');
@@ -0,0 +1,5 @@
+ALTER TABLE gcfm_audit_logs
+    DROP INDEX idx_gcfm_audit_revert_of,
+    DROP COLUMN revert_of;
+
+DELETE FROM gcfm_registry_schema_version WHERE version = 8;
@@ -0,0 +1,5 @@
+ALTER TABLE gcfm_audit_logs
+    ADD COLUMN revert_of BIGINT NULL,
+    ADD INDEX idx_gcfm_audit_revert_of (revert_of);
+
+INSERT IGNORE INTO gcfm_registry_schema_version(version, semver) VALUES (8,'1.0');
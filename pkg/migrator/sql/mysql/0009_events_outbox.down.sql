@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS gcfm_events_outbox;
+
+DELETE FROM gcfm_registry_schema_version WHERE version = 9;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS gcfm_events_outbox (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    name VARCHAR(128) NOT NULL,
+    event_id VARCHAR(255) NOT NULL DEFAULT '',
+    payload JSON NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    delivered_sinks VARCHAR(255) NOT NULL DEFAULT '',
+    next_attempt_at DATETIME(6) NOT NULL,
+    lease_owner VARCHAR(128) NULL,
+    lease_until DATETIME(6) NULL,
+    last_error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    delivered_at DATETIME(6) NULL,
+    INDEX idx_gcfm_events_outbox_due (status, next_attempt_at)
+);
+
+INSERT IGNORE INTO gcfm_registry_schema_version(version, semver) VALUES (9,'1.1');
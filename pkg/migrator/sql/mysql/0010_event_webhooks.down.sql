@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS gcfm_event_webhook_deliveries;
+DROP TABLE IF EXISTS gcfm_event_webhooks;
+
+DELETE FROM gcfm_registry_schema_version WHERE version = 10;
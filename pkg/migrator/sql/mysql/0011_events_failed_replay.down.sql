@@ -0,0 +1,10 @@
+ALTER TABLE gcfm_events_failed
+    DROP INDEX idx_gcfm_events_failed_replay,
+    DROP INDEX idx_gcfm_events_failed_tenant,
+    DROP COLUMN next_replay_at,
+    DROP COLUMN replay_attempts,
+    DROP COLUMN sink,
+    DROP COLUMN event_id,
+    DROP COLUMN tenant_id;
+
+DELETE FROM gcfm_registry_schema_version WHERE version = 11;
@@ -0,0 +1,10 @@
+ALTER TABLE gcfm_events_failed
+    ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    ADD COLUMN event_id VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN sink VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN replay_attempts INT NOT NULL DEFAULT 0,
+    ADD COLUMN next_replay_at DATETIME(6) NULL,
+    ADD INDEX idx_gcfm_events_failed_tenant (tenant_id, id),
+    ADD INDEX idx_gcfm_events_failed_replay (next_replay_at);
+
+INSERT IGNORE INTO gcfm_registry_schema_version(version, semver) VALUES (11,'1.3');
@@ -0,0 +1,4 @@
+ALTER TABLE gcfm_events_outbox
+    DROP INDEX idx_gcfm_events_outbox_tenant;
+
+DELETE FROM gcfm_registry_schema_version WHERE version = 12;
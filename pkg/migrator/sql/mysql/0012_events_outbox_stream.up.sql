@@ -0,0 +1,4 @@
+ALTER TABLE gcfm_events_outbox
+    ADD INDEX idx_gcfm_events_outbox_tenant (tenant_id, id);
+
+INSERT IGNORE INTO gcfm_registry_schema_version(version, semver) VALUES (12,'1.4');
@@ -0,0 +1,4 @@
+ALTER TABLE gcfm_targets
+    ADD COLUMN draining BOOLEAN NOT NULL DEFAULT FALSE;
+
+INSERT IGNORE INTO gcfm_registry_schema_version(version, semver) VALUES (13,'1.5');
@@ -0,0 +1,4 @@
+ALTER TABLE gcfm_events_failed
+    ADD COLUMN leased_until DATETIME(6) NULL;
+
+INSERT IGNORE INTO gcfm_registry_schema_version(version, semver) VALUES (14,'1.6');
@@ -0,0 +1,4 @@
+ALTER TABLE gcfm_widgets
+    DROP COLUMN IF EXISTS disabled_tenants;
+
+DELETE FROM gcfm_registry_schema_version WHERE version = 3;
@@ -0,0 +1,5 @@
+ALTER TABLE gcfm_widgets
+    ADD COLUMN IF NOT EXISTS disabled_tenants TEXT[] NOT NULL DEFAULT '{}';
+
+INSERT INTO gcfm_registry_schema_version(version, semver) VALUES (3,'0.5')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS gcfm_snapshot_retention (
+    tenant_id VARCHAR(64) PRIMARY KEY,
+    keep_last INT NOT NULL DEFAULT 0,
+    keep_within_seconds BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS gcfm_registry_snapshot_tags (
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    tag VARCHAR(64) NOT NULL,
+    snapshot_id BIGINT NOT NULL REFERENCES gcfm_registry_snapshots(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (tenant_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_snapshot_tags_snapshot ON gcfm_registry_snapshot_tags(snapshot_id);
+
+INSERT INTO gcfm_registry_schema_version(version, semver) VALUES (4,'0.6')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS gcfm_snapshot_promotions (
+    id BIGSERIAL PRIMARY KEY,
+    tag VARCHAR(64) NOT NULL,
+    source_tenant VARCHAR(64) NOT NULL,
+    source_snapshot_id BIGINT NOT NULL,
+    source_semver VARCHAR(32) NOT NULL,
+    dest_tenant VARCHAR(64) NOT NULL,
+    dest_snapshot_id BIGINT NOT NULL,
+    dest_semver VARCHAR(32) NOT NULL,
+    actor VARCHAR(64),
+    promoted_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_snapshot_promotions_source ON gcfm_snapshot_promotions(source_snapshot_id);
+CREATE INDEX IF NOT EXISTS idx_snapshot_promotions_dest ON gcfm_snapshot_promotions(dest_snapshot_id);
+
+INSERT INTO gcfm_registry_schema_version(version, semver) VALUES (5,'0.7')
+ON CONFLICT DO NOTHING;
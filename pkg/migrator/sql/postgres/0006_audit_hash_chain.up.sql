@@ -0,0 +1,12 @@
+ALTER TABLE gcfm_audit_logs ADD COLUMN IF NOT EXISTS prev_hash VARCHAR(64);
+ALTER TABLE gcfm_audit_logs ADD COLUMN IF NOT EXISTS hash VARCHAR(64);
+
+CREATE TABLE IF NOT EXISTS gcfm_audit_chain_heads (
+    tenant_id VARCHAR(64) PRIMARY KEY,
+    last_id BIGINT NOT NULL DEFAULT 0,
+    last_hash VARCHAR(64) NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO gcfm_registry_schema_version(version, semver) VALUES (6,'0.8')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_gcfm_audit_resource;
+ALTER TABLE gcfm_audit_logs DROP COLUMN IF EXISTS request_id;
+ALTER TABLE gcfm_audit_logs DROP COLUMN IF EXISTS resource_id;
+ALTER TABLE gcfm_audit_logs DROP COLUMN IF EXISTS resource_type;
+
+DELETE FROM gcfm_registry_schema_version WHERE version = 7;
@@ -0,0 +1,19 @@
+ALTER TABLE gcfm_audit_logs ADD COLUMN IF NOT EXISTS resource_type VARCHAR(64);
+ALTER TABLE gcfm_audit_logs ADD COLUMN IF NOT EXISTS resource_id VARCHAR(255);
+ALTER TABLE gcfm_audit_logs ADD COLUMN IF NOT EXISTS request_id VARCHAR(64);
+
+-- Classify rows written before resource types existed. Hashed rows are left
+-- untouched so that their chain hash keeps verifying.
+UPDATE gcfm_audit_logs
+   SET resource_type = 'custom_field', resource_id = table_name || '.' || column_name
+ WHERE resource_type IS NULL AND hash IS NULL
+   AND action IN ('add', 'update', 'delete')
+   AND table_name IS NOT NULL AND table_name <> '' AND table_name <> 'registry';
+UPDATE gcfm_audit_logs
+   SET resource_type = 'snapshot', resource_id = column_name
+ WHERE resource_type IS NULL AND hash IS NULL AND table_name = 'registry';
+
+CREATE INDEX IF NOT EXISTS idx_gcfm_audit_resource ON gcfm_audit_logs(tenant_id, resource_type, applied_at DESC, id DESC);
+
+INSERT INTO gcfm_registry_schema_version(version, semver) VALUES (7,'0.9')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_gcfm_audit_revert_of;
+ALTER TABLE gcfm_audit_logs DROP COLUMN IF EXISTS revert_of;
+
+DELETE FROM gcfm_registry_schema_version WHERE version = 8;
@@ -0,0 +1,5 @@
+ALTER TABLE gcfm_audit_logs ADD COLUMN IF NOT EXISTS revert_of BIGINT;
+CREATE INDEX IF NOT EXISTS idx_gcfm_audit_revert_of ON gcfm_audit_logs(revert_of);
+
+INSERT INTO gcfm_registry_schema_version(version, semver) VALUES (8,'1.0')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_gcfm_events_outbox_due;
+DROP TABLE IF EXISTS gcfm_events_outbox;
+
+DELETE FROM gcfm_registry_schema_version WHERE version = 9;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS gcfm_events_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    name VARCHAR(128) NOT NULL,
+    event_id VARCHAR(255) NOT NULL DEFAULT '',
+    payload JSONB NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    delivered_sinks VARCHAR(255) NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    lease_owner VARCHAR(128),
+    lease_until TIMESTAMPTZ,
+    last_error TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_gcfm_events_outbox_due ON gcfm_events_outbox(status, next_attempt_at);
+
+INSERT INTO gcfm_registry_schema_version(version, semver) VALUES (9,'1.1')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_gcfm_event_webhook_deliveries_event;
+DROP TABLE IF EXISTS gcfm_event_webhook_deliveries;
+DROP INDEX IF EXISTS idx_gcfm_event_webhooks_tenant;
+DROP TABLE IF EXISTS gcfm_event_webhooks;
+
+DELETE FROM gcfm_registry_schema_version WHERE version = 10;
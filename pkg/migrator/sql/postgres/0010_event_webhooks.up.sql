@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS gcfm_event_webhooks (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    event_types VARCHAR(1024) NOT NULL DEFAULT '',
+    filter_expr TEXT,
+    mode VARCHAR(16) NOT NULL DEFAULT 'structured',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_gcfm_event_webhooks_tenant ON gcfm_event_webhooks(tenant_id);
+
+CREATE TABLE IF NOT EXISTS gcfm_event_webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT NOT NULL,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    event_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(128) NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    response_code INT NOT NULL DEFAULT 0,
+    error TEXT,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_gcfm_event_webhook_deliveries_event ON gcfm_event_webhook_deliveries(webhook_id, event_id);
+
+INSERT INTO gcfm_registry_schema_version(version, semver) VALUES (10,'1.2')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_gcfm_events_failed_replay;
+DROP INDEX IF EXISTS idx_gcfm_events_failed_tenant;
+ALTER TABLE gcfm_events_failed DROP COLUMN IF EXISTS next_replay_at;
+ALTER TABLE gcfm_events_failed DROP COLUMN IF EXISTS replay_attempts;
+ALTER TABLE gcfm_events_failed DROP COLUMN IF EXISTS sink;
+ALTER TABLE gcfm_events_failed DROP COLUMN IF EXISTS event_id;
+ALTER TABLE gcfm_events_failed DROP COLUMN IF EXISTS tenant_id;
+
+DELETE FROM gcfm_registry_schema_version WHERE version = 11;
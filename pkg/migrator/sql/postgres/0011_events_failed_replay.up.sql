@@ -0,0 +1,10 @@
+ALTER TABLE gcfm_events_failed ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE gcfm_events_failed ADD COLUMN IF NOT EXISTS event_id VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE gcfm_events_failed ADD COLUMN IF NOT EXISTS sink VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE gcfm_events_failed ADD COLUMN IF NOT EXISTS replay_attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE gcfm_events_failed ADD COLUMN IF NOT EXISTS next_replay_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_gcfm_events_failed_tenant ON gcfm_events_failed(tenant_id, id);
+CREATE INDEX IF NOT EXISTS idx_gcfm_events_failed_replay ON gcfm_events_failed(next_replay_at);
+
+INSERT INTO gcfm_registry_schema_version(version, semver) VALUES (11,'1.3')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_gcfm_events_outbox_tenant;
+
+DELETE FROM gcfm_registry_schema_version WHERE version = 12;
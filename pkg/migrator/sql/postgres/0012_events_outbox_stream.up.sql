@@ -0,0 +1,4 @@
+CREATE INDEX IF NOT EXISTS idx_gcfm_events_outbox_tenant ON gcfm_events_outbox(tenant_id, id);
+
+INSERT INTO gcfm_registry_schema_version(version, semver) VALUES (12,'1.4')
+ON CONFLICT DO NOTHING;
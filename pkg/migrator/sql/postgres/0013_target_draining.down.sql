@@ -0,0 +1,3 @@
+ALTER TABLE gcfm_targets DROP COLUMN IF EXISTS draining;
+
+DELETE FROM gcfm_registry_schema_version WHERE version = 13;
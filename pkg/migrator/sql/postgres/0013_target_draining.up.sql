@@ -0,0 +1,5 @@
+ALTER TABLE gcfm_targets
+    ADD COLUMN IF NOT EXISTS draining BOOLEAN NOT NULL DEFAULT FALSE;
+
+INSERT INTO gcfm_registry_schema_version(version, semver) VALUES (13,'1.5')
+ON CONFLICT DO NOTHING;
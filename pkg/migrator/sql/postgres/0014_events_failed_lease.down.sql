@@ -0,0 +1,3 @@
+ALTER TABLE gcfm_events_failed DROP COLUMN IF EXISTS leased_until;
+
+DELETE FROM gcfm_registry_schema_version WHERE version = 14;
@@ -0,0 +1,5 @@
+ALTER TABLE gcfm_events_failed
+    ADD COLUMN IF NOT EXISTS leased_until TIMESTAMPTZ;
+
+INSERT INTO gcfm_registry_schema_version(version, semver) VALUES (14,'1.6')
+ON CONFLICT DO NOTHING;
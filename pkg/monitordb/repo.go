@@ -22,6 +22,16 @@ type Record struct {
 
 var ErrNotFound = errors.New("monitored database not found")
 
+// execer is satisfied by *sql.DB and *sql.Tx.
+type execer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	Exec(query string, args ...any) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
 func GetByID(ctx context.Context, db *sql.DB, d ormdriver.Dialect, prefix, tenant string, id int64) (Record, error) {
 	tbl := prefix + "monitored_databases"
 	if prefix == "" {
@@ -77,6 +87,15 @@ func GetByID(ctx context.Context, db *sql.DB, d ormdriver.Dialect, prefix, tenan
 
 // EnsureExists inserts a placeholder monitored database record if the specified ID is missing.
 func EnsureExists(ctx context.Context, db *sql.DB, d ormdriver.Dialect, prefix, tenant string, id int64) error {
+	return ensureExists(ctx, db, d, prefix, tenant, id)
+}
+
+// EnsureExistsTx is like EnsureExists but writes within tx.
+func EnsureExistsTx(ctx context.Context, tx *sql.Tx, d ormdriver.Dialect, prefix, tenant string, id int64) error {
+	return ensureExists(ctx, tx, d, prefix, tenant, id)
+}
+
+func ensureExists(ctx context.Context, db execer, d ormdriver.Dialect, prefix, tenant string, id int64) error {
 	tbl := prefix + "monitored_databases"
 	if prefix == "" {
 		tbl = "gcfm_monitored_databases"
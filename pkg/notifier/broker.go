@@ -10,7 +10,6 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-// Broker publishes diff reports to external systems.
 // DiffReport summarizes registry changes.
 type DiffReport struct {
 	Added   int
@@ -18,6 +17,10 @@ type DiffReport struct {
 	Updated int
 }
 
+// Broker publishes diff reports to external systems.
+//
+// Deprecated: set sdk.ServiceConfig.Events to an Emitter such as a
+// Dispatcher, which receives one cf.field.* event per changed field.
 type Broker interface {
 	Emit(ctx context.Context, diff DiffReport) error
 }
@@ -0,0 +1,235 @@
+package notifier
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/faciam-dev/gcfm/internal/events"
+	"github.com/faciam-dev/gcfm/pkg/registry"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+)
+
+// The types below mirror the events the API server publishes. SDK users
+// build the same pipeline with NewDispatcher, so both paths emit identical
+// cf.* CloudEvents.
+
+// Event is a registry change, published as a CloudEvent whose type is Name.
+type Event struct {
+	Name string    `json:"name"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+	// ID is unique per event. The dispatcher generates one when empty.
+	ID string `json:"id"`
+	// Subject names the affected resource, e.g. "posts.title".
+	Subject string `json:"subject,omitempty"`
+	// Tenant defaults to the tenant of the emitting context.
+	Tenant     string `json:"tenant,omitempty"`
+	Source     string `json:"source,omitempty"`
+	DataSchema string `json:"dataschema,omitempty"`
+}
+
+// CloudEvent is the structured-mode JSON envelope sinks publish. Tenant is
+// carried as the "tenant" extension attribute.
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	DataSchema      string          `json:"dataschema,omitempty"`
+	Tenant          string          `json:"tenant,omitempty"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// FieldChange is the data of cf.field.* events. Before is nil for created
+// fields and After is nil for deleted ones.
+type FieldChange struct {
+	DBID   int64               `json:"dbId"`
+	Table  string              `json:"table"`
+	Column string              `json:"column"`
+	Before *registry.FieldMeta `json:"before"`
+	After  *registry.FieldMeta `json:"after"`
+}
+
+// Subscription selects the events a sink receives.
+type Subscription struct {
+	// Events are glob patterns on the event type, e.g. "cf.field.*".
+	Events []string `yaml:"events" json:"events,omitempty"`
+	// Tenants restricts delivery to events of the listed tenants.
+	Tenants []string `yaml:"tenants" json:"tenants,omitempty"`
+	// Filter is a CEL expression evaluated against the event, as in the
+	// subscribe.filter setting of the events configuration.
+	Filter string `yaml:"filter" json:"filter,omitempty"`
+}
+
+// The mirrored types must convert to the internal ones; a field that is
+// added, removed or retyped on either side fails to compile here.
+var (
+	_ = events.Event(Event{})
+	_ = events.CloudEvent(CloudEvent{})
+	_ = events.FieldChange(FieldChange{})
+	_ = events.Subscription(Subscription{})
+)
+
+// Event types.
+const (
+	TypeFieldCreated = events.TypeFieldCreated
+	TypeFieldUpdated = events.TypeFieldUpdated
+	TypeFieldDeleted = events.TypeFieldDeleted
+	TypeScan         = events.TypeScan
+	TypeAuditPrefix  = events.TypeAuditPrefix
+)
+
+// Emitter accepts events for delivery. *Dispatcher implements it; Dispatch
+// must not block on the sinks.
+type Emitter interface {
+	Dispatch(ctx context.Context, e Event)
+}
+
+// EmitterFunc adapts a function to Emitter.
+type EmitterFunc func(ctx context.Context, e Event)
+
+// Dispatch calls f.
+func (f EmitterFunc) Dispatch(ctx context.Context, e Event) { f(ctx, e) }
+
+// Sink publishes events.
+type Sink interface {
+	Emit(ctx context.Context, e Event) error
+}
+
+// DLQ stores events a sink failed to accept after all attempts.
+type DLQ interface {
+	Store(ctx context.Context, e Event, sink string, attempts int, lastErr string) error
+}
+
+// Config is the events configuration read from CF_EVENTS_CONFIG. The zero
+// value configures no sinks and the default retries.
+type Config struct {
+	cfg events.Config
+}
+
+// LoadConfig reads an events configuration file.
+func LoadConfig(path string) (Config, error) {
+	c, err := events.LoadConfig(path)
+	return Config{cfg: c}, err
+}
+
+// Dispatcher sends events to its sinks with retries and a DLQ.
+type Dispatcher struct {
+	d *events.Dispatcher
+}
+
+// NewDispatcher creates a dispatcher that retries every sink as configured
+// in cfg and stores events that still fail in dlq, which may be nil.
+func NewDispatcher(cfg Config, dlq DLQ, sinks ...Sink) *Dispatcher {
+	in := make([]events.Sink, 0, len(sinks))
+	for _, s := range sinks {
+		in = append(in, toInternalSink(s))
+	}
+	var q events.DLQ
+	switch v := dlq.(type) {
+	case nil:
+	case sqlDLQ:
+		q = v.q
+	default:
+		q = internalDLQ{dlq}
+	}
+	return &Dispatcher{d: events.NewDispatcher(cfg.cfg, q, in...)}
+}
+
+// Dispatch sends e to every sink in the background.
+func (d *Dispatcher) Dispatch(ctx context.Context, e Event) {
+	d.d.Dispatch(ctx, events.Event(e))
+}
+
+// NewSinks builds the webhook, Redis and Kafka sinks enabled in cfg.
+func NewSinks(cfg Config) ([]Sink, error) {
+	in, err := events.NewSinks(cfg.cfg)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Sink, 0, len(in))
+	for _, s := range in {
+		out = append(out, builtinSink{s})
+	}
+	return out, nil
+}
+
+// Subscribe wraps s so that it only receives the events selected by sub.
+func Subscribe(s Sink, sub Subscription) (Sink, error) {
+	in, err := events.Subscribe(toInternalSink(s), events.Subscription(sub))
+	if err != nil {
+		return nil, err
+	}
+	return builtinSink{in}, nil
+}
+
+// NewSQLDLQ returns a DLQ storing failed events in the events_failed table
+// of db, the table the API server's DLQ endpoints and replay worker use.
+func NewSQLDLQ(db *sql.DB, dialect ormdriver.Dialect, tablePrefix string) DLQ {
+	return sqlDLQ{&events.SQLDLQ{DB: db, Dialect: dialect, TablePrefix: tablePrefix}}
+}
+
+// FieldEvent builds a cf.field.* event for a change from before to after.
+func FieldEvent(typ string, before, after *registry.FieldMeta) Event {
+	e := events.FieldEvent(typ, before, after)
+	e.Data = FieldChange(e.Data.(events.FieldChange))
+	return Event(e)
+}
+
+// builtinSink exposes a sink of the events package.
+type builtinSink struct {
+	s events.Sink
+}
+
+func (b builtinSink) Emit(ctx context.Context, e Event) error {
+	return b.s.Emit(ctx, events.Event(e))
+}
+
+func (b builtinSink) Name() string { return events.SinkName(b.s) }
+
+// sinkAdapter passes events of the events package to a user sink.
+type sinkAdapter struct {
+	s Sink
+}
+
+func (a sinkAdapter) Emit(ctx context.Context, e events.Event) error {
+	return a.s.Emit(ctx, Event(e))
+}
+
+func (a sinkAdapter) Name() string {
+	if n, ok := a.s.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", a.s)
+}
+
+func toInternalSink(s Sink) events.Sink {
+	if b, ok := s.(builtinSink); ok {
+		return b.s
+	}
+	return sinkAdapter{s}
+}
+
+// sqlDLQ exposes the SQL DLQ of the events package.
+type sqlDLQ struct {
+	q *events.SQLDLQ
+}
+
+func (d sqlDLQ) Store(ctx context.Context, e Event, sink string, attempts int, lastErr string) error {
+	return d.q.Store(ctx, events.Event(e), sink, attempts, lastErr)
+}
+
+// internalDLQ passes failed events to a user DLQ.
+type internalDLQ struct {
+	q DLQ
+}
+
+func (d internalDLQ) Store(ctx context.Context, e events.Event, sink string, attempts int, lastErr string) error {
+	return d.q.Store(ctx, Event(e), sink, attempts, lastErr)
+}
@@ -11,6 +11,16 @@ import (
 	"github.com/faciam-dev/goquent/orm/query"
 )
 
+// execer is satisfied by *sql.DB and *sql.Tx.
+type execer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	Exec(query string, args ...any) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
 func LoadSQL(ctx context.Context, db *sql.DB, conf DBConfig) ([]FieldMeta, error) {
 	dialect := pkgutil.DialectFromDriver(conf.Driver)
 	tbl := TableName(conf.TablePrefix, "custom_fields")
@@ -115,6 +125,15 @@ func LoadSQL(ctx context.Context, db *sql.DB, conf DBConfig) ([]FieldMeta, error
 
 // LoadSQLByTenant is like LoadSQL but filters by tenant ID.
 func LoadSQLByTenant(ctx context.Context, db *sql.DB, conf DBConfig, tenant string) ([]FieldMeta, error) {
+	return loadSQLByTenant(ctx, db, conf, tenant)
+}
+
+// LoadSQLByTenantTx is like LoadSQLByTenant but reads within tx.
+func LoadSQLByTenantTx(ctx context.Context, tx *sql.Tx, conf DBConfig, tenant string) ([]FieldMeta, error) {
+	return loadSQLByTenant(ctx, tx, conf, tenant)
+}
+
+func loadSQLByTenant(ctx context.Context, db execer, conf DBConfig, tenant string) ([]FieldMeta, error) {
 	dialect := pkgutil.DialectFromDriver(conf.Driver)
 	tbl := TableName(conf.TablePrefix, "custom_fields")
 	q := query.New(db, tbl, dialect).
@@ -292,24 +311,39 @@ func UpsertSQL(ctx context.Context, db *sql.DB, driver, tablePrefix string, meta
 	if err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
+	if err := UpsertSQLTx(ctx, tx, driver, tablePrefix, metas); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("rollback: %v: %w", rbErr, err)
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
 
+// UpsertSQLTx inserts or updates metas within tx so that callers can commit
+// other writes, such as outbox events, atomically with the change. The
+// caller commits or rolls back tx.
+func UpsertSQLTx(ctx context.Context, tx *sql.Tx, driver, tablePrefix string, metas []FieldMeta) error {
+	if len(metas) == 0 {
+		return nil
+	}
 	tbl := TableName(tablePrefix, "custom_fields")
-	var stmt *sql.Stmt
+	var (
+		stmt *sql.Stmt
+		err  error
+	)
 	switch driver {
 	case "postgres":
 		stmt, err = tx.PrepareContext(ctx, fmt.Sprintf(`INSERT INTO %s (db_id, table_name, column_name, data_type, store_kind, kind, physical_type, driver_extras, label_key, widget, widget_config, placeholder_key, nullable, "unique", has_default, default_value, validator, created_at, updated_at) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17, NOW(), NOW()) ON CONFLICT (db_id, tenant_id, table_name, column_name) DO UPDATE SET data_type=EXCLUDED.data_type, store_kind=EXCLUDED.store_kind, kind=EXCLUDED.kind, physical_type=EXCLUDED.physical_type, driver_extras=EXCLUDED.driver_extras, label_key=EXCLUDED.label_key, widget=EXCLUDED.widget, widget_config=EXCLUDED.widget_config, placeholder_key=EXCLUDED.placeholder_key, nullable=EXCLUDED.nullable, "unique"=EXCLUDED."unique", has_default=EXCLUDED.has_default, default_value=EXCLUDED.default_value, validator=EXCLUDED.validator, updated_at=NOW()`, tbl))
 	case "mysql":
 		stmt, err = tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s (db_id, table_name, column_name, data_type, store_kind, kind, physical_type, driver_extras, label_key, widget, widget_config, placeholder_key, nullable, `unique`, has_default, default_value, validator, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW()) ON DUPLICATE KEY UPDATE data_type=VALUES(data_type), store_kind=VALUES(store_kind), kind=VALUES(kind), physical_type=VALUES(physical_type), driver_extras=VALUES(driver_extras), label_key=VALUES(label_key), widget=VALUES(widget), widget_config=VALUES(widget_config), placeholder_key=VALUES(placeholder_key), nullable=VALUES(nullable), `unique`=VALUES(`unique`), has_default=VALUES(has_default), default_value=VALUES(default_value), validator=VALUES(validator), updated_at=NOW()", tbl))
 	default:
-		if rbErr := tx.Rollback(); rbErr != nil {
-			return fmt.Errorf("rollback: %v: unsupported driver: %s", rbErr, driver)
-		}
 		return fmt.Errorf("unsupported driver: %s", driver)
 	}
 	if err != nil {
-		if rbErr := tx.Rollback(); rbErr != nil {
-			return fmt.Errorf("rollback: %v: prepare: %w", rbErr, err)
-		}
 		return fmt.Errorf("prepare: %w", err)
 	}
 	defer stmt.Close()
@@ -339,9 +373,6 @@ func UpsertSQL(ctx context.Context, db *sql.DB, driver, tablePrefix string, meta
 		if len(m.DriverExtras) > 0 {
 			encoded, err := json.Marshal(m.DriverExtras)
 			if err != nil {
-				if rbErr := tx.Rollback(); rbErr != nil {
-					return fmt.Errorf("rollback: %v: driver extras marshal: %w", rbErr, err)
-				}
 				return fmt.Errorf("driver extras marshal: %w", err)
 			}
 			extrasBytes = encoded
@@ -349,15 +380,9 @@ func UpsertSQL(ctx context.Context, db *sql.DB, driver, tablePrefix string, meta
 		extrasVal := string(extrasBytes)
 		dbid := monitordb.NormalizeDBID(m.DBID)
 		if _, err := stmt.ExecContext(ctx, dbid, m.TableName, m.ColumnName, m.DataType, storeKind, kind, physical, extrasVal, labelKey, widget, widgetCfg, placeholderKey, m.Nullable, m.Unique, m.HasDefault, def, m.Validator); err != nil {
-			if rbErr := tx.Rollback(); rbErr != nil {
-				return fmt.Errorf("rollback: %v: exec: %w", rbErr, err)
-			}
 			return fmt.Errorf("exec: %w", err)
 		}
 	}
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("commit: %w", err)
-	}
 	return nil
 }
 
@@ -370,6 +395,25 @@ func UpsertSQLByTenant(ctx context.Context, db *sql.DB, driver, tablePrefix, ten
 	if err != nil {
 		return 0, 0, fmt.Errorf("begin tx: %w", err)
 	}
+	inserted, updated, err = UpsertSQLByTenantTx(ctx, tx, driver, tablePrefix, tenant, metas)
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return 0, 0, fmt.Errorf("rollback: %v: %w", rbErr, err)
+		}
+		return 0, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("commit: %w", err)
+	}
+	return inserted, updated, nil
+}
+
+// UpsertSQLByTenantTx is like UpsertSQLByTenant but writes within tx. The
+// caller commits or rolls back tx.
+func UpsertSQLByTenantTx(ctx context.Context, tx *sql.Tx, driver, tablePrefix, tenant string, metas []FieldMeta) (inserted, updated int, err error) {
+	if len(metas) == 0 {
+		return 0, 0, nil
+	}
 	tbl := TableName(tablePrefix, "custom_fields")
 	var stmt *sql.Stmt
 	switch driver {
@@ -378,15 +422,9 @@ func UpsertSQLByTenant(ctx context.Context, db *sql.DB, driver, tablePrefix, ten
 	case "mysql":
 		stmt, err = tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s (db_id, tenant_id, table_name, column_name, data_type, store_kind, kind, physical_type, driver_extras, label_key, widget, widget_config, placeholder_key, nullable, `unique`, has_default, default_value, validator, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW()) ON DUPLICATE KEY UPDATE data_type=VALUES(data_type), store_kind=VALUES(store_kind), kind=VALUES(kind), physical_type=VALUES(physical_type), driver_extras=VALUES(driver_extras), label_key=VALUES(label_key), widget=VALUES(widget), widget_config=VALUES(widget_config), placeholder_key=VALUES(placeholder_key), nullable=VALUES(nullable), `unique`=VALUES(`unique`), has_default=VALUES(has_default), default_value=VALUES(default_value), validator=VALUES(validator), updated_at=NOW()", tbl))
 	default:
-		if rbErr := tx.Rollback(); rbErr != nil {
-			return 0, 0, fmt.Errorf("rollback: %v: unsupported driver: %s", rbErr, driver)
-		}
 		return 0, 0, fmt.Errorf("unsupported driver: %s", driver)
 	}
 	if err != nil {
-		if rbErr := tx.Rollback(); rbErr != nil {
-			return 0, 0, fmt.Errorf("rollback: %v: prepare: %w", rbErr, err)
-		}
 		return 0, 0, fmt.Errorf("prepare: %w", err)
 	}
 	defer stmt.Close()
@@ -417,9 +455,6 @@ func UpsertSQLByTenant(ctx context.Context, db *sql.DB, driver, tablePrefix, ten
 		if len(m.DriverExtras) > 0 {
 			encoded, err := json.Marshal(m.DriverExtras)
 			if err != nil {
-				if rbErr := tx.Rollback(); rbErr != nil {
-					return 0, 0, fmt.Errorf("rollback: %v: driver extras marshal: %w", rbErr, err)
-				}
 				return 0, 0, fmt.Errorf("driver extras marshal: %w", err)
 			}
 			extrasBytes = encoded
@@ -429,9 +464,6 @@ func UpsertSQLByTenant(ctx context.Context, db *sql.DB, driver, tablePrefix, ten
 		case "postgres":
 			var isInsert bool
 			if err := stmt.QueryRowContext(ctx, dbid, tenant, m.TableName, m.ColumnName, m.DataType, storeKind, kind, physical, extrasVal, labelKey, widget, widgetCfg, placeholderKey, m.Nullable, m.Unique, m.HasDefault, def, m.Validator).Scan(&isInsert); err != nil {
-				if rbErr := tx.Rollback(); rbErr != nil {
-					return 0, 0, fmt.Errorf("rollback: %v: exec: %w", rbErr, err)
-				}
 				return 0, 0, fmt.Errorf("exec: %w", err)
 			}
 			if isInsert {
@@ -442,9 +474,6 @@ func UpsertSQLByTenant(ctx context.Context, db *sql.DB, driver, tablePrefix, ten
 		case "mysql":
 			res, err := stmt.ExecContext(ctx, dbid, tenant, m.TableName, m.ColumnName, m.DataType, storeKind, kind, physical, extrasVal, labelKey, widget, widgetCfg, placeholderKey, m.Nullable, m.Unique, m.HasDefault, def, m.Validator)
 			if err != nil {
-				if rbErr := tx.Rollback(); rbErr != nil {
-					return 0, 0, fmt.Errorf("rollback: %v: exec: %w", rbErr, err)
-				}
 				return 0, 0, fmt.Errorf("exec: %w", err)
 			}
 			ra, _ := res.RowsAffected()
@@ -455,9 +484,6 @@ func UpsertSQLByTenant(ctx context.Context, db *sql.DB, driver, tablePrefix, ten
 			}
 		}
 	}
-	if err := tx.Commit(); err != nil {
-		return 0, 0, fmt.Errorf("commit: %w", err)
-	}
 	return inserted, updated, nil
 }
 
@@ -480,37 +506,98 @@ func DeleteSQL(ctx context.Context, db *sql.DB, driver, tablePrefix string, meta
 	if err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
+	if err := DeleteSQLTx(ctx, tx, driver, tablePrefix, metas); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("rollback: %v: %w", rbErr, err)
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+// DeleteSQLTx removes metas within tx. The caller commits or rolls back tx.
+func DeleteSQLTx(ctx context.Context, tx *sql.Tx, driver, tablePrefix string, metas []FieldMeta) error {
+	if len(metas) == 0 {
+		return nil
+	}
 	tbl := TableName(tablePrefix, "custom_fields")
-	var stmt *sql.Stmt
+	var (
+		stmt *sql.Stmt
+		err  error
+	)
 	switch driver {
 	case "postgres":
 		stmt, err = tx.PrepareContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE db_id = $1 AND table_name = $2 AND column_name = $3`, tbl))
 	case "mysql":
 		stmt, err = tx.PrepareContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE db_id = ? AND table_name = ? AND column_name = ?`, tbl))
 	default:
-		if rbErr := tx.Rollback(); rbErr != nil {
-			return fmt.Errorf("rollback: %v: unsupported driver: %s", rbErr, driver)
-		}
 		return fmt.Errorf("unsupported driver: %s", driver)
 	}
 	if err != nil {
-		if rbErr := tx.Rollback(); rbErr != nil {
-			return fmt.Errorf("rollback: %v: prepare: %w", rbErr, err)
-		}
 		return fmt.Errorf("prepare: %w", err)
 	}
 	defer stmt.Close()
 	for _, m := range metas {
 		dbid := monitordb.NormalizeDBID(m.DBID)
 		if _, err := stmt.ExecContext(ctx, dbid, m.TableName, m.ColumnName); err != nil {
-			if rbErr := tx.Rollback(); rbErr != nil {
-				return fmt.Errorf("rollback: %v: exec: %w", rbErr, err)
-			}
 			return fmt.Errorf("exec: %w", err)
 		}
 	}
+	return nil
+}
+
+// DeleteSQLByTenant removes field definitions belonging to tenant.
+func DeleteSQLByTenant(ctx context.Context, db *sql.DB, driver, tablePrefix, tenant string, metas []FieldMeta) error {
+	if len(metas) == 0 {
+		return nil
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	if err := DeleteSQLByTenantTx(ctx, tx, driver, tablePrefix, tenant, metas); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("rollback: %v: %w", rbErr, err)
+		}
+		return err
+	}
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("commit: %w", err)
 	}
 	return nil
 }
+
+// DeleteSQLByTenantTx is like DeleteSQLByTenant but deletes within tx. The
+// caller commits or rolls back tx.
+func DeleteSQLByTenantTx(ctx context.Context, tx *sql.Tx, driver, tablePrefix, tenant string, metas []FieldMeta) error {
+	if len(metas) == 0 {
+		return nil
+	}
+	tbl := TableName(tablePrefix, "custom_fields")
+	var (
+		stmt *sql.Stmt
+		err  error
+	)
+	switch driver {
+	case "postgres":
+		stmt, err = tx.PrepareContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE db_id = $1 AND tenant_id = $2 AND table_name = $3 AND column_name = $4`, tbl))
+	case "mysql":
+		stmt, err = tx.PrepareContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE db_id = ? AND tenant_id = ? AND table_name = ? AND column_name = ?`, tbl))
+	default:
+		return fmt.Errorf("unsupported driver: %s", driver)
+	}
+	if err != nil {
+		return fmt.Errorf("prepare: %w", err)
+	}
+	defer stmt.Close()
+	for _, m := range metas {
+		dbid := monitordb.NormalizeDBID(m.DBID)
+		if _, err := stmt.ExecContext(ctx, dbid, tenant, m.TableName, m.ColumnName); err != nil {
+			return fmt.Errorf("exec: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDeleteSQLByTenantScopesTenant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare("DELETE FROM gcfm_custom_fields WHERE db_id = \\$1 AND tenant_id = \\$2").
+		ExpectExec().
+		WithArgs(int64(1), "prod", "posts", "title").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	metas := []FieldMeta{{DBID: 1, TableName: "posts", ColumnName: "title"}}
+	if err := DeleteSQLByTenant(context.Background(), db, "postgres", "gcfm_", "prod", metas); err != nil {
+		t.Fatalf("DeleteSQLByTenant: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations were not met: %v", err)
+	}
+}
@@ -9,11 +9,17 @@ import (
 // AuditLog represents a single audit log entry
 // returned by GET /v1/audit-logs
 type AuditLog struct {
-	ID         int            `json:"id"`
-	Actor      string         `json:"actor"`
-	Action     string         `json:"action"`
-	TableName  string         `json:"tableName"`
-	ColumnName string         `json:"columnName"`
+	ID         int    `json:"id"`
+	Actor      string `json:"actor"`
+	Action     string `json:"action"`
+	TableName  string `json:"tableName"`
+	ColumnName string `json:"columnName"`
+	// ResourceType and ResourceID identify the audited resource.
+	ResourceType string `json:"resourceType,omitempty"`
+	ResourceID   string `json:"resourceId,omitempty"`
+	RequestID    string `json:"requestId,omitempty"`
+	// RevertOf is the ID of the entry this entry reverted.
+	RevertOf   int64          `json:"revertOf,omitempty"`
 	BeforeJSON sql.NullString `json:"-"`
 	AfterJSON  sql.NullString `json:"-"`
 	AppliedAt  time.Time      `json:"appliedAt"`
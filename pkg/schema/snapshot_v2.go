@@ -8,6 +8,7 @@ type Snapshot struct {
 	Semver  string    `json:"semver"`
 	TakenAt time.Time `json:"takenAt"`
 	Author  string    `json:"author,omitempty"`
+	Tags    []string  `json:"tags,omitempty"`
 }
 
 // SnapshotCreateRequest is the body for POST /v1/snapshots.
@@ -15,4 +16,31 @@ type SnapshotCreateRequest struct {
 	Bump    string `json:"bump,omitempty"`
 	Semver  string `json:"semver,omitempty"`
 	Message string `json:"message,omitempty"`
+	// Bundle captures the full tenant configuration instead of only the
+	// custom field registry.
+	Bundle bool `json:"bundle,omitempty"`
+}
+
+// SnapshotApplyResult reports what POST /v1/snapshots/{ver}/apply restored.
+type SnapshotApplyResult struct {
+	Parts   []string `json:"parts"`
+	Added   int      `json:"added"`
+	Deleted int      `json:"deleted"`
+	Updated int      `json:"updated"`
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// SnapshotRetention is the per-tenant snapshot retention policy.
+// KeepWithin is a Go duration string such as "720h".
+type SnapshotRetention struct {
+	KeepLast   int    `json:"keepLast"`
+	KeepWithin string `json:"keepWithin,omitempty"`
+}
+
+// SnapshotPruneResult reports snapshots removed by POST /v1/snapshots/prune.
+type SnapshotPruneResult struct {
+	DryRun  bool       `json:"dryRun"`
+	Deleted []Snapshot `json:"deleted"`
+	Kept    int        `json:"kept"`
+	Bytes   int64      `json:"bytes"`
 }
@@ -14,6 +14,7 @@ type Target struct {
 	ConnMaxIdleMs int       `json:"connMaxIdleMs,omitempty" validate:"omitempty,min=0"`
 	ConnMaxLifeMs int       `json:"connMaxLifeMs,omitempty" validate:"omitempty,min=0"`
 	IsDefault     bool      `json:"isDefault,omitempty"`
+	Draining      bool      `json:"draining,omitempty"`
 	UpdatedAt     time.Time `json:"updatedAt,omitempty"`
 }
 
@@ -39,3 +40,17 @@ type TargetsList struct {
 	Items      []Target `json:"items"`
 	NextCursor string   `json:"nextCursor,omitempty"`
 }
+
+// TargetHealth reports the circuit breaker state of a target as seen by the
+// active health prober.
+type TargetHealth struct {
+	Key            string     `json:"key"`
+	State          string     `json:"state" enum:"closed,open,half_open"`
+	Failures       int        `json:"failures"`
+	OpenUntil      *time.Time `json:"openUntil,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+	LastErrorAt    *time.Time `json:"lastErrorAt,omitempty"`
+	LastSuccessAt  *time.Time `json:"lastSuccessAt,omitempty"`
+	LastProbeAt    *time.Time `json:"lastProbeAt,omitempty"`
+	ProbeLatencyMs int64      `json:"probeLatencyMs,omitempty"`
+}
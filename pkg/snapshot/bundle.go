@@ -0,0 +1,354 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/faciam-dev/gcfm/pkg/widgetpolicy"
+	"gopkg.in/yaml.v3"
+)
+
+// BundleVersion is the version of the bundle format written by EncodeBundle.
+const BundleVersion = 1
+
+// Bundle parts. Each part is stored as one typed document in the bundle and
+// can be selected individually when restoring.
+const (
+	PartRegistry       = "registry"
+	PartWidgetPolicies = "widget_policies"
+	PartWidgets        = "widgets"
+	PartRBAC           = "rbac"
+	PartDatabases      = "databases"
+	PartTargets        = "targets"
+)
+
+// BundleParts lists all parts in the order they are written and restored.
+var BundleParts = []string{PartRegistry, PartWidgetPolicies, PartWidgets, PartRBAC, PartDatabases, PartTargets}
+
+var partKinds = map[string]string{
+	PartRegistry:       "Registry",
+	PartWidgetPolicies: "WidgetPolicy",
+	PartWidgets:        "Widgets",
+	PartRBAC:           "RBAC",
+	PartDatabases:      "MonitoredDatabases",
+	PartTargets:        "TargetLabels",
+}
+
+const manifestName = "manifest.yaml"
+
+// BundleManifest describes the documents contained in a bundle.
+type BundleManifest struct {
+	Kind      string        `yaml:"kind"`
+	Version   int           `yaml:"version"`
+	Tenant    string        `yaml:"tenant"`
+	CreatedAt time.Time     `yaml:"createdAt"`
+	Documents []BundleEntry `yaml:"documents"`
+}
+
+// BundleEntry names one document of a bundle.
+type BundleEntry struct {
+	Name string `yaml:"name"`
+	Kind string `yaml:"kind"`
+}
+
+// BundleWidget is an installed widget as captured in a bundle.
+// TenantEnabled reflects the per-tenant toggle of the bundle tenant.
+type BundleWidget struct {
+	ID            string         `yaml:"id"`
+	Name          string         `yaml:"name"`
+	Version       string         `yaml:"version"`
+	Type          string         `yaml:"type"`
+	Scopes        []string       `yaml:"scopes,omitempty"`
+	Enabled       bool           `yaml:"enabled"`
+	Description   string         `yaml:"description,omitempty"`
+	Capabilities  []string       `yaml:"capabilities,omitempty"`
+	Homepage      string         `yaml:"homepage,omitempty"`
+	Meta          map[string]any `yaml:"meta,omitempty"`
+	TenantScope   string         `yaml:"tenantScope"`
+	Tenants       []string       `yaml:"tenants,omitempty"`
+	TenantEnabled bool           `yaml:"tenantEnabled"`
+}
+
+// BundleRole is an RBAC role with its path/method policies.
+type BundleRole struct {
+	Name     string         `yaml:"name"`
+	Comment  string         `yaml:"comment,omitempty"`
+	Policies []BundlePolicy `yaml:"policies,omitempty"`
+}
+
+// BundlePolicy grants a role access to a path and method.
+type BundlePolicy struct {
+	Path   string `yaml:"path"`
+	Method string `yaml:"method"`
+}
+
+// BundleDatabase is a monitored database definition. Connection strings are
+// never captured.
+type BundleDatabase struct {
+	Name   string `yaml:"name"`
+	Driver string `yaml:"driver"`
+	Schema string `yaml:"schema,omitempty"`
+}
+
+// BundleTargetLabels records the labels of a target.
+type BundleTargetLabels struct {
+	Key    string   `yaml:"key"`
+	Labels []string `yaml:"labels"`
+}
+
+// Bundle is the full configuration of a tenant. Nil or empty parts are
+// omitted from the encoded bundle.
+type Bundle struct {
+	Manifest     BundleManifest
+	Registry     []byte
+	WidgetPolicy *widgetpolicy.WidgetPolicy
+	Widgets      []BundleWidget
+	Roles        []BundleRole
+	Databases    []BundleDatabase
+	TargetLabels []BundleTargetLabels
+}
+
+// Has reports whether the bundle contains part.
+func (b *Bundle) Has(part string) bool {
+	for _, d := range b.Manifest.Documents {
+		if d.Name == part+".yaml" {
+			return true
+		}
+	}
+	return false
+}
+
+// Parts returns the parts contained in the bundle in restore order.
+func (b *Bundle) Parts() []string {
+	var out []string
+	for _, p := range BundleParts {
+		if b.Has(p) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+type bundleDoc struct {
+	Kind    string `yaml:"kind"`
+	Version int    `yaml:"version"`
+	Spec    any    `yaml:"spec"`
+}
+
+// EncodeBundle writes b as an uncompressed tar archive. The archive holds
+// manifest.yaml followed by one YAML document per part. The registry part is
+// stored verbatim so that it can be applied like a regular snapshot.
+func EncodeBundle(b *Bundle) ([]byte, error) {
+	type file struct {
+		name string
+		data []byte
+	}
+	var files []file
+	add := func(part string, spec any) error {
+		data, err := yaml.Marshal(bundleDoc{Kind: partKinds[part], Version: BundleVersion, Spec: spec})
+		if err != nil {
+			return err
+		}
+		files = append(files, file{name: part + ".yaml", data: data})
+		return nil
+	}
+	if len(b.Registry) > 0 {
+		files = append(files, file{name: PartRegistry + ".yaml", data: b.Registry})
+	}
+	if b.WidgetPolicy != nil {
+		if err := add(PartWidgetPolicies, b.WidgetPolicy); err != nil {
+			return nil, err
+		}
+	}
+	if b.Widgets != nil {
+		if err := add(PartWidgets, b.Widgets); err != nil {
+			return nil, err
+		}
+	}
+	if b.Roles != nil {
+		if err := add(PartRBAC, map[string]any{"roles": b.Roles}); err != nil {
+			return nil, err
+		}
+	}
+	if b.Databases != nil {
+		if err := add(PartDatabases, b.Databases); err != nil {
+			return nil, err
+		}
+	}
+	if b.TargetLabels != nil {
+		if err := add(PartTargets, b.TargetLabels); err != nil {
+			return nil, err
+		}
+	}
+
+	m := b.Manifest
+	m.Kind = "Bundle"
+	m.Version = BundleVersion
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = time.Now().UTC()
+	}
+	m.Documents = nil
+	for _, f := range files {
+		m.Documents = append(m.Documents, BundleEntry{Name: f.name, Kind: partKinds[strings.TrimSuffix(f.name, ".yaml")]})
+	}
+	mdata, err := yaml.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	files = append([]file{{name: manifestName, data: mdata}}, files...)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		hdr := &tar.Header{Name: f.name, Mode: 0o600, Size: int64(len(f.data)), ModTime: m.CreatedAt, Format: tar.FormatPAX}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	b.Manifest = m
+	return buf.Bytes(), nil
+}
+
+// IsBundle reports whether data is a bundle archive rather than registry YAML.
+func IsBundle(data []byte) bool {
+	return len(data) >= 262 && string(data[257:262]) == "ustar"
+}
+
+// RegistryYAML returns the registry YAML of a decoded snapshot, extracting
+// it from the bundle when data is a bundle archive.
+func RegistryYAML(data []byte) ([]byte, error) {
+	if !IsBundle(data) {
+		return data, nil
+	}
+	b, err := DecodeBundle(data)
+	if err != nil {
+		return nil, err
+	}
+	return b.Registry, nil
+}
+
+// DecodeBundle parses a bundle archive produced by EncodeBundle.
+func DecodeBundle(data []byte) (*Bundle, error) {
+	if !IsBundle(data) {
+		return nil, errors.New("not a bundle archive")
+	}
+	files := map[string][]byte{}
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[hdr.Name] = b
+	}
+	mdata, ok := files[manifestName]
+	if !ok {
+		return nil, errors.New("bundle has no manifest")
+	}
+	b := &Bundle{}
+	if err := yaml.Unmarshal(mdata, &b.Manifest); err != nil {
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+	if b.Manifest.Kind != "Bundle" {
+		return nil, fmt.Errorf("manifest: unexpected kind %q", b.Manifest.Kind)
+	}
+	if b.Manifest.Version > BundleVersion {
+		return nil, fmt.Errorf("bundle version %d is newer than supported version %d", b.Manifest.Version, BundleVersion)
+	}
+	for _, e := range b.Manifest.Documents {
+		raw, ok := files[e.Name]
+		if !ok {
+			return nil, fmt.Errorf("bundle document %s missing", e.Name)
+		}
+		part := strings.TrimSuffix(e.Name, ".yaml")
+		if part == PartRegistry {
+			b.Registry = raw
+			continue
+		}
+		var spec any
+		switch part {
+		case PartWidgetPolicies:
+			b.WidgetPolicy = &widgetpolicy.WidgetPolicy{}
+			spec = b.WidgetPolicy
+		case PartWidgets:
+			spec = &b.Widgets
+		case PartRBAC:
+			spec = &struct {
+				Roles *[]BundleRole `yaml:"roles"`
+			}{Roles: &b.Roles}
+		case PartDatabases:
+			spec = &b.Databases
+		case PartTargets:
+			spec = &b.TargetLabels
+		default:
+			// Documents added by newer minor revisions are ignored.
+			continue
+		}
+		if err := decodeBundleDoc(raw, partKinds[part], spec); err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Name, err)
+		}
+	}
+	return b, nil
+}
+
+func decodeBundleDoc(raw []byte, kind string, spec any) error {
+	var doc struct {
+		Kind    string    `yaml:"kind"`
+		Version int       `yaml:"version"`
+		Spec    yaml.Node `yaml:"spec"`
+	}
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+	if doc.Kind != kind {
+		return fmt.Errorf("unexpected kind %q, want %q", doc.Kind, kind)
+	}
+	if doc.Version > BundleVersion {
+		return fmt.Errorf("document version %d is newer than supported version %d", doc.Version, BundleVersion)
+	}
+	return doc.Spec.Decode(spec)
+}
+
+// ParseInclude parses a comma separated list of bundle parts. An empty
+// string selects every part.
+func ParseInclude(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return append([]string(nil), BundleParts...), nil
+	}
+	seen := map[string]bool{}
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if _, ok := partKinds[p]; !ok {
+			return nil, fmt.Errorf("unknown bundle part %q (valid: %s)", p, strings.Join(BundleParts, ", "))
+		}
+		seen[p] = true
+	}
+	var out []string
+	for _, p := range BundleParts {
+		if seen[p] {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,70 @@
+package snapshot
+
+import (
+	"testing"
+
+	"github.com/faciam-dev/gcfm/pkg/widgetpolicy"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	in := &Bundle{
+		Manifest:     BundleManifest{Tenant: "dev"},
+		Registry:     []byte("version: 0.4\nfields: []\n"),
+		WidgetPolicy: &widgetpolicy.WidgetPolicy{Version: 1, SuggestTop: 3},
+		Widgets:      []BundleWidget{{ID: "color", Name: "Color", Version: "1.0.0", Enabled: true, TenantScope: "system"}},
+		Roles:        []BundleRole{{Name: "editor", Policies: []BundlePolicy{{Path: "/v1/*", Method: "GET"}}}},
+		Databases:    []BundleDatabase{{Name: "main", Driver: "postgres", Schema: "public"}},
+	}
+	data, err := EncodeBundle(in)
+	if err != nil {
+		t.Fatalf("EncodeBundle: %v", err)
+	}
+	if !IsBundle(data) {
+		t.Fatal("encoded bundle not detected")
+	}
+	if IsBundle(in.Registry) {
+		t.Fatal("registry YAML detected as bundle")
+	}
+	out, err := DecodeBundle(data)
+	if err != nil {
+		t.Fatalf("DecodeBundle: %v", err)
+	}
+	if out.Manifest.Tenant != "dev" || string(out.Registry) != string(in.Registry) {
+		t.Fatalf("unexpected bundle: %+v", out.Manifest)
+	}
+	if out.WidgetPolicy == nil || out.WidgetPolicy.SuggestTop != 3 {
+		t.Fatalf("widget policy not restored: %+v", out.WidgetPolicy)
+	}
+	if len(out.Widgets) != 1 || out.Widgets[0].ID != "color" {
+		t.Fatalf("widgets not restored: %+v", out.Widgets)
+	}
+	if len(out.Roles) != 1 || len(out.Roles[0].Policies) != 1 {
+		t.Fatalf("roles not restored: %+v", out.Roles)
+	}
+	if len(out.Databases) != 1 || out.Databases[0].Schema != "public" {
+		t.Fatalf("databases not restored: %+v", out.Databases)
+	}
+	if out.Has(PartTargets) {
+		t.Fatal("targets part should be absent")
+	}
+	want := []string{PartRegistry, PartWidgetPolicies, PartWidgets, PartRBAC, PartDatabases}
+	if got := out.Parts(); len(got) != len(want) {
+		t.Fatalf("Parts() = %v, want %v", got, want)
+	}
+}
+
+func TestParseInclude(t *testing.T) {
+	got, err := ParseInclude("rbac, registry")
+	if err != nil {
+		t.Fatalf("ParseInclude: %v", err)
+	}
+	if len(got) != 2 || got[0] != PartRegistry || got[1] != PartRBAC {
+		t.Fatalf("unexpected parts: %v", got)
+	}
+	if all, _ := ParseInclude(""); len(all) != len(BundleParts) {
+		t.Fatalf("empty include should select all parts: %v", all)
+	}
+	if _, err := ParseInclude("secrets"); err == nil {
+		t.Fatal("expected error for unknown part")
+	}
+}
@@ -54,7 +54,21 @@ type SnapshotData struct {
 	YAML   []byte
 }
 
+// execer is satisfied by *sql.DB and *sql.Tx.
+type execer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	Exec(query string, args ...any) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
 func Insert(ctx context.Context, db *sql.DB, dialect ormdriver.Dialect, prefix string, data SnapshotData) (Record, error) {
+	return insert(ctx, db, dialect, prefix, data)
+}
+
+func insert(ctx context.Context, db execer, dialect ormdriver.Dialect, prefix string, data SnapshotData) (Record, error) {
 	table := prefix + "registry_snapshots"
 	id, err := query.New(db, table, dialect).WithContext(ctx).InsertGetId(map[string]any{
 		"tenant_id": data.Tenant,
@@ -109,6 +123,10 @@ func List(ctx context.Context, db *sql.DB, dialect ormdriver.Dialect, prefix, te
 }
 
 func LatestSemver(ctx context.Context, db *sql.DB, dialect ormdriver.Dialect, prefix, tenant string) (string, error) {
+	return latestSemver(ctx, db, dialect, prefix, tenant)
+}
+
+func latestSemver(ctx context.Context, db execer, dialect ormdriver.Dialect, prefix, tenant string) (string, error) {
 	table := prefix + "registry_snapshots"
 	q := query.New(db, table, dialect).
 		Select("semver").
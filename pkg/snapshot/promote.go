@@ -0,0 +1,213 @@
+package snapshot
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/faciam-dev/gcfm/pkg/audit"
+	"github.com/faciam-dev/gcfm/pkg/registry"
+	"github.com/faciam-dev/gcfm/pkg/registry/codec"
+	"github.com/faciam-dev/gcfm/pkg/tenant"
+	sdk "github.com/faciam-dev/gcfm/sdk"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+	"github.com/faciam-dev/goquent/orm/query"
+)
+
+// Promotion links a source snapshot to the snapshot created in the
+// destination tenant when it was promoted.
+type Promotion struct {
+	ID               int64     `db:"id" json:"id"`
+	Tag              string    `db:"tag" json:"tag"`
+	SourceTenant     string    `db:"source_tenant" json:"sourceTenant"`
+	SourceSnapshotID int64     `db:"source_snapshot_id" json:"sourceSnapshotId"`
+	SourceSemver     string    `db:"source_semver" json:"sourceSemver"`
+	DestTenant       string    `db:"dest_tenant" json:"destTenant"`
+	DestSnapshotID   int64     `db:"dest_snapshot_id" json:"destSnapshotId"`
+	DestSemver       string    `db:"dest_semver" json:"destSemver"`
+	Actor            string    `db:"actor" json:"actor,omitempty"`
+	PromotedAt       time.Time `db:"promoted_at" json:"promotedAt"`
+}
+
+// PromoteOptions configures Promote.
+type PromoteOptions struct {
+	FromTenant string
+	ToTenant   string
+	Tag        string
+	Actor      string
+	// Bump selects the semver bump of the destination snapshot (default patch).
+	Bump string
+	// DryRun only computes the diff against the destination registry.
+	DryRun bool
+}
+
+// PromoteResult describes a promotion. Dest and Promotion are empty for dry
+// runs.
+type PromoteResult struct {
+	Source    Record
+	Dest      Record
+	Changes   []registry.Change
+	Report    sdk.DiffReport
+	Promotion Promotion
+}
+
+// Promote applies the snapshot tagged opts.Tag in opts.FromTenant to the
+// registry of opts.ToTenant. The registry changes, the new destination
+// snapshot carrying the same tag and the lineage between both snapshots are
+// written in one transaction, so a failed promotion leaves the destination
+// untouched.
+func Promote(ctx context.Context, db *sql.DB, dialect ormdriver.Dialect, driver, prefix string, opts PromoteOptions, rec *audit.Recorder) (PromoteResult, error) {
+	var res PromoteResult
+	if opts.FromTenant == opts.ToTenant {
+		return res, errors.New("source and destination tenant must differ")
+	}
+	src, err := GetByTag(ctx, db, dialect, prefix, opts.FromTenant, opts.Tag)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return res, fmt.Errorf("tag %q not found in tenant %s", opts.Tag, opts.FromTenant)
+		}
+		return res, err
+	}
+	res.Source = src
+	srcData, err := Decode(src.YAML)
+	if err != nil {
+		return res, err
+	}
+	srcYAML, err := RegistryYAML(srcData)
+	if err != nil {
+		return res, err
+	}
+	current, err := SnapshotYaml(ctx, db, driver, prefix, opts.ToTenant)
+	if err != nil {
+		return res, err
+	}
+	res.Changes, err = DiffYaml(current, srcYAML)
+	if err != nil {
+		return res, err
+	}
+	res.Report = sdk.CalculateDiff(res.Changes)
+	if opts.DryRun {
+		return res, nil
+	}
+
+	ctx = tenant.WithTenant(ctx, opts.ToTenant)
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return res, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	changes, err := ApplyYamlTx(ctx, tx, driver, prefix, opts.ToTenant, srcYAML)
+	if err != nil {
+		return res, err
+	}
+	res.Changes = changes
+	res.Report = sdk.CalculateDiff(changes)
+	forwards := make([]func(), 0, len(changes))
+	for _, c := range changes {
+		forward, err := rec.WriteTx(ctx, tx, opts.Actor, c.Old, c.New)
+		if err != nil {
+			return res, err
+		}
+		forwards = append(forwards, forward)
+	}
+	metas, err := registry.LoadSQLByTenantTx(ctx, tx, registry.DBConfig{Schema: "public", Driver: driver, TablePrefix: prefix}, opts.ToTenant)
+	if err != nil {
+		return res, err
+	}
+	applied, err := codec.EncodeYAML(metas)
+	if err != nil {
+		return res, err
+	}
+	comp, err := Encode(applied)
+	if err != nil {
+		return res, err
+	}
+	last, err := latestSemver(ctx, tx, dialect, prefix, opts.ToTenant)
+	if err != nil {
+		return res, err
+	}
+	bump := opts.Bump
+	if bump == "" {
+		bump = "patch"
+	}
+	dest, err := insert(ctx, tx, dialect, prefix, SnapshotData{
+		Tenant: opts.ToTenant,
+		Semver: NextSemver(last, bump),
+		Author: opts.Actor,
+		YAML:   comp,
+	})
+	if err != nil {
+		return res, err
+	}
+	if err := setTag(ctx, tx, dialect, prefix, opts.ToTenant, opts.Tag, dest.ID); err != nil {
+		return res, err
+	}
+	p := Promotion{
+		Tag:              opts.Tag,
+		SourceTenant:     opts.FromTenant,
+		SourceSnapshotID: src.ID,
+		SourceSemver:     src.Semver,
+		DestTenant:       opts.ToTenant,
+		DestSnapshotID:   dest.ID,
+		DestSemver:       dest.Semver,
+		Actor:            opts.Actor,
+		PromotedAt:       time.Now().UTC(),
+	}
+	p.ID, err = query.New(tx, prefix+"snapshot_promotions", dialect).WithContext(ctx).InsertGetId(map[string]any{
+		"tag":                p.Tag,
+		"source_tenant":      p.SourceTenant,
+		"source_snapshot_id": p.SourceSnapshotID,
+		"source_semver":      p.SourceSemver,
+		"dest_tenant":        p.DestTenant,
+		"dest_snapshot_id":   p.DestSnapshotID,
+		"dest_semver":        p.DestSemver,
+		"actor":              p.Actor,
+		"promoted_at":        p.PromotedAt,
+	})
+	if err != nil {
+		return res, err
+	}
+	if err := tx.Commit(); err != nil {
+		return res, err
+	}
+	for _, f := range forwards {
+		f()
+	}
+	res.Dest = dest
+	res.Promotion = p
+	summary, err := json.Marshal(map[string]any{
+		"tag":         p.Tag,
+		"from_tenant": p.SourceTenant,
+		"from_semver": p.SourceSemver,
+		"added":       res.Report.Added,
+		"deleted":     res.Report.Deleted,
+		"updated":     res.Report.Updated,
+	})
+	if err != nil {
+		return res, err
+	}
+	if err := rec.WriteAction(ctx, opts.Actor, "promote", dest.Semver, string(summary)); err != nil {
+		return res, fmt.Errorf("promoted to %s@%s but recording the audit entry failed: %w", p.DestTenant, dest.Semver, err)
+	}
+	return res, nil
+}
+
+// Lineage returns the promotions the snapshot with the given id took part
+// in, either as source or as destination, newest first.
+func Lineage(ctx context.Context, db *sql.DB, dialect ormdriver.Dialect, prefix string, snapshotID int64) ([]Promotion, error) {
+	var rows []Promotion
+	err := query.New(db, prefix+"snapshot_promotions", dialect).
+		Select("id", "tag", "source_tenant", "source_snapshot_id", "source_semver", "dest_tenant", "dest_snapshot_id", "dest_semver", "actor", "promoted_at").
+		Where("source_snapshot_id", snapshotID).
+		OrWhere("dest_snapshot_id", snapshotID).
+		OrderBy("id", "desc").
+		WithContext(ctx).
+		Get(&rows)
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
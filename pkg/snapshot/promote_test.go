@@ -0,0 +1,58 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/faciam-dev/gcfm/pkg/registry/codec"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+)
+
+func TestPromoteRollsBackOnFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	empty, err := codec.EncodeYAML(nil)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	comp, err := Encode(empty)
+	if err != nil {
+		t.Fatalf("compress: %v", err)
+	}
+	now := time.Now()
+	mock.ExpectQuery("FROM `registry_snapshot_tags`").
+		WillReturnRows(sqlmock.NewRows([]string{"snapshot_id"}).AddRow(7))
+	mock.ExpectQuery("FROM `registry_snapshots`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "semver", "yaml", "taken_at", "author"}).AddRow(7, "1.0.0", comp, now, "a"))
+	mock.ExpectQuery("FROM `gcfm_custom_fields`").WillReturnRows(sqlmock.NewRows([]string{"db_id"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM `gcfm_custom_fields`").WillReturnRows(sqlmock.NewRows([]string{"db_id"}))
+	mock.ExpectQuery("FROM `gcfm_custom_fields`").WillReturnRows(sqlmock.NewRows([]string{"db_id"}))
+	mock.ExpectQuery("SELECT `semver` FROM `registry_snapshots`").
+		WillReturnRows(sqlmock.NewRows([]string{"semver"}).AddRow("0.1.0"))
+	mock.ExpectExec("INSERT INTO `registry_snapshots`").WillReturnResult(sqlmock.NewResult(8, 1))
+	mock.ExpectQuery("SELECT `taken_at` FROM `registry_snapshots`").
+		WillReturnRows(sqlmock.NewRows([]string{"taken_at"}).AddRow(now))
+	mock.ExpectExec("INSERT INTO `registry_snapshot_tags`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO `snapshot_promotions`").WillReturnError(errors.New("boom"))
+	mock.ExpectRollback()
+
+	_, err = Promote(context.Background(), db, ormdriver.MySQLDialect{}, "mysql", "", PromoteOptions{
+		FromTenant: "dev",
+		ToTenant:   "prod",
+		Tag:        "release",
+	}, nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
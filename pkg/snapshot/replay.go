@@ -0,0 +1,161 @@
+package snapshot
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/faciam-dev/gcfm/pkg/audit"
+	"github.com/faciam-dev/gcfm/pkg/monitordb"
+	"github.com/faciam-dev/gcfm/pkg/registry"
+	"github.com/faciam-dev/gcfm/pkg/registry/codec"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+	"github.com/faciam-dev/goquent/orm/query"
+)
+
+// PointInTime is a registry reconstructed by RegistryAt.
+type PointInTime struct {
+	At time.Time
+	// Base is the snapshot replay started from. It is nil when no snapshot
+	// precedes At and the registry was rebuilt from an empty state.
+	Base *Record
+	// Replayed counts the audit entries applied on top of Base.
+	Replayed int
+	YAML     []byte
+}
+
+// AuditChange is a field change recorded in the audit log.
+type AuditChange struct {
+	ID     int64          `db:"id"`
+	Action string         `db:"action"`
+	Before sql.NullString `db:"before_json"`
+	After  sql.NullString `db:"after_json"`
+}
+
+// RegistryAt rebuilds the registry of tenant as it was at the given time. It
+// starts from the newest snapshot taken at or before at and replays the field
+// changes recorded in the audit log up to at.
+func RegistryAt(ctx context.Context, db *sql.DB, dialect ormdriver.Dialect, prefix, tenant string, at time.Time) (PointInTime, error) {
+	res := PointInTime{At: at}
+	var metas []registry.FieldMeta
+	var base Record
+	err := query.New(db, prefix+"registry_snapshots", dialect).
+		Select("id", "semver", "yaml", "taken_at", "author").
+		Where("tenant_id", tenant).
+		Where("taken_at", "<=", at).
+		OrderBy("taken_at", "desc").
+		OrderBy("id", "desc").
+		Limit(1).
+		WithContext(ctx).
+		First(&base)
+	switch {
+	case err == nil:
+		data, err := Decode(base.YAML)
+		if err != nil {
+			return res, fmt.Errorf("snapshot %s: %w", base.Semver, err)
+		}
+		if data, err = RegistryYAML(data); err != nil {
+			return res, fmt.Errorf("snapshot %s: %w", base.Semver, err)
+		}
+		if metas, err = codec.DecodeYAML(data); err != nil {
+			return res, fmt.Errorf("snapshot %s: %w", base.Semver, err)
+		}
+		base.YAML = nil
+		res.Base = &base
+	case errors.Is(err, sql.ErrNoRows):
+	default:
+		return res, err
+	}
+
+	textCast := "CAST(%s AS CHAR)"
+	if _, ok := dialect.(ormdriver.PostgresDialect); ok {
+		textCast = "%s::text"
+	}
+	q := query.New(db, prefix+"audit_logs", dialect).
+		Select("id", "action").
+		SelectRaw(fmt.Sprintf(textCast, "before_json")+" AS before_json").
+		SelectRaw(fmt.Sprintf(textCast, "after_json")+" AS after_json").
+		Where("tenant_id", tenant).
+		WhereIn("action", []string{"add", "update", "delete"}).
+		WhereGroup(func(g *query.Query) {
+			g.Where("resource_type", audit.ResourceCustomField).OrWhereNull("resource_type")
+		}).
+		Where("applied_at", "<=", at)
+	if res.Base != nil {
+		q.Where("applied_at", ">", res.Base.TakenAt)
+	}
+	var changes []AuditChange
+	if err := q.OrderBy("applied_at", "asc").OrderBy("id", "asc").WithContext(ctx).Get(&changes); err != nil {
+		return res, err
+	}
+	if metas, err = ReplayAudit(metas, changes); err != nil {
+		return res, err
+	}
+	res.Replayed = len(changes)
+	if res.YAML, err = codec.EncodeYAML(metas); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// ReplayAudit applies changes in order to metas. Entries without field
+// payloads, such as the ones written for registry snapshots, are ignored.
+func ReplayAudit(metas []registry.FieldMeta, changes []AuditChange) ([]registry.FieldMeta, error) {
+	fields := make(map[string]registry.FieldMeta, len(metas))
+	for _, m := range metas {
+		fields[replayKey(m)] = m
+	}
+	decode := func(id int64, s sql.NullString) (*registry.FieldMeta, error) {
+		if !s.Valid || s.String == "" || s.String == "null" {
+			return nil, nil
+		}
+		var m registry.FieldMeta
+		if err := json.Unmarshal([]byte(s.String), &m); err != nil {
+			return nil, fmt.Errorf("audit log %d: %w", id, err)
+		}
+		if m.TableName == "" || m.ColumnName == "" {
+			return nil, nil
+		}
+		return &m, nil
+	}
+	for _, c := range changes {
+		before, err := decode(c.ID, c.Before)
+		if err != nil {
+			return nil, err
+		}
+		after, err := decode(c.ID, c.After)
+		if err != nil {
+			return nil, err
+		}
+		if before != nil {
+			delete(fields, replayKey(*before))
+		}
+		if after != nil && c.Action != "delete" {
+			fields[replayKey(*after)] = *after
+		}
+	}
+	out := make([]registry.FieldMeta, 0, len(fields))
+	for _, m := range fields {
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].TableName != out[j].TableName {
+			return out[i].TableName < out[j].TableName
+		}
+		if out[i].ColumnName != out[j].ColumnName {
+			return out[i].ColumnName < out[j].ColumnName
+		}
+		return monitordb.NormalizeDBID(out[i].DBID) < monitordb.NormalizeDBID(out[j].DBID)
+	})
+	return out, nil
+}
+
+// replayKey identifies a field across databases. Fields without a database
+// ID belong to the default database.
+func replayKey(m registry.FieldMeta) string {
+	return fmt.Sprintf("%d:%s.%s", monitordb.NormalizeDBID(m.DBID), m.TableName, m.ColumnName)
+}
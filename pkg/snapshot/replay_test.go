@@ -0,0 +1,74 @@
+package snapshot
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"github.com/faciam-dev/gcfm/pkg/registry"
+)
+
+func auditJSON(t *testing.T, m *registry.FieldMeta) sql.NullString {
+	t.Helper()
+	if m == nil {
+		return sql.NullString{}
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sql.NullString{String: string(b), Valid: true}
+}
+
+func TestReplayAudit(t *testing.T) {
+	base := []registry.FieldMeta{
+		{TableName: "posts", ColumnName: "title", DataType: "varchar"},
+		{TableName: "posts", ColumnName: "body", DataType: "text"},
+	}
+	email := &registry.FieldMeta{TableName: "users", ColumnName: "email", DataType: "varchar"}
+	oldTitle := &base[0]
+	newTitle := &registry.FieldMeta{TableName: "posts", ColumnName: "title", DataType: "text", Nullable: true}
+	changes := []AuditChange{
+		{ID: 1, Action: "add", After: auditJSON(t, email)},
+		{ID: 2, Action: "update", Before: auditJSON(t, oldTitle), After: auditJSON(t, newTitle)},
+		{ID: 3, Action: "delete", Before: auditJSON(t, &base[1])},
+		// Registry snapshot entries carry no field payload.
+		{ID: 4, Action: "update"},
+	}
+	got, err := ReplayAudit(base, changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []registry.FieldMeta{*newTitle, *email}
+	if len(got) != len(want) {
+		t.Fatalf("got %d fields, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i].TableName != want[i].TableName || got[i].ColumnName != want[i].ColumnName ||
+			got[i].DataType != want[i].DataType || got[i].Nullable != want[i].Nullable {
+			t.Fatalf("field %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestReplayAuditKeepsDatabasesApart(t *testing.T) {
+	base := []registry.FieldMeta{
+		{TableName: "posts", ColumnName: "title", DataType: "varchar"},
+	}
+	other := &registry.FieldMeta{DBID: 7, TableName: "posts", ColumnName: "title", DataType: "text"}
+	changes := []AuditChange{
+		{ID: 1, Action: "add", After: auditJSON(t, other)},
+		{ID: 2, Action: "delete", Before: auditJSON(t, other)},
+		{ID: 3, Action: "add", After: auditJSON(t, other)},
+	}
+	got, err := ReplayAudit(base, changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d fields, want 2: %+v", len(got), got)
+	}
+	if got[0].DBID != 0 || got[0].DataType != "varchar" || got[1].DBID != 7 || got[1].DataType != "text" {
+		t.Fatalf("fields = %+v", got)
+	}
+}
@@ -0,0 +1,270 @@
+package snapshot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/faciam-dev/gcfm/pkg/metrics"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+	"github.com/faciam-dev/goquent/orm/query"
+)
+
+// RetentionPolicy controls which snapshots of a tenant are pruned. A snapshot
+// is kept when it is among the KeepLast newest ones, younger than KeepWithin,
+// tagged, or the latest snapshot of the tenant. A policy with both limits set
+// to zero disables pruning.
+type RetentionPolicy struct {
+	Tenant     string
+	KeepLast   int
+	KeepWithin time.Duration
+}
+
+// Enabled reports whether the policy prunes anything at all.
+func (p RetentionPolicy) Enabled() bool {
+	return p.KeepLast > 0 || p.KeepWithin > 0
+}
+
+// PruneResult describes the snapshots removed (or, for dry runs, the ones
+// that would be removed) for a tenant.
+type PruneResult struct {
+	Tenant  string
+	Deleted []Record
+	Kept    int
+	Bytes   int64
+	DryRun  bool
+}
+
+type retentionRow struct {
+	TenantID          string `db:"tenant_id"`
+	KeepLast          int    `db:"keep_last"`
+	KeepWithinSeconds int64  `db:"keep_within_seconds"`
+}
+
+func (r retentionRow) policy() RetentionPolicy {
+	return RetentionPolicy{
+		Tenant:     r.TenantID,
+		KeepLast:   r.KeepLast,
+		KeepWithin: time.Duration(r.KeepWithinSeconds) * time.Second,
+	}
+}
+
+// GetRetention returns the retention policy configured for tenant. A zero
+// policy is returned when none is stored.
+func GetRetention(ctx context.Context, db *sql.DB, dialect ormdriver.Dialect, prefix, tenant string) (RetentionPolicy, error) {
+	var row retentionRow
+	err := query.New(db, prefix+"snapshot_retention", dialect).
+		Select("tenant_id", "keep_last", "keep_within_seconds").
+		Where("tenant_id", tenant).
+		WithContext(ctx).
+		First(&row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return RetentionPolicy{Tenant: tenant}, nil
+		}
+		return RetentionPolicy{}, err
+	}
+	return row.policy(), nil
+}
+
+// SetRetention stores the retention policy for p.Tenant.
+func SetRetention(ctx context.Context, db *sql.DB, dialect ormdriver.Dialect, prefix string, p RetentionPolicy) error {
+	data := map[string]any{
+		"tenant_id":           p.Tenant,
+		"keep_last":           p.KeepLast,
+		"keep_within_seconds": int64(p.KeepWithin / time.Second),
+		"updated_at":          time.Now().UTC(),
+	}
+	_, err := query.New(db, prefix+"snapshot_retention", dialect).
+		WithContext(ctx).
+		Upsert([]map[string]any{data}, []string{"tenant_id"}, []string{"keep_last", "keep_within_seconds", "updated_at"})
+	return err
+}
+
+// ListRetention returns all stored retention policies.
+func ListRetention(ctx context.Context, db *sql.DB, dialect ormdriver.Dialect, prefix string) ([]RetentionPolicy, error) {
+	var rows []retentionRow
+	err := query.New(db, prefix+"snapshot_retention", dialect).
+		Select("tenant_id", "keep_last", "keep_within_seconds").
+		OrderBy("tenant_id", "asc").
+		WithContext(ctx).
+		Get(&rows)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]RetentionPolicy, len(rows))
+	for i, r := range rows {
+		out[i] = r.policy()
+	}
+	return out, nil
+}
+
+// TaggedIDs returns the ids of all snapshots of tenant that carry a tag.
+func TaggedIDs(ctx context.Context, db *sql.DB, dialect ormdriver.Dialect, prefix, tenant string) (map[int64]bool, error) {
+	var rows []struct {
+		SnapshotID int64 `db:"snapshot_id"`
+	}
+	err := query.New(db, prefix+"registry_snapshot_tags", dialect).
+		Select("snapshot_id").
+		Where("tenant_id", tenant).
+		WithContext(ctx).
+		Get(&rows)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[int64]bool, len(rows))
+	for _, r := range rows {
+		ids[r.SnapshotID] = true
+	}
+	return ids, nil
+}
+
+// Prune removes the snapshots of p.Tenant that are not retained by p. With
+// dryRun set nothing is deleted and the result lists the candidates.
+func Prune(ctx context.Context, db *sql.DB, dialect ormdriver.Dialect, prefix string, p RetentionPolicy, now time.Time, dryRun bool) (PruneResult, error) {
+	res := PruneResult{Tenant: p.Tenant, DryRun: dryRun}
+	table := prefix + "registry_snapshots"
+	var rows []struct {
+		ID      int64     `db:"id"`
+		Semver  string    `db:"semver"`
+		TakenAt time.Time `db:"taken_at"`
+		Author  string    `db:"author"`
+		Size    int64     `db:"size"`
+	}
+	err := query.New(db, table, dialect).
+		Select("id", "semver", "taken_at", "author").
+		SelectRaw("OCTET_LENGTH(yaml) AS size").
+		Where("tenant_id", p.Tenant).
+		OrderBy("id", "desc").
+		WithContext(ctx).
+		Get(&rows)
+	if err != nil {
+		return res, err
+	}
+	tagged, err := TaggedIDs(ctx, db, dialect, prefix, p.Tenant)
+	if err != nil {
+		return res, err
+	}
+	recs := make([]Record, len(rows))
+	sizes := make(map[int64]int64, len(rows))
+	for i, r := range rows {
+		recs[i] = Record{ID: r.ID, Semver: r.Semver, TakenAt: r.TakenAt, Author: r.Author}
+		sizes[r.ID] = r.Size
+	}
+	res.Deleted = selectPrunable(recs, tagged, p, now)
+	res.Kept = len(recs) - len(res.Deleted)
+	if len(res.Deleted) == 0 {
+		return res, nil
+	}
+	ids := make([]int64, len(res.Deleted))
+	for i, r := range res.Deleted {
+		ids[i] = r.ID
+		res.Bytes += sizes[r.ID]
+	}
+	if dryRun {
+		return res, nil
+	}
+	// A snapshot may be tagged after the tags were read, so the delete
+	// itself skips tagged rows.
+	out, err := query.New(db, table, dialect).
+		Where("tenant_id", p.Tenant).
+		WhereIn("id", ids).
+		WhereNotInSubQuery("id", query.New(db, prefix+"registry_snapshot_tags", dialect).
+			Select("snapshot_id").
+			Where("tenant_id", p.Tenant)).
+		WithContext(ctx).
+		Delete()
+	if err != nil {
+		return res, err
+	}
+	if n, err := out.RowsAffected(); err == nil && int(n) < len(ids) {
+		if err := dropSurvivors(ctx, db, dialect, table, p.Tenant, ids, sizes, &res); err != nil {
+			return res, err
+		}
+	}
+	metrics.SnapshotsPruned.WithLabelValues(p.Tenant).Add(float64(len(res.Deleted)))
+	metrics.SnapshotPrunedBytes.WithLabelValues(p.Tenant).Add(float64(res.Bytes))
+	return res, nil
+}
+
+// dropSurvivors removes the snapshots among ids that still exist after the
+// delete from res.
+func dropSurvivors(ctx context.Context, db *sql.DB, dialect ormdriver.Dialect, table, tenant string, ids []int64, sizes map[int64]int64, res *PruneResult) error {
+	var rows []struct {
+		ID int64 `db:"id"`
+	}
+	err := query.New(db, table, dialect).
+		Select("id").
+		Where("tenant_id", tenant).
+		WhereIn("id", ids).
+		WithContext(ctx).
+		Get(&rows)
+	if err != nil {
+		return err
+	}
+	kept := make(map[int64]bool, len(rows))
+	for _, r := range rows {
+		kept[r.ID] = true
+	}
+	deleted := res.Deleted[:0]
+	for _, r := range res.Deleted {
+		if kept[r.ID] {
+			res.Kept++
+			res.Bytes -= sizes[r.ID]
+			continue
+		}
+		deleted = append(deleted, r)
+	}
+	res.Deleted = deleted
+	return nil
+}
+
+// PruneAll applies the stored retention policy of every tenant. Tenants
+// without a stored policy are not touched.
+func PruneAll(ctx context.Context, db *sql.DB, dialect ormdriver.Dialect, prefix string, now time.Time) ([]PruneResult, error) {
+	policies, err := ListRetention(ctx, db, dialect, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		out  []PruneResult
+		errs []error
+	)
+	for _, p := range policies {
+		if !p.Enabled() {
+			continue
+		}
+		res, err := Prune(ctx, db, dialect, prefix, p, now, false)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", p.Tenant, err))
+			continue
+		}
+		out = append(out, res)
+	}
+	return out, errors.Join(errs...)
+}
+
+// selectPrunable returns the records not retained by p. The newest record is
+// always kept so that NextSemver keeps producing increasing versions.
+func selectPrunable(recs []Record, tagged map[int64]bool, p RetentionPolicy, now time.Time) []Record {
+	if !p.Enabled() || len(recs) == 0 {
+		return nil
+	}
+	sorted := append([]Record(nil), recs...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ID > sorted[j].ID })
+	var out []Record
+	for i, r := range sorted {
+		switch {
+		case i == 0:
+		case tagged[r.ID]:
+		case p.KeepLast > 0 && i < p.KeepLast:
+		case p.KeepWithin > 0 && now.Sub(r.TakenAt) < p.KeepWithin:
+		default:
+			out = append(out, r)
+		}
+	}
+	return out
+}
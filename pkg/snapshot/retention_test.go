@@ -0,0 +1,73 @@
+package snapshot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+)
+
+func TestSelectPrunable(t *testing.T) {
+	now := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	recs := []Record{
+		{ID: 1, Semver: "0.0.1", TakenAt: now.Add(-9 * 24 * time.Hour)},
+		{ID: 2, Semver: "0.0.2", TakenAt: now.Add(-8 * 24 * time.Hour)},
+		{ID: 3, Semver: "0.0.3", TakenAt: now.Add(-7 * 24 * time.Hour)},
+		{ID: 4, Semver: "0.0.4", TakenAt: now.Add(-2 * time.Hour)},
+		{ID: 5, Semver: "0.0.5", TakenAt: now.Add(-1 * time.Hour)},
+	}
+	tagged := map[int64]bool{1: true}
+
+	got := selectPrunable(recs, tagged, RetentionPolicy{KeepLast: 1, KeepWithin: 24 * time.Hour}, now)
+	if len(got) != 2 || got[0].ID != 3 || got[1].ID != 2 {
+		t.Fatalf("unexpected prune set: %+v", got)
+	}
+
+	got = selectPrunable(recs, nil, RetentionPolicy{KeepLast: 4}, now)
+	if len(got) != 1 || got[0].ID != 1 {
+		t.Fatalf("unexpected prune set: %+v", got)
+	}
+
+	if got := selectPrunable(recs, nil, RetentionPolicy{}, now); got != nil {
+		t.Fatalf("disabled policy must not prune: %+v", got)
+	}
+
+	got = selectPrunable(recs[:1], nil, RetentionPolicy{KeepWithin: time.Minute}, now)
+	if len(got) != 0 {
+		t.Fatalf("latest snapshot must be kept: %+v", got)
+	}
+}
+
+func TestPruneKeepsSnapshotsTaggedDuringPrune(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	now := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT .* FROM `registry_snapshots`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "semver", "taken_at", "author", "size"}).
+			AddRow(3, "0.0.3", now, "a", 30).
+			AddRow(2, "0.0.2", now, "a", 20).
+			AddRow(1, "0.0.1", now, "a", 10))
+	mock.ExpectQuery("SELECT `snapshot_id` FROM `registry_snapshot_tags`").
+		WillReturnRows(sqlmock.NewRows([]string{"snapshot_id"}))
+	// Snapshot 1 is tagged before the delete runs.
+	mock.ExpectExec("DELETE FROM `registry_snapshots` WHERE .*`id` NOT IN \\(SELECT `snapshot_id` FROM `registry_snapshot_tags`").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT `id` FROM `registry_snapshots`").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	res, err := Prune(context.Background(), db, ormdriver.MySQLDialect{}, "", RetentionPolicy{Tenant: "t1", KeepLast: 1}, now, false)
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if len(res.Deleted) != 1 || res.Deleted[0].ID != 2 || res.Kept != 2 || res.Bytes != 20 {
+		t.Fatalf("result = %+v", res)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
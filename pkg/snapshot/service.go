@@ -5,8 +5,10 @@ import (
 	"database/sql"
 
 	"github.com/faciam-dev/gcfm/pkg/audit"
+	"github.com/faciam-dev/gcfm/pkg/monitordb"
 	"github.com/faciam-dev/gcfm/pkg/registry"
 	"github.com/faciam-dev/gcfm/pkg/registry/codec"
+	"github.com/faciam-dev/gcfm/pkg/util"
 	sdk "github.com/faciam-dev/gcfm/sdk"
 )
 
@@ -19,10 +21,58 @@ func SnapshotYaml(ctx context.Context, db *sql.DB, driver, prefix, tenant string
 	return codec.EncodeYAML(metas)
 }
 
-// ApplyYaml applies the given YAML to the registry using Service.Apply.
+// RestoreOptions returns the options every snapshot restore applies a
+// registry with: the diff is taken against, and changes are written to, the
+// stored fields of tenant only.
+func RestoreOptions(tenant, actor string) sdk.ApplyOptions {
+	return sdk.ApplyOptions{Tenant: tenant, Actor: actor}
+}
+
+// ApplyYaml applies the given YAML to the registry of tenant using
+// Service.Apply with RestoreOptions.
 func ApplyYaml(ctx context.Context, dsn, driver, prefix, tenant string, yaml []byte, rec *audit.Recorder) (sdk.DiffReport, error) {
 	svc := sdk.New(sdk.ServiceConfig{Recorder: rec})
-	return svc.Apply(ctx, sdk.DBConfig{Driver: driver, DSN: dsn, Schema: "public", TablePrefix: prefix}, yaml, sdk.ApplyOptions{})
+	return svc.Apply(ctx, sdk.DBConfig{Driver: driver, DSN: dsn, Schema: "public", TablePrefix: prefix}, yaml, RestoreOptions(tenant, ""))
+}
+
+// ApplyYamlTx replaces the registry of tenant with yaml within tx and returns
+// the applied changes. Unlike ApplyYaml it records no audit entries, so that
+// callers can do so once tx has committed.
+func ApplyYamlTx(ctx context.Context, tx *sql.Tx, driver, prefix, tenant string, yaml []byte) ([]registry.Change, error) {
+	metas, err := codec.DecodeYAML(yaml)
+	if err != nil {
+		return nil, err
+	}
+	current, err := registry.LoadSQLByTenantTx(ctx, tx, registry.DBConfig{Schema: "public", Driver: driver, TablePrefix: prefix}, tenant)
+	if err != nil {
+		return nil, err
+	}
+	changes := registry.Diff(current, metas)
+	var upserts, dels []registry.FieldMeta
+	ids := map[int64]struct{}{}
+	for _, c := range changes {
+		switch c.Type {
+		case registry.ChangeAdded, registry.ChangeUpdated:
+			upserts = append(upserts, *c.New)
+			ids[monitordb.NormalizeDBID(c.New.DBID)] = struct{}{}
+		case registry.ChangeDeleted:
+			dels = append(dels, *c.Old)
+			ids[monitordb.NormalizeDBID(c.Old.DBID)] = struct{}{}
+		}
+	}
+	dialect := util.DialectFromDriver(driver)
+	for id := range ids {
+		if err := monitordb.EnsureExistsTx(ctx, tx, dialect, prefix, "default", id); err != nil {
+			return nil, err
+		}
+	}
+	if err := registry.DeleteSQLByTenantTx(ctx, tx, driver, prefix, tenant, dels); err != nil {
+		return nil, err
+	}
+	if _, _, err := registry.UpsertSQLByTenantTx(ctx, tx, driver, prefix, tenant, upserts); err != nil {
+		return nil, err
+	}
+	return changes, nil
 }
 
 // DiffYaml returns the registry changes between two YAML documents.
@@ -0,0 +1,13 @@
+package snapshot
+
+import "testing"
+
+func TestRestoreOptionsScopeToTenant(t *testing.T) {
+	opts := RestoreOptions("t1", "alice")
+	if opts.Tenant != "t1" || opts.Actor != "alice" {
+		t.Fatalf("RestoreOptions = %+v", opts)
+	}
+	if opts.DryRun {
+		t.Fatal("restores must write")
+	}
+}
@@ -0,0 +1,101 @@
+package snapshot
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"time"
+
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+	"github.com/faciam-dev/goquent/orm/query"
+)
+
+var tagPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// ValidateTag reports whether tag is a valid snapshot tag name such as
+// "release-2026.10" or "prod-approved".
+func ValidateTag(tag string) error {
+	if !tagPattern.MatchString(tag) {
+		return fmt.Errorf("invalid tag %q: use up to 64 letters, digits, '.', '_' or '-'", tag)
+	}
+	return nil
+}
+
+// SetTag points tag at the snapshot with the given id. A tag names at most
+// one snapshot per tenant; setting an existing tag moves it.
+func SetTag(ctx context.Context, db *sql.DB, dialect ormdriver.Dialect, prefix, tenant, tag string, snapshotID int64) error {
+	return setTag(ctx, db, dialect, prefix, tenant, tag, snapshotID)
+}
+
+func setTag(ctx context.Context, db execer, dialect ormdriver.Dialect, prefix, tenant, tag string, snapshotID int64) error {
+	if err := ValidateTag(tag); err != nil {
+		return err
+	}
+	data := map[string]any{
+		"tenant_id":   tenant,
+		"tag":         tag,
+		"snapshot_id": snapshotID,
+		"created_at":  time.Now().UTC(),
+	}
+	_, err := query.New(db, prefix+"registry_snapshot_tags", dialect).
+		WithContext(ctx).
+		Upsert([]map[string]any{data}, []string{"tenant_id", "tag"}, []string{"snapshot_id", "created_at"})
+	return err
+}
+
+// DeleteTag removes tag from tenant.
+func DeleteTag(ctx context.Context, db *sql.DB, dialect ormdriver.Dialect, prefix, tenant, tag string) error {
+	_, err := query.New(db, prefix+"registry_snapshot_tags", dialect).
+		Where("tenant_id", tenant).
+		Where("tag", tag).
+		WithContext(ctx).
+		Delete()
+	return err
+}
+
+// Tags returns the tags of tenant grouped by snapshot id.
+func Tags(ctx context.Context, db *sql.DB, dialect ormdriver.Dialect, prefix, tenant string) (map[int64][]string, error) {
+	var rows []struct {
+		Tag        string `db:"tag"`
+		SnapshotID int64  `db:"snapshot_id"`
+	}
+	err := query.New(db, prefix+"registry_snapshot_tags", dialect).
+		Select("tag", "snapshot_id").
+		Where("tenant_id", tenant).
+		OrderBy("tag", "asc").
+		WithContext(ctx).
+		Get(&rows)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[int64][]string)
+	for _, r := range rows {
+		out[r.SnapshotID] = append(out[r.SnapshotID], r.Tag)
+	}
+	return out, nil
+}
+
+// GetByTag returns the snapshot tenant's tag points at.
+func GetByTag(ctx context.Context, db *sql.DB, dialect ormdriver.Dialect, prefix, tenant, tag string) (Record, error) {
+	var row struct {
+		SnapshotID int64 `db:"snapshot_id"`
+	}
+	err := query.New(db, prefix+"registry_snapshot_tags", dialect).
+		Select("snapshot_id").
+		Where("tenant_id", tenant).
+		Where("tag", tag).
+		WithContext(ctx).
+		First(&row)
+	if err != nil {
+		return Record{}, err
+	}
+	var r Record
+	err = query.New(db, prefix+"registry_snapshots", dialect).
+		Select("id", "semver", "yaml", "taken_at", "author").
+		Where("tenant_id", tenant).
+		Where("id", row.SnapshotID).
+		WithContext(ctx).
+		First(&r)
+	return r, err
+}
@@ -0,0 +1,16 @@
+package snapshot
+
+import "testing"
+
+func TestValidateTag(t *testing.T) {
+	for _, tag := range []string{"release-2026.10", "prod-approved", "v1_2"} {
+		if err := ValidateTag(tag); err != nil {
+			t.Fatalf("ValidateTag(%q): %v", tag, err)
+		}
+	}
+	for _, tag := range []string{"", "-leading", "has space", "a/b"} {
+		if err := ValidateTag(tag); err == nil {
+			t.Fatalf("ValidateTag(%q) should fail", tag)
+		}
+	}
+}
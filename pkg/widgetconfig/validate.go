@@ -0,0 +1,184 @@
+package widgetconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Violation describes a single widget_config value that does not satisfy the
+// widget schema. Pointer is an RFC 6901 JSON pointer relative to the config
+// document ("" refers to the document itself).
+type Violation struct {
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+// Error aggregates all violations found while validating a config.
+type Error struct {
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		p := v.Pointer
+		if p == "" {
+			p = "/"
+		}
+		parts = append(parts, p+": "+v.Message)
+	}
+	return "invalid widget_config: " + strings.Join(parts, "; ")
+}
+
+// Validate checks cfg against schema and returns an *Error listing every
+// violation. A nil or empty schema accepts any config, and an empty config is
+// validated as an empty object so that required properties are enforced.
+//
+// Schemas are JSON Schema documents, draft 2020-12 unless they declare
+// another draft in $schema. References may point into the schema itself
+// but not to other documents. A schema that does not compile is reported as
+// a violation of the whole config.
+func Validate(schema map[string]any, cfg []byte) error {
+	if len(schema) == 0 {
+		return nil
+	}
+	sch, err := compile(schema)
+	if err != nil {
+		return &Error{Violations: []Violation{{Pointer: "", Message: "invalid config schema: " + err.Error()}}}
+	}
+	var doc any = map[string]any{}
+	if len(bytes.TrimSpace(cfg)) > 0 {
+		if doc, err = jsonschema.UnmarshalJSON(bytes.NewReader(cfg)); err != nil {
+			return &Error{Violations: []Violation{{Pointer: "", Message: "invalid JSON: " + err.Error()}}}
+		}
+	}
+	err = sch.Validate(doc)
+	if err == nil {
+		return nil
+	}
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return &Error{Violations: []Violation{{Pointer: "", Message: err.Error()}}}
+	}
+	var out []Violation
+	violations(verr, &out)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Pointer < out[j].Pointer })
+	return &Error{Violations: out}
+}
+
+// SchemaFromMeta extracts a config schema from widget manifest metadata. The
+// schema may be stored under "config_schema" as an object or a JSON string.
+func SchemaFromMeta(meta map[string]any) map[string]any {
+	raw, ok := meta["config_schema"]
+	if !ok || raw == nil {
+		return nil
+	}
+	switch v := raw.(type) {
+	case map[string]any:
+		return v
+	case string:
+		var m map[string]any
+		if err := json.Unmarshal([]byte(v), &m); err == nil {
+			return m
+		}
+	case []byte:
+		var m map[string]any
+		if err := json.Unmarshal(v, &m); err == nil {
+			return m
+		}
+	}
+	return nil
+}
+
+// schemaURL is the location compiled schemas are registered under.
+const schemaURL = "urn:gcfm:widget-config"
+
+// compiled caches compiled schemas by their JSON encoding.
+var compiled sync.Map // string -> *jsonschema.Schema
+
+// noLoader refuses to load referenced documents, so schemas cannot make the
+// server read files or fetch URLs.
+type noLoader struct{}
+
+func (noLoader) Load(url string) (any, error) {
+	return nil, fmt.Errorf("reference to %s is not allowed", url)
+}
+
+func compile(schema map[string]any) (*jsonschema.Schema, error) {
+	raw, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	if sch, ok := compiled.Load(string(raw)); ok {
+		return sch.(*jsonschema.Schema), nil
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	c := jsonschema.NewCompiler()
+	c.UseLoader(noLoader{})
+	if err := c.AddResource(schemaURL, doc); err != nil {
+		return nil, err
+	}
+	sch, err := c.Compile(schemaURL)
+	if err != nil {
+		return nil, err
+	}
+	compiled.Store(string(raw), sch)
+	return sch, nil
+}
+
+var printer = message.NewPrinter(language.English)
+
+// violations flattens e into out. Missing and additional properties are
+// reported at the pointer of the property; anyOf and oneOf failures are
+// reported once at the value rather than for every alternative.
+func violations(e *jsonschema.ValidationError, out *[]Violation) {
+	ptr := pointer(e.InstanceLocation)
+	switch k := e.ErrorKind.(type) {
+	case *kind.Required:
+		for _, p := range k.Missing {
+			*out = append(*out, Violation{Pointer: ptr + "/" + escape(p), Message: "required property is missing"})
+		}
+		return
+	case *kind.AdditionalProperties:
+		for _, p := range k.Properties {
+			*out = append(*out, Violation{Pointer: ptr + "/" + escape(p), Message: "additional property is not allowed"})
+		}
+		return
+	case *kind.AnyOf, *kind.OneOf:
+		*out = append(*out, Violation{Pointer: ptr, Message: k.LocalizedString(printer)})
+		return
+	}
+	if len(e.Causes) == 0 {
+		*out = append(*out, Violation{Pointer: ptr, Message: e.ErrorKind.LocalizedString(printer)})
+		return
+	}
+	for _, c := range e.Causes {
+		violations(c, out)
+	}
+}
+
+func pointer(tokens []string) string {
+	var sb strings.Builder
+	for _, t := range tokens {
+		sb.WriteByte('/')
+		sb.WriteString(escape(t))
+	}
+	return sb.String()
+}
+
+func escape(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
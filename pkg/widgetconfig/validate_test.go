@@ -0,0 +1,104 @@
+package widgetconfig
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidatePointers(t *testing.T) {
+	schema := map[string]any{
+		"type":                 "object",
+		"required":             []any{"format"},
+		"additionalProperties": false,
+		"properties": map[string]any{
+			"format": map[string]any{"type": "string", "enum": []any{"short", "long"}},
+			"max":    map[string]any{"type": "integer", "minimum": 1.0},
+			"tags": map[string]any{
+				"type":  "array",
+				"items": map[string]any{"type": "string", "maxLength": 3.0},
+			},
+		},
+	}
+	if err := Validate(schema, []byte(`{"format":"short","max":2,"tags":["a"]}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := Validate(schema, []byte(`{"max":0,"tags":["ok","toolong"],"a/b":1}`))
+	var verr *Error
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected *Error, got %v", err)
+	}
+	want := map[string]bool{
+		"/a~1b":   true,
+		"/format": true,
+		"/max":    true,
+		"/tags/1": true,
+	}
+	if len(verr.Violations) != len(want) {
+		t.Fatalf("unexpected violations: %+v", verr.Violations)
+	}
+	for _, v := range verr.Violations {
+		if !want[v.Pointer] {
+			t.Fatalf("unexpected pointer %q in %+v", v.Pointer, verr.Violations)
+		}
+	}
+}
+
+func TestValidateEmptyConfigEnforcesRequired(t *testing.T) {
+	schema := map[string]any{"type": "object", "required": []any{"min"}}
+	if err := Validate(schema, nil); err == nil {
+		t.Fatal("expected required violation for empty config")
+	}
+	if err := Validate(nil, []byte(`{"anything":true}`)); err != nil {
+		t.Fatalf("nil schema must accept any config: %v", err)
+	}
+}
+
+func TestSchemaFromMeta(t *testing.T) {
+	s := SchemaFromMeta(map[string]any{"config_schema": `{"type":"object"}`})
+	if s["type"] != "object" {
+		t.Fatalf("unexpected schema: %v", s)
+	}
+	if SchemaFromMeta(map[string]any{}) != nil {
+		t.Fatal("expected nil schema")
+	}
+}
+
+func TestValidateFullJSONSchema(t *testing.T) {
+	schema := map[string]any{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"type":    "object",
+		"definitions": map[string]any{
+			"kind": map[string]any{"enum": []any{"bar", "line"}},
+		},
+		"properties": map[string]any{
+			"kind":  map[string]any{"$ref": "#/definitions/kind"},
+			"color": map[string]any{"type": "string", "format": "color"},
+		},
+		"if":   map[string]any{"properties": map[string]any{"kind": map[string]any{"const": "line"}}},
+		"then": map[string]any{"required": []any{"width"}},
+	}
+	if err := Validate(schema, []byte(`{"kind":"bar"}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := Validate(schema, []byte(`{"kind":"pie"}`))
+	var verr *Error
+	if !errors.As(err, &verr) || len(verr.Violations) != 1 || verr.Violations[0].Pointer != "/kind" {
+		t.Fatalf("ref violation: %v", err)
+	}
+	err = Validate(schema, []byte(`{"kind":"line"}`))
+	if !errors.As(err, &verr) || len(verr.Violations) != 1 || verr.Violations[0].Pointer != "/width" {
+		t.Fatalf("if/then violation: %v", err)
+	}
+}
+
+func TestValidateRejectsExternalReferences(t *testing.T) {
+	for _, ref := range []string{"file:///etc/passwd", "https://example.com/schema.json"} {
+		err := Validate(map[string]any{"$ref": ref}, []byte(`{}`))
+		var verr *Error
+		if !errors.As(err, &verr) || !strings.Contains(verr.Violations[0].Message, "invalid config schema") {
+			t.Fatalf("%s: err = %v", ref, err)
+		}
+	}
+}
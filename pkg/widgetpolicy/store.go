@@ -51,6 +51,27 @@ func (s *Store) Load() error {
 	return nil
 }
 
+// Save writes p to the policy file, using JSON or YAML depending on the file
+// extension, and makes it the current policy.
+func (s *Store) Save(p *WidgetPolicy) error {
+	var (
+		b   []byte
+		err error
+	)
+	if strings.HasSuffix(strings.ToLower(s.path), ".json") {
+		b, err = json.MarshalIndent(p, "", "  ")
+	} else {
+		b, err = yaml.Marshal(p)
+	}
+	if err != nil {
+		return fmt.Errorf("encode policy: %w", err)
+	}
+	if err := os.WriteFile(s.path, b, 0o600); err != nil {
+		return fmt.Errorf("write policy: %w", err)
+	}
+	return s.Load()
+}
+
 func (s *Store) Watch(ctx context.Context) {
 	w, err := fsnotify.NewWatcher()
 	if err != nil {
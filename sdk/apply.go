@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"log/slog"
+	"strings"
 
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
@@ -13,6 +14,7 @@ import (
 
 	"gopkg.in/yaml.v3"
 
+	"github.com/faciam-dev/gcfm/internal/display"
 	"github.com/faciam-dev/gcfm/pkg/metrics"
 	"github.com/faciam-dev/gcfm/pkg/migrator"
 	monitordbrepo "github.com/faciam-dev/gcfm/pkg/monitordb"
@@ -20,7 +22,9 @@ import (
 	"github.com/faciam-dev/gcfm/pkg/notifier"
 	"github.com/faciam-dev/gcfm/pkg/registry"
 	"github.com/faciam-dev/gcfm/pkg/registry/codec"
+	"github.com/faciam-dev/gcfm/pkg/tenant"
 	"github.com/faciam-dev/gcfm/pkg/util"
+	"github.com/faciam-dev/gcfm/pkg/widgetconfig"
 	ormdriver "github.com/faciam-dev/goquent/orm/driver"
 )
 
@@ -35,6 +39,9 @@ func (s *service) Apply(ctx context.Context, cfg DBConfig, data []byte, opts App
 	if err != nil {
 		return DiffReport{}, err
 	}
+	if err := validateWidgets(metas, opts); err != nil {
+		return DiffReport{}, err
+	}
 
 	var hdr struct {
 		Version string `yaml:"version"`
@@ -57,11 +64,11 @@ func (s *service) Apply(ctx context.Context, cfg DBConfig, data []byte, opts App
 		}
 		mig := migrator.NewWithDriverAndPrefix(drv, prefix)
 		if drv == "mysql" || drv == "postgres" {
-			db, err := sql.Open(drv, cfg.DSN)
+			db, release, err := openSQL(cfg, drv)
 			if err != nil {
 				return DiffReport{}, err
 			}
-			defer func() { _ = db.Close() }()
+			defer release()
 			cur, err := mig.Current(ctx, db)
 			if err != nil && err != migrator.ErrNoVersionTable {
 				return DiffReport{}, err
@@ -81,7 +88,13 @@ func (s *service) Apply(ctx context.Context, cfg DBConfig, data []byte, opts App
 		}
 	}
 
-	current, err := s.Scan(ctx, cfg)
+	var current []registry.FieldMeta
+	if opts.Tenant != "" {
+		ctx = tenant.WithTenant(ctx, opts.Tenant)
+		current, err = loadTenantFields(ctx, cfg, opts.Tenant)
+	} else {
+		current, err = s.Scan(ctx, cfg)
+	}
 	if err != nil {
 		return DiffReport{}, err
 	}
@@ -114,15 +127,30 @@ func (s *service) Apply(ctx context.Context, cfg DBConfig, data []byte, opts App
 	}
 	switch drv {
 	case "postgres", "mysql":
-		db, err := sql.Open(drv, cfg.DSN)
+		db, release, err := openSQL(cfg, drv)
 		if err != nil {
 			return rep, err
 		}
-		defer func() { _ = db.Close() }()
+		defer release()
 		dialect := util.DialectFromDriver(drv)
 		if err := ensureMonitoredDBsExist(ctx, db, dialect, cfg.TablePrefix, upserts, dels); err != nil {
 			return rep, err
 		}
+		if opts.Tenant != "" {
+			if err := registry.DeleteSQLByTenant(ctx, db, drv, cfg.TablePrefix, opts.Tenant, dels); err != nil {
+				if len(dels) > 0 {
+					recordApplyError(dels[0].TableName)
+				}
+				return rep, err
+			}
+			if _, _, err := registry.UpsertSQLByTenant(ctx, db, drv, cfg.TablePrefix, opts.Tenant, upserts); err != nil {
+				if len(upserts) > 0 {
+					recordApplyError(upserts[0].TableName)
+				}
+				return rep, err
+			}
+			break
+		}
 		if err := registry.DeleteSQL(ctx, db, drv, cfg.TablePrefix, dels); err != nil {
 			if len(dels) > 0 {
 				recordApplyError(dels[0].TableName)
@@ -136,11 +164,11 @@ func (s *service) Apply(ctx context.Context, cfg DBConfig, data []byte, opts App
 			return rep, err
 		}
 	case "sqlmock":
-		db, err := sql.Open("sqlmock", cfg.DSN)
+		db, release, err := openSQL(cfg, "sqlmock")
 		if err != nil {
 			return rep, err
 		}
-		defer func() { _ = db.Close() }()
+		defer release()
 		dialect := util.DialectFromDriver("mysql")
 		if err := ensureMonitoredDBsExist(ctx, db, dialect, cfg.TablePrefix, upserts, dels); err != nil {
 			return rep, err
@@ -192,11 +220,115 @@ func (s *service) Apply(ctx context.Context, cfg DBConfig, data []byte, opts App
 		if s.notifier != nil {
 			_ = s.notifier.Emit(ctx, notifier.DiffReport{Added: rep.Added, Deleted: rep.Deleted, Updated: rep.Updated})
 		}
+		if s.events != nil {
+			// Delivery outlives the call, so cancelling ctx must not
+			// abort the retries.
+			ectx := context.WithoutCancel(ctx)
+			for _, c := range changes {
+				if e, ok := fieldEvent(c); ok {
+					s.events.Dispatch(ectx, e)
+				}
+			}
+		}
 	}
 
 	return rep, nil
 }
 
+// fieldEvent converts a registry change into its cf.field.* event. It
+// reports false for unchanged fields.
+func fieldEvent(c registry.Change) (notifier.Event, bool) {
+	switch c.Type {
+	case registry.ChangeAdded:
+		return notifier.FieldEvent(notifier.TypeFieldCreated, nil, c.New), true
+	case registry.ChangeDeleted:
+		return notifier.FieldEvent(notifier.TypeFieldDeleted, c.Old, nil), true
+	case registry.ChangeUpdated:
+		return notifier.FieldEvent(notifier.TypeFieldUpdated, c.Old, c.New), true
+	}
+	return notifier.Event{}, false
+}
+
+// openSQL returns the pool of cfg, or opens cfg.DSN with drv. release
+// closes a pool opened here and leaves the pool of cfg open.
+func openSQL(cfg DBConfig, drv string) (db *sql.DB, release func(), err error) {
+	if cfg.db != nil {
+		return cfg.db, func() {}, nil
+	}
+	db, err = sql.Open(drv, cfg.DSN)
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, func() { _ = db.Close() }, nil
+}
+
+// loadTenantFields returns the stored field definitions of tenant.
+func loadTenantFields(ctx context.Context, cfg DBConfig, tenant string) ([]registry.FieldMeta, error) {
+	drv := cfg.Driver
+	if drv == "" {
+		var err error
+		drv, err = util.DetectDriver(cfg.DSN)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if drv != "postgres" && drv != "mysql" {
+		return nil, fmt.Errorf("tenant-scoped apply is not supported for driver %s", drv)
+	}
+	db, release, err := openSQL(cfg, drv)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return registry.LoadSQLByTenant(ctx, db, registry.DBConfig{Driver: drv, Schema: cfg.Schema, TablePrefix: cfg.TablePrefix}, tenant)
+}
+
+// WidgetConfigError reports a field whose widget_config does not validate
+// against its widget schema.
+type WidgetConfigError struct {
+	Table  string
+	Column string
+	Err    error
+}
+
+func (e *WidgetConfigError) Error() string {
+	return fmt.Sprintf("%s.%s: %v", e.Table, e.Column, e.Err)
+}
+
+func (e *WidgetConfigError) Unwrap() error { return e.Err }
+
+// validateWidgets checks that every plugin widget referenced by metas is
+// available and that its widget_config validates against the widget schema.
+// Widget ids are canonicalized as the custom field API does before the
+// lookup. Returned errors name the offending field and wrap the underlying
+// cause.
+func validateWidgets(metas []registry.FieldMeta, opts ApplyOptions) error {
+	if opts.WidgetAvailable == nil && opts.WidgetSchema == nil {
+		return nil
+	}
+	for _, m := range metas {
+		if m.Display == nil {
+			continue
+		}
+		w := display.CanonicalizeWidgetID(m.Display.Widget)
+		if !strings.HasPrefix(w, "plugin://") {
+			continue
+		}
+		id := strings.TrimPrefix(w, "plugin://")
+		if opts.WidgetAvailable != nil {
+			if err := opts.WidgetAvailable(id); err != nil {
+				return fmt.Errorf("%s.%s: widget %s: %w", m.TableName, m.ColumnName, id, err)
+			}
+		}
+		if opts.WidgetSchema != nil {
+			if err := widgetconfig.Validate(opts.WidgetSchema(id), m.Display.WidgetConfig); err != nil {
+				return &WidgetConfigError{Table: m.TableName, Column: m.ColumnName, Err: err}
+			}
+		}
+	}
+	return nil
+}
+
 // CalculateDiff returns counts of added, deleted and updated changes.
 func CalculateDiff(changes []registry.Change) DiffReport {
 	var rep DiffReport
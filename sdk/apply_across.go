@@ -0,0 +1,184 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrApplyAborted is returned by ApplyAcross when a canary failed or the
+// failure ratio was exceeded.
+var ErrApplyAborted = errors.New("apply across targets aborted")
+
+// Statuses of a TargetApplyResult.
+const (
+	ApplyStatusApplied = "applied"
+	ApplyStatusFailed  = "failed"
+	ApplyStatusSkipped = "skipped"
+)
+
+// AcrossOptions controls ApplyAcross.
+type AcrossOptions struct {
+	ApplyOptions
+	// TablePrefix is the registry table prefix of every target.
+	TablePrefix string
+	// Concurrency bounds the number of targets applied at once. Defaults
+	// to 4.
+	Concurrency int
+	// Canaries is the number of targets applied, one at a time, before the
+	// others. Any canary failure aborts the run.
+	Canaries int
+	// CanaryLabel moves targets with this label to the front, so that they
+	// are picked as canaries first.
+	CanaryLabel string
+	// MaxFailureRatio aborts the run once more than this fraction of the
+	// selected targets failed. Zero aborts on the first failure.
+	MaxFailureRatio float64
+}
+
+// TargetApplyResult is the outcome of ApplyAcross for one target.
+type TargetApplyResult struct {
+	Key      string
+	Canary   bool
+	Status   string
+	Report   DiffReport
+	Err      error
+	Duration time.Duration
+}
+
+// AcrossReport lists the result of every selected target in the order they
+// were scheduled.
+type AcrossReport struct {
+	Results []TargetApplyResult
+	Applied int
+	Failed  int
+	Skipped int
+}
+
+// ApplyAcross applies the registry YAML to every target matching q. Canaries
+// go first, the remaining targets follow with bounded concurrency, and
+// targets not started when the run aborts are reported as skipped.
+func (s *service) ApplyAcross(ctx context.Context, q Query, data []byte, opts AcrossOptions) (AcrossReport, error) {
+	return s.applyAcross(ctx, q, data, opts, s.Apply)
+}
+
+// applyFunc applies a registry to one database, like Service.Apply.
+type applyFunc func(ctx context.Context, cfg DBConfig, data []byte, opts ApplyOptions) (DiffReport, error)
+
+// applyAcross runs ApplyAcross with apply as the per target apply.
+func (s *service) applyAcross(ctx context.Context, q Query, data []byte, opts AcrossOptions, apply applyFunc) (AcrossReport, error) {
+	keys := s.targets.FindByQuery(q)
+	if len(keys) == 0 {
+		return AcrossReport{}, ErrNoTarget
+	}
+	keys = s.canaryOrder(keys, opts.CanaryLabel)
+	conc := opts.Concurrency
+	if conc < 1 {
+		conc = 4
+	}
+	canaries := min(max(opts.Canaries, 0), len(keys))
+
+	rep := AcrossReport{Results: make([]TargetApplyResult, len(keys))}
+	for i, k := range keys {
+		rep.Results[i] = TargetApplyResult{Key: k, Canary: i < canaries, Status: ApplyStatusSkipped}
+	}
+	var (
+		mu      sync.Mutex
+		failed  int
+		aborted bool
+	)
+	run := func(i int) {
+		res := &rep.Results[i]
+		start := time.Now()
+		res.Report, res.Err = s.applyTarget(ctx, res.Key, data, opts, apply)
+		res.Duration = time.Since(start)
+		mu.Lock()
+		defer mu.Unlock()
+		if res.Err == nil {
+			res.Status = ApplyStatusApplied
+			return
+		}
+		res.Status = ApplyStatusFailed
+		failed++
+		if res.Canary || float64(failed) > opts.MaxFailureRatio*float64(len(keys)) {
+			aborted = true
+		}
+	}
+	stopped := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return aborted || ctx.Err() != nil
+	}
+
+	for i := 0; i < canaries && !stopped(); i++ {
+		run(i)
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < conc; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				run(i)
+			}
+		}()
+	}
+	for i := canaries; i < len(keys) && !stopped(); i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	for _, r := range rep.Results {
+		switch r.Status {
+		case ApplyStatusApplied:
+			rep.Applied++
+		case ApplyStatusFailed:
+			rep.Failed++
+		default:
+			rep.Skipped++
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return rep, err
+	}
+	if aborted {
+		return rep, fmt.Errorf("%w: %d of %d targets failed", ErrApplyAborted, rep.Failed, len(keys))
+	}
+	return rep, nil
+}
+
+// canaryOrder moves the keys carrying label to the front.
+func (s *service) canaryOrder(keys []string, label string) []string {
+	if label == "" {
+		return keys
+	}
+	set := make(map[string]struct{})
+	for _, k := range s.targets.FindByLabel(label) {
+		set[k] = struct{}{}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		_, ci := set[keys[i]]
+		_, cj := set[keys[j]]
+		return ci && !cj
+	})
+	return keys
+}
+
+// applyTarget applies data to the target key over its pooled connection.
+// MongoDB targets, which have no pool, are reached through their DSN.
+func (s *service) applyTarget(ctx context.Context, key string, data []byte, opts AcrossOptions, apply applyFunc) (DiffReport, error) {
+	t, ok := s.targets.Get(key)
+	if !ok {
+		return DiffReport{}, ErrNoTarget
+	}
+	if t.DB == nil && t.DSN == "" {
+		return DiffReport{}, fmt.Errorf("target %s has no connection", key)
+	}
+	cfg := DBConfig{Driver: t.Driver, DSN: t.DSN, Schema: t.Schema, TablePrefix: opts.TablePrefix, db: t.DB}
+	return apply(ctx, cfg, data, opts.ApplyOptions)
+}
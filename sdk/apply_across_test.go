@@ -0,0 +1,172 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/faciam-dev/gcfm/pkg/registry"
+	"github.com/faciam-dev/gcfm/pkg/registry/codec"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+	"github.com/faciam-dev/goquent/orm/query"
+)
+
+func newAcrossService(t *testing.T, n int, fail func(dsn string) bool) (*service, applyFunc, *[]string) {
+	t.Helper()
+	reg := NewHotReloadRegistry(nil)
+	for i := 0; i < n; i++ {
+		k := string(rune('a' + i))
+		labels := []string{"region=eu"}
+		if k == "d" {
+			labels = append(labels, "canary")
+		}
+		if err := reg.Register(context.Background(), k, TargetConfig{DB: new(sql.DB), Driver: "mysql", DSN: k, Labels: labels}, nil); err != nil {
+			t.Fatalf("register: %v", err)
+		}
+	}
+	var mu sync.Mutex
+	var order []string
+	apply := func(_ context.Context, cfg DBConfig, _ []byte, _ ApplyOptions) (DiffReport, error) {
+		mu.Lock()
+		order = append(order, cfg.DSN)
+		mu.Unlock()
+		if fail(cfg.DSN) {
+			return DiffReport{}, errors.New("boom")
+		}
+		return DiffReport{Added: 1}, nil
+	}
+	return &service{targets: reg}, apply, &order
+}
+
+func TestApplyAcrossCanaryFirst(t *testing.T) {
+	svc, apply, order := newAcrossService(t, 5, func(string) bool { return false })
+	q, _ := ParseQuery("region=eu")
+	rep, err := svc.applyAcross(context.Background(), q, nil, AcrossOptions{Canaries: 1, CanaryLabel: "canary", Concurrency: 2}, apply)
+	if err != nil {
+		t.Fatalf("ApplyAcross: %v", err)
+	}
+	if (*order)[0] != "d" || !rep.Results[0].Canary || rep.Results[0].Key != "d" {
+		t.Fatalf("canary not first: %v %+v", *order, rep.Results[0])
+	}
+	if rep.Applied != 5 || rep.Failed != 0 || rep.Skipped != 0 {
+		t.Fatalf("report: %+v", rep)
+	}
+}
+
+func TestApplyAcrossCanaryFailureAborts(t *testing.T) {
+	svc, apply, order := newAcrossService(t, 4, func(dsn string) bool { return dsn == "d" })
+	q, _ := ParseQuery("region=eu")
+	rep, err := svc.applyAcross(context.Background(), q, nil, AcrossOptions{Canaries: 1, CanaryLabel: "canary", MaxFailureRatio: 1}, apply)
+	if !errors.Is(err, ErrApplyAborted) {
+		t.Fatalf("err = %v", err)
+	}
+	if len(*order) != 1 || rep.Failed != 1 || rep.Skipped != 3 || rep.Results[0].Err == nil {
+		t.Fatalf("order %v report %+v", *order, rep)
+	}
+}
+
+func TestApplyAcrossFailureRatio(t *testing.T) {
+	svc, apply, _ := newAcrossService(t, 10, func(dsn string) bool { return strings.Contains("abc", dsn) })
+	q, _ := ParseQuery("region=eu")
+	// Two failures are tolerated, the third aborts.
+	rep, err := svc.applyAcross(context.Background(), q, nil, AcrossOptions{Concurrency: 1, MaxFailureRatio: 0.2}, apply)
+	if !errors.Is(err, ErrApplyAborted) {
+		t.Fatalf("err = %v", err)
+	}
+	if rep.Failed != 3 || rep.Applied != 0 || rep.Skipped != 7 {
+		t.Fatalf("report: %+v", rep)
+	}
+
+	svc, apply, _ = newAcrossService(t, 10, func(dsn string) bool { return strings.Contains("ab", dsn) })
+	rep, err = svc.applyAcross(context.Background(), q, nil, AcrossOptions{Concurrency: 3, MaxFailureRatio: 0.2}, apply)
+	if err != nil || rep.Failed != 2 || rep.Applied != 8 {
+		t.Fatalf("report %+v, err %v", rep, err)
+	}
+}
+
+func TestApplyAcrossBoundedConcurrency(t *testing.T) {
+	reg := NewHotReloadRegistry(nil)
+	for _, k := range []string{"a", "b", "c", "d", "e", "f"} {
+		_ = reg.Register(context.Background(), k, TargetConfig{DB: new(sql.DB), DSN: k}, nil)
+	}
+	var running, peak atomic.Int32
+	svc := &service{targets: reg}
+	apply := func(context.Context, DBConfig, []byte, ApplyOptions) (DiffReport, error) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		return DiffReport{}, nil
+	}
+	if _, err := svc.applyAcross(context.Background(), Query{}, nil, AcrossOptions{Concurrency: 2}, apply); err != nil {
+		t.Fatalf("ApplyAcross: %v", err)
+	}
+	if peak.Load() != 2 {
+		t.Fatalf("peak concurrency = %d", peak.Load())
+	}
+}
+
+func TestApplyAcrossUsesTargetPool(t *testing.T) {
+	t.Setenv("CF_ENC_KEY", "0123456789abcdef0123456789abcdef")
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	scanSQL, _, _ := query.New(db, "gcfm_custom_fields", ormdriver.MySQLDialect{}).
+		Select("db_id", "table_name", "column_name", "data_type", "store_kind", "kind", "physical_type", "driver_extras", "label_key", "widget", "widget_config", "placeholder_key", "nullable", "unique", "has_default", "default_value", "validator").
+		OrderByRaw("table_name, column_name").
+		Build()
+	mock.ExpectQuery(regexp.QuoteMeta(scanSQL)).
+		WillReturnRows(sqlmock.NewRows([]string{"db_id", "table_name", "column_name", "data_type", "store_kind", "kind", "physical_type", "driver_extras", "label_key", "widget", "widget_config", "placeholder_key", "nullable", "unique", "has_default", "default_value", "validator"}))
+	mock.ExpectQuery("SELECT .*gcfm_monitored_databases").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO gcfm_custom_fields").ExpectExec().WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	reg := NewHotReloadRegistry(nil)
+	// The target has no DSN: the apply must run over its pool.
+	if err := reg.Register(context.Background(), "a", TargetConfig{DB: db, Driver: "sqlmock"}, nil); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	svc := &service{targets: reg}
+	data, err := codec.EncodeYAML([]registry.FieldMeta{{TableName: "posts", ColumnName: "cf1", DataType: "text"}})
+	if err != nil {
+		t.Fatalf("EncodeYAML: %v", err)
+	}
+	rep, err := svc.ApplyAcross(context.Background(), Query{}, data, AcrossOptions{TablePrefix: "gcfm_"})
+	if err != nil || rep.Applied != 1 {
+		t.Fatalf("report %+v, err %v", rep, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Fatalf("target pool was closed: %v", err)
+	}
+}
+
+func TestParseQueryNotEqual(t *testing.T) {
+	reg := NewHotReloadRegistry(nil)
+	_ = reg.Register(context.Background(), "a", TargetConfig{DB: new(sql.DB), Labels: []string{"region=eu", "tier=free"}}, nil)
+	_ = reg.Register(context.Background(), "b", TargetConfig{DB: new(sql.DB), Labels: []string{"region=eu", "tier=pro"}}, nil)
+	q, err := ParseQuery("region=eu,tier!=free")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := reg.FindByQuery(q); len(got) != 1 || got[0] != "b" {
+		t.Fatalf("FindByQuery = %v", got)
+	}
+}
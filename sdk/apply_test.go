@@ -2,6 +2,7 @@ package sdk
 
 import (
 	"context"
+	"errors"
 	"regexp"
 	"testing"
 
@@ -59,3 +60,24 @@ func TestApplyEnsuresMonitoredDB(t *testing.T) {
 		t.Fatalf("expectations: %v", err)
 	}
 }
+
+func TestValidateWidgetsCanonicalizesIDs(t *testing.T) {
+	var seen []string
+	opts := ApplyOptions{
+		WidgetAvailable: func(id string) error { seen = append(seen, id); return nil },
+		WidgetSchema: func(id string) map[string]any {
+			return map[string]any{"type": "object", "required": []any{"format"}}
+		},
+	}
+	metas := []registry.FieldMeta{
+		{TableName: "posts", ColumnName: "title", Display: &registry.DisplayMeta{Widget: " Plugin://Rating "}},
+	}
+	err := validateWidgets(metas, opts)
+	var ferr *WidgetConfigError
+	if !errors.As(err, &ferr) || ferr.Table != "posts" || ferr.Column != "title" {
+		t.Fatalf("expected WidgetConfigError for posts.title, got %v", err)
+	}
+	if len(seen) != 1 || seen[0] != "rating" {
+		t.Fatalf("widget looked up as %v", seen)
+	}
+}
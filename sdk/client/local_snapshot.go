@@ -98,6 +98,9 @@ func (l *snapshotLocal) Apply(ctx context.Context, tenant, ver string) error {
 	if err != nil {
 		return err
 	}
+	if data, err = snapshot.RegistryYAML(data); err != nil {
+		return err
+	}
 	svc := sdk.New(sdk.ServiceConfig{})
 	_, err = svc.Apply(ctx, sdk.DBConfig{Driver: l.driver, DSN: l.dsn, Schema: l.schema, TablePrefix: l.prefix}, data, sdk.ApplyOptions{})
 	return err
@@ -119,6 +122,8 @@ func (l *snapshotLocal) Diff(ctx context.Context, tenant, from, to string) (stri
 	}
 	ya, _ := snapshot.Decode(a.YAML)
 	yb, _ := snapshot.Decode(b.YAML)
+	ya, _ = snapshot.RegistryYAML(ya)
+	yb, _ = snapshot.RegistryYAML(yb)
 	return sdk.UnifiedDiff(string(ya), string(yb)), nil
 }
 
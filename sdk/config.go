@@ -16,6 +16,9 @@ type DBConfig struct {
 	DSN         string
 	Schema      string
 	TablePrefix string
+	// db is an open pool used instead of opening DSN, such as the pool of
+	// a registered target.
+	db *sql.DB
 }
 
 // Connector is responsible for establishing physical database connections.
@@ -32,7 +35,15 @@ type ServiceConfig struct {
 	PluginPublicKey string
 	PluginEnabled   *bool
 	Recorder        *audit.Recorder
-	Notifier        notifier.Broker
+	// Notifier receives a summary of every Apply.
+	//
+	// Deprecated: use Events.
+	Notifier notifier.Broker
+	// Events receives a cf.field.created, cf.field.updated or
+	// cf.field.deleted event for every field changed by Apply, the same
+	// events the API server publishes. notifier.NewDispatcher provides
+	// sinks, retries and a DLQ.
+	Events notifier.Emitter
 
 	// Default connection for monitored databases. Kept for backward
 	// compatibility.
@@ -78,6 +89,9 @@ type ServiceConfig struct {
 
 	// ReadSource selects where to read custom field metadata from.
 	ReadSource ReadSource
+
+	// Replicas controls how reads are routed to target replicas.
+	Replicas ReplicaPolicy
 }
 
 // TargetConfig defines an individual monitored database.
@@ -86,6 +100,9 @@ type TargetConfig struct {
 	Driver string
 	Schema string
 	Labels []string // optional tags such as "tenant:foo" or "region:tokyo"
+	// Weight is the share of calls the target gets under
+	// SelectWeightedRoundRobin. Values below 1 count as 1.
+	Weight int
 
 	// Physical connection information (hot reload target).
 	DSN          string
@@ -93,6 +110,12 @@ type TargetConfig struct {
 	MaxIdleConns int
 	ConnMaxIdle  time.Duration
 	ConnMaxLife  time.Duration
+	// ReplicaDSNs are read-only copies of the target. Reads are routed to
+	// them according to ServiceConfig.Replicas.
+	ReplicaDSNs []string
+	// Draining keeps the target registered but out of selection, e.g. while
+	// its database is migrated. No pool is opened for a draining target.
+	Draining bool
 
 	// Backward compatibility: pre-established connection. Connections
 	// provided via DB are not subject to hot reload.
@@ -126,6 +149,16 @@ const (
 	// SelectConsistentHash chooses a target based on a consistent hash of
 	// a provided source string.
 	SelectConsistentHash
+	// SelectWeightedRoundRobin rotates through the candidates in proportion
+	// to their TargetConfig.Weight.
+	SelectWeightedRoundRobin
+	// SelectLeastInFlight picks the candidate with the fewest calls in
+	// progress through RunWithTarget.
+	SelectLeastInFlight
+	// SelectLowestLatency picks the candidate with the lowest moving
+	// average latency of its successful calls through RunWithTarget.
+	// Candidates without samples are picked first.
+	SelectLowestLatency
 )
 
 // SelectionHint provides optional parameters for selection strategies.
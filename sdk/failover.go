@@ -57,12 +57,29 @@ const (
 	stateHalfOpen
 )
 
+func (s breakerState) String() string {
+	switch s {
+	case stateOpen:
+		return "open"
+	case stateHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
 type targetHealth struct {
 	mu        sync.Mutex
 	state     breakerState
 	failures  int
 	openUntil time.Time
 	halfProbe int
+
+	lastErr     string
+	lastErrAt   time.Time
+	lastSuccess time.Time
+	lastProbe   time.Time
+	probeRTT    time.Duration
 }
 
 type healthRegistry struct {
@@ -95,16 +112,21 @@ func (r *healthRegistry) get(key string) *targetHealth {
 func (r *healthRegistry) onSuccess(key string) {
 	h := r.get(key)
 	h.mu.Lock()
+	h.lastSuccess = time.Now()
 	h.state = stateClosed
 	h.failures = 0
 	h.halfProbe = 0
 	h.mu.Unlock()
 	r.setStateMetric(key, stateClosed)
 }
-func (r *healthRegistry) onFailure(key string, transient bool) {
+func (r *healthRegistry) onFailure(key string, err error, transient bool) {
 	metrics.TargetFailures.WithLabelValues(key, strconv.FormatBool(transient)).Inc()
 	h := r.get(key)
 	h.mu.Lock()
+	if err != nil {
+		h.lastErr = err.Error()
+		h.lastErrAt = time.Now()
+	}
 	defer func() {
 		st := h.state
 		h.mu.Unlock()
@@ -163,6 +185,30 @@ func (r *healthRegistry) isAvailable(key string) bool {
 	return available
 }
 
+// status returns the health of key without changing its breaker state.
+func (r *healthRegistry) status(key string) TargetHealth {
+	r.mu.RLock()
+	h, ok := r.m[key]
+	r.mu.RUnlock()
+	th := TargetHealth{Key: key, State: stateClosed.String()}
+	if !ok {
+		return th
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	th.State = h.state.String()
+	th.Failures = h.failures
+	if h.state == stateOpen {
+		th.OpenUntil = h.openUntil
+	}
+	th.LastError = h.lastErr
+	th.LastErrorAt = h.lastErrAt
+	th.LastSuccess = h.lastSuccess
+	th.LastProbe = h.lastProbe
+	th.ProbeLatency = h.probeRTT
+	return th
+}
+
 func (r *healthRegistry) prune(keys []string) {
 	set := make(map[string]struct{}, len(keys))
 	for _, k := range keys {
@@ -225,17 +271,28 @@ func (s *service) RunWithTarget(ctx context.Context, dec TargetDecision, isWrite
 			}
 		} else {
 			if t, ok := s.targets.Default(); ok {
-				return fn(t)
+				if t.Draining {
+					return drainingError("")
+				}
+				return s.call(ctx, "", t, isWrite, fn)
 			}
 			return ErrNoTarget
 		}
 		if key != "" {
 			if t, ok := s.targets.Get(key); ok {
-				return fn(t)
+				if t.Draining {
+					return drainingError(key)
+				}
+				return s.call(ctx, key, t, isWrite, fn)
 			}
 		}
 		return ErrNoTarget
 	}
+	if dec.Query == nil && dec.Key != "" {
+		if t, ok := s.targets.Get(dec.Key); ok && t.Draining {
+			return drainingError(dec.Key)
+		}
+	}
 
 	var keys []string
 	if dec.Query != nil {
@@ -262,17 +319,17 @@ func (s *service) RunWithTarget(ctx context.Context, dec TargetDecision, isWrite
 			continue
 		}
 		tgt, ok := s.targets.Get(key)
-		if !ok {
+		if !ok || tgt.Draining {
 			continue
 		}
 		attempts++
-		err := fn(tgt)
+		err := s.call(ctx, key, tgt, isWrite, fn)
 		if err == nil {
 			s.health.onSuccess(key)
 			return nil
 		}
 		transient, retryable := s.classify(err)
-		s.health.onFailure(key, transient)
+		s.health.onFailure(key, err, transient)
 		failures = append(failures, failure{Key: key, Err: err})
 		if !retryable || (isWrite && !s.failover.AllowWriteRetry) {
 			return err
@@ -295,6 +352,33 @@ func (s *service) RunWithTarget(ctx context.Context, dec TargetDecision, isWrite
 	return errors.New(msg)
 }
 
+// call runs fn on the target key. Reads use a replica when one qualifies and
+// fall back to the primary when it fails. Writes are remembered for
+// WithReadYourWrites contexts.
+func (s *service) call(ctx context.Context, key string, t TargetConn, isWrite bool, fn func(TargetConn) error) (err error) {
+	if key != "" {
+		end := s.load.begin(key)
+		defer func() { end(err) }()
+	}
+	if isWrite {
+		err = fn(t)
+		if err == nil {
+			writeLogFrom(ctx).record(key, time.Now())
+		}
+		return err
+	}
+	c := s.replicas.readConn(ctx, key, t)
+	if c.DB == t.DB {
+		return fn(t)
+	}
+	err = fn(c)
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	s.replicas.fail(c.DSN, err)
+	return fn(t)
+}
+
 func (s *service) orderCandidates(keys []string, primary string, prefer *SelectionHint) []string {
 	set := map[string]struct{}{}
 	out := make([]string, 0, len(keys)+1)
@@ -311,7 +395,7 @@ func (s *service) orderCandidates(keys []string, primary string, prefer *Selecti
 			}
 		}
 	}
-	for _, k := range keys {
+	for _, k := range s.scoredOrder(keys, prefer) {
 		if _, ok := set[k]; !ok {
 			out = append(out, k)
 			set[k] = struct{}{}
@@ -320,6 +404,22 @@ func (s *service) orderCandidates(keys []string, primary string, prefer *Selecti
 	return out
 }
 
+// scoredOrder orders keys by the load aware strategy of hint or the service
+// default, so that failover tries candidates in the order chooseOne would
+// pick them. Keys are returned as is for other strategies.
+func (s *service) scoredOrder(keys []string, hint *SelectionHint) []string {
+	strategy := s.stratDefault
+	if hint != nil && hint.Strategy != 0 {
+		strategy = hint.Strategy
+	}
+	switch strategy {
+	case SelectWeightedRoundRobin, SelectLeastInFlight, SelectLowestLatency:
+		return s.chooseOrder(append([]string(nil), keys...), &SelectionHint{Strategy: strategy})
+	default:
+		return keys
+	}
+}
+
 func (s *service) failoverPrefer(dec TargetDecision) *SelectionHint {
 	if dec.Hint != nil {
 		return dec.Hint
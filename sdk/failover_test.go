@@ -58,6 +58,23 @@ func TestRunWithTargetFailover(t *testing.T) {
 	}
 }
 
+func TestRunWithTargetPinnedToDraining(t *testing.T) {
+	ctx := context.Background()
+	reg := NewHotReloadRegistry(nil)
+	_ = reg.Register(ctx, "p", TargetConfig{DB: &sql.DB{}, Draining: true}, nil)
+	_ = reg.Register(ctx, "s", TargetConfig{DB: &sql.DB{}}, nil)
+	for _, pol := range []FailoverPolicy{{}, {Enabled: true, MaxAttempts: 2}} {
+		svc := &service{targets: reg, failover: pol, health: newHealthRegistry(pol)}
+		err := svc.RunWithTarget(ctx, TargetDecision{Key: "p"}, false, func(TargetConn) error {
+			t.Fatalf("draining target called")
+			return nil
+		})
+		if !errors.Is(err, ErrTargetDraining) {
+			t.Fatalf("failover=%v: err = %v, want ErrTargetDraining", pol.Enabled, err)
+		}
+	}
+}
+
 func TestCircuitBreaker(t *testing.T) {
 	ctx := context.Background()
 	reg := NewHotReloadRegistry(nil)
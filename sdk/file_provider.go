@@ -2,22 +2,58 @@ package sdk
 
 import (
 	"context"
+	"crypto/ed25519"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
 )
 
-// FileProvider reads target configurations from a JSON file.
-type FileProvider struct{ path string }
+// ErrTargetsSignature is returned by a signed FileProvider when the targets
+// file does not match its signature.
+var ErrTargetsSignature = errors.New("targets file signature mismatch")
+
+// FileProvider reads target configurations from a JSON or YAML file.
+type FileProvider struct {
+	path string
+	pub  ed25519.PublicKey
+}
 
-// NewFileProvider creates a provider for the given file path.
+// NewFileProvider creates a provider for the given file path. Files ending in
+// .yaml or .yml are read as YAML, others as JSON.
 func NewFileProvider(path string) *FileProvider { return &FileProvider{path: path} }
 
+// NewSignedFileProvider creates a provider that only accepts the file when
+// path+".sig" holds its hex encoded ed25519 signature by pub.
+func NewSignedFileProvider(path string, pub ed25519.PublicKey) *FileProvider {
+	return &FileProvider{path: path, pub: pub}
+}
+
 // Fetch loads target configs from the file, expanding environment variables in DSNs.
 func (p *FileProvider) Fetch(ctx context.Context) (map[string]TargetConfig, string, string, error) {
 	b, err := os.ReadFile(p.path)
 	if err != nil {
 		return nil, "", "", err
 	}
+	if err := p.verify(b); err != nil {
+		return nil, "", "", err
+	}
+	if ext := strings.ToLower(filepath.Ext(p.path)); ext == ".yaml" || ext == ".yml" {
+		// Convert to JSON so that both formats share the same field names.
+		var doc any
+		if err := yaml.Unmarshal(b, &doc); err != nil {
+			return nil, "", "", err
+		}
+		if b, err = json.Marshal(doc); err != nil {
+			return nil, "", "", err
+		}
+	}
 	var v struct {
 		Version string         `json:"version"`
 		Default string         `json:"default"`
@@ -29,7 +65,72 @@ func (p *FileProvider) Fetch(ctx context.Context) (map[string]TargetConfig, stri
 	cfgs := make(map[string]TargetConfig, len(v.Targets))
 	for _, t := range v.Targets {
 		t.DSN = os.ExpandEnv(t.DSN)
+		for i, r := range t.ReplicaDSNs {
+			t.ReplicaDSNs[i] = os.ExpandEnv(r)
+		}
 		cfgs[t.Key] = t
 	}
 	return cfgs, v.Default, v.Version, nil
 }
+
+// verify checks data against the signature file when a public key is set.
+func (p *FileProvider) verify(data []byte) error {
+	if p.pub == nil {
+		return nil
+	}
+	sigData, err := os.ReadFile(p.path + ".sig")
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrTargetsSignature, err)
+	}
+	sig, err := hex.DecodeString(strings.TrimSpace(string(sigData)))
+	if err != nil || len(sig) != ed25519.SignatureSize || !ed25519.Verify(p.pub, data, sig) {
+		return ErrTargetsSignature
+	}
+	return nil
+}
+
+// Watch signals writes, renames and removals of the file and of its
+// signature. It watches the parent directory so that files replaced by
+// rename, as editors and Kubernetes ConfigMaps do, keep being followed.
+func (p *FileProvider) Watch(ctx context.Context) (<-chan struct{}, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Clean(p.path)
+	if err := fw.Add(filepath.Dir(path)); err != nil {
+		_ = fw.Close()
+		return nil, err
+	}
+	out := make(chan struct{}, 1)
+	go func() {
+		defer close(out)
+		defer fw.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-fw.Events:
+				if !ok {
+					return
+				}
+				name := filepath.Clean(ev.Name)
+				if name != path && name != path+".sig" && !isDataSwap(ev.Name) {
+					continue
+				}
+				notify(out)
+			case _, ok := <-fw.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// isDataSwap reports the ..data symlink that Kubernetes swaps when a mounted
+// ConfigMap or Secret changes.
+func isDataSwap(name string) bool {
+	return filepath.Base(name) == "..data"
+}
@@ -0,0 +1,71 @@
+package sdk
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const targetsYAML = `version: v1
+default: tenant:a
+targets:
+  - Key: tenant:a
+    Driver: postgres
+    DSN: postgres://${TEST_TARGET_HOST}/a
+    Labels: [region=eu]
+`
+
+func TestFileProviderYAML(t *testing.T) {
+	t.Setenv("TEST_TARGET_HOST", "db.local")
+	path := filepath.Join(t.TempDir(), "targets.yaml")
+	if err := os.WriteFile(path, []byte(targetsYAML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfgs, def, ver, err := NewFileProvider(path).Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if def != "tenant:a" || ver != "v1" {
+		t.Fatalf("default=%q version=%q", def, ver)
+	}
+	c := cfgs["tenant:a"]
+	if c.DSN != "postgres://db.local/a" || len(c.Labels) != 1 || c.Labels[0] != "region=eu" {
+		t.Fatalf("target = %+v", c)
+	}
+}
+
+func TestSignedFileProvider(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "targets.yaml")
+	p := NewSignedFileProvider(path, pub)
+	if err := os.WriteFile(path, []byte(targetsYAML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := p.Fetch(context.Background()); !errors.Is(err, ErrTargetsSignature) {
+		t.Fatalf("missing signature err = %v", err)
+	}
+
+	sig := hex.EncodeToString(ed25519.Sign(priv, []byte(targetsYAML)))
+	if err := os.WriteFile(path+".sig", []byte(sig+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := p.Fetch(context.Background()); err != nil {
+		t.Fatalf("signed Fetch: %v", err)
+	}
+
+	tampered := targetsYAML + "  - Key: tenant:evil\n    Driver: postgres\n"
+	if err := os.WriteFile(path, []byte(tampered), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := p.Fetch(context.Background()); !errors.Is(err, ErrTargetsSignature) {
+		t.Fatalf("tampered err = %v", err)
+	}
+}
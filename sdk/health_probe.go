@@ -0,0 +1,235 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	crand "crypto/rand"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/faciam-dev/gcfm/pkg/metrics"
+)
+
+// TargetHealth describes the circuit breaker state of a target and the
+// outcome of its latest calls and probes.
+type TargetHealth struct {
+	Key string
+	// State is closed, open or half_open.
+	State string
+	// Failures counts consecutive transient failures of a closed circuit.
+	Failures int
+	// OpenUntil is when an open circuit lets the next call through.
+	OpenUntil time.Time
+	// LastError is the latest failure of a call or probe.
+	LastError   string
+	LastErrorAt time.Time
+	LastSuccess time.Time
+	// LastProbe and ProbeLatency describe the latest active probe.
+	LastProbe    time.Time
+	ProbeLatency time.Duration
+}
+
+// TargetProbe checks whether a target is reachable.
+type TargetProbe func(ctx context.Context, t TargetConn) error
+
+// HealthProbeConfig configures the active health prober.
+type HealthProbeConfig struct {
+	// Interval between two probes of a target. Defaults to 30s.
+	Interval time.Duration
+	// JitterRatio spreads probes by up to this fraction of Interval so that
+	// targets are not probed in lockstep.
+	JitterRatio float64
+	// Timeout bounds a single probe. Defaults to 5s.
+	Timeout time.Duration
+	// Probe overrides the default probe, which runs SELECT 1 on SQL targets
+	// and pings MongoDB targets.
+	Probe TargetProbe
+}
+
+// probeTarget runs SELECT 1 on SQL targets and pings MongoDB targets over the
+// clients in mc, which are opened once per DSN and reused by later probes.
+func probeTarget(ctx context.Context, t TargetConn, mc *mongoClients) error {
+	if t.Driver == "mongo" {
+		if t.DSN == "" {
+			return errors.New("mongo target has no DSN")
+		}
+		cli, err := mc.get(ctx, t.DSN)
+		if err != nil {
+			return err
+		}
+		return cli.Ping(ctx, nil)
+	}
+	if t.DB == nil {
+		return errors.New("target has no connection")
+	}
+	var one int
+	return t.DB.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+}
+
+// mongoClients keeps one MongoDB client per DSN for the health probes.
+type mongoClients struct {
+	mu      sync.Mutex
+	clients map[string]*mongo.Client
+}
+
+func (m *mongoClients) get(ctx context.Context, dsn string) (*mongo.Client, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cli, ok := m.clients[dsn]; ok {
+		return cli, nil
+	}
+	// mongo.Connect does not dial; the ping reports unreachable servers.
+	cli, err := mongo.Connect(ctx, options.Client().ApplyURI(dsn))
+	if err != nil {
+		return nil, err
+	}
+	if m.clients == nil {
+		m.clients = map[string]*mongo.Client{}
+	}
+	m.clients[dsn] = cli
+	return cli, nil
+}
+
+// retain disconnects the clients whose DSN is not in keep.
+func (m *mongoClients) retain(ctx context.Context, keep map[string]bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for dsn, cli := range m.clients {
+		if !keep[dsn] {
+			_ = cli.Disconnect(ctx)
+			delete(m.clients, dsn)
+		}
+	}
+}
+
+// StartHealthProber launches a goroutine that probes every registered target
+// each interval and feeds the results into the circuit breaker: a failed
+// probe counts like a failed call, a successful one closes the circuit.
+func (s *service) StartHealthProber(ctx context.Context, cfg HealthProbeConfig) (stop func()) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	// The prober owns the MongoDB clients of the default probe and closes
+	// those of removed targets.
+	var mc *mongoClients
+	if cfg.Probe == nil {
+		mc = &mongoClients{}
+		cfg.Probe = func(ctx context.Context, t TargetConn) error { return probeTarget(ctx, t, mc) }
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	go func() {
+		timer := time.NewTimer(cfg.jitter())
+		defer timer.Stop()
+		if mc != nil {
+			defer mc.retain(context.WithoutCancel(cctx), nil)
+		}
+		for {
+			select {
+			case <-cctx.Done():
+				return
+			case <-timer.C:
+			}
+			keep := s.probeAll(cctx, cfg)
+			if mc != nil {
+				mc.retain(cctx, keep)
+			}
+			timer.Reset(cfg.Interval + cfg.jitter())
+		}
+	}()
+	return cancel
+}
+
+// probeAll probes the registered targets concurrently and waits for all
+// probes to finish. Draining targets are not probed. It returns the DSNs of
+// the probed targets.
+func (s *service) probeAll(ctx context.Context, cfg HealthProbeConfig) map[string]bool {
+	snap := s.targets.Snapshot()
+	dsns := make(map[string]bool, len(snap))
+	for key, t := range snap {
+		if t.Draining {
+			delete(snap, key)
+			continue
+		}
+		dsns[t.DSN] = true
+	}
+	done := make(chan struct{}, len(snap))
+	for key, t := range snap {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			s.probe(ctx, cfg, key, t)
+		}()
+	}
+	for range snap {
+		<-done
+	}
+	return dsns
+}
+
+func (s *service) probe(ctx context.Context, cfg HealthProbeConfig, key string, t TargetConn) {
+	pctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	start := time.Now()
+	err := cfg.Probe(pctx, t)
+	rtt := time.Since(start)
+	if ctx.Err() != nil {
+		return
+	}
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	metrics.TargetProbeLatency.WithLabelValues(key, status).Observe(rtt.Seconds())
+
+	h := s.health.get(key)
+	h.mu.Lock()
+	h.lastProbe = start
+	h.probeRTT = rtt
+	h.mu.Unlock()
+	if err == nil {
+		s.health.onSuccess(key)
+		return
+	}
+	classify := s.classify
+	if classify == nil {
+		classify = DefaultErrorClassifier
+	}
+	transient, _ := classify(err)
+	s.health.onFailure(key, err, transient)
+}
+
+// jitter returns a random delay of up to JitterRatio * Interval.
+func (c HealthProbeConfig) jitter() time.Duration {
+	max := int64(c.JitterRatio * float64(c.Interval))
+	if max <= 0 {
+		return 0
+	}
+	n, err := crand.Int(crand.Reader, big.NewInt(max))
+	if err != nil {
+		return 0
+	}
+	return time.Duration(n.Int64())
+}
+
+// TargetHealth returns the health of every registered target sorted by key.
+func (s *service) TargetHealth() []TargetHealth {
+	keys := s.targets.Keys()
+	sort.Strings(keys)
+	out := make([]TargetHealth, 0, len(keys))
+	for _, k := range keys {
+		if s.health == nil {
+			out = append(out, TargetHealth{Key: k, State: stateClosed.String()})
+			continue
+		}
+		out = append(out, s.health.status(k))
+	}
+	return out
+}
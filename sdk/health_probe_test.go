@@ -0,0 +1,118 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestHealthProberFeedsBreaker(t *testing.T) {
+	ctx := context.Background()
+	reg := NewHotReloadRegistry(nil)
+	_ = reg.Register(ctx, "a", TargetConfig{DB: &sql.DB{}, Schema: "a"}, nil)
+	_ = reg.Register(ctx, "b", TargetConfig{DB: &sql.DB{}, Schema: "b"}, nil)
+	pol := FailoverPolicy{OpenAfterFailures: 2, OpenDuration: time.Minute}
+	svc := &service{targets: reg, classify: func(error) (bool, bool) { return true, true }, health: newHealthRegistry(pol)}
+
+	var down atomic.Bool
+	down.Store(true)
+	cfg := HealthProbeConfig{Timeout: time.Second, Probe: func(_ context.Context, tc TargetConn) error {
+		if tc.Schema == "a" && down.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	}}
+
+	svc.probeAll(ctx, cfg)
+	if h := svc.TargetHealth(); h[0].State != "closed" || h[0].Failures != 1 {
+		t.Fatalf("after one failure: %+v", h[0])
+	}
+	svc.probeAll(ctx, cfg)
+	h := svc.TargetHealth()
+	if len(h) != 2 || h[0].Key != "a" || h[0].State != "open" || h[0].LastError != "connection refused" || h[0].OpenUntil.IsZero() {
+		t.Fatalf("unexpected health: %+v", h)
+	}
+	if h[1].State != "closed" || h[1].LastProbe.IsZero() || h[1].LastSuccess.IsZero() {
+		t.Fatalf("unexpected health of b: %+v", h[1])
+	}
+	if svc.health.isAvailable("a") {
+		t.Fatal("open circuit should not be available")
+	}
+
+	down.Store(false)
+	svc.probeAll(ctx, cfg)
+	if h := svc.TargetHealth(); h[0].State != "closed" || h[0].LastError != "connection refused" {
+		t.Fatalf("after recovery: %+v", h[0])
+	}
+}
+
+func TestStartHealthProber(t *testing.T) {
+	ctx := context.Background()
+	reg := NewHotReloadRegistry(nil)
+	_ = reg.Register(ctx, "a", TargetConfig{DB: &sql.DB{}}, nil)
+	svc := &service{targets: reg, health: newHealthRegistry(FailoverPolicy{})}
+	var probes atomic.Int32
+	stop := svc.StartHealthProber(ctx, HealthProbeConfig{
+		Interval:    10 * time.Millisecond,
+		JitterRatio: 0.5,
+		Probe: func(context.Context, TargetConn) error {
+			probes.Add(1)
+			return nil
+		},
+	})
+	defer stop()
+	deadline := time.Now().Add(time.Second)
+	for probes.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if probes.Load() < 3 {
+		t.Fatalf("probes = %d", probes.Load())
+	}
+}
+
+func TestProbeTarget(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	mc := &mongoClients{}
+	if err := probeTarget(context.Background(), TargetConn{DB: db, Driver: "sqlite3"}, mc); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	_ = db.Close()
+	if err := probeTarget(context.Background(), TargetConn{DB: db, Driver: "sqlite3"}, mc); err == nil {
+		t.Fatal("expected an error for a closed connection")
+	}
+	if err := probeTarget(context.Background(), TargetConn{Driver: "mongo"}, mc); err == nil {
+		t.Fatal("expected an error for a mongo target without DSN")
+	}
+}
+
+func TestMongoProbeClientsAreReused(t *testing.T) {
+	ctx := context.Background()
+	mc := &mongoClients{}
+	a, err := mc.get(ctx, "mongodb://127.0.0.1:1/a")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if b, _ := mc.get(ctx, "mongodb://127.0.0.1:1/a"); b != a {
+		t.Fatal("client not reused")
+	}
+	if _, err := mc.get(ctx, "mongodb://127.0.0.1:1/b"); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	mc.retain(ctx, map[string]bool{"mongodb://127.0.0.1:1/b": true})
+	if len(mc.clients) != 1 || mc.clients["mongodb://127.0.0.1:1/b"] == nil {
+		t.Fatalf("clients = %v", mc.clients)
+	}
+	mc.retain(ctx, nil)
+	if len(mc.clients) != 0 {
+		t.Fatalf("clients = %v", mc.clients)
+	}
+}
@@ -1,7 +1,9 @@
 package sdk
 
 import (
+	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -9,14 +11,26 @@ func normalizeString(s string) string {
 	return strings.ToLower(strings.TrimSpace(s))
 }
 
+// LabelSet is the normalized label set of a target. It holds every label
+// and, for key=value labels, the key on its own.
+type LabelSet map[string]struct{}
+
+// Has reports whether label is in s.
+func (s LabelSet) Has(label string) bool {
+	_, ok := s[label]
+	return ok
+}
+
+// LabelExpr is a node of a label query. Eval reports whether a target with
+// labels matches the node.
 type LabelExpr interface {
-	Eval(has func(label string) bool) bool
+	Eval(labels LabelSet) bool
 }
 
 type EqExpr struct{ Label, Value string }
 
-func (e EqExpr) Eval(has func(label string) bool) bool {
-	return has(e.Label + "=" + e.Value)
+func (e EqExpr) Eval(labels LabelSet) bool {
+	return labels.Has(e.Label + "=" + e.Value)
 }
 
 type InExpr struct {
@@ -24,9 +38,9 @@ type InExpr struct {
 	Values []string
 }
 
-func (e InExpr) Eval(has func(label string) bool) bool {
+func (e InExpr) Eval(labels LabelSet) bool {
 	for _, v := range e.Values {
-		if has(e.Label + "=" + v) {
+		if labels.Has(e.Label + "=" + v) {
 			return true
 		}
 	}
@@ -35,114 +49,424 @@ func (e InExpr) Eval(has func(label string) bool) bool {
 
 type HasExpr struct{ Label string }
 
-func (e HasExpr) Eval(has func(label string) bool) bool {
-	return has(e.Label)
+func (e HasExpr) Eval(labels LabelSet) bool {
+	return labels.Has(e.Label)
 }
 
+// NotExpr matches targets without Label, which is either a key or a
+// key=value pair.
 type NotExpr struct{ Label string }
 
-func (e NotExpr) Eval(has func(label string) bool) bool {
-	return !has(e.Label)
+func (e NotExpr) Eval(labels LabelSet) bool {
+	return !labels.Has(e.Label)
+}
+
+// MatchExpr matches targets with a Label value matched by Pattern.
+type MatchExpr struct {
+	Label   string
+	Pattern *regexp.Regexp
+}
+
+func (e MatchExpr) Eval(labels LabelSet) bool {
+	prefix := e.Label + "="
+	for l := range labels {
+		if v, ok := strings.CutPrefix(l, prefix); ok && e.Pattern.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// NegExpr negates a sub-expression.
+type NegExpr struct{ Expr LabelExpr }
+
+func (e NegExpr) Eval(labels LabelSet) bool {
+	return !e.Expr.Eval(labels)
+}
+
+// AndExpr matches targets matching all of Exprs.
+type AndExpr struct{ Exprs []LabelExpr }
+
+func (e AndExpr) Eval(labels LabelSet) bool {
+	for _, x := range e.Exprs {
+		if !x.Eval(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// OrExpr matches targets matching any of Exprs.
+type OrExpr struct{ Exprs []LabelExpr }
+
+func (e OrExpr) Eval(labels LabelSet) bool {
+	for _, x := range e.Exprs {
+		if x.Eval(labels) {
+			return true
+		}
+	}
+	return false
 }
 
+// Query selects targets by label. Targets match any OR group when OR is set
+// and every AND expression otherwise, and must match Expr when it is set.
 type Query struct {
 	AND []LabelExpr
 	OR  [][]LabelExpr
+	// Expr is the expression parsed by ParseQuery.
+	Expr LabelExpr
 }
 
-func ParseQuery(s string) (Query, error) {
-	q := Query{}
-	s = normalizeString(s)
-	if s == "" {
-		return q, nil
-	}
-	if strings.Contains(s, "|") {
-		parts := strings.Split(s, "|")
-		q.OR = make([][]LabelExpr, 0, len(parts))
-		for _, p := range parts {
-			exprs, err := parseAND(p)
-			if err != nil {
-				return Query{}, err
-			}
-			q.OR = append(q.OR, exprs)
+// Matches reports whether a target with labels is selected by q.
+func (q Query) Matches(labels []string) bool {
+	return q.asExpr().Eval(toSet(labels))
+}
+
+// asExpr returns q as a single expression.
+func (q Query) asExpr() LabelExpr {
+	var e LabelExpr = AndExpr{Exprs: q.AND}
+	if len(q.OR) > 0 {
+		groups := make([]LabelExpr, len(q.OR))
+		for i, grp := range q.OR {
+			groups[i] = AndExpr{Exprs: grp}
 		}
-		return q, nil
+		e = OrExpr{Exprs: groups}
+	}
+	if q.Expr == nil {
+		return e
+	}
+	return AndExpr{Exprs: []LabelExpr{e, q.Expr}}
+}
+
+// QueryError reports a syntax error in a label query.
+type QueryError struct {
+	Query string
+	// Pos is the 1-based byte offset of the offending token.
+	Pos int
+	Msg string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("label query %q: %s at position %d", e.Query, e.Msg, e.Pos)
+}
+
+// ParseQuery parses a label query. The grammar, from lowest to highest
+// precedence:
+//
+//	or   = and { ("|" | "||") and }
+//	and  = not { ("," | "&&") not }
+//	not  = "!" not | "(" or ")" | pred
+//	pred = key [ ("=" | "==" | "!=") value
+//	           | ("=~" | "!~") "regex"
+//	           | ("in" | "notin") "(" value { "," value } ")" ]
+//
+// A bare key tests for its existence, so "!gpu" and "!region=eu" select
+// targets without the label. "notin" and "!=" also select targets without
+// the key. Keys and values are case-insensitive and may be double quoted;
+// regular expressions must be quoted and match case-insensitively.
+func ParseQuery(s string) (Query, error) {
+	toks, err := lexQuery(s)
+	if err != nil {
+		return Query{}, err
+	}
+	if len(toks) == 1 {
+		return Query{}, nil
 	}
-	exprs, err := parseAND(s)
+	p := &queryParser{src: s, toks: toks}
+	e, err := p.parseOr()
 	if err != nil {
 		return Query{}, err
 	}
-	q.AND = exprs
-	return q, nil
-}
-
-func parseAND(part string) ([]LabelExpr, error) {
-	part = normalizeString(part)
-	if part == "" {
-		return nil, nil
-	}
-	toks := make([]string, 0)
-	var cur strings.Builder
-	depth := 0
-	for _, r := range part {
-		switch r {
-		case '(':
-			depth++
-			cur.WriteRune(r)
-		case ')':
-			if depth > 0 {
-				depth--
+	if t := p.peek(); t.kind != tokEOF {
+		return Query{}, p.errorf(t, "unexpected %s", t)
+	}
+	return Query{Expr: e}, nil
+}
+
+type queryTokenKind int
+
+const (
+	tokEOF queryTokenKind = iota
+	tokIdent
+	tokString
+	tokOp
+)
+
+type queryToken struct {
+	kind queryTokenKind
+	text string // operator, lowercased identifier or unquoted string
+	pos  int
+}
+
+func (t queryToken) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokString:
+		return strconv.Quote(t.text)
+	default:
+		return "\"" + t.text + "\""
+	}
+}
+
+// queryOps lists the operators, longest first.
+var queryOps = []string{"==", "!=", "=~", "!~", "&&", "||", "=", "!", "(", ")", ",", "|"}
+
+func isLabelChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '-' || c == '.' || c == '/' || c == ':'
+}
+
+func lexQuery(s string) ([]queryToken, error) {
+	var toks []queryToken
+	i := 0
+next:
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
 			}
-			cur.WriteRune(r)
-		case ',':
-			if depth == 0 {
-				toks = append(toks, normalizeString(cur.String()))
-				cur.Reset()
-			} else {
-				cur.WriteRune(r)
+			if j >= len(s) {
+				return nil, &QueryError{Query: s, Pos: i + 1, Msg: "unterminated string"}
+			}
+			v, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return nil, &QueryError{Query: s, Pos: i + 1, Msg: "invalid string"}
+			}
+			toks = append(toks, queryToken{kind: tokString, text: v, pos: i + 1})
+			i = j + 1
+			continue
+		case isLabelChar(c):
+			j := i
+			for j < len(s) && isLabelChar(s[j]) {
+				j++
+			}
+			toks = append(toks, queryToken{kind: tokIdent, text: strings.ToLower(s[i:j]), pos: i + 1})
+			i = j
+			continue
+		}
+		for _, op := range queryOps {
+			if strings.HasPrefix(s[i:], op) {
+				toks = append(toks, queryToken{kind: tokOp, text: op, pos: i + 1})
+				i += len(op)
+				continue next
 			}
-		default:
-			cur.WriteRune(r)
 		}
+		return nil, &QueryError{Query: s, Pos: i + 1, Msg: fmt.Sprintf("unexpected character %q", c)}
 	}
-	if cur.Len() > 0 {
-		toks = append(toks, normalizeString(cur.String()))
+	return append(toks, queryToken{kind: tokEOF, pos: len(s) + 1}), nil
+}
+
+type queryParser struct {
+	src  string
+	toks []queryToken
+	i    int
+}
+
+func (p *queryParser) peek() queryToken { return p.toks[p.i] }
+
+func (p *queryParser) next() queryToken {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
 	}
-	out := make([]LabelExpr, 0, len(toks))
-	for _, t := range toks {
-		if t == "" {
-			continue
+	return t
+}
+
+func (p *queryParser) isOp(ops ...string) bool {
+	t := p.peek()
+	if t.kind != tokOp {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *queryParser) errorf(t queryToken, format string, args ...any) error {
+	return &QueryError{Query: p.src, Pos: t.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *queryParser) expect(op string) error {
+	if !p.isOp(op) {
+		t := p.peek()
+		return p.errorf(t, "expected %q, found %s", op, t)
+	}
+	p.next()
+	return nil
+}
+
+func (p *queryParser) parseOr() (LabelExpr, error) {
+	e, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	exprs := []LabelExpr{e}
+	for p.isOp("|", "||") {
+		p.next()
+		if e, err = p.parseAnd(); err != nil {
+			return nil, err
 		}
-		expr, err := parseExpr(t)
+		exprs = append(exprs, e)
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return OrExpr{Exprs: exprs}, nil
+}
+
+func (p *queryParser) parseAnd() (LabelExpr, error) {
+	e, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	exprs := []LabelExpr{e}
+	for p.isOp(",", "&&") {
+		p.next()
+		if e, err = p.parseNot(); err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return AndExpr{Exprs: exprs}, nil
+}
+
+func (p *queryParser) parseNot() (LabelExpr, error) {
+	switch {
+	case p.isOp("!"):
+		p.next()
+		e, err := p.parseNot()
 		if err != nil {
 			return nil, err
 		}
-		out = append(out, expr)
+		return negate(e), nil
+	case p.isOp("("):
+		p.next()
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return e, nil
 	}
-	return out, nil
+	return p.parsePred()
 }
 
-func parseExpr(tok string) (LabelExpr, error) {
-	tok = normalizeString(tok)
-	if strings.HasPrefix(tok, "!") {
-		return NotExpr{Label: normalizeString(tok[1:])}, nil
+// negate keeps negated existence tests in the NotExpr form.
+func negate(e LabelExpr) LabelExpr {
+	switch ex := e.(type) {
+	case HasExpr:
+		return NotExpr{Label: ex.Label}
+	case EqExpr:
+		return NotExpr{Label: ex.Label + "=" + ex.Value}
+	case NegExpr:
+		return ex.Expr
 	}
-	if matches := inExprRe.FindStringSubmatch(tok); matches != nil {
-		label := normalizeString(matches[1])
-		vals := matches[2]
-		items := strings.Split(vals, ",")
-		values := make([]string, 0, len(items))
-		for _, it := range items {
-			if v := normalizeString(it); v != "" {
-				values = append(values, v)
-			}
+	return NegExpr{Expr: e}
+}
+
+func (p *queryParser) parsePred() (LabelExpr, error) {
+	t := p.next()
+	if t.kind != tokIdent && t.kind != tokString {
+		return nil, p.errorf(t, "expected label, found %s", t)
+	}
+	key := normalizeString(t.text)
+	if key == "" {
+		return nil, p.errorf(t, "empty label")
+	}
+	op := p.peek()
+	switch {
+	case p.isOp("=", "=="):
+		p.next()
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return EqExpr{Label: key, Value: v}, nil
+	case p.isOp("!="):
+		p.next()
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
 		}
-		return InExpr{Label: label, Values: values}, nil
+		return NotExpr{Label: key + "=" + v}, nil
+	case p.isOp("=~", "!~"):
+		p.next()
+		rt := p.next()
+		if rt.kind != tokString {
+			return nil, p.errorf(rt, "expected quoted regular expression, found %s", rt)
+		}
+		if _, err := regexp.Compile(rt.text); err != nil {
+			return nil, p.errorf(rt, "invalid regular expression: %v", err)
+		}
+		m := MatchExpr{Label: key, Pattern: regexp.MustCompile("(?i)" + rt.text)}
+		if op.text == "!~" {
+			return NegExpr{Expr: m}, nil
+		}
+		return m, nil
+	case op.kind == tokIdent && (op.text == "in" || op.text == "notin"):
+		p.next()
+		vals, err := p.parseValues()
+		if err != nil {
+			return nil, err
+		}
+		in := InExpr{Label: key, Values: vals}
+		if op.text == "notin" {
+			return NegExpr{Expr: in}, nil
+		}
+		return in, nil
+	}
+	return HasExpr{Label: key}, nil
+}
+
+func (p *queryParser) parseValue() (string, error) {
+	t := p.next()
+	if t.kind != tokIdent && t.kind != tokString {
+		return "", p.errorf(t, "expected value, found %s", t)
+	}
+	v := normalizeString(t.text)
+	if v == "" {
+		return "", p.errorf(t, "empty value")
+	}
+	return v, nil
+}
+
+func (p *queryParser) parseValues() ([]string, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var vals []string
+	for {
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, v)
+		if !p.isOp(",") {
+			break
+		}
+		p.next()
 	}
-	if i := strings.IndexByte(tok, '='); i >= 0 {
-		return EqExpr{Label: normalizeString(tok[:i]), Value: normalizeString(tok[i+1:])}, nil
+	if err := p.expect(")"); err != nil {
+		return nil, err
 	}
-	return HasExpr{Label: tok}, nil
+	return vals, nil
 }
 
 // QueryFromLabels builds a Query that ANDs all label strings.
@@ -158,5 +482,3 @@ func QueryFromLabels(labels []string) Query {
 	}
 	return q
 }
-
-var inExprRe = regexp.MustCompile(`^([a-z0-9_\-]+)\s+in\s+\(([^)]*)\)$`)
@@ -108,6 +108,114 @@ func TestLabelQueries(t *testing.T) {
 	}
 }
 
+func TestParseQueryGrammar(t *testing.T) {
+	ctx := context.Background()
+	reg := NewHotReloadRegistry(nil)
+	db, _ := sql.Open("nop", "")
+	labels := map[string][]string{
+		"a": {"region=eu-west", "env=prod", "gpu"},
+		"b": {"region=eu-central", "env=stg"},
+		"c": {"region=us-east", "deprecated"},
+		"d": {"env=prod"},
+	}
+	for k, l := range labels {
+		if err := reg.Register(ctx, k, TargetConfig{DB: db, Driver: "nop", Labels: l}, nil); err != nil {
+			t.Fatalf("register: %v", err)
+		}
+	}
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{`region=~"^eu-"`, []string{"a", "b"}},
+		{`region!~"^EU-"`, []string{"c", "d"}},
+		{`env notin (prod)`, []string{"b", "c"}},
+		{`env in (prod, stg), region`, []string{"a", "b"}},
+		{`!(gpu | deprecated)`, []string{"b", "d"}},
+		{`(env=prod || env=stg) && !region=eu-west`, []string{"b", "d"}},
+		{`!env=prod`, []string{"b", "c"}},
+		{`env != prod, !deprecated`, []string{"b"}},
+		{`REGION == "EU-WEST"`, []string{"a"}},
+		{`!!gpu`, []string{"a"}},
+		{``, []string{"a", "b", "c", "d"}},
+	}
+	for _, tc := range cases {
+		q, err := ParseQuery(tc.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", tc.query, err)
+		}
+		if got := reg.FindByQuery(q); !equalSlices(got, tc.want) {
+			t.Fatalf("FindByQuery(%q) = %v, want %v", tc.query, got, tc.want)
+		}
+		var matched []string
+		for _, k := range []string{"a", "b", "c", "d"} {
+			if q.Matches(labels[k]) {
+				matched = append(matched, k)
+			}
+		}
+		if !equalSlices(matched, tc.want) {
+			t.Fatalf("Matches(%q) = %v, want %v", tc.query, matched, tc.want)
+		}
+	}
+}
+
+func TestMatchExprEval(t *testing.T) {
+	q, err := ParseQuery(`region=~"^eu-"`)
+	if err != nil {
+		t.Fatalf("ParseQuery: %v", err)
+	}
+	m, ok := q.Expr.(MatchExpr)
+	if !ok {
+		t.Fatalf("expr = %T, want MatchExpr", q.Expr)
+	}
+	eu := toSet([]string{"region=eu-west", "gpu"})
+	us := toSet([]string{"region=us-east"})
+	none := toSet([]string{"gpu"})
+	if !m.Eval(eu) || m.Eval(us) || m.Eval(none) {
+		t.Fatalf("Eval: eu=%v us=%v none=%v", m.Eval(eu), m.Eval(us), m.Eval(none))
+	}
+	neg := NegExpr{Expr: m}
+	if neg.Eval(eu) || !neg.Eval(us) || !neg.Eval(none) {
+		t.Fatalf("negated Eval: eu=%v us=%v none=%v", neg.Eval(eu), neg.Eval(us), neg.Eval(none))
+	}
+	q, err = ParseQuery(`region!~"^eu-", gpu`)
+	if err != nil {
+		t.Fatalf("ParseQuery: %v", err)
+	}
+	if q.Expr.Eval(eu) || q.Expr.Eval(us) || !q.Expr.Eval(none) {
+		t.Fatalf("Eval(%v) on eu, us, none = %v %v %v", q.Expr, q.Expr.Eval(eu), q.Expr.Eval(us), q.Expr.Eval(none))
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	cases := []struct {
+		query string
+		pos   int
+	}{
+		{`region=`, 8},
+		{`(gpu`, 5},
+		{`env in prod`, 8},
+		{`region=~"("`, 9},
+		{`region=~eu`, 9},
+		{`gpu env`, 5},
+		{`gpu,,env`, 5},
+		{`gpu$`, 4},
+		{`env="prod`, 5},
+		{`)`, 1},
+	}
+	for _, tc := range cases {
+		_, err := ParseQuery(tc.query)
+		var qe *QueryError
+		if !errors.As(err, &qe) {
+			t.Fatalf("ParseQuery(%q) err = %v, want *QueryError", tc.query, err)
+		}
+		if qe.Pos != tc.pos {
+			t.Fatalf("ParseQuery(%q) pos = %d (%v), want %d", tc.query, qe.Pos, err, tc.pos)
+		}
+	}
+}
+
 func TestLabelQueriesRace(t *testing.T) {
 	ctx := context.Background()
 	reg := NewHotReloadRegistry(nil)
@@ -160,6 +160,9 @@ type AutoLabelResolverOptions struct {
 	JWT  *JWTLabelRules
 	Ctx  *CtxValueRules
 	Hint *SelectionHint
+	// Filter further restricts the targets selected by the resolved labels,
+	// e.g. a ParseQuery result of "!deprecated".
+	Filter *Query
 }
 
 // AutoLabelResolver builds a TargetResolverV2 that aggregates labels from
@@ -188,6 +191,9 @@ func AutoLabelResolver(opts AutoLabelResolverOptions) TargetResolverV2 {
 			return TargetDecision{}, false
 		}
 		q := QueryFromLabels(labels)
+		if opts.Filter != nil {
+			q.Expr = opts.Filter.asExpr()
+		}
 		return TargetDecision{Query: &q, Hint: opts.Hint}, true
 	}
 }
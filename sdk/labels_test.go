@@ -254,3 +254,32 @@ func TestGRPCJWTCombination(t *testing.T) {
 		t.Fatalf("FindByQuery = %v", keys)
 	}
 }
+
+func TestAutoLabelResolverFilter(t *testing.T) {
+	ctx := context.Background()
+	reg := NewHotReloadRegistry(nil)
+	must := func(err error) {
+		if err != nil {
+			t.Fatalf("register: %v", err)
+		}
+	}
+	must(reg.Register(ctx, "eu-1", TargetConfig{DB: new(sql.DB), Driver: "sqlite3", Labels: []string{"tenant=acme", "region=eu-west"}}, nil))
+	must(reg.Register(ctx, "eu-2", TargetConfig{DB: new(sql.DB), Driver: "sqlite3", Labels: []string{"tenant=acme", "region=eu-central", "deprecated"}}, nil))
+	must(reg.Register(ctx, "us-1", TargetConfig{DB: new(sql.DB), Driver: "sqlite3", Labels: []string{"tenant=acme", "region=us-east"}}, nil))
+
+	filter, err := ParseQuery(`region=~"^eu-", !deprecated`)
+	if err != nil {
+		t.Fatalf("ParseQuery: %v", err)
+	}
+	opts := AutoLabelResolverOptions{
+		JWT:    &JWTLabelRules{ClaimMap: map[string]string{"tid": "tenant"}},
+		Filter: &filter,
+	}
+	dec, ok := AutoLabelResolver(opts)(WithJWTClaims(ctx, map[string]any{"tid": "acme"}))
+	if !ok || dec.Query == nil {
+		t.Fatalf("resolver failed")
+	}
+	if keys := reg.FindByQuery(*dec.Query); !reflect.DeepEqual(keys, []string{"eu-1"}) {
+		t.Fatalf("FindByQuery = %v", keys)
+	}
+}
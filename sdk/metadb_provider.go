@@ -8,12 +8,28 @@ import (
 
 // MetaDBProvider reads target configuration from a MetaStore.
 type MetaDBProvider struct {
-	meta metapkg.MetaStore
+	meta   metapkg.MetaStore
+	signal TargetSignal
 }
 
 // NewMetaDBProvider creates a provider backed by MetaStore.
 func NewMetaDBProvider(meta metapkg.MetaStore) *MetaDBProvider { return &MetaDBProvider{meta: meta} }
 
+// NewWatchingMetaDBProvider creates a provider backed by MetaStore that
+// pushes the changes announced by sig.
+func NewWatchingMetaDBProvider(meta metapkg.MetaStore, sig TargetSignal) *MetaDBProvider {
+	return &MetaDBProvider{meta: meta, signal: sig}
+}
+
+// Watch implements WatchingTargetProvider. Without a signal it returns a nil
+// channel.
+func (p *MetaDBProvider) Watch(ctx context.Context) (<-chan struct{}, error) {
+	if p.signal == nil {
+		return nil, nil
+	}
+	return p.signal.Subscribe(ctx)
+}
+
 // Fetch retrieves target configurations from the meta store.
 func (p *MetaDBProvider) Fetch(ctx context.Context) (map[string]TargetConfig, string, string, error) {
 	rows, ver, def, err := p.meta.ListTargets(ctx)
@@ -32,6 +48,7 @@ func (p *MetaDBProvider) Fetch(ctx context.Context) (map[string]TargetConfig, st
 			ConnMaxIdle:  r.ConnMaxIdle,
 			ConnMaxLife:  r.ConnMaxLife,
 			Labels:       r.Labels,
+			Draining:     r.Draining,
 		}
 	}
 	return cfgs, def, ver, nil
@@ -29,6 +29,7 @@ func newTargetStore(t *testing.T) *sqlmetastore.SQLMetaStore {
         conn_max_idle_ms BIGINT DEFAULT 0,
         conn_max_life_ms BIGINT DEFAULT 0,
         is_default BOOLEAN DEFAULT FALSE,
+        draining BOOLEAN NOT NULL DEFAULT FALSE,
         updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
     );
     CREATE TABLE IF NOT EXISTS gcfm_target_labels (
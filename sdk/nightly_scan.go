@@ -12,11 +12,15 @@ import (
 )
 
 // NightlyScan enumerates tables across all registered targets and records the
-// results in the MetaDB. Each target is scanned independently and results are
-// stored using a MetaDB transaction.
+// results in the MetaDB. Each target is scanned independently, on a replica
+// when it has one, and results are stored using a MetaDB transaction.
+// Draining targets are skipped.
 func (s *service) NightlyScan(ctx context.Context) error {
 	for key, tgt := range s.targets.Snapshot() {
-		tables, err := listTables(ctx, tgt)
+		if tgt.Draining {
+			continue
+		}
+		tables, err := listTables(ctx, s.replicas.readConn(ctx, key, tgt))
 		if err != nil {
 			return fmt.Errorf("%s: %w", key, err)
 		}
@@ -61,3 +61,30 @@ func TestNightlyScan(t *testing.T) {
 		t.Fatalf("expected 1 scan result per tenant, got %d and %d", cntA, cntB)
 	}
 }
+
+func TestNightlyScanSkipsDrainingTargets(t *testing.T) {
+	metaDB, _ := sql.Open("sqlite3", ":memory:")
+	createScanResultTable(t, metaDB)
+	target, _ := sql.Open("sqlite3", ":memory:")
+	createTable(t, target, "a1")
+
+	svc := New(ServiceConfig{
+		MetaDB:     metaDB,
+		MetaDriver: "sqlite3",
+		Targets: []TargetConfig{
+			{Key: "tenant:A", DB: target, Driver: "sqlite3"},
+			{Key: "tenant:B", Driver: "sqlite3", Draining: true},
+		},
+	}).(*service)
+
+	if err := svc.NightlyScan(context.Background()); err != nil {
+		t.Fatalf("NightlyScan: %v", err)
+	}
+	var cnt int
+	if err := metaDB.QueryRow("SELECT COUNT(*) FROM gcfm_scan_results WHERE tenant_id='tenant:B'").Scan(&cnt); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if cnt != 0 {
+		t.Fatalf("draining target scanned: %d results", cnt)
+	}
+}
@@ -0,0 +1,214 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Replica is a read-only copy of a target database.
+type Replica struct {
+	DB  *sql.DB
+	DSN string
+}
+
+// ReplicaPolicy controls how reads are routed to target replicas.
+type ReplicaPolicy struct {
+	// MaxLag excludes replicas lagging further behind their primary. Zero
+	// disables the limit.
+	MaxLag time.Duration
+	// LagCheckInterval is how long a measured lag is reused. Defaults to 5s.
+	LagCheckInterval time.Duration
+	// ReadYourWritesWindow is how long reads of a target stay on its
+	// primary after a WithReadYourWrites context wrote to it. Defaults to
+	// 5s.
+	ReadYourWritesWindow time.Duration
+}
+
+// errNotReplicating reports a replica whose replication is stopped.
+var errNotReplicating = errors.New("replication is not running")
+
+type ctxKeyReadYourWrites struct{}
+
+// writeLog records when a context last wrote to each target.
+type writeLog struct {
+	mu sync.Mutex
+	m  map[string]time.Time
+}
+
+// WithReadYourWrites returns a context whose reads observe its own writes:
+// after a write to a target through the context, reads of that target go to
+// its primary for ReplicaPolicy.ReadYourWritesWindow. The measured lag is
+// not trusted for this since it is sampled at most every LagCheckInterval
+// and a replica can report no lag before it has replayed the write.
+func WithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxKeyReadYourWrites{}, &writeLog{m: map[string]time.Time{}})
+}
+
+func writeLogFrom(ctx context.Context) *writeLog {
+	w, _ := ctx.Value(ctxKeyReadYourWrites{}).(*writeLog)
+	return w
+}
+
+func (w *writeLog) record(key string, at time.Time) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.m[key] = at
+	w.mu.Unlock()
+}
+
+func (w *writeLog) last(key string) (time.Time, bool) {
+	if w == nil {
+		return time.Time{}, false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	t, ok := w.m[key]
+	return t, ok
+}
+
+type lagSample struct {
+	lag time.Duration
+	err error
+	at  time.Time
+}
+
+// replicaRouter picks replicas for reads and caches their measured lag.
+type replicaRouter struct {
+	pol     ReplicaPolicy
+	measure func(ctx context.Context, driver string, db *sql.DB) (time.Duration, error)
+	next    atomic.Uint64
+
+	mu  sync.Mutex
+	lag map[string]lagSample // by replica DSN
+}
+
+func newReplicaRouter(pol ReplicaPolicy) *replicaRouter {
+	if pol.LagCheckInterval <= 0 {
+		pol.LagCheckInterval = 5 * time.Second
+	}
+	if pol.ReadYourWritesWindow <= 0 {
+		pol.ReadYourWritesWindow = 5 * time.Second
+	}
+	return &replicaRouter{pol: pol, measure: replicaLag, lag: map[string]lagSample{}}
+}
+
+// readConn returns the connection a read of target key should use: one of
+// its replicas in turn, skipping replicas that lag too far behind or have
+// failed, or the primary when none qualifies or ctx wrote to key within the
+// read-your-writes window.
+func (r *replicaRouter) readConn(ctx context.Context, key string, t TargetConn) TargetConn {
+	if r == nil || len(t.Replicas) == 0 {
+		return t
+	}
+	if w, ok := writeLogFrom(ctx).last(key); ok && time.Since(w) < r.pol.ReadYourWritesWindow {
+		return t
+	}
+	maxLag := r.pol.MaxLag
+	start := r.next.Add(1)
+	n := uint64(len(t.Replicas))
+	for i := uint64(0); i < n; i++ {
+		rep := t.Replicas[(start+i)%n]
+		s := r.sample(ctx, t.Driver, rep)
+		if s.err != nil || (maxLag > 0 && s.lag >= maxLag) {
+			continue
+		}
+		c := t
+		c.DB = rep.DB
+		c.DSN = rep.DSN
+		c.Replicas = nil
+		return c
+	}
+	return t
+}
+
+// sample returns the cached lag of rep, measuring it when the cached value
+// is older than the check interval.
+func (r *replicaRouter) sample(ctx context.Context, driver string, rep Replica) lagSample {
+	r.mu.Lock()
+	s, ok := r.lag[rep.DSN]
+	r.mu.Unlock()
+	if ok && time.Since(s.at) < r.pol.LagCheckInterval {
+		return s
+	}
+	lag, err := r.measure(ctx, driver, rep.DB)
+	s = lagSample{lag: lag, err: err, at: time.Now()}
+	r.mu.Lock()
+	r.lag[rep.DSN] = s
+	r.mu.Unlock()
+	return s
+}
+
+// fail excludes the replica until its lag is measured again.
+func (r *replicaRouter) fail(dsn string, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.lag[dsn] = lagSample{err: err, at: time.Now()}
+	r.mu.Unlock()
+}
+
+// replicaLag measures how far db lags behind its primary. Databases that
+// are not replicas report no lag.
+func replicaLag(ctx context.Context, driver string, db *sql.DB) (time.Duration, error) {
+	switch driver {
+	case "postgres":
+		var secs sql.NullFloat64
+		err := db.QueryRowContext(ctx, `SELECT CASE
+	WHEN NOT pg_is_in_recovery() THEN 0
+	WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()) END`).Scan(&secs)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(secs.Float64 * float64(time.Second)), nil
+	case "mysql":
+		return mysqlReplicaLag(ctx, db)
+	default:
+		return 0, nil
+	}
+}
+
+func mysqlReplicaLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	rows, err := db.QueryContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+	vals := make([]sql.RawBytes, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return 0, err
+	}
+	for i, c := range cols {
+		if c != "Seconds_Behind_Source" && c != "Seconds_Behind_Master" {
+			continue
+		}
+		if vals[i] == nil {
+			return 0, errNotReplicating
+		}
+		secs, err := strconv.ParseInt(string(vals[i]), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(secs) * time.Second, nil
+	}
+	return 0, errors.New("SHOW REPLICA STATUS has no Seconds_Behind_Source column")
+}
@@ -0,0 +1,176 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newReplicaService(t *testing.T, pol ReplicaPolicy, lag map[string]time.Duration) *service {
+	t.Helper()
+	reg := NewHotReloadRegistry(nil)
+	err := reg.Register(context.Background(), "t", TargetConfig{
+		Driver:      "sqlite3",
+		DSN:         "file:primary?mode=memory",
+		ReplicaDSNs: []string{"file:r1?mode=memory", "file:r2?mode=memory"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	t.Cleanup(func() { _ = reg.Unregister("t") })
+	r := newReplicaRouter(pol)
+	tc, _ := reg.Get("t")
+	r.measure = func(_ context.Context, _ string, db *sql.DB) (time.Duration, error) {
+		for _, rep := range tc.Replicas {
+			if rep.DB == db {
+				if d, ok := lag[rep.DSN]; ok {
+					return d, nil
+				}
+				return 0, errNotReplicating
+			}
+		}
+		return 0, nil
+	}
+	return &service{targets: reg, replicas: r}
+}
+
+func readDSN(t *testing.T, svc *service, ctx context.Context) string {
+	t.Helper()
+	var dsn string
+	err := svc.RunWithTarget(ctx, TargetDecision{Key: "t"}, false, func(c TargetConn) error {
+		dsn = c.DSN
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return dsn
+}
+
+func TestReadsUseReplicasInTurn(t *testing.T) {
+	svc := newReplicaService(t, ReplicaPolicy{}, map[string]time.Duration{
+		"file:r1?mode=memory": 0,
+		"file:r2?mode=memory": time.Second,
+	})
+	seen := map[string]int{}
+	for i := 0; i < 4; i++ {
+		seen[readDSN(t, svc, context.Background())]++
+	}
+	if seen["file:r1?mode=memory"] != 2 || seen["file:r2?mode=memory"] != 2 {
+		t.Fatalf("reads = %v", seen)
+	}
+	var dsn string
+	_ = svc.RunWithTarget(context.Background(), TargetDecision{Key: "t"}, true, func(c TargetConn) error {
+		dsn = c.DSN
+		return nil
+	})
+	if dsn != "file:primary?mode=memory" {
+		t.Fatalf("write went to %s", dsn)
+	}
+}
+
+func TestLaggingReplicasExcluded(t *testing.T) {
+	svc := newReplicaService(t, ReplicaPolicy{MaxLag: 5 * time.Second}, map[string]time.Duration{
+		"file:r1?mode=memory": 10 * time.Second,
+	})
+	// r1 lags too far behind and r2 is not replicating.
+	if dsn := readDSN(t, svc, context.Background()); dsn != "file:primary?mode=memory" {
+		t.Fatalf("read went to %s", dsn)
+	}
+}
+
+func TestReadYourWrites(t *testing.T) {
+	svc := newReplicaService(t, ReplicaPolicy{}, map[string]time.Duration{
+		"file:r1?mode=memory": time.Minute,
+		"file:r2?mode=memory": time.Minute,
+	})
+	ctx := WithReadYourWrites(context.Background())
+	if dsn := readDSN(t, svc, ctx); dsn == "file:primary?mode=memory" {
+		t.Fatal("reads before a write should use replicas")
+	}
+	if err := svc.RunWithTarget(ctx, TargetDecision{Key: "t"}, true, func(TargetConn) error { return nil }); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if dsn := readDSN(t, svc, ctx); dsn != "file:primary?mode=memory" {
+		t.Fatalf("read after write went to %s", dsn)
+	}
+	if dsn := readDSN(t, svc, context.Background()); dsn == "file:primary?mode=memory" {
+		t.Fatal("other contexts should still use replicas")
+	}
+}
+
+func TestReadYourWritesIgnoresReportedLag(t *testing.T) {
+	// Both replicas report no lag, as a replica sampled before the write
+	// still does.
+	svc := newReplicaService(t, ReplicaPolicy{ReadYourWritesWindow: 50 * time.Millisecond}, map[string]time.Duration{
+		"file:r1?mode=memory": 0,
+		"file:r2?mode=memory": 0,
+	})
+	ctx := WithReadYourWrites(context.Background())
+	if err := svc.RunWithTarget(ctx, TargetDecision{Key: "t"}, true, func(TargetConn) error { return nil }); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if dsn := readDSN(t, svc, ctx); dsn != "file:primary?mode=memory" {
+			t.Fatalf("read %d after write went to %s", i, dsn)
+		}
+	}
+	time.Sleep(60 * time.Millisecond)
+	if dsn := readDSN(t, svc, ctx); dsn == "file:primary?mode=memory" {
+		t.Fatal("reads after the window should use replicas again")
+	}
+}
+
+func TestFailedReplicaFallsBackToPrimary(t *testing.T) {
+	svc := newReplicaService(t, ReplicaPolicy{}, map[string]time.Duration{
+		"file:r1?mode=memory": 0,
+	})
+	var tries []string
+	err := svc.RunWithTarget(context.Background(), TargetDecision{Key: "t"}, false, func(c TargetConn) error {
+		tries = append(tries, c.DSN)
+		if c.DSN != "file:primary?mode=memory" {
+			return errors.New("replica down")
+		}
+		return nil
+	})
+	if err != nil || len(tries) != 2 || tries[1] != "file:primary?mode=memory" {
+		t.Fatalf("tries = %v, err = %v", tries, err)
+	}
+	// The failed replica stays excluded until its lag is measured again.
+	if dsn := readDSN(t, svc, context.Background()); dsn != "file:primary?mode=memory" {
+		t.Fatalf("read went to %s", dsn)
+	}
+}
+
+func TestMySQLReplicaLag(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	mock.ExpectQuery("SHOW REPLICA STATUS").
+		WillReturnRows(sqlmock.NewRows([]string{"Replica_IO_State", "Seconds_Behind_Source"}).AddRow("Waiting", "7"))
+	if lag, err := replicaLag(ctx, "mysql", db); err != nil || lag != 7*time.Second {
+		t.Fatalf("lag = %v, %v", lag, err)
+	}
+	mock.ExpectQuery("SHOW REPLICA STATUS").
+		WillReturnRows(sqlmock.NewRows([]string{"Seconds_Behind_Source"}).AddRow(nil))
+	if _, err := replicaLag(ctx, "mysql", db); !errors.Is(err, errNotReplicating) {
+		t.Fatalf("err = %v", err)
+	}
+	mock.ExpectQuery("SHOW REPLICA STATUS").
+		WillReturnRows(sqlmock.NewRows([]string{"Seconds_Behind_Source"}))
+	if lag, err := replicaLag(ctx, "mysql", db); err != nil || lag != 0 {
+		t.Fatalf("primary lag = %v, %v", lag, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
@@ -2,7 +2,6 @@ package sdk
 
 import (
 	"context"
-	"database/sql"
 
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
@@ -28,11 +27,11 @@ func (s *service) Scan(ctx context.Context, cfg DBConfig) ([]registry.FieldMeta,
 	}
 	switch drv {
 	case "postgres":
-		db, err := sql.Open("postgres", cfg.DSN)
+		db, release, err := openSQL(cfg, "postgres")
 		if err != nil {
 			return nil, err
 		}
-		defer func() { _ = db.Close() }()
+		defer release()
 		sc := pscanner.NewScanner(db)
 		return sc.Scan(ctx, registry.DBConfig{DSN: cfg.DSN, Schema: cfg.Schema, TablePrefix: cfg.TablePrefix})
 	case "mongo":
@@ -44,18 +43,18 @@ func (s *service) Scan(ctx context.Context, cfg DBConfig) ([]registry.FieldMeta,
 		sc := mongoscanner.NewScanner(cli)
 		return sc.Scan(ctx, registry.DBConfig{Schema: cfg.Schema, TablePrefix: cfg.TablePrefix})
 	case "sqlmock":
-		db, err := sql.Open("sqlmock", cfg.DSN)
+		db, release, err := openSQL(cfg, "sqlmock")
 		if err != nil {
 			return nil, err
 		}
-		defer func() { _ = db.Close() }()
+		defer release()
 		return registry.LoadSQL(ctx, db, registry.DBConfig{Schema: cfg.Schema, Driver: cfg.Driver, TablePrefix: cfg.TablePrefix})
 	default:
-		db, err := sql.Open("mysql", cfg.DSN)
+		db, release, err := openSQL(cfg, "mysql")
 		if err != nil {
 			return nil, err
 		}
-		defer func() { _ = db.Close() }()
+		defer release()
 		sc := mysqlscanner.NewScanner(db)
 		return sc.Scan(ctx, registry.DBConfig{DSN: cfg.DSN, Schema: cfg.Schema, TablePrefix: cfg.TablePrefix})
 	}
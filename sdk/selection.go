@@ -0,0 +1,186 @@
+package sdk
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/faciam-dev/gcfm/pkg/metrics"
+)
+
+// ewmaAlpha is the weight of the newest latency sample in the moving average.
+const ewmaAlpha = 0.3
+
+// targetLoad holds the per target state of the load aware strategies.
+type targetLoad struct {
+	inFlight int
+	ewma     float64 // seconds; 0 until the first sample
+	current  int     // smooth weighted round robin counter
+}
+
+// loadTracker tracks in-flight calls, latency and round robin state of
+// targets. A nil tracker keeps no state.
+type loadTracker struct {
+	mu sync.Mutex
+	m  map[string]*targetLoad
+}
+
+func newLoadTracker() *loadTracker {
+	return &loadTracker{m: make(map[string]*targetLoad)}
+}
+
+func (l *loadTracker) get(key string) *targetLoad {
+	t, ok := l.m[key]
+	if !ok {
+		t = &targetLoad{}
+		l.m[key] = t
+	}
+	return t
+}
+
+// begin marks a call to key as started. The returned func ends it with the
+// call's error. Successful calls are timed in cf_target_call_seconds and
+// the same sample feeds the latency average; failed calls are not timed, so
+// a target that fails fast does not look fast.
+func (l *loadTracker) begin(key string) func(err error) {
+	start := time.Now()
+	if l != nil {
+		l.mu.Lock()
+		l.get(key).inFlight++
+		l.mu.Unlock()
+	}
+	return func(err error) {
+		if l != nil {
+			l.mu.Lock()
+			l.get(key).inFlight--
+			l.mu.Unlock()
+		}
+		if err != nil {
+			return
+		}
+		d := time.Since(start)
+		metrics.TargetCallLatency.WithLabelValues(key).Observe(d.Seconds())
+		l.observe(key, d)
+	}
+}
+
+// observe adds a latency sample of key to its moving average.
+func (l *loadTracker) observe(key string, d time.Duration) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	t := l.get(key)
+	if t.ewma == 0 {
+		t.ewma = d.Seconds()
+		return
+	}
+	t.ewma = ewmaAlpha*d.Seconds() + (1-ewmaAlpha)*t.ewma
+}
+
+// busy reports whether calls to key are in flight.
+func (l *loadTracker) busy(key string) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	t, ok := l.m[key]
+	return ok && t.inFlight > 0
+}
+
+// prune forgets the state of targets not in keys.
+func (l *loadTracker) prune(keys []string) {
+	if l == nil {
+		return
+	}
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		set[k] = struct{}{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for k := range l.m {
+		if _, ok := set[k]; !ok {
+			delete(l.m, k)
+		}
+	}
+}
+
+// byLeastInFlight orders keys by their number of in-flight calls. Keys keep
+// their relative order on ties.
+func (l *loadTracker) byLeastInFlight(keys []string) []string {
+	if l == nil {
+		return keys
+	}
+	l.mu.Lock()
+	n := make(map[string]int, len(keys))
+	for _, k := range keys {
+		if t, ok := l.m[k]; ok {
+			n[k] = t.inFlight
+		}
+	}
+	l.mu.Unlock()
+	sort.SliceStable(keys, func(i, j int) bool { return n[keys[i]] < n[keys[j]] })
+	return keys
+}
+
+// byLatency orders keys by their latency average. Keys without samples come
+// first so that they get measured.
+func (l *loadTracker) byLatency(keys []string) []string {
+	if l == nil {
+		return keys
+	}
+	l.mu.Lock()
+	ewma := make(map[string]float64, len(keys))
+	for _, k := range keys {
+		if t, ok := l.m[k]; ok {
+			ewma[k] = t.ewma
+		}
+	}
+	l.mu.Unlock()
+	sort.SliceStable(keys, func(i, j int) bool { return ewma[keys[i]] < ewma[keys[j]] })
+	return keys
+}
+
+// byWeightedRoundRobin advances the smooth weighted round robin over keys and
+// orders them by their counters, so that each key leads a share of the calls
+// proportional to its weight.
+func (l *loadTracker) byWeightedRoundRobin(keys []string, weight func(string) int) []string {
+	if len(keys) == 0 {
+		return keys
+	}
+	w := make(map[string]int, len(keys))
+	total := 0
+	for _, k := range keys {
+		w[k] = max(weight(k), 1)
+		total += w[k]
+	}
+	if l == nil {
+		sort.SliceStable(keys, func(i, j int) bool { return w[keys[i]] > w[keys[j]] })
+		return keys
+	}
+	l.mu.Lock()
+	cur := make(map[string]int, len(keys))
+	best := ""
+	for _, k := range keys {
+		t := l.get(k)
+		t.current += w[k]
+		if best == "" || t.current > l.m[best].current {
+			best = k
+		}
+	}
+	l.m[best].current -= total
+	for _, k := range keys {
+		cur[k] = l.m[k].current
+	}
+	l.mu.Unlock()
+	sort.SliceStable(keys, func(i, j int) bool {
+		if keys[i] == best || keys[j] == best {
+			return keys[i] == best
+		}
+		return cur[keys[i]] > cur[keys[j]]
+	})
+	return keys
+}
@@ -3,7 +3,13 @@ package sdk
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/faciam-dev/gcfm/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 // helper to create registry with sample targets
@@ -83,3 +89,134 @@ func TestConsistentHashDifferentSources(t *testing.T) {
 		t.Fatalf("expected different keys for different hash sources: %s", a1)
 	}
 }
+
+func TestWeightedRoundRobin(t *testing.T) {
+	reg := NewHotReloadRegistry(nil)
+	ctx := context.Background()
+	_ = reg.Register(ctx, "big", TargetConfig{DB: new(sql.DB), Weight: 3}, nil)
+	_ = reg.Register(ctx, "small", TargetConfig{DB: new(sql.DB)}, nil)
+	svc := &service{targets: reg, stratDefault: SelectWeightedRoundRobin, load: newLoadTracker()}
+
+	counts := map[string]int{}
+	var seq []string
+	for i := 0; i < 8; i++ {
+		k, ok := svc.chooseOne([]string{"small", "big"}, nil)
+		if !ok {
+			t.Fatal("no selection")
+		}
+		counts[k]++
+		seq = append(seq, k)
+	}
+	if counts["big"] != 6 || counts["small"] != 2 {
+		t.Fatalf("counts = %v", counts)
+	}
+	// Smooth: the small target is not starved for three picks in a row.
+	if !reflect.DeepEqual(seq[:4], []string{"big", "big", "small", "big"}) {
+		t.Fatalf("sequence = %v", seq)
+	}
+}
+
+func TestLeastInFlight(t *testing.T) {
+	reg := newTestRegistry(t)
+	svc := &service{targets: reg, stratDefault: SelectLeastInFlight, load: newLoadTracker()}
+	endA := svc.load.begin("a")
+	endB := svc.load.begin("b")
+	_ = svc.load.begin("b")
+	if k, _ := svc.chooseOne([]string{"a", "b", "c"}, nil); k != "c" {
+		t.Fatalf("got %s, want c", k)
+	}
+	if got := svc.chooseOrder([]string{"b", "a", "c"}, nil); !reflect.DeepEqual(got, []string{"c", "a", "b"}) {
+		t.Fatalf("order = %v", got)
+	}
+	endA(nil)
+	endB(nil)
+}
+
+func TestLowestLatency(t *testing.T) {
+	reg := newTestRegistry(t)
+	svc := &service{targets: reg, stratDefault: SelectLowestLatency, load: newLoadTracker()}
+	svc.load.observe("a", 50*time.Millisecond)
+	svc.load.observe("b", 10*time.Millisecond)
+	svc.load.observe("c", 20*time.Millisecond)
+	if k, _ := svc.chooseOne([]string{"a", "b", "c"}, nil); k != "b" {
+		t.Fatalf("got %s, want b", k)
+	}
+	// b slows down; the average follows.
+	for i := 0; i < 5; i++ {
+		svc.load.observe("b", 100*time.Millisecond)
+	}
+	if got := svc.chooseOrder([]string{"a", "b", "c"}, nil); !reflect.DeepEqual(got, []string{"c", "a", "b"}) {
+		t.Fatalf("order = %v", got)
+	}
+	// Unmeasured targets are tried first.
+	if k, _ := svc.chooseOne([]string{"a", "b", "c", "d"}, nil); k != "d" {
+		t.Fatalf("got %s, want d", k)
+	}
+}
+
+func TestLatencyRecordsSuccessfulCallsOnly(t *testing.T) {
+	reg := newTestRegistry(t)
+	svc := &service{targets: reg, stratDefault: SelectLowestLatency, load: newLoadTracker()}
+	svc.load.observe("a", 50*time.Millisecond)
+	svc.load.observe("b", 10*time.Millisecond)
+	before := testutil.CollectAndCount(metrics.TargetCallLatency)
+	// Failing calls return at once; they must not make a target look fast.
+	for i := 0; i < 5; i++ {
+		svc.load.begin("a")(errors.New("connection refused"))
+		svc.load.begin("failing-test")(errors.New("connection refused"))
+	}
+	if got := svc.chooseOrder([]string{"a", "b"}, nil); !reflect.DeepEqual(got, []string{"b", "a"}) {
+		t.Fatalf("order = %v", got)
+	}
+	if got := testutil.CollectAndCount(metrics.TargetCallLatency); got != before {
+		t.Fatalf("failed calls were timed: %d series, want %d", got, before)
+	}
+	svc.load.begin("latency-test")(nil)
+	if got := testutil.CollectAndCount(metrics.TargetCallLatency); got != before+1 {
+		t.Fatalf("successful call not timed: %d series, want %d", got, before+1)
+	}
+	svc.load.begin("c")(nil)
+	if k, _ := svc.chooseOne([]string{"a", "b", "c"}, nil); k != "c" {
+		t.Fatalf("got %s, want c", k)
+	}
+}
+
+func TestWeightedRoundRobinWithoutKeys(t *testing.T) {
+	l := newLoadTracker()
+	if got := l.byWeightedRoundRobin(nil, func(string) int { return 1 }); len(got) != 0 {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestFailoverUsesLatencyOrder(t *testing.T) {
+	reg := NewHotReloadRegistry(nil)
+	for _, k := range []string{"a", "b", "c"} {
+		_ = reg.Register(context.Background(), k, TargetConfig{DB: new(sql.DB), Schema: k, Labels: []string{"group=1"}}, nil)
+	}
+	svc := &service{
+		targets:      reg,
+		stratDefault: SelectLowestLatency,
+		failover:     FailoverPolicy{Enabled: true, MaxAttempts: 3},
+		classify:     func(error) (bool, bool) { return true, true },
+		health:       newHealthRegistry(FailoverPolicy{}),
+		load:         newLoadTracker(),
+	}
+	svc.load.observe("a", 30*time.Millisecond)
+	svc.load.observe("b", 10*time.Millisecond)
+	svc.load.observe("c", 20*time.Millisecond)
+	q, _ := ParseQuery("group=1")
+	var tries []string
+	err := svc.RunWithTarget(context.Background(), TargetDecision{Query: &q}, false, func(t TargetConn) error {
+		tries = append(tries, t.Schema)
+		if len(tries) < 3 {
+			return errors.New("fail")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RunWithTarget: %v", err)
+	}
+	if !reflect.DeepEqual(tries, []string{"b", "c", "a"}) {
+		t.Fatalf("tries = %v", tries)
+	}
+}
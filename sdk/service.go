@@ -3,6 +3,7 @@ package sdk
 import (
 	"context"
 	"errors"
+	"fmt"
 	"hash/fnv"
 	"sort"
 	"time"
@@ -53,8 +54,15 @@ type Service interface {
 	DeleteCustomField(ctx context.Context, table, column string) error
 	// ReconcileCustomFields compares metadata between target and MetaDB and optionally repairs discrepancies.
 	ReconcileCustomFields(ctx context.Context, dbID int64, table string, repair bool) (*ReconcileReport, error)
+	// ApplyAcross applies the registry YAML to every target matching q.
+	ApplyAcross(ctx context.Context, q Query, yaml []byte, opts AcrossOptions) (AcrossReport, error)
 	// StartTargetWatcher periodically fetches target configurations from a provider.
 	StartTargetWatcher(ctx context.Context, p TargetProvider, interval time.Duration) (stop func())
+	// StartHealthProber periodically probes the registered targets and feeds
+	// the results into the circuit breaker.
+	StartHealthProber(ctx context.Context, cfg HealthProbeConfig) (stop func())
+	// TargetHealth reports the circuit breaker state of the registered targets.
+	TargetHealth() []TargetHealth
 }
 
 // New returns a Service initialized with the given configuration.
@@ -109,11 +117,14 @@ func New(cfg ServiceConfig) Service {
 			Driver:       drv,
 			Schema:       sch,
 			Labels:       t.Labels,
+			Weight:       t.Weight,
 			DSN:          t.DSN,
+			ReplicaDSNs:  t.ReplicaDSNs,
 			MaxOpenConns: t.MaxOpenConns,
 			MaxIdleConns: t.MaxIdleConns,
 			ConnMaxIdle:  t.ConnMaxIdle,
 			ConnMaxLife:  t.ConnMaxLife,
+			Draining:     t.Draining,
 			DB:           t.DB,
 		}
 		if err := reg.Register(context.Background(), t.Key, tc, mk); err != nil {
@@ -126,6 +137,9 @@ func New(cfg ServiceConfig) Service {
 		classifier = DefaultErrorClassifier
 	}
 
+	load := newLoadTracker()
+	reg.busy = load.busy
+
 	rs := cfg.ReadSource
 	if rs == 0 {
 		rs = ReadFromTarget
@@ -137,6 +151,7 @@ func New(cfg ServiceConfig) Service {
 		pluginDir:    cfg.PluginDir,
 		recorder:     cfg.Recorder,
 		notifier:     cfg.Notifier,
+		events:       cfg.Events,
 		meta:         sqlmetastore.NewSQLMetaStore(metaDB, metaDriver, metaSchema),
 		targets:      reg,
 		resolveV1:    cfg.TargetResolver,
@@ -147,6 +162,8 @@ func New(cfg ServiceConfig) Service {
 		failover:     cfg.Failover,
 		classify:     classifier,
 		health:       newHealthRegistry(cfg.Failover),
+		load:         load,
+		replicas:     newReplicaRouter(cfg.Replicas),
 		readSource:   rs,
 	}
 }
@@ -156,6 +173,7 @@ type service struct {
 	pluginDir    string
 	recorder     *audit.Recorder
 	notifier     notifier.Broker
+	events       notifier.Emitter
 	meta         metapkg.MetaStore
 	targets      TargetRegistry
 	resolveV1    TargetResolver
@@ -166,11 +184,25 @@ type service struct {
 	failover     FailoverPolicy
 	classify     ErrorClassifier
 	health       *healthRegistry
+	load         *loadTracker
+	replicas     *replicaRouter
 	readSource   ReadSource
 }
 
 var ErrNoTarget = errors.New("no target database resolved")
 
+// ErrTargetDraining is returned when a call is pinned to a draining target.
+var ErrTargetDraining = errors.New("target is draining")
+
+// drainingError reports the pinned target key as draining. An empty key
+// stands for the default target.
+func drainingError(key string) error {
+	if key == "" {
+		return fmt.Errorf("%w: default target", ErrTargetDraining)
+	}
+	return fmt.Errorf("%w: %s", ErrTargetDraining, key)
+}
+
 func (s *service) resolveDecision(ctx context.Context) (TargetDecision, bool) {
 	if s.resolveV2 != nil {
 		if dec, ok := s.resolveV2(ctx); ok {
@@ -203,6 +235,9 @@ func (s *service) pickTarget(ctx context.Context) (TargetConn, error) {
 		if dec, ok := s.resolveV2(ctx); ok {
 			if dec.Key != "" {
 				if t, ok := s.targets.Get(dec.Key); ok {
+					if t.Draining {
+						return TargetConn{}, drainingError(dec.Key)
+					}
 					return t, nil
 				}
 			}
@@ -219,11 +254,17 @@ func (s *service) pickTarget(ctx context.Context) (TargetConn, error) {
 	if s.resolveV1 != nil {
 		if key, ok := s.resolveV1(ctx); ok {
 			if t, ok := s.targets.Get(key); ok {
+				if t.Draining {
+					return TargetConn{}, drainingError(key)
+				}
 				return t, nil
 			}
 		}
 	}
 	if t, ok := s.targets.Default(); ok {
+		if t.Draining {
+			return TargetConn{}, drainingError("")
+		}
 		return t, nil
 	}
 	return TargetConn{}, ErrNoTarget
@@ -291,15 +332,42 @@ func (s *service) chooseOrder(keys []string, hint *SelectionHint) []string {
 		hash := uint64(h.Sum32())
 		idx := int(hash % uint64(n)) // #nosec G115 -- bounded by key slice length
 		return append(keys[idx:], keys[:idx]...)
+	case SelectWeightedRoundRobin:
+		return s.load.byWeightedRoundRobin(keys, s.targetWeight)
+	case SelectLeastInFlight:
+		return s.load.byLeastInFlight(keys)
+	case SelectLowestLatency:
+		return s.load.byLatency(keys)
 	default:
 		return keys
 	}
 }
 
+// targetWeight returns the weighted round robin weight of key.
+func (s *service) targetWeight(key string) int {
+	if t, ok := s.targets.Get(key); ok {
+		return t.Weight
+	}
+	return 0
+}
+
 type ApplyOptions struct {
 	// DryRun skips applying changes and only computes the diff.
 	DryRun bool
 	Actor  string
+	// WidgetSchema returns the widget_config JSON Schema for a plugin widget
+	// ID. When set, Apply rejects fields whose widget_config does not
+	// validate against the schema of their widget.
+	WidgetSchema func(id string) map[string]any
+	// WidgetAvailable reports why a plugin widget ID cannot be referenced,
+	// for example because it is disabled or installed for another tenant.
+	// When set, Apply rejects fields referencing unavailable widgets.
+	WidgetAvailable func(id string) error
+	// Tenant scopes Apply to the stored field definitions of one tenant.
+	// The YAML is diffed against that tenant's registry instead of a
+	// physical scan, and audit entries are recorded for the tenant. Only
+	// SQL metadata stores support tenant-scoped apply.
+	Tenant string
 }
 
 type DiffReport struct {
@@ -25,8 +25,9 @@ func (m *stubMeta) DeleteTarget(context.Context, *sql.Tx, string) error
 func (m *stubMeta) ListTargets(context.Context) ([]meta.TargetRowWithLabels, string, string, error) {
 	return nil, "", "", nil
 }
-func (m *stubMeta) SetDefaultTarget(context.Context, *sql.Tx, string) error     { return nil }
-func (m *stubMeta) BumpTargetsVersion(context.Context, *sql.Tx) (string, error) { return "", nil }
+func (m *stubMeta) SetDefaultTarget(context.Context, *sql.Tx, string) error        { return nil }
+func (m *stubMeta) SetTargetDraining(context.Context, *sql.Tx, string, bool) error { return nil }
+func (m *stubMeta) BumpTargetsVersion(context.Context, *sql.Tx) (string, error)    { return "", nil }
 
 // TestListCustomFieldsMeta verifies ReadFromMeta returns definitions without hitting target DB.
 func TestListCustomFieldsMeta(t *testing.T) {
@@ -0,0 +1,144 @@
+package sdk
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+
+	metapkg "github.com/faciam-dev/gcfm/meta"
+)
+
+// DefaultTargetsRedisChannel is the Redis channel of RedisTargetSignal when
+// none is set.
+const DefaultTargetsRedisChannel = "gcfm:targets"
+
+// TargetSignal announces changes of the targets stored in the meta database.
+type TargetSignal interface {
+	// Subscribe returns a channel that receives a value after each change.
+	// The channel is closed when ctx is done.
+	Subscribe(ctx context.Context) (<-chan struct{}, error)
+}
+
+// PostgresTargetSignal listens for the notification BumpTargetsVersion sends
+// on PostgreSQL meta databases.
+type PostgresTargetSignal struct {
+	// DSN of the meta database.
+	DSN string
+	// TablePrefix of the meta tables; defaults to gcfm_.
+	TablePrefix string
+}
+
+// Subscribe implements TargetSignal. Reconnects are signalled too, as
+// notifications may have been missed meanwhile.
+func (s PostgresTargetSignal) Subscribe(ctx context.Context) (<-chan struct{}, error) {
+	l := pq.NewListener(s.DSN, time.Second, time.Minute, nil)
+	// Listen waits for the listener to connect; closing it gives up.
+	errc := make(chan error, 1)
+	go func() { errc <- l.Listen(metapkg.TargetsChannel(s.TablePrefix)) }()
+	select {
+	case err := <-errc:
+		if err != nil {
+			_ = l.Close()
+			return nil, err
+		}
+	case <-ctx.Done():
+		_ = l.Close()
+		<-errc
+		return nil, ctx.Err()
+	}
+	out := make(chan struct{}, 1)
+	go func() {
+		defer close(out)
+		defer l.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-l.Notify:
+				notify(out)
+			}
+		}
+	}()
+	return out, nil
+}
+
+// RedisTargetSignal carries target changes over Redis Pub/Sub, for meta
+// databases without LISTEN/NOTIFY. Whoever changes the targets calls Publish,
+// and Relay publishes the changes of writers that do not.
+type RedisTargetSignal struct {
+	Client  *redis.Client
+	Channel string
+}
+
+func (s RedisTargetSignal) channel() string {
+	if s.Channel == "" {
+		return DefaultTargetsRedisChannel
+	}
+	return s.Channel
+}
+
+// Publish announces the targets version ver.
+func (s RedisTargetSignal) Publish(ctx context.Context, ver string) error {
+	return s.Client.Publish(ctx, s.channel(), ver).Err()
+}
+
+// Relay publishes the targets version of meta each time it changes, checking
+// every interval until ctx is done. It covers writers calling
+// BumpTargetsVersion without Publish, such as snapshot bundle restores.
+func (s RedisTargetSignal) Relay(ctx context.Context, meta metapkg.MetaStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var last string
+	started := false
+	for {
+		// The first version read is the starting point, not a change. A
+		// failed publish is retried on the next tick.
+		if _, ver, _, err := meta.ListTargets(ctx); err == nil && (!started || ver != last) {
+			if !started || s.Publish(ctx, ver) == nil {
+				last, started = ver, true
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Subscribe implements TargetSignal.
+func (s RedisTargetSignal) Subscribe(ctx context.Context) (<-chan struct{}, error) {
+	ps := s.Client.Subscribe(ctx, s.channel())
+	if _, err := ps.Receive(ctx); err != nil {
+		_ = ps.Close()
+		return nil, err
+	}
+	msgs := ps.Channel()
+	out := make(chan struct{}, 1)
+	go func() {
+		defer close(out)
+		defer ps.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-msgs:
+				if !ok {
+					return
+				}
+				notify(out)
+			}
+		}
+	}()
+	return out, nil
+}
+
+// notify sends on ch unless a signal is already pending.
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
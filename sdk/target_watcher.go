@@ -10,6 +10,17 @@ type TargetProvider interface {
 	Fetch(ctx context.Context) (cfgs map[string]TargetConfig, defaultKey string, version string, err error)
 }
 
+// WatchingTargetProvider is a TargetProvider that pushes change signals, so
+// that watchers fetch updates as soon as they happen instead of on the next
+// poll.
+type WatchingTargetProvider interface {
+	TargetProvider
+	// Watch returns a channel that receives a value whenever the targets may
+	// have changed. The channel is closed when ctx is done. A nil channel
+	// means that the provider cannot push changes and is only polled.
+	Watch(ctx context.Context) (<-chan struct{}, error)
+}
+
 // TargetWatcher periodically applies configuration updates from a provider.
 type TargetWatcher struct {
 	svc      *service
@@ -19,12 +30,11 @@ type TargetWatcher struct {
 	cancel   context.CancelFunc
 }
 
-// StartTargetWatcher launches a goroutine that periodically fetches target updates.
+// StartTargetWatcher launches a goroutine that periodically fetches target
+// updates. Providers implementing WatchingTargetProvider are also fetched on
+// every change signal; polling continues as a fallback.
 func (s *service) StartTargetWatcher(ctx context.Context, p TargetProvider, interval time.Duration) (stop func()) {
 	cctx, cancel := context.WithCancel(ctx)
-	if s.health == nil {
-		s.health = newHealthRegistry(s.failover)
-	}
 	w := &TargetWatcher{svc: s, provider: p, interval: interval, cancel: cancel}
 	go w.loop(cctx)
 	return cancel
@@ -33,31 +43,51 @@ func (s *service) StartTargetWatcher(ctx context.Context, p TargetProvider, inte
 func (w *TargetWatcher) loop(ctx context.Context) {
 	ticker := time.NewTicker(w.interval)
 	defer ticker.Stop()
+	var changes <-chan struct{}
+	if wp, ok := w.provider.(WatchingTargetProvider); ok {
+		ch, err := wp.Watch(ctx)
+		if err != nil {
+			w.svc.logger.Warnf("target watch error, polling only: %v", err)
+		}
+		changes = ch
+	}
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			cfgs, def, ver, err := w.provider.Fetch(ctx)
-			if err != nil {
-				w.svc.logger.Warnf("target fetch error: %v", err)
-				continue
-			}
-			if ver != "" && ver == w.lastVer {
-				continue
-			}
-			if err := w.svc.targets.ReplaceAll(ctx, cfgs, w.svc.connector(), def); err != nil {
-				w.svc.logger.Warnf("target replace error: %v", err)
+		case _, ok := <-changes:
+			if !ok {
+				changes = nil
 				continue
 			}
-			keys := make([]string, 0, len(cfgs))
-			for k := range cfgs {
-				keys = append(keys, k)
-			}
-			w.svc.health.prune(keys)
-			w.lastVer = ver
 		}
+		w.sync(ctx)
+	}
+}
+
+// sync fetches the provider's targets and applies them when their version
+// changed.
+func (w *TargetWatcher) sync(ctx context.Context) {
+	cfgs, def, ver, err := w.provider.Fetch(ctx)
+	if err != nil {
+		w.svc.logger.Warnf("target fetch error: %v", err)
+		return
+	}
+	if ver != "" && ver == w.lastVer {
+		return
+	}
+	if err := w.svc.targets.ReplaceAll(ctx, cfgs, w.svc.connector(), def); err != nil {
+		w.svc.logger.Warnf("target replace error: %v", err)
+		return
+	}
+	keys := make([]string, 0, len(cfgs))
+	for k := range cfgs {
+		keys = append(keys, k)
 	}
+	w.svc.health.prune(keys)
+	w.svc.load.prune(keys)
+	w.lastVer = ver
 }
 
 func (s *service) connector() Connector {
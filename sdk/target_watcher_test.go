@@ -3,10 +3,16 @@ package sdk
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 
 	metapkg "github.com/faciam-dev/gcfm/meta"
@@ -74,7 +80,7 @@ func TestMetaDBProviderWatcher(t *testing.T) {
 	}
 
 	reg := newCountingRegistry()
-	svc := &service{targets: reg, logger: zap.NewNop().Sugar(), cn: connector}
+	svc := &service{targets: reg, health: newHealthRegistry(FailoverPolicy{}), logger: zap.NewNop().Sugar(), cn: connector}
 	stop := svc.StartTargetWatcher(ctx, NewMetaDBProvider(store), 10*time.Millisecond)
 	defer stop()
 
@@ -137,3 +143,128 @@ func TestMetaDBProviderWatcher(t *testing.T) {
 		t.Fatalf("dsns not recorded: %v", got)
 	}
 }
+
+// waitReplaceWhile runs poke until r saw want ReplaceAll calls, since push
+// watchers may subscribe after the test made its change.
+func waitReplaceWhile(r *countingRegistry, want int, poke func()) bool {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		poke()
+		if waitReplace(r, want, 50*time.Millisecond) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFileProviderPushesChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.json")
+	write := func(ver string) {
+		body := fmt.Sprintf(`{"version":%q,"targets":[{"Key":"tenant:a","Driver":"sqlite3","DSN":":memory:"}]}`, ver)
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("v0")
+
+	reg := newCountingRegistry()
+	svc := &service{targets: reg, health: newHealthRegistry(FailoverPolicy{}), logger: zap.NewNop().Sugar(), cn: func(ctx context.Context, driver, dsn string) (*sql.DB, error) {
+		return sql.Open("sqlite3", dsn)
+	}}
+	// The poll interval is too long to matter: only fsnotify triggers fetches.
+	stop := svc.StartTargetWatcher(context.Background(), NewFileProvider(path), time.Hour)
+	defer stop()
+
+	n := 0
+	if !waitReplaceWhile(reg, 1, func() { n++; write(fmt.Sprintf("v%d", n)) }) {
+		t.Fatalf("file change not pushed")
+	}
+	if _, ok := reg.Get("tenant:a"); !ok {
+		t.Fatalf("target not registered")
+	}
+}
+
+func TestMetaDBProviderRedisSignal(t *testing.T) {
+	ctx := context.Background()
+	store := newTargetStore(t)
+	if err := store.UpsertTarget(ctx, nil, metapkg.TargetRow{Key: "tenant:A", Driver: "sqlite3", DSN: ":memory:"}, nil); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	ver, err := store.BumpTargetsVersion(ctx, nil)
+	if err != nil {
+		t.Fatalf("bump: %v", err)
+	}
+
+	mr := miniredis.RunT(t)
+	sig := RedisTargetSignal{Client: redis.NewClient(&redis.Options{Addr: mr.Addr()})}
+	reg := newCountingRegistry()
+	svc := &service{targets: reg, health: newHealthRegistry(FailoverPolicy{}), logger: zap.NewNop().Sugar(), cn: func(ctx context.Context, driver, dsn string) (*sql.DB, error) {
+		return sql.Open("sqlite3", dsn)
+	}}
+	stop := svc.StartTargetWatcher(ctx, NewWatchingMetaDBProvider(store, sig), time.Hour)
+	defer stop()
+
+	if !waitReplaceWhile(reg, 1, func() { _ = sig.Publish(ctx, ver) }) {
+		t.Fatalf("signal not observed")
+	}
+	// Repeated signals of the same version do not reload the targets.
+	_ = sig.Publish(ctx, ver)
+	time.Sleep(50 * time.Millisecond)
+	if c := reg.calls(); c != 1 {
+		t.Fatalf("replace calls = %d", c)
+	}
+}
+
+func TestRedisTargetSignalRelay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := newTargetStore(t)
+	if _, err := store.BumpTargetsVersion(ctx, nil); err != nil {
+		t.Fatalf("bump: %v", err)
+	}
+	mr := miniredis.RunT(t)
+	cli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer cli.Close()
+	sig := RedisTargetSignal{Client: cli}
+	ch, err := sig.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	go sig.Relay(ctx, store, 10*time.Millisecond)
+
+	// The version current at start is not published.
+	select {
+	case <-ch:
+		t.Fatal("unchanged version published")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// A bump without Publish, as a bundle restore does, is relayed.
+	if _, err := store.BumpTargetsVersion(ctx, nil); err != nil {
+		t.Fatalf("bump: %v", err)
+	}
+	select {
+	case <-ch:
+	case <-time.After(2 * time.Second):
+		t.Fatal("change not relayed")
+	}
+}
+
+func TestPostgresTargetSignalHonoursContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	// Nothing listens on port 1, so the listener never connects.
+	sig := PostgresTargetSignal{DSN: "postgres://user@127.0.0.1:1/db?sslmode=disable&connect_timeout=1"}
+	done := make(chan error, 1)
+	go func() {
+		_, err := sig.Subscribe(ctx)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("err = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Subscribe ignored the context")
+	}
+}
@@ -40,6 +40,17 @@ type TargetConn struct {
 	Schema  string
 	Dialect ormdriver.Dialect
 	Labels  map[string]struct{}
+	// DSN is the connection string the target was opened with. It is empty
+	// for pre-established connections.
+	DSN string
+	// Weight is the weighted round robin weight of the target.
+	Weight int
+	// Replicas serve the reads routed away from the primary.
+	Replicas []Replica
+	// Draining targets are left out of label lookups and queries. Their DB
+	// and Replicas are nil; the pool they had is closed once no call uses
+	// it.
+	Draining bool
 }
 
 type snapshot struct {
@@ -49,11 +60,17 @@ type snapshot struct {
 	labelIndex map[string]map[string]struct{}
 }
 
+// drainPollInterval is how often the pool of a draining target is checked
+// for idleness.
+var drainPollInterval = time.Second
+
 // HotReloadRegistry is an RCU-style implementation of TargetRegistry.
 type HotReloadRegistry struct {
 	mu     sync.RWMutex
 	snap   atomic.Value            // *snapshot
 	closer map[string]func() error // key -> close func
+	// busy reports calls in flight on a target; set by the service.
+	busy func(key string) bool
 }
 
 // NewHotReloadRegistry creates a registry initialized with the default connection.
@@ -88,16 +105,68 @@ func (r *HotReloadRegistry) buildConn(ctx context.Context, cfg TargetConfig, mk
 		}
 		tune(db, cfg)
 	}
-	c := TargetConn{DB: db, Driver: cfg.Driver, Schema: cfg.Schema, Dialect: util.DialectFromDriver(cfg.Driver), Labels: toSet(cfg.Labels)}
+	c := TargetConn{DB: db, Driver: cfg.Driver, Schema: cfg.Schema, Dialect: util.DialectFromDriver(cfg.Driver), Labels: toSet(cfg.Labels), DSN: cfg.DSN, Weight: cfg.Weight}
+	closeReplicas := func() error {
+		var errs []error
+		for _, rep := range c.Replicas {
+			errs = append(errs, rep.DB.Close())
+		}
+		return errors.Join(errs...)
+	}
+	for _, dsn := range cfg.ReplicaDSNs {
+		if mk == nil {
+			mk = defaultConnector
+		}
+		rdb, err := mk(ctx, cfg.Driver, dsn)
+		if err != nil {
+			_ = closeReplicas()
+			if cfg.DB == nil {
+				_ = db.Close()
+			}
+			return TargetConn{}, nil, err
+		}
+		tune(rdb, cfg)
+		c.Replicas = append(c.Replicas, Replica{DB: rdb, DSN: dsn})
+	}
 	closer := func() error {
+		err := closeReplicas()
 		if cfg.DB != nil {
-			return nil
+			return err
 		}
-		return db.Close()
+		return errors.Join(err, db.Close())
 	}
 	return c, closer, nil
 }
 
+// drainingConn returns the connection of a draining target. It has no pool:
+// calls in flight keep the one they were given until closeWhenIdle closes it.
+func drainingConn(cfg TargetConfig) TargetConn {
+	return TargetConn{Driver: cfg.Driver, Schema: cfg.Schema, Dialect: util.DialectFromDriver(cfg.Driver), Labels: toSet(cfg.Labels), DSN: cfg.DSN, Weight: cfg.Weight, Draining: true}
+}
+
+// closeWhenIdle runs closer once the pool db of the draining target key has
+// no connection in use and no call in flight. The first check waits a poll
+// interval, so that a call that selected the target just before it drained
+// has started.
+func (r *HotReloadRegistry) closeWhenIdle(key string, db *sql.DB, closer func() error) {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if (db == nil || db.Stats().InUse == 0) && (r.busy == nil || !r.busy(key)) {
+			_ = closer()
+			return
+		}
+	}
+}
+
+// indexed returns the labels of c that label lookups see.
+func indexed(c TargetConn) map[string]struct{} {
+	if c.Draining {
+		return nil
+	}
+	return c.Labels
+}
+
 // Register adds a new target.
 func (r *HotReloadRegistry) Register(ctx context.Context, key string, cfg TargetConfig, mk Connector) (err error) {
 	start := time.Now()
@@ -110,16 +179,20 @@ func (r *HotReloadRegistry) Register(ctx context.Context, key string, cfg Target
 	}()
 
 	var conn TargetConn
-	var closer func() error
-	conn, closer, err = r.buildConn(ctx, cfg, mk)
-	if err != nil {
-		return err
-	}
-	if cfg.DB == nil {
-		if err = conn.DB.PingContext(ctx); err != nil {
-			_ = closer()
+	closer := func() error { return nil }
+	if cfg.Draining {
+		conn = drainingConn(cfg)
+	} else {
+		conn, closer, err = r.buildConn(ctx, cfg, mk)
+		if err != nil {
 			return err
 		}
+		if cfg.DB == nil {
+			if err = conn.DB.PingContext(ctx); err != nil {
+				_ = closer()
+				return err
+			}
+		}
 	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -130,7 +203,7 @@ func (r *HotReloadRegistry) Register(ctx context.Context, key string, cfg Target
 	ns := cloneSnap(old)
 	ns.byKey[key] = conn
 	ns.keys = upsertKey(ns.keys, key)
-	addToIndex(ns.labelIndex, key, conn.Labels)
+	addToIndex(ns.labelIndex, key, indexed(conn))
 	r.closer[key] = closer
 	r.snap.Store(ns)
 	r.updateMetrics(ns)
@@ -157,7 +230,7 @@ func (r *HotReloadRegistry) Unregister(key string) (err error) {
 	ns := cloneSnap(old)
 	delete(ns.byKey, key)
 	ns.keys = removeKey(ns.keys, key)
-	removeFromIndex(ns.labelIndex, key, old.byKey[key].Labels)
+	removeFromIndex(ns.labelIndex, key, indexed(old.byKey[key]))
 	if ns.defaultKey == key {
 		ns.defaultKey = ""
 	}
@@ -170,7 +243,8 @@ func (r *HotReloadRegistry) Unregister(key string) (err error) {
 	return nil
 }
 
-// Update replaces an existing target's connection.
+// Update replaces an existing target's connection. Updating a target to
+// draining keeps its pool until no call uses it.
 func (r *HotReloadRegistry) Update(ctx context.Context, key string, cfg TargetConfig, mk Connector) (err error) {
 	start := time.Now()
 	defer func() {
@@ -181,6 +255,9 @@ func (r *HotReloadRegistry) Update(ctx context.Context, key string, cfg TargetCo
 		metrics.TargetOpLatency.WithLabelValues("update", status).Observe(time.Since(start).Seconds())
 	}()
 
+	if cfg.Draining {
+		return r.drain(key, cfg)
+	}
 	var conn TargetConn
 	var closer func() error
 	conn, closer, err = r.buildConn(ctx, cfg, mk)
@@ -202,7 +279,7 @@ func (r *HotReloadRegistry) Update(ctx context.Context, key string, cfg TargetCo
 	ns := cloneSnap(old)
 	ns.byKey[key] = conn
 	ns.keys = upsertKey(ns.keys, key)
-	updateIndex(ns.labelIndex, key, old.byKey[key].Labels, conn.Labels)
+	updateIndex(ns.labelIndex, key, indexed(old.byKey[key]), conn.Labels)
 	oldCloser := r.closer[key]
 	r.closer[key] = closer
 	r.snap.Store(ns)
@@ -213,7 +290,30 @@ func (r *HotReloadRegistry) Update(ctx context.Context, key string, cfg TargetCo
 	return nil
 }
 
-// ReplaceAll swaps the registry contents atomically.
+func (r *HotReloadRegistry) drain(key string, cfg TargetConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old := r.snap.Load().(*snapshot)
+	cur, ok := old.byKey[key]
+	if !ok {
+		return errors.New("unknown key")
+	}
+	conn := drainingConn(cfg)
+	ns := cloneSnap(old)
+	ns.byKey[key] = conn
+	updateIndex(ns.labelIndex, key, indexed(cur), nil)
+	oldCloser, had := r.closer[key]
+	delete(r.closer, key)
+	r.snap.Store(ns)
+	r.updateMetrics(ns)
+	if had && !cur.Draining {
+		go r.closeWhenIdle(key, cur.DB, oldCloser)
+	}
+	return nil
+}
+
+// ReplaceAll swaps the registry contents atomically. Targets that become
+// draining keep their current pool until no call uses it.
 func (r *HotReloadRegistry) ReplaceAll(ctx context.Context, cfgs map[string]TargetConfig, mk Connector, defaultKey string) (err error) {
 	start := time.Now()
 	defer func() {
@@ -224,9 +324,14 @@ func (r *HotReloadRegistry) ReplaceAll(ctx context.Context, cfgs map[string]Targ
 		metrics.TargetOpLatency.WithLabelValues("replace_all", status).Observe(time.Since(start).Seconds())
 	}()
 
+	old := r.snap.Load().(*snapshot)
 	nextByKey := make(map[string]TargetConn, len(cfgs))
 	nextCloser := make(map[string]func() error, len(cfgs))
 	for k, c := range cfgs {
+		if c.Draining {
+			nextByKey[k] = drainingConn(c)
+			continue
+		}
 		var conn TargetConn
 		var closer func() error
 		conn, closer, err = r.buildConn(ctx, c, mk)
@@ -242,7 +347,7 @@ func (r *HotReloadRegistry) ReplaceAll(ctx context.Context, cfgs map[string]Targ
 	var wg sync.WaitGroup
 	errCh := make(chan error, len(nextByKey))
 	for k, c := range nextByKey {
-		if cfgs[k].DB != nil {
+		if cfgs[k].DB != nil || c.Draining {
 			continue
 		}
 		wg.Add(1)
@@ -276,7 +381,11 @@ func (r *HotReloadRegistry) ReplaceAll(ctx context.Context, cfgs map[string]Targ
 	r.closer = nextCloser
 	r.mu.Unlock()
 
-	for _, cl := range oldClosers {
+	for k, cl := range oldClosers {
+		if cfgs[k].Draining {
+			go r.closeWhenIdle(k, old.byKey[k].DB, cl)
+			continue
+		}
 		_ = cl()
 	}
 	return nil
@@ -298,7 +407,8 @@ func (r *HotReloadRegistry) SetDefault(key string) {
 	r.mu.Unlock()
 }
 
-// Snapshot returns a copy of the current targets.
+// Snapshot returns a copy of the current targets. Draining targets are
+// included, without a pool.
 func (r *HotReloadRegistry) Snapshot() map[string]TargetConn {
 	s := r.snap.Load().(*snapshot)
 	out := make(map[string]TargetConn, len(s.byKey))
@@ -554,7 +664,7 @@ func keysOf(m map[string]TargetConn) ([]string, map[string]map[string]struct{})
 	idx := make(map[string]map[string]struct{})
 	for k, v := range m {
 		keys = append(keys, k)
-		addToIndex(idx, k, v.Labels)
+		addToIndex(idx, k, indexed(v))
 	}
 	return keys, idx
 }
@@ -690,10 +800,7 @@ func (s *snapshot) evalAnd(exprs []LabelExpr) map[string]struct{} {
 		return nil
 	}
 	if len(positives) == 0 {
-		res = make(map[string]struct{}, len(s.keys))
-		for _, k := range s.keys {
-			res[k] = struct{}{}
-		}
+		res = s.all()
 	}
 	if len(negatives) > 0 {
 		res = diffSet(res, unionMany(negatives...))
@@ -702,14 +809,89 @@ func (s *snapshot) evalAnd(exprs []LabelExpr) map[string]struct{} {
 }
 
 func (s *snapshot) filter(q Query) map[string]struct{} {
+	var res map[string]struct{}
 	if len(q.OR) > 0 {
 		groups := make([]map[string]struct{}, 0, len(q.OR))
 		for _, grp := range q.OR {
 			groups = append(groups, s.evalAnd(grp))
 		}
-		return unionMany(groups...)
+		res = unionMany(groups...)
+	} else {
+		res = s.evalAnd(q.AND)
+	}
+	if q.Expr == nil || res == nil {
+		return res
+	}
+	return intersectMany(res, s.eval(q.Expr))
+}
+
+// all returns the set of every target key except draining ones.
+func (s *snapshot) all() map[string]struct{} {
+	res := make(map[string]struct{}, len(s.keys))
+	for _, k := range s.keys {
+		if !s.byKey[k].Draining {
+			res[k] = struct{}{}
+		}
+	}
+	return res
+}
+
+// eval returns the keys of targets matching e. Returned sets may alias the
+// label index and must not be modified.
+func (s *snapshot) eval(e LabelExpr) map[string]struct{} {
+	switch ex := e.(type) {
+	case EqExpr:
+		return s.labelIndex[ex.Label+"="+ex.Value]
+	case HasExpr:
+		return s.labelIndex[ex.Label]
+	case NotExpr:
+		return diffSet(s.all(), s.labelIndex[ex.Label])
+	case InExpr:
+		inner := make([]map[string]struct{}, len(ex.Values))
+		for i, v := range ex.Values {
+			inner[i] = s.labelIndex[ex.Label+"="+v]
+		}
+		return unionMany(inner...)
+	case MatchExpr:
+		prefix := ex.Label + "="
+		var inner []map[string]struct{}
+		for l, keys := range s.labelIndex {
+			if v, ok := strings.CutPrefix(l, prefix); ok && ex.Pattern.MatchString(v) {
+				inner = append(inner, keys)
+			}
+		}
+		return unionMany(inner...)
+	case NegExpr:
+		return diffSet(s.all(), s.eval(ex.Expr))
+	case AndExpr:
+		if len(ex.Exprs) == 0 {
+			return s.all()
+		}
+		sets := make([]map[string]struct{}, len(ex.Exprs))
+		for i, x := range ex.Exprs {
+			sets[i] = s.eval(x)
+		}
+		return intersectMany(sets...)
+	case OrExpr:
+		sets := make([]map[string]struct{}, len(ex.Exprs))
+		for i, x := range ex.Exprs {
+			sets[i] = s.eval(x)
+		}
+		return unionMany(sets...)
 	}
-	return s.evalAnd(q.AND)
+	res := make(map[string]struct{})
+	for k, c := range s.byKey {
+		if c.Draining {
+			continue
+		}
+		if e.Eval(c.Labels) {
+			res[k] = struct{}{}
+		}
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
 }
 
 // defaultConnector is the built-in connector using database/sql.
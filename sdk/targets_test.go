@@ -168,3 +168,105 @@ func TestReplaceAllKeepsOldConnAlive(t *testing.T) {
 		t.Fatalf("exec on old connection failed: %v", err)
 	}
 }
+
+func TestDrainingTargetIsNotSelected(t *testing.T) {
+	ctx := context.Background()
+	reg := NewHotReloadRegistry(nil)
+	_ = reg.Register(ctx, "a", TargetConfig{DB: &sql.DB{}, Labels: []string{"region=eu"}}, nil)
+	_ = reg.Register(ctx, "b", TargetConfig{DB: &sql.DB{}, Labels: []string{"region=eu"}, Draining: true}, nil)
+	_ = reg.Register(ctx, "c", TargetConfig{DB: &sql.DB{}, Labels: []string{"region=us"}}, nil)
+
+	if got := reg.FindByLabel("region=eu"); len(got) != 1 || got[0] != "a" {
+		t.Fatalf("FindByLabel = %v", got)
+	}
+	for _, expr := range []string{"region=eu", "region!=us", `region=~"^e"`, "!tier"} {
+		q, err := ParseQuery(expr)
+		if err != nil {
+			t.Fatalf("parse %q: %v", expr, err)
+		}
+		for _, k := range reg.FindByQuery(q) {
+			if k == "b" {
+				t.Fatalf("%q selected draining target", expr)
+			}
+		}
+	}
+	if _, ok := reg.Get("b"); !ok {
+		t.Fatalf("draining target should stay registered")
+	}
+
+	if err := reg.Update(ctx, "b", TargetConfig{DB: &sql.DB{}, Labels: []string{"region=eu"}}, nil); err != nil {
+		t.Fatalf("undrain: %v", err)
+	}
+	if got := reg.FindByLabel("region=eu"); len(got) != 2 {
+		t.Fatalf("FindByLabel after undrain = %v", got)
+	}
+}
+
+func TestReplaceAllDrainClosesPoolOnceIdle(t *testing.T) {
+	old := drainPollInterval
+	drainPollInterval = 5 * time.Millisecond
+	defer func() { drainPollInterval = old }()
+
+	ctx := context.Background()
+	reg := NewHotReloadRegistry(nil)
+	if err := reg.Register(ctx, "a", TargetConfig{Driver: "sqlite3", DSN: ":memory:"}, nil); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	cur, _ := reg.Get("a")
+	tx, err := cur.DB.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+
+	cfgs := map[string]TargetConfig{"a": {Driver: "sqlite3", DSN: ":memory:", Draining: true}}
+	if err := reg.ReplaceAll(ctx, cfgs, nil, ""); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	drained, _ := reg.Get("a")
+	if !drained.Draining || drained.DB != nil {
+		t.Fatalf("draining target should not expose its pool: %+v", drained)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := tx.Exec("SELECT 1"); err != nil {
+		t.Fatalf("in-flight transaction failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for cur.DB.Ping() == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("pool not closed once idle")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDrainWaitsBeforeClosingIdlePool(t *testing.T) {
+	old := drainPollInterval
+	drainPollInterval = 50 * time.Millisecond
+	defer func() { drainPollInterval = old }()
+
+	ctx := context.Background()
+	reg := NewHotReloadRegistry(nil)
+	if err := reg.Register(ctx, "a", TargetConfig{Driver: "sqlite3", DSN: ":memory:"}, nil); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	// Selected before the drain, not yet running.
+	cur, _ := reg.Get("a")
+	if err := reg.Update(ctx, "a", TargetConfig{Driver: "sqlite3", DSN: ":memory:", Draining: true}, nil); err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := cur.DB.Ping(); err != nil {
+		t.Fatalf("pool closed before a poll interval: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for cur.DB.Ping() == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("pool not closed once idle")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,169 @@
+package adminapi_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/faciam-dev/gcfm/internal/adminapi/targets"
+	metapkg "github.com/faciam-dev/gcfm/meta"
+)
+
+// memStore keeps targets in memory. Transactions come from a sqlmock
+// database, so every write expects a begin and a commit.
+type memStore struct {
+	metapkg.MetaStore
+	db   *sql.DB
+	rows []metapkg.TargetRowWithLabels
+	ver  int
+	// remove drops the target with this key when its draining state is
+	// set, as a concurrent delete would.
+	remove string
+	// notified lists the versions passed to Deps.Notify.
+	notified []string
+}
+
+func (m *memStore) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return m.db.BeginTx(ctx, opts)
+}
+
+func (m *memStore) ListTargets(context.Context) ([]metapkg.TargetRowWithLabels, string, string, error) {
+	return append([]metapkg.TargetRowWithLabels(nil), m.rows...), m.version(), "", nil
+}
+
+func (m *memStore) SetTargetDraining(_ context.Context, _ *sql.Tx, key string, draining bool) error {
+	if key == m.remove {
+		m.rows = nil
+	}
+	for i := range m.rows {
+		if m.rows[i].Key == key {
+			m.rows[i].Draining = draining
+			return nil
+		}
+	}
+	return metapkg.ErrTargetNotFound
+}
+
+func (m *memStore) BumpTargetsVersion(context.Context, *sql.Tx) (string, error) {
+	m.ver++
+	return m.version(), nil
+}
+
+func (m *memStore) version() string { return string(rune('0' + m.ver)) }
+
+func newTargetsAPI(t *testing.T, rows ...metapkg.TargetRowWithLabels) (http.Handler, *memStore, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	store := &memStore{db: db, rows: rows}
+	r := chi.NewRouter()
+	api := humachi.New(r, huma.DefaultConfig("test", "1.0"))
+	targets.RegisterRoutes(api, targets.Deps{Meta: store, Notify: func(_ context.Context, ver string) {
+		store.notified = append(store.notified, ver)
+	}})
+	return r, store, mock
+}
+
+func serve(h http.Handler, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestListRejectsLabelWithSelector(t *testing.T) {
+	h, _, _ := newTargetsAPI(t,
+		metapkg.TargetRowWithLabels{TargetRow: metapkg.TargetRow{Key: "a", Driver: "mysql"}, Labels: []string{"region=eu"}},
+		metapkg.TargetRowWithLabels{TargetRow: metapkg.TargetRow{Key: "b", Driver: "mysql"}, Labels: []string{"region=us"}},
+	)
+	w := serve(h, http.MethodGet, "/admin/targets/?label=region=eu&selector=region=eu")
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "selector") {
+		t.Fatalf("status %d body %s", w.Code, w.Body.String())
+	}
+
+	for _, q := range []string{"label=region=eu", "selector=region=~%22%5Eeu%22"} {
+		w := serve(h, http.MethodGet, "/admin/targets/?"+q)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d body %s", q, w.Code, w.Body.String())
+		}
+		var out struct {
+			Items []struct {
+				Key string `json:"key"`
+			} `json:"items"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if len(out.Items) != 1 || out.Items[0].Key != "a" {
+			t.Fatalf("%s: items = %+v", q, out.Items)
+		}
+	}
+}
+
+func TestDrainAndUndrain(t *testing.T) {
+	h, store, mock := newTargetsAPI(t, metapkg.TargetRowWithLabels{TargetRow: metapkg.TargetRow{Key: "a", Driver: "mysql"}})
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	w := serve(h, http.MethodPost, "/admin/targets/a/drain")
+	if w.Code != http.StatusNoContent || w.Header().Get("ETag") != "1" {
+		t.Fatalf("drain: status %d etag %q body %s", w.Code, w.Header().Get("ETag"), w.Body.String())
+	}
+	if !store.rows[0].Draining || len(store.notified) != 1 {
+		t.Fatalf("after drain: %+v, notified %v", store.rows[0], store.notified)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	w = serve(h, http.MethodPost, "/admin/targets/a/undrain")
+	if w.Code != http.StatusNoContent || w.Header().Get("ETag") != "2" {
+		t.Fatalf("undrain: status %d etag %q body %s", w.Code, w.Header().Get("ETag"), w.Body.String())
+	}
+	if store.rows[0].Draining || len(store.notified) != 2 {
+		t.Fatalf("after undrain: %+v, notified %v", store.rows[0], store.notified)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestDrainErrors(t *testing.T) {
+	h, store, mock := newTargetsAPI(t, metapkg.TargetRowWithLabels{TargetRow: metapkg.TargetRow{Key: "a", Driver: "mysql"}})
+
+	if w := serve(h, http.MethodPost, "/admin/targets/missing/drain"); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown target: status %d", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/targets/a/drain", nil)
+	req.Header.Set("If-Match", "stale")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("stale If-Match: status %d", w.Code)
+	}
+
+	// The target is deleted between the lookup and the update.
+	store.remove = "a"
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	if w := serve(h, http.MethodPost, "/admin/targets/a/undrain"); w.Code != http.StatusNotFound {
+		t.Fatalf("deleted target: status %d body %s", w.Code, w.Body.String())
+	}
+	if len(store.notified) != 0 {
+		t.Fatalf("failed changes notified: %v", store.notified)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
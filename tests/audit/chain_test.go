@@ -0,0 +1,210 @@
+package audit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/faciam-dev/gcfm/pkg/audit"
+	"github.com/faciam-dev/gcfm/pkg/registry"
+	"github.com/faciam-dev/gcfm/pkg/tenant"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+)
+
+func TestChainHashIgnoresJSONFormatting(t *testing.T) {
+	a := audit.ChainEntry{TenantID: "t1", Action: "update", BeforeJSON: `{"b":1,"a":"x"}`}
+	b := audit.ChainEntry{TenantID: "t1", Action: "update", BeforeJSON: `{"a": "x", "b": 1}`}
+	if audit.ChainHash("", a) != audit.ChainHash("", b) {
+		t.Fatalf("hash depends on JSON formatting")
+	}
+	if audit.ChainHash("", a) == audit.ChainHash("prev", a) {
+		t.Fatalf("hash does not depend on previous hash")
+	}
+	b.Actor = "mallory"
+	if audit.ChainHash("", a) == audit.ChainHash("", b) {
+		t.Fatalf("hash does not cover actor")
+	}
+}
+
+func TestRecorderWriteHashChain(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	rec := &audit.Recorder{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_", HashChain: true}
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO .*audit_chain_heads").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT .*last_hash.* FROM .*audit_chain_heads.* FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"last_hash"}).AddRow("abc"))
+	mock.ExpectExec("INSERT INTO .*audit_logs").
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectExec("UPDATE .*audit_chain_heads").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ctx := tenant.WithTenant(context.Background(), "t1")
+	newm := &registry.FieldMeta{TableName: "posts", ColumnName: "title", DataType: "varchar"}
+	if err := rec.Write(ctx, "alice", nil, newm); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
+
+func TestRecorderWriteTxUsesCallerTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	var forwarded int64
+	rec := &audit.Recorder{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_", HashChain: true,
+		Forward: func(_ context.Context, id int64, _ audit.ChainEntry) { forwarded = id }}
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO .*audit_chain_heads").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT .*last_hash.* FROM .*audit_chain_heads.* FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"last_hash"}).AddRow(""))
+	mock.ExpectExec("INSERT INTO .*audit_logs").
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectExec("UPDATE .*audit_chain_heads").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ctx := tenant.WithTenant(context.Background(), "t1")
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	forward, err := rec.WriteTx(ctx, tx, "alice", nil, &registry.FieldMeta{TableName: "posts", ColumnName: "title"})
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if forwarded != 0 {
+		t.Fatal("row forwarded before commit")
+	}
+	// Only the caller commits; the recorder opened no transaction itself.
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+	forward()
+	if forwarded != 7 {
+		t.Fatalf("forwarded id = %d, want 7", forwarded)
+	}
+}
+
+var chainCols = []string{"id", "tenant_id", "actor", "action", "table_name", "column_name", "resource_type", "resource_id", "request_id", "revert_of", "before_json", "after_json", "added_count", "removed_count", "change_count", "applied_at", "prev_hash", "hash"}
+
+type chainFixture struct {
+	id    int64
+	entry audit.ChainEntry
+	prev  string
+	hash  string
+}
+
+// buildChain links entries the way Recorder does and returns the rows with
+// the final hash.
+func buildChain(entries ...audit.ChainEntry) ([]chainFixture, string) {
+	var (
+		out  []chainFixture
+		prev string
+	)
+	for i, e := range entries {
+		h := audit.ChainHash(prev, e)
+		out = append(out, chainFixture{id: int64(i + 1), entry: e, prev: prev, hash: h})
+		prev = h
+	}
+	return out, prev
+}
+
+func chainRows(rows []chainFixture) *sqlmock.Rows {
+	r := sqlmock.NewRows(chainCols)
+	for _, f := range rows {
+		e := f.entry
+		r.AddRow(f.id, e.TenantID, e.Actor, e.Action, e.TableName, e.ColumnName, nil, nil, nil, nil,
+			e.BeforeJSON, e.AfterJSON, e.AddedCount, e.RemovedCount, e.ChangeCount,
+			[]byte(e.AppliedAt.Format("2006-01-02 15:04:05")), f.prev, f.hash)
+	}
+	return r
+}
+
+func chainEntries() []audit.ChainEntry {
+	at := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	return []audit.ChainEntry{
+		{TenantID: "t1", Actor: "alice", Action: "add", TableName: "posts", ColumnName: "title", AfterJSON: `{"type":"varchar"}`, AppliedAt: at},
+		{TenantID: "t1", Actor: "alice", Action: "update", TableName: "posts", ColumnName: "title", BeforeJSON: `{"type":"varchar"}`, AfterJSON: `{"type":"text"}`, AppliedAt: at.Add(time.Minute)},
+		{TenantID: "t1", Actor: "bob", Action: "delete", TableName: "posts", ColumnName: "title", BeforeJSON: `{"type":"text"}`, AppliedAt: at.Add(2 * time.Minute)},
+	}
+}
+
+func verify(t *testing.T, rows []chainFixture, head string, headID int64) audit.ChainStatus {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT .* FROM .*audit_logs").WillReturnRows(chainRows(rows))
+	mock.ExpectQuery("SELECT .* FROM .*audit_chain_heads").
+		WillReturnRows(sqlmock.NewRows([]string{"tenant_id", "last_id", "last_hash"}).AddRow("t1", headID, head))
+	st, err := audit.VerifyChain(context.Background(), db, ormdriver.MySQLDialect{}, "gcfm_", "t1")
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	return st
+}
+
+func TestVerifyChainIntact(t *testing.T) {
+	rows, head := buildChain(chainEntries()...)
+	// Rows written without HashChain are skipped wherever they appear.
+	rows = append([]chainFixture{{id: 0, entry: audit.ChainEntry{TenantID: "t1", Action: "add"}}}, rows...)
+	st := verify(t, rows, head, 3)
+	if st.Broken != nil {
+		t.Fatalf("broken: %+v", st.Broken)
+	}
+	if st.Checked != 3 || st.Unhashed != 1 {
+		t.Fatalf("status = %+v", st)
+	}
+}
+
+func TestVerifyChainDetectsEditedPayload(t *testing.T) {
+	rows, head := buildChain(chainEntries()...)
+	rows[1].entry.AfterJSON = `{"type":"longtext"}`
+	st := verify(t, rows, head, 3)
+	if st.Broken == nil || st.Broken.ID != 2 {
+		t.Fatalf("status = %+v, want break at row 2", st)
+	}
+}
+
+func TestVerifyChainDetectsEditedTime(t *testing.T) {
+	rows, head := buildChain(chainEntries()...)
+	rows[0].entry.AppliedAt = rows[0].entry.AppliedAt.Add(-time.Hour)
+	st := verify(t, rows, head, 3)
+	if st.Broken == nil || st.Broken.ID != 1 {
+		t.Fatalf("status = %+v, want break at row 1", st)
+	}
+}
+
+func TestVerifyChainDetectsDeletedRow(t *testing.T) {
+	rows, head := buildChain(chainEntries()...)
+	rows = append(rows[:1], rows[2:]...)
+	st := verify(t, rows, head, 3)
+	if st.Broken == nil || st.Broken.ID != 3 {
+		t.Fatalf("status = %+v, want break at row 3", st)
+	}
+}
+
+func TestVerifyChainDetectsReorderedRows(t *testing.T) {
+	rows, head := buildChain(chainEntries()...)
+	rows[1].id, rows[2].id = rows[2].id, rows[1].id
+	rows[1], rows[2] = rows[2], rows[1]
+	st := verify(t, rows, head, 3)
+	if st.Broken == nil || st.Broken.ID != 2 {
+		t.Fatalf("status = %+v, want break at row 2", st)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/faciam-dev/gcfm/pkg/audit"
 	"github.com/faciam-dev/gcfm/pkg/registry"
+	"github.com/faciam-dev/gcfm/pkg/tenant"
 	ormdriver "github.com/faciam-dev/goquent/orm/driver"
 )
 
@@ -19,7 +20,7 @@ func TestRecorderWrite(t *testing.T) {
 	old := &registry.FieldMeta{TableName: "posts", ColumnName: "title", DataType: "text"}
 	newm := &registry.FieldMeta{TableName: "posts", ColumnName: "title", DataType: "varchar"}
 	mock.ExpectExec("INSERT INTO .*audit_logs").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	if err := rec.Write(context.Background(), "alice", old, newm); err != nil {
 		t.Fatalf("write: %v", err)
@@ -36,7 +37,7 @@ func TestRecorderWriteAction(t *testing.T) {
 	}
 	rec := &audit.Recorder{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_"}
 	mock.ExpectExec("INSERT INTO .*audit_logs").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	if err := rec.WriteAction(context.Background(), "bob", "snapshot", "1.0.0", "+1 -0"); err != nil {
 		t.Fatalf("write action: %v", err)
@@ -45,3 +46,29 @@ func TestRecorderWriteAction(t *testing.T) {
 		t.Fatalf("unmet: %v", err)
 	}
 }
+
+func TestRecorderRecord(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	rec := &audit.Recorder{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_"}
+	// Columns are inserted in alphabetical order.
+	mock.ExpectExec("INSERT INTO .*audit_logs").
+		WithArgs("delete", "carol", sqlmock.AnyArg(), nil, sqlmock.AnyArg(), sqlmock.AnyArg(), nil, sqlmock.AnyArg(), "req-1", "7", audit.ResourceRole, nil, "t1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	ctx := audit.WithRequestID(tenant.WithTenant(context.Background(), "t1"), "req-1")
+	err = rec.Record(ctx, audit.Event{
+		ResourceType: audit.ResourceRole,
+		ResourceID:   "7",
+		Action:       "delete",
+		Actor:        "carol",
+		Before:       map[string]any{"id": 7, "name": "editor"},
+	})
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
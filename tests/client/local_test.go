@@ -40,6 +40,9 @@ func (s *stubService) Export(context.Context, sdk.DBConfig) ([]byte, error)
 func (s *stubService) Apply(context.Context, sdk.DBConfig, []byte, sdk.ApplyOptions) (sdk.DiffReport, error) {
 	return sdk.DiffReport{}, nil
 }
+func (s *stubService) ApplyAcross(context.Context, sdk.Query, []byte, sdk.AcrossOptions) (sdk.AcrossReport, error) {
+	return sdk.AcrossReport{}, nil
+}
 func (s *stubService) MigrateRegistry(context.Context, sdk.DBConfig, int) error   { return nil }
 func (s *stubService) RegistryVersion(context.Context, sdk.DBConfig) (int, error) { return 0, nil }
 
@@ -48,6 +51,12 @@ func (s *stubService) StartTargetWatcher(context.Context, sdk.TargetProvider, ti
 	return func() {}
 }
 
+// StartHealthProber is a no-op for tests to satisfy the Service interface.
+func (s *stubService) StartHealthProber(context.Context, sdk.HealthProbeConfig) func() {
+	return func() {}
+}
+func (s *stubService) TargetHealth() []sdk.TargetHealth { return nil }
+
 func TestLocalClientDelegates(t *testing.T) {
 	svc := &stubService{}
 	c := client.NewLocalService(svc)
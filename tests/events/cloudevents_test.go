@@ -0,0 +1,128 @@
+package events_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/faciam-dev/gcfm/internal/events"
+	"github.com/faciam-dev/gcfm/pkg/registry"
+	"github.com/faciam-dev/gcfm/pkg/tenant"
+)
+
+type chanSink chan events.Event
+
+func (c chanSink) Emit(ctx context.Context, e events.Event) error {
+	c <- e
+	return nil
+}
+
+func sampleEvent() events.Event {
+	e := events.FieldEvent(events.TypeFieldUpdated,
+		&registry.FieldMeta{TableName: "posts", ColumnName: "title", DataType: "varchar"},
+		&registry.FieldMeta{TableName: "posts", ColumnName: "title", DataType: "text"})
+	e.ID = "e1"
+	e.Time = time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	e.Tenant = "t1"
+	e.DataSchema = events.SchemaURL("", e.Name)
+	return e
+}
+
+func TestWebhookStructuredMode(t *testing.T) {
+	var ct string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ct = r.Header.Get("Content-Type")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+	wh := events.NewWebhookSink(events.WebhookConfig{Enabled: true, Endpoint: srv.URL})
+	if err := wh.Emit(context.Background(), sampleEvent()); err != nil {
+		t.Fatalf("emit: %v", err)
+	}
+	if ct != events.ContentTypeStructured {
+		t.Fatalf("content type %q", ct)
+	}
+	var ce events.CloudEvent
+	if err := json.Unmarshal(body, &ce); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if ce.SpecVersion != "1.0" || ce.Type != events.TypeFieldUpdated || ce.Source != events.DefaultSource ||
+		ce.Subject != "posts.title" || ce.Tenant != "t1" || ce.DataSchema != events.DefaultSchemaBaseURL+"/cf.field.updated.json" {
+		t.Fatalf("unexpected envelope: %+v", ce)
+	}
+	var data events.FieldChange
+	if err := json.Unmarshal(ce.Data, &data); err != nil || data.After.DataType != "text" {
+		t.Fatalf("unexpected data %s: %v", ce.Data, err)
+	}
+}
+
+func TestWebhookBinaryMode(t *testing.T) {
+	var hdr http.Header
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hdr = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+	wh := events.NewWebhookSink(events.WebhookConfig{Enabled: true, Endpoint: srv.URL, Secret: "s", Mode: events.ModeBinary})
+	if err := wh.Emit(context.Background(), sampleEvent()); err != nil {
+		t.Fatalf("emit: %v", err)
+	}
+	ce := events.FromHTTPBinary(hdr, body)
+	if ce.SpecVersion != "1.0" || ce.ID != "e1" || ce.Type != events.TypeFieldUpdated || ce.Tenant != "t1" || ce.DataContentType != "application/json" {
+		t.Fatalf("unexpected headers: %v", hdr)
+	}
+	if !ce.Time.Equal(time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("time %v", ce.Time)
+	}
+	var data events.FieldChange
+	if err := json.Unmarshal(body, &data); err != nil || data.Table != "posts" {
+		t.Fatalf("body is not the event data: %s", body)
+	}
+	if hdr.Get("X-CF-Signature") == "" {
+		t.Fatal("missing signature")
+	}
+}
+
+func TestKafkaHeaders(t *testing.T) {
+	ce, err := sampleEvent().CloudEvent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, h := range ce.KafkaHeaders() {
+		got[string(h.Key)] = string(h.Value)
+	}
+	for k, want := range map[string]string{
+		"ce_specversion": "1.0",
+		"ce_id":          "e1",
+		"ce_type":        events.TypeFieldUpdated,
+		"ce_subject":     "posts.title",
+		"ce_tenant":      "t1",
+		"content-type":   "application/json",
+	} {
+		if got[k] != want {
+			t.Errorf("%s = %q, want %q", k, got[k], want)
+		}
+	}
+}
+
+func TestDispatchFillsAttributes(t *testing.T) {
+	sink := make(chanSink, 1)
+	d := events.NewDispatcher(events.Config{Source: "/gcfm/eu-1", SchemaBaseURL: "https://example.com/schemas"}, nil, sink)
+	ctx := tenant.WithTenant(context.Background(), "t9")
+	d.Dispatch(ctx, events.Event{Name: events.TypeScan, Data: events.ScanCompleted{DBID: 1}})
+	select {
+	case e := <-sink:
+		if e.ID == "" || e.Time.IsZero() || e.Tenant != "t9" || e.Source != "/gcfm/eu-1" || e.DataSchema != "https://example.com/schemas/cf.scan.json" {
+			t.Fatalf("unexpected event: %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("event not dispatched")
+	}
+}
@@ -0,0 +1,154 @@
+package events_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/faciam-dev/gcfm/internal/events"
+	"github.com/faciam-dev/gcfm/pkg/tenant"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+)
+
+type namedSink struct {
+	name    string
+	err     error
+	got     []events.Event
+	tenants []string
+}
+
+func (s *namedSink) Name() string { return s.name }
+
+func (s *namedSink) Emit(ctx context.Context, e events.Event) error {
+	s.got = append(s.got, e)
+	s.tenants = append(s.tenants, tenant.FromContext(ctx))
+	return s.err
+}
+
+type memDLQ struct {
+	stored []events.Event
+	sinks  []string
+}
+
+func (q *memDLQ) Store(ctx context.Context, e events.Event, sink string, attempts int, lastErr string) error {
+	q.stored = append(q.stored, e)
+	q.sinks = append(q.sinks, sink)
+	return nil
+}
+
+func newRelay(t *testing.T, d *events.Dispatcher) (*events.Relay, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	outbox := &events.Outbox{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_"}
+	r := events.NewRelay(outbox, d, events.OutboxConfig{})
+	r.Owner = "r1"
+	r.Retention = 0
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	r.Now = func() time.Time { return now }
+	return r, mock
+}
+
+func expectClaim(mock sqlmock.Sqlmock, attempts int, delivered string) {
+	mock.ExpectQuery("SELECT `id` FROM `gcfm_events_outbox` WHERE `status` = \\? AND `next_attempt_at` <= \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec("UPDATE `gcfm_events_outbox` SET .* WHERE `id` = \\? AND `status` = \\?").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT `id`, `tenant_id`, `payload`, `attempts`, `delivered_sinks` FROM `gcfm_events_outbox`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id", "payload", "attempts", "delivered_sinks"}).
+			AddRow(7, "t1", `{"name":"cf.field.created","time":"2026-05-01T11:59:00Z","data":{"a":1},"id":"posts.title"}`, attempts, delivered))
+}
+
+func TestRelaySkipsDeliveredSinks(t *testing.T) {
+	a := &namedSink{name: "a"}
+	b := &namedSink{name: "b"}
+	d := events.NewDispatcher(events.Config{}, nil, a, b)
+	r, mock := newRelay(t, d)
+	expectClaim(mock, 1, "a")
+	mock.ExpectExec("UPDATE `gcfm_events_outbox` SET .* WHERE `id` = \\? AND `lease_owner` = \\?").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err := r.RunOnce(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("RunOnce = %d, %v", n, err)
+	}
+	if len(a.got) != 0 {
+		t.Fatalf("sink a called again: %+v", a.got)
+	}
+	if len(b.got) != 1 || b.got[0].Name != "cf.field.created" || b.tenants[0] != "t1" {
+		t.Fatalf("sink b got %+v for %v", b.got, b.tenants)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
+
+func TestRelayMovesExhaustedRowsToDLQ(t *testing.T) {
+	a := &namedSink{name: "a", err: errors.New("down")}
+	b := &namedSink{name: "b"}
+	dlq := &memDLQ{}
+	d := events.NewDispatcher(events.Config{Retry: events.RetryConfig{MaxAttempts: 2}}, dlq, a, b)
+	r, mock := newRelay(t, d)
+	expectClaim(mock, 1, "")
+	// Sinks handed to the DLQ count as delivered for the outbox row.
+	mock.ExpectExec("UPDATE `gcfm_events_outbox` SET .* WHERE `id` = \\? AND `lease_owner` = \\?").
+		WithArgs(2, "b,a", "a: down", nil, nil, events.OutboxFailed, int64(7), "r1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if _, err := r.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if len(dlq.stored) != 1 || dlq.stored[0].ID != "posts.title" || dlq.sinks[0] != "a" {
+		t.Fatalf("dlq = %+v for %v", dlq.stored, dlq.sinks)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
+
+func TestRelayRetriesWithBackoff(t *testing.T) {
+	s := &namedSink{name: "a", err: errors.New("down")}
+	d := events.NewDispatcher(events.Config{Retry: events.RetryConfig{MaxAttempts: 5, InitialDelay: time.Second}}, nil, s)
+	r, mock := newRelay(t, d)
+	expectClaim(mock, 2, "")
+	// Third attempt: 1s << 2.
+	next := time.Date(2026, 5, 1, 12, 0, 4, 0, time.UTC)
+	mock.ExpectExec("UPDATE `gcfm_events_outbox` SET .* WHERE `id` = \\? AND `lease_owner` = \\?").
+		WithArgs(3, "", "a: down", nil, nil, next, int64(7), "r1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if _, err := r.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
+
+func TestDispatchWithOutboxEnqueues(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	s := &namedSink{name: "a"}
+	d := events.NewDispatcher(events.Config{}, nil, s)
+	d.UseOutbox(&events.Outbox{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_"})
+	mock.ExpectExec("INSERT INTO `gcfm_events_outbox`").
+		WithArgs("posts.title", "cf.field.created", sqlmock.AnyArg(), sqlmock.AnyArg(), events.OutboxPending, "t1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	ctx := tenant.WithTenant(context.Background(), "t1")
+	d.Dispatch(ctx, events.Event{Name: "cf.field.created", ID: "posts.title"})
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+	if len(s.got) != 0 {
+		t.Fatalf("sink called directly: %+v", s.got)
+	}
+}
@@ -31,11 +31,11 @@ func TestRedisSink(t *testing.T) {
 	}
 	select {
 	case msg := <-sub.Channel():
-		var got events.Event
+		var got events.CloudEvent
 		if err := json.Unmarshal([]byte(msg.Payload), &got); err != nil {
 			t.Fatalf("decode: %v", err)
 		}
-		if got.Name != evt.Name {
+		if got.Type != evt.Name || got.SpecVersion != events.SpecVersion {
 			t.Fatalf("event mismatch: %#v", got)
 		}
 	case <-time.After(time.Second):
@@ -0,0 +1,155 @@
+package events_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/faciam-dev/gcfm/internal/events"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+)
+
+var failedColumns = []string{"id", "tenant_id", "name", "sink", "payload", "attempts", "last_error", "inserted_at", "replay_attempts", "next_replay_at"}
+
+const failedPayload = `{"name":"cf.field.created","time":"2026-05-01T11:00:00Z","data":{"a":1},"id":"e1","tenant":"t1"}`
+
+func newReplayer(t *testing.T, d *events.Dispatcher) (*events.Replayer, sqlmock.Sqlmock, time.Time) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	dlq := &events.SQLDLQ{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_"}
+	r := events.NewReplayer(dlq, d, events.ReplayConfig{Enabled: true, InitialDelay: time.Minute, MaxAttempts: 3})
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	r.Now = func() time.Time { return now }
+	return r, mock, now
+}
+
+func TestReplayerBacksOffPerSink(t *testing.T) {
+	a := &namedSink{name: "a", err: errors.New("down")}
+	b := &namedSink{name: "b"}
+	r, mock, now := newReplayer(t, events.NewDispatcher(events.Config{}, nil, a, b))
+	inserted := now.Add(-time.Hour)
+	mock.ExpectQuery("SELECT .* FROM `gcfm_events_failed` WHERE \\(`leased_until` IS NULL OR `leased_until` <= \\?\\) AND `next_replay_at` <= \\?").
+		WillReturnRows(sqlmock.NewRows(failedColumns).
+			AddRow(1, "t1", "cf.field.created", "a", failedPayload, 3, "down", inserted, 1, now).
+			AddRow(2, "t1", "cf.field.created", "a", failedPayload, 3, "down", inserted, 0, now).
+			AddRow(3, "t1", "cf.field.created", "b", failedPayload, 3, "down", inserted, 0, now))
+	mock.ExpectExec("UPDATE `gcfm_events_failed` SET `leased_until` = \\? WHERE `id` = \\? AND \\(`leased_until` IS NULL OR `leased_until` <= \\?\\) AND `next_replay_at` <= \\?").
+		WithArgs(now.Add(time.Minute), int64(1), now, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Second failure of entry 1: 1m doubled once more.
+	mock.ExpectExec("UPDATE `gcfm_events_failed` SET .* WHERE `id` = \\?").
+		WithArgs("down", nil, now.Add(4*time.Minute), 2, int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Entry 2 waits because sink a just failed.
+	mock.ExpectExec("UPDATE `gcfm_events_failed` SET `leased_until` = \\? WHERE `id` = \\? AND \\(`leased_until` IS NULL OR `leased_until` <= \\?\\) AND `next_replay_at` <= \\?").
+		WithArgs(now.Add(time.Minute), int64(3), now, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM `gcfm_events_failed` WHERE `tenant_id` = \\? AND `id` = \\?").
+		WithArgs("t1", int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err := r.RunOnce(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("RunOnce = %d, %v", n, err)
+	}
+	if len(a.got) != 1 || len(b.got) != 1 || b.tenants[0] != "t1" {
+		t.Fatalf("a got %d, b got %d for %v", len(a.got), len(b.got), b.tenants)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
+
+func TestReplayerParksExhaustedEntries(t *testing.T) {
+	a := &namedSink{name: "a", err: errors.New("down")}
+	r, mock, now := newReplayer(t, events.NewDispatcher(events.Config{}, nil, a))
+	mock.ExpectQuery("SELECT .* FROM `gcfm_events_failed` WHERE `tenant_id` = \\? AND `id` = \\?").
+		WithArgs("t1", int64(1)).
+		WillReturnRows(sqlmock.NewRows(failedColumns).
+			AddRow(1, "t1", "cf.field.created", "a", failedPayload, 3, "down", now, 2, now))
+	mock.ExpectExec("UPDATE `gcfm_events_failed` SET `leased_until` = \\? WHERE `id` = \\? AND \\(`leased_until` IS NULL OR `leased_until` <= \\?\\)$").
+		WithArgs(now.Add(time.Minute), int64(1), now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE `gcfm_events_failed` SET .* WHERE `id` = \\?").
+		WithArgs("down", nil, nil, 3, int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := r.ReplayNow(context.Background(), "t1", 1); err == nil {
+		t.Fatal("expected the sink error")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
+
+func TestReplayNowRejectsLeasedEntries(t *testing.T) {
+	a := &namedSink{name: "a"}
+	r, mock, now := newReplayer(t, events.NewDispatcher(events.Config{}, nil, a))
+	mock.ExpectQuery("SELECT .* FROM `gcfm_events_failed` WHERE `tenant_id` = \\? AND `id` = \\?").
+		WithArgs("t1", int64(1)).
+		WillReturnRows(sqlmock.NewRows(failedColumns).
+			AddRow(1, "t1", "cf.field.created", "a", failedPayload, 3, "down", now, 0, now))
+	// The replay worker holds the lease.
+	mock.ExpectExec("UPDATE `gcfm_events_failed` SET `leased_until` = \\?").
+		WithArgs(now.Add(time.Minute), int64(1), now).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := r.ReplayNow(context.Background(), "t1", 1); !errors.Is(err, events.ErrFailedEventLeased) {
+		t.Fatalf("ReplayNow = %v", err)
+	}
+	if len(a.got) != 0 {
+		t.Fatalf("leased entry was sent: %+v", a.got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
+
+func TestSQLDLQStoresSinkAndSchedule(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	dlq := &events.SQLDLQ{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "x_", ReplayDelay: time.Minute}
+	mock.ExpectExec("INSERT INTO `x_events_failed`").
+		WithArgs(3, "e1", "down", "cf.scan", sqlmock.AnyArg(), sqlmock.AnyArg(), "kafka", "t1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if err := dlq.Store(context.Background(), events.Event{ID: "e1", Name: events.TypeScan, Tenant: "t1"}, "kafka", 3, "down"); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
+
+func TestSQLDLQListAllTenants(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	dlq := &events.SQLDLQ{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_"}
+	mock.ExpectQuery("SELECT .* FROM `gcfm_events_failed` WHERE `tenant_id` = \\?").
+		WithArgs("t1").
+		WillReturnRows(sqlmock.NewRows(failedColumns))
+	mock.ExpectQuery("SELECT .* FROM `gcfm_events_failed` ORDER BY").
+		WillReturnRows(sqlmock.NewRows(failedColumns))
+
+	if _, err := dlq.List(context.Background(), events.FailedFilter{Tenant: "t1"}); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if _, err := dlq.List(context.Background(), events.FailedFilter{Tenant: "t1", AllTenants: true}); err != nil {
+		t.Fatalf("List all: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet: %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+package events_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/faciam-dev/gcfm/internal/events"
+	ormdriver "github.com/faciam-dev/goquent/orm/driver"
+)
+
+func TestSubscriptionMatch(t *testing.T) {
+	field := sampleEvent() // cf.field.updated on posts.title for t1
+	scan := events.Event{Name: events.TypeScan, Tenant: "t2", Data: events.ScanCompleted{DBID: 1, Total: 12}}
+	cases := []struct {
+		name  string
+		sub   events.Subscription
+		field bool
+		scan  bool
+	}{
+		{"empty", events.Subscription{}, true, true},
+		{"glob", events.Subscription{Events: []string{"cf.field.*"}}, true, false},
+		{"exact", events.Subscription{Events: []string{"cf.scan"}}, false, true},
+		{"tenant", events.Subscription{Tenants: []string{"t1"}}, true, false},
+		{"glob and tenant", events.Subscription{Events: []string{"cf.scan"}, Tenants: []string{"t1"}}, false, false},
+		{"filter on data", events.Subscription{Filter: `data.table == "posts"`}, true, false},
+		{"filter on number", events.Subscription{Filter: `event.type == "cf.scan" && data.total > 10`}, false, true},
+		{"filter on subject", events.Subscription{Filter: `event.subject.startsWith("posts.")`}, true, false},
+		{"filter with comprehension", events.Subscription{Filter: `event.tenant == "t1" && ["posts", "pages"].exists(t, data.table == t)`}, true, false},
+	}
+	for _, c := range cases {
+		m, err := c.sub.Compile()
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if got := m.Match(field); got != c.field {
+			t.Errorf("%s: field event matched = %v", c.name, got)
+		}
+		if got := m.Match(scan); got != c.scan {
+			t.Errorf("%s: scan event matched = %v", c.name, got)
+		}
+	}
+}
+
+func TestSubscriptionCompileErrors(t *testing.T) {
+	for _, sub := range []events.Subscription{
+		{Events: []string{"cf.[field"}},
+		{Filter: `data.table ==`},
+		{Filter: `"posts"`},
+		// Too costly: the estimate is about filterMaxSize cubed.
+		{Filter: `data.items.exists(a, data.items.exists(b, data.items.exists(c, a == b && b == c)))`},
+	} {
+		if _, err := sub.Compile(); err == nil {
+			t.Errorf("%+v: expected an error", sub)
+		}
+	}
+}
+
+func TestSubscribedSinkSkipsUnmatched(t *testing.T) {
+	inner := &namedSink{name: "kafka"}
+	s, err := events.Subscribe(inner, events.Subscription{Events: []string{"cf.scan"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events.SinkName(s) != "kafka" {
+		t.Fatalf("name = %q", events.SinkName(s))
+	}
+	if err := s.Emit(context.Background(), sampleEvent()); err != nil {
+		t.Fatalf("skipped event should count as delivered: %v", err)
+	}
+	if err := s.Emit(context.Background(), events.Event{Name: events.TypeScan}); err != nil {
+		t.Fatal(err)
+	}
+	if len(inner.got) != 1 || inner.got[0].Name != events.TypeScan {
+		t.Fatalf("inner sink got %+v", inner.got)
+	}
+}
+
+func TestTenantWebhookSinkLogsDeliveries(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.Header.Get("X-CF-Signature") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// The test server listens on loopback.
+	sink := events.NewTenantWebhookSink(&events.WebhookStore{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_"}, events.TenantWebhookConfig{AllowPrivateNetworks: true})
+
+	cols := []string{"id", "tenant_id", "url", "secret", "event_types", "filter_expr", "mode", "enabled", "created_at", "updated_at"}
+	mock.ExpectQuery("SELECT .* FROM `gcfm_event_webhooks` WHERE `tenant_id` = \\? AND `enabled` = \\?").
+		WithArgs("t1", true).
+		WillReturnRows(sqlmock.NewRows(cols).
+			AddRow(1, "t1", srv.URL, "s1", "cf.field.*", `data.table == "posts"`, "structured", true, nil, nil).
+			AddRow(2, "t1", srv.URL, "s2", "cf.scan", nil, "structured", true, nil, nil).
+			AddRow(3, "t1", srv.URL, "s3", "", nil, "binary", true, nil, nil))
+	// Webhook 1 is new; webhook 2 does not subscribe; webhook 3 already
+	// received the event on an earlier attempt.
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM `gcfm_event_webhook_deliveries`").
+		WithArgs(1, "e1", events.DeliverySucceeded).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("INSERT INTO `gcfm_event_webhook_deliveries`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "", "e1", events.TypeFieldUpdated, 200, events.DeliverySucceeded, "t1", int64(1)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM `gcfm_event_webhook_deliveries`").
+		WithArgs(3, "e1", events.DeliverySucceeded).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	if err := sink.Emit(context.Background(), sampleEvent()); err != nil {
+		t.Fatalf("emit: %v", err)
+	}
+	if hits != 1 {
+		t.Fatalf("endpoint called %d times", hits)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTenantWebhookSinkRefusesInternalAddresses(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits++ }))
+	defer srv.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	sink := events.NewTenantWebhookSink(&events.WebhookStore{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_"}, events.TenantWebhookConfig{})
+
+	cols := []string{"id", "tenant_id", "url", "secret", "event_types", "filter_expr", "mode", "enabled", "created_at", "updated_at"}
+	mock.ExpectQuery("SELECT .* FROM `gcfm_event_webhooks`").
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(1, "t1", srv.URL, "s1", "", nil, "structured", true, nil, nil))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM `gcfm_event_webhook_deliveries`").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("INSERT INTO `gcfm_event_webhook_deliveries`").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = sink.Emit(context.Background(), sampleEvent())
+	if !errors.Is(err, events.ErrBlockedAddress) {
+		t.Fatalf("err = %v, want ErrBlockedAddress", err)
+	}
+	if hits != 0 {
+		t.Fatal("internal endpoint was called")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTenantWebhookSinkCachesWebhooks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	sink := events.NewTenantWebhookSink(&events.WebhookStore{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_"}, events.TenantWebhookConfig{CacheTTL: time.Hour})
+
+	cols := []string{"id", "tenant_id", "url", "secret", "event_types", "filter_expr", "mode", "enabled", "created_at", "updated_at"}
+	mock.ExpectQuery("SELECT .* FROM `gcfm_event_webhooks`").
+		WithArgs("t1", true).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(1, "t1", "https://example.com/hook", "s1", "cf.scan", nil, "structured", true, nil, nil))
+
+	// Neither event matches, and the second one is served from the cache.
+	for i := 0; i < 2; i++ {
+		if err := sink.Emit(context.Background(), sampleEvent()); err != nil {
+			t.Fatalf("emit: %v", err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// captureArg records the argument it is matched against.
+type captureArg struct{ v *string }
+
+func (c captureArg) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	*c.v = s
+	return ok
+}
+
+func TestWebhookStoreEncryptsSecrets(t *testing.T) {
+	t.Setenv("CF_ENC_KEY", "0123456789abcdef")
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store := &events.WebhookStore{DB: db, Dialect: ormdriver.MySQLDialect{}, TablePrefix: "gcfm_"}
+	cols := []string{"id", "tenant_id", "url", "secret", "event_types", "filter_expr", "mode", "enabled", "created_at", "updated_at"}
+
+	var stored string
+	mock.ExpectExec("INSERT INTO `gcfm_event_webhooks`").
+		WithArgs(true, "", "", "structured", captureArg{&stored}, "t1", "https://example.com/hook").
+		WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectQuery("SELECT .* FROM `gcfm_event_webhooks`").
+		WillReturnRows(sqlmock.NewRows(cols))
+	_, err = store.Create(context.Background(), events.TenantWebhook{Tenant: "t1", URL: "https://example.com/hook", Secret: "whsec_plain", Enabled: true})
+	if !errors.Is(err, events.ErrWebhookNotFound) {
+		t.Fatalf("create: %v", err)
+	}
+	if !strings.HasPrefix(stored, "enc:") || strings.Contains(stored, "whsec_plain") {
+		t.Fatalf("secret stored as %q", stored)
+	}
+
+	mock.ExpectQuery("SELECT .* FROM `gcfm_event_webhooks`").
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(4, "t1", "https://example.com/hook", stored, "", nil, "structured", true, nil, nil))
+	w, err := store.Get(context.Background(), "t1", 4)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if w.Secret != "whsec_plain" {
+		t.Fatalf("secret = %q", w.Secret)
+	}
+}
@@ -0,0 +1,160 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/faciam-dev/gcfm/internal/auth"
+	sm "github.com/faciam-dev/gcfm/internal/server/middleware"
+)
+
+const scopeSecret = "secret"
+
+// newScopedAPI serves GET and POST on /admin/targets and PUT and DELETE on
+// /admin/targets/{key}. With policies, p.Allowed enforces them.
+func newScopedAPI(t *testing.T, p sm.ScopePolicy, policies ...[]string) huma.API {
+	t.Helper()
+	m, err := model.NewModelFromString(`
+[request_definition]
+r = sub, obj, act
+[policy_definition]
+p = sub, obj, act
+[policy_effect]
+e = some(where (p.eft == allow))
+[matchers]
+m = r.sub == p.sub && keyMatch2(r.obj, p.obj) && r.act == p.act
+`)
+	if err != nil {
+		t.Fatalf("model: %v", err)
+	}
+	enf, err := casbin.NewEnforcer(m)
+	if err != nil {
+		t.Fatalf("enforcer: %v", err)
+	}
+	for _, pol := range policies {
+		if _, err := enf.AddPolicy(pol[0], pol[1], pol[2]); err != nil {
+			t.Fatalf("policy: %v", err)
+		}
+	}
+	if len(policies) > 0 {
+		p.Allowed = func(ctx context.Context, method, path string) bool {
+			ok, _ := enf.Enforce(sm.UserFromContext(ctx), path, method)
+			return ok
+		}
+	}
+	r := chi.NewRouter()
+	api := humachi.New(r, huma.DefaultConfig("test", "1.0"))
+	api.UseMiddleware(auth.Middleware(api, auth.NewJWT(scopeSecret, time.Minute)))
+	api.UseMiddleware(sm.RBAC(enf, nil))
+	require := sm.RequireScopes(api, p)
+	type in struct{}
+	reg := func(method, path, scope string) {
+		huma.Register(api, huma.Operation{
+			OperationID: strings.ToLower(method) + "Targets",
+			Method:      method,
+			Path:        path,
+			Middlewares: huma.Middlewares{require(scope)},
+			Metadata:    map[string]any{sm.ScopesMetadataKey: []string{scope}},
+		}, func(context.Context, *in) (*struct{}, error) { return nil, nil })
+	}
+	reg(http.MethodGet, "/admin/targets", "admin:targets")
+	reg(http.MethodPost, "/admin/targets", "admin:targets:write")
+	reg(http.MethodPut, "/admin/targets/{key}", "admin:targets:write")
+	reg(http.MethodDelete, "/admin/targets/{key}", "admin:targets:write")
+	return api
+}
+
+func scopeToken(t *testing.T, scope string, roles ...string) string {
+	t.Helper()
+	tok, err := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "u1",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+		Roles: roles,
+		Scope: scope,
+	}).SignedString([]byte(scopeSecret))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return tok
+}
+
+func doScoped(api huma.API, method, token string) *httptest.ResponseRecorder {
+	path := "/admin/targets"
+	if method == http.MethodPut || method == http.MethodDelete {
+		path += "/a"
+	}
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	api.Adapter().ServeHTTP(w, req)
+	return w
+}
+
+func TestRequireScopesClaim(t *testing.T) {
+	api := newScopedAPI(t, sm.ScopePolicy{})
+	if w := doScoped(api, http.MethodGet, scopeToken(t, "openid admin:targets")); w.Code != http.StatusNoContent {
+		t.Fatalf("read status %d body=%s", w.Code, w.Body.String())
+	}
+	w := doScoped(api, http.MethodPost, scopeToken(t, "admin:targets"))
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "missing scope admin:targets:write") {
+		t.Fatalf("write status %d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func TestRequireScopesWriteImpliesRead(t *testing.T) {
+	api := newScopedAPI(t, sm.ScopePolicy{})
+	tok := scopeToken(t, "admin:targets:write")
+	for _, m := range []string{http.MethodGet, http.MethodPost} {
+		if w := doScoped(api, m, tok); w.Code != http.StatusNoContent {
+			t.Fatalf("%s status %d body=%s", m, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRequireScopesRoleMapping(t *testing.T) {
+	api := newScopedAPI(t, sm.ScopePolicy{
+		RoleScopes: map[string][]string{"operator": {"admin:targets"}, "platform": {"admin:targets:write"}},
+		Roles: func(context.Context, string) ([]string, error) {
+			return []string{"platform"}, nil
+		},
+	})
+	if w := doScoped(api, http.MethodGet, scopeToken(t, "", "operator")); w.Code != http.StatusNoContent {
+		t.Fatalf("claim role status %d body=%s", w.Code, w.Body.String())
+	}
+	if w := doScoped(api, http.MethodPost, scopeToken(t, "")); w.Code != http.StatusNoContent {
+		t.Fatalf("resolved role status %d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func TestRequireScopesFallsBackToRBACPerOperation(t *testing.T) {
+	api := newScopedAPI(t, sm.ScopePolicy{},
+		[]string{"u1", "/admin/targets", http.MethodGet},
+		[]string{"u1", "/admin/targets/:key", http.MethodPut},
+	)
+	tok := scopeToken(t, "")
+	for _, m := range []string{http.MethodGet, http.MethodPut} {
+		if w := doScoped(api, m, tok); w.Code != http.StatusNoContent {
+			t.Fatalf("%s status %d body=%s", m, w.Code, w.Body.String())
+		}
+	}
+	// The policy allows PUT only, which grants no other write.
+	for _, m := range []string{http.MethodPost, http.MethodDelete} {
+		w := doScoped(api, m, tok)
+		if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "missing scope admin:targets:write") {
+			t.Fatalf("%s status %d body=%s", m, w.Code, w.Body.String())
+		}
+	}
+}
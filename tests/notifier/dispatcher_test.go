@@ -0,0 +1,70 @@
+package notifier_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faciam-dev/gcfm/pkg/notifier"
+	"github.com/faciam-dev/gcfm/pkg/registry"
+)
+
+type chanSink struct {
+	events chan notifier.Event
+	err    error
+}
+
+func (s *chanSink) Emit(_ context.Context, e notifier.Event) error {
+	s.events <- e
+	return s.err
+}
+
+func (s *chanSink) Name() string { return "chan" }
+
+type chanDLQ struct{ sinks chan string }
+
+func (q *chanDLQ) Store(_ context.Context, e notifier.Event, sink string, _ int, _ string) error {
+	q.sinks <- sink + " " + e.Name
+	return nil
+}
+
+func TestDispatcherDeliversToSinksAndDLQ(t *testing.T) {
+	ok := &chanSink{events: make(chan notifier.Event, 1)}
+	failing := &chanSink{events: make(chan notifier.Event, 1), err: errors.New("down")}
+	dlq := &chanDLQ{sinks: make(chan string, 1)}
+	typed, err := notifier.Subscribe(ok, notifier.Subscription{Events: []string{"cf.field.*"}})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "events.yaml")
+	if err := os.WriteFile(path, []byte("retry:\n  max_attempts: 1\n  initial_delay: 1ms\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := notifier.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("config: %v", err)
+	}
+	var em notifier.Emitter = notifier.NewDispatcher(cfg, dlq, typed, failing)
+
+	em.Dispatch(context.Background(), notifier.FieldEvent(notifier.TypeFieldCreated, nil, &registry.FieldMeta{TableName: "posts", ColumnName: "title"}))
+	select {
+	case e := <-ok.events:
+		fc, isChange := e.Data.(notifier.FieldChange)
+		if e.Name != notifier.TypeFieldCreated || e.Subject != "posts.title" || e.ID == "" || !isChange || fc.After == nil {
+			t.Fatalf("unexpected event %+v", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("event not delivered")
+	}
+	select {
+	case got := <-dlq.sinks:
+		if got != "chan "+notifier.TypeFieldCreated {
+			t.Fatalf("dlq got %q", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("failed event not stored")
+	}
+}
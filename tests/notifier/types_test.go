@@ -0,0 +1,27 @@
+package notifier_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/faciam-dev/gcfm/internal/events"
+	"github.com/faciam-dev/gcfm/pkg/notifier"
+)
+
+// TestMirroredTypesMatchTags checks the struct tags of the public mirrors,
+// which conversions between the types ignore.
+func TestMirroredTypesMatchTags(t *testing.T) {
+	for _, pair := range [][2]any{
+		{notifier.Event{}, events.Event{}},
+		{notifier.CloudEvent{}, events.CloudEvent{}},
+		{notifier.FieldChange{}, events.FieldChange{}},
+		{notifier.Subscription{}, events.Subscription{}},
+	} {
+		pub, in := reflect.TypeOf(pair[0]), reflect.TypeOf(pair[1])
+		for i := 0; i < pub.NumField(); i++ {
+			if got, want := pub.Field(i).Tag, in.Field(i).Tag; got != want {
+				t.Errorf("%s.%s: tag %q, want %q", pub.Name(), pub.Field(i).Name, got, want)
+			}
+		}
+	}
+}
@@ -15,6 +15,12 @@ import (
 
 type stubNotifier struct{ diffs []notifier.DiffReport }
 
+type stubEmitter struct{ events []notifier.Event }
+
+func (s *stubEmitter) Dispatch(ctx context.Context, e notifier.Event) {
+	s.events = append(s.events, e)
+}
+
 func (s *stubNotifier) Emit(ctx context.Context, d notifier.DiffReport) error {
 	s.diffs = append(s.diffs, d)
 	return nil
@@ -46,8 +52,9 @@ func TestApplyHooks(t *testing.T) {
 	mock.ExpectCommit()
 
 	nt := &stubNotifier{}
+	em := &stubEmitter{}
 	disable := false
-	svc := sdk.New(sdk.ServiceConfig{Recorder: &audit.Recorder{DB: db, Dialect: ormdriver.MySQLDialect{}}, Notifier: nt, PluginEnabled: &disable})
+	svc := sdk.New(sdk.ServiceConfig{Recorder: &audit.Recorder{DB: db, Dialect: ormdriver.MySQLDialect{}}, Notifier: nt, Events: em, PluginEnabled: &disable})
 	yamlData := []byte("version: 0.4\nfields:\n  - table: posts\n    column: title\n    type: text\n")
 	rep, err := svc.Apply(context.Background(), sdk.DBConfig{Driver: "sqlmock", DSN: "sqlmock_db", TablePrefix: "gcfm_"}, yamlData, sdk.ApplyOptions{Actor: "alice"})
 	if err != nil {
@@ -59,6 +66,12 @@ func TestApplyHooks(t *testing.T) {
 	if len(nt.diffs) != 1 || nt.diffs[0].Added != 1 {
 		t.Fatalf("notifier called: %#v", nt.diffs)
 	}
+	if len(em.events) != 1 || em.events[0].Name != notifier.TypeFieldCreated || em.events[0].Subject != "posts.title" {
+		t.Fatalf("events emitted: %#v", em.events)
+	}
+	if fc, ok := em.events[0].Data.(notifier.FieldChange); !ok || fc.Before != nil || fc.After == nil || fc.After.DataType != "text" {
+		t.Fatalf("event data: %#v", em.events[0].Data)
+	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Fatalf("db expectations: %v", err)
 	}
@@ -75,15 +88,16 @@ func TestApplyHooksDryRun(t *testing.T) {
 	mock.ExpectQuery("SELECT .* FROM .*custom_fields").WillReturnRows(sqlmock.NewRows([]string{"db_id", "table_name", "column_name", "data_type", "store_kind", "kind", "physical_type", "driver_extras", "label_key", "widget", "widget_config", "placeholder_key", "nullable", "unique", "has_default", "default_value", "validator"}))
 
 	nt := &stubNotifier{}
+	em := &stubEmitter{}
 	disable := false
-	svc := sdk.New(sdk.ServiceConfig{Recorder: &audit.Recorder{DB: db, Dialect: ormdriver.MySQLDialect{}}, Notifier: nt, PluginEnabled: &disable})
+	svc := sdk.New(sdk.ServiceConfig{Recorder: &audit.Recorder{DB: db, Dialect: ormdriver.MySQLDialect{}}, Notifier: nt, Events: em, PluginEnabled: &disable})
 	yamlData := []byte("version: 0.4\nfields:\n  - table: posts\n    column: title\n    type: text\n")
 	_, err = svc.Apply(context.Background(), sdk.DBConfig{Driver: "sqlmock", DSN: "sqlmock_db2", TablePrefix: "gcfm_"}, yamlData, sdk.ApplyOptions{Actor: "alice", DryRun: true})
 	if err != nil {
 		t.Fatalf("apply: %v", err)
 	}
-	if len(nt.diffs) != 0 {
-		t.Fatalf("notifier called: %#v", nt.diffs)
+	if len(nt.diffs) != 0 || len(em.events) != 0 {
+		t.Fatalf("notified on dry run: %#v %#v", nt.diffs, em.events)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Fatalf("db expectations: %v", err)